@@ -0,0 +1,776 @@
+package custodes
+
+import (
+	"context"
+	"math/big"
+
+	"custodes/party"
+	"custodes/zkp"
+
+	"github.com/sachaservan/paillier"
+)
+
+// Every protocol comes in two forms. Foo runs under the context of mpc,
+// which is never done unless set with WithContext, and FooContext runs
+// under ctx. Both return the failure of the protocol, such as ctx.Err()
+// once ctx is done, after deleting the shares it created at the parties.
+// The errors are described in errors.go; MustFoo in must.go panics instead
+
+// RevealShareFP reveals a shared fixed point value with scale bits of precision
+func (mpc *MPC) RevealShareFP(share *party.Share, scale int) (*big.Float, error) {
+	return mpc.RevealShareFPContext(mpc.ctx, share, scale)
+}
+
+func (mpc *MPC) RevealShareFPContext(ctx context.Context, share *party.Share, scale int) (res *big.Float, err error) {
+	run := mpc.run(ctx)
+	defer run.finish(&err)
+	res = run.revealShareFP(share, scale)
+	run.checkMACs()
+	return res, nil
+}
+
+// RevealShare reconstructs a shared value from the first parties to answer.
+// With MACs enabled, it first checks the MACs of every value opened so far
+func (mpc *MPC) RevealShare(share *party.Share) (*big.Int, error) {
+	return mpc.RevealShareContext(mpc.ctx, share)
+}
+
+func (mpc *MPC) RevealShareContext(ctx context.Context, share *party.Share) (res *big.Int, err error) {
+	run := mpc.run(ctx)
+	defer run.finish(&err)
+	res = run.revealShare(share)
+	run.checkMACs()
+	return res, nil
+}
+
+// DeleteAllShares clears the shares stored at every online party
+func (mpc *MPC) DeleteAllShares() (int, error) {
+	return mpc.DeleteAllSharesContext(mpc.ctx)
+}
+
+func (mpc *MPC) DeleteAllSharesContext(ctx context.Context) (res int, err error) {
+	run := mpc.run(ctx)
+	defer run.finish(&err)
+	return run.deleteAllShares(), nil
+}
+
+// CopyShare returns a new share of the same value
+func (mpc *MPC) CopyShare(share *party.Share) (*party.Share, error) {
+	return mpc.CopyShareContext(mpc.ctx, share)
+}
+
+func (mpc *MPC) CopyShareContext(ctx context.Context, share *party.Share) (res *party.Share, err error) {
+	run := mpc.run(ctx)
+	defer run.finish(&err)
+	return run.copyShare(share), nil
+}
+
+// CreateShares deals shares of value to the online parties
+func (mpc *MPC) CreateShares(value *big.Int) (*party.Share, error) {
+	return mpc.CreateSharesContext(mpc.ctx, value)
+}
+
+func (mpc *MPC) CreateSharesContext(ctx context.Context, value *big.Int) (res *party.Share, err error) {
+	run := mpc.run(ctx)
+	defer run.finish(&err)
+	return run.createShares(value), nil
+}
+
+// Add returns a share of the sum of the shared values
+func (mpc *MPC) Add(share1, share2 *party.Share) (*party.Share, error) {
+	return mpc.AddContext(mpc.ctx, share1, share2)
+}
+
+func (mpc *MPC) AddContext(ctx context.Context, share1, share2 *party.Share) (res *party.Share, err error) {
+	run := mpc.run(ctx)
+	defer run.finish(&err)
+	return run.add(share1, share2), nil
+}
+
+// Sub returns a share of the difference of the shared values
+func (mpc *MPC) Sub(share1, share2 *party.Share) (*party.Share, error) {
+	return mpc.SubContext(mpc.ctx, share1, share2)
+}
+
+func (mpc *MPC) SubContext(ctx context.Context, share1, share2 *party.Share) (res *party.Share, err error) {
+	run := mpc.run(ctx)
+	defer run.finish(&err)
+	return run.sub(share1, share2), nil
+}
+
+// MultC returns a share of the shared value multiplied by c
+func (mpc *MPC) MultC(share *party.Share, c *big.Int) (*party.Share, error) {
+	return mpc.MultCContext(mpc.ctx, share, c)
+}
+
+func (mpc *MPC) MultCContext(ctx context.Context, share *party.Share, c *big.Int) (res *party.Share, err error) {
+	run := mpc.run(ctx)
+	defer run.finish(&err)
+	return run.multC(share, c), nil
+}
+
+// Mult returns a share of the product of the shared values
+func (mpc *MPC) Mult(share1, share2 *party.Share) (*party.Share, error) {
+	return mpc.MultContext(mpc.ctx, share1, share2)
+}
+
+func (mpc *MPC) MultContext(ctx context.Context, share1, share2 *party.Share) (res *party.Share, err error) {
+	run := mpc.run(ctx)
+	defer run.finish(&err)
+	return run.mult(share1, share2), nil
+}
+
+// FPNormalize returns a tuple (b, v) such that a/2^v is between 0.5 and 1
+func (mpc *MPC) FPNormalize(b *party.Share) (*party.Share, *party.Share, error) {
+	return mpc.FPNormalizeContext(mpc.ctx, b)
+}
+
+func (mpc *MPC) FPNormalizeContext(ctx context.Context, b *party.Share) (res1 *party.Share, res2 *party.Share, err error) {
+	run := mpc.run(ctx)
+	defer run.finish(&err)
+	res1, res2 = run.fpNormalize(b)
+	return res1, res2, nil
+}
+
+// FPReciprocal returns an approximation of [1/b]
+func (mpc *MPC) FPReciprocal(b *party.Share) (*party.Share, error) {
+	return mpc.FPReciprocalContext(mpc.ctx, b)
+}
+
+func (mpc *MPC) FPReciprocalContext(ctx context.Context, b *party.Share) (res *party.Share, err error) {
+	run := mpc.run(ctx)
+	defer run.finish(&err)
+	return run.fpReciprocal(b), nil
+}
+
+// FPDivision returns the approximate result of [a/b]
+func (mpc *MPC) FPDivision(a, b *party.Share) (*party.Share, error) {
+	return mpc.FPDivisionContext(mpc.ctx, a, b)
+}
+
+func (mpc *MPC) FPDivisionContext(ctx context.Context, a, b *party.Share) (res *party.Share, err error) {
+	run := mpc.run(ctx)
+	defer run.finish(&err)
+	return run.fpDivision(a, b), nil
+}
+
+// FPSqrtReciprocal returns an approximation of [1/sqrt(a)]
+func (mpc *MPC) FPSqrtReciprocal(a *party.Share) (*party.Share, error) {
+	return mpc.FPSqrtReciprocalContext(mpc.ctx, a)
+}
+
+func (mpc *MPC) FPSqrtReciprocalContext(ctx context.Context, a *party.Share) (res *party.Share, err error) {
+	run := mpc.run(ctx)
+	defer run.finish(&err)
+	return run.fpSqrtReciprocal(a), nil
+}
+
+// TruncPR returns a share of a / 2^m, where a has at most k bits,
+// rounded probabilistically to a nearby integer
+func (mpc *MPC) TruncPR(a *party.Share, k, m int) (*party.Share, error) {
+	return mpc.TruncPRContext(mpc.ctx, a, k, m)
+}
+
+func (mpc *MPC) TruncPRContext(ctx context.Context, a *party.Share, k, m int) (res *party.Share, err error) {
+	run := mpc.run(ctx)
+	defer run.finish(&err)
+	return run.truncPR(a, k, m), nil
+}
+
+// SignBit returns a share of 1 if the shared value is negative and 0 otherwise
+func (mpc *MPC) SignBit(a *party.Share) (*party.Share, error) {
+	return mpc.SignBitContext(mpc.ctx, a)
+}
+
+func (mpc *MPC) SignBitContext(ctx context.Context, a *party.Share) (res *party.Share, err error) {
+	run := mpc.run(ctx)
+	defer run.finish(&err)
+	return run.signBit(a), nil
+}
+
+// RandomBits returns a random bit vector from {0,1}^l
+func (mpc *MPC) RandomBits(m int) ([]*party.Share, error) {
+	return mpc.RandomBitsContext(mpc.ctx, m)
+}
+
+func (mpc *MPC) RandomBitsContext(ctx context.Context, m int) (res []*party.Share, err error) {
+	run := mpc.run(ctx)
+	defer run.finish(&err)
+	return run.randomBits(m), nil
+}
+
+// RandomShare returns a shared random value between 0...n*bound
+func (mpc *MPC) RandomShare(bound *big.Int) (*party.Share, error) {
+	return mpc.RandomShareContext(mpc.ctx, bound)
+}
+
+func (mpc *MPC) RandomShareContext(ctx context.Context, bound *big.Int) (res *party.Share, err error) {
+	run := mpc.run(ctx)
+	defer run.finish(&err)
+	return run.randomShare(bound), nil
+}
+
+// RandomInvertibleShare returns a random encrypted integer
+// in {1...P} and its inverse (mod P)
+func (mpc *MPC) RandomInvertibleShare() (*party.Share, *party.Share, error) {
+	return mpc.RandomInvertibleShareContext(mpc.ctx)
+}
+
+func (mpc *MPC) RandomInvertibleShareContext(ctx context.Context) (res1 *party.Share, res2 *party.Share, err error) {
+	run := mpc.run(ctx)
+	defer run.finish(&err)
+	return run.randomInvertibleShare()
+}
+
+// SolvedBits returns a random bit string from {0,1}^m and the corresponding
+// shared integer
+func (mpc *MPC) SolvedBits(m int) ([]*party.Share, *party.Share, error) {
+	return mpc.SolvedBitsContext(mpc.ctx, m)
+}
+
+func (mpc *MPC) SolvedBitsContext(ctx context.Context, m int) (res1 []*party.Share, res2 *party.Share, err error) {
+	run := mpc.run(ctx)
+	defer run.finish(&err)
+	return run.solvedBits(m)
+}
+
+// BitsExp returns 2^x where x = integer(bits)
+func (mpc *MPC) BitsExp(bits []*party.Share) (*party.Share, error) {
+	return mpc.BitsExpContext(mpc.ctx, bits)
+}
+
+func (mpc *MPC) BitsExpContext(ctx context.Context, bits []*party.Share) (res *party.Share, err error) {
+	run := mpc.run(ctx)
+	defer run.finish(&err)
+	return run.bitsExp(bits), nil
+}
+
+// BitsMult returns the bitwise sharing of a*b (note: a*b < pk.T)
+func (mpc *MPC) BitsMult(a, b []*party.Share) ([]*party.Share, error) {
+	return mpc.BitsMultContext(mpc.ctx, a, b)
+}
+
+func (mpc *MPC) BitsMultContext(ctx context.Context, a, b []*party.Share) (res []*party.Share, err error) {
+	run := mpc.run(ctx)
+	defer run.finish(&err)
+	return run.bitsMult(a, b), nil
+}
+
+// BitsToEInteger returns the integer (in Zn) representation of an encrypted binary string
+func (mpc *MPC) BitsToEInteger(bits []*party.Share) (*party.Share, error) {
+	return mpc.BitsToEIntegerContext(mpc.ctx, bits)
+}
+
+func (mpc *MPC) BitsToEIntegerContext(ctx context.Context, bits []*party.Share) (res *party.Share, err error) {
+	run := mpc.run(ctx)
+	defer run.finish(&err)
+	return run.bitsToEInteger(bits), nil
+}
+
+// BitsDec returns a bit representation of an integer in {0...T}
+func (mpc *MPC) BitsDec(a *party.Share, m int) ([]*party.Share, error) {
+	return mpc.BitsDecContext(mpc.ctx, a, m)
+}
+
+func (mpc *MPC) BitsDecContext(ctx context.Context, a *party.Share, m int) (res []*party.Share, err error) {
+	run := mpc.run(ctx)
+	defer run.finish(&err)
+	return run.bitsDec(a, m), nil
+}
+
+// FanInMULT efficiently computes [x,x^2,x^3...x^n] where n = len(elements)
+// Note: can be used as a PrefixAND when elements are binary
+func (mpc *MPC) FanInMULT(elements []*party.Share) ([]*party.Share, error) {
+	return mpc.FanInMULTContext(mpc.ctx, elements)
+}
+
+func (mpc *MPC) FanInMULTContext(ctx context.Context, elements []*party.Share) (res []*party.Share, err error) {
+	run := mpc.run(ctx)
+	defer run.finish(&err)
+	return run.fanInMULT(elements), nil
+}
+
+// BitsPrefixOR returns the prefix ORs of the shared bits
+func (mpc *MPC) BitsPrefixOR(bits []*party.Share) ([]*party.Share, error) {
+	return mpc.BitsPrefixORContext(mpc.ctx, bits)
+}
+
+func (mpc *MPC) BitsPrefixORContext(ctx context.Context, bits []*party.Share) (res []*party.Share, err error) {
+	run := mpc.run(ctx)
+	defer run.finish(&err)
+	return run.bitsPrefixOR(bits), nil
+}
+
+// BitsADD outputs the bitwise representation of a+b
+func (mpc *MPC) BitsADD(a, b []*party.Share) ([]*party.Share, error) {
+	return mpc.BitsADDContext(mpc.ctx, a, b)
+}
+
+func (mpc *MPC) BitsADDContext(ctx context.Context, a, b []*party.Share) (res []*party.Share, err error) {
+	run := mpc.run(ctx)
+	defer run.finish(&err)
+	return run.bitsADD(a, b), nil
+}
+
+// BitsLT returns [0] if a > b, [1] otherwise
+func (mpc *MPC) BitsLT(a, b []*party.Share) (*party.Share, error) {
+	return mpc.BitsLTContext(mpc.ctx, a, b)
+}
+
+func (mpc *MPC) BitsLTContext(ctx context.Context, a, b []*party.Share) (res *party.Share, err error) {
+	run := mpc.run(ctx)
+	defer run.finish(&err)
+	return run.bitsLT(a, b), nil
+}
+
+// BitsCarries returns the carry bits of adding a and b
+func (mpc *MPC) BitsCarries(a, b []*party.Share) ([]*party.Share, error) {
+	return mpc.BitsCarriesContext(mpc.ctx, a, b)
+}
+
+func (mpc *MPC) BitsCarriesContext(ctx context.Context, a, b []*party.Share) (res []*party.Share, err error) {
+	run := mpc.run(ctx)
+	defer run.finish(&err)
+	return run.bitsCarries(a, b), nil
+}
+
+// BitsBigEndian returns the n-bit (encrypted) representation of an integer a
+func (mpc *MPC) BitsBigEndian(a *big.Int, n int) ([]*party.Share, error) {
+	return mpc.BitsBigEndianContext(mpc.ctx, a, n)
+}
+
+func (mpc *MPC) BitsBigEndianContext(ctx context.Context, a *big.Int, n int) (res []*party.Share, err error) {
+	run := mpc.run(ctx)
+	defer run.finish(&err)
+	return run.bitsBigEndian(a, n), nil
+}
+
+// BitsZero returns the n-bit vector of zeros
+func (mpc *MPC) BitsZero() ([]*party.Share, error) {
+	return mpc.BitsZeroContext(mpc.ctx)
+}
+
+func (mpc *MPC) BitsZeroContext(ctx context.Context) (res []*party.Share, err error) {
+	run := mpc.run(ctx)
+	defer run.finish(&err)
+	return run.bitsZero(), nil
+}
+
+// BitsXOR computes the XOR of all the bits
+func (mpc *MPC) BitsXOR(bits []*party.Share) (*party.Share, error) {
+	return mpc.BitsXORContext(mpc.ctx, bits)
+}
+
+func (mpc *MPC) BitsXORContext(ctx context.Context, bits []*party.Share) (res *party.Share, err error) {
+	run := mpc.run(ctx)
+	defer run.finish(&err)
+	return run.bitsXOR(bits), nil
+}
+
+// BitsOR computes the OR of all the bits
+func (mpc *MPC) BitsOR(bits []*party.Share) (*party.Share, error) {
+	return mpc.BitsORContext(mpc.ctx, bits)
+}
+
+func (mpc *MPC) BitsORContext(ctx context.Context, bits []*party.Share) (res *party.Share, err error) {
+	run := mpc.run(ctx)
+	defer run.finish(&err)
+	return run.bitsOR(bits), nil
+}
+
+// BitsAND computes the AND of all the bits
+func (mpc *MPC) BitsAND(bits []*party.Share) (*party.Share, error) {
+	return mpc.BitsANDContext(mpc.ctx, bits)
+}
+
+func (mpc *MPC) BitsANDContext(ctx context.Context, bits []*party.Share) (res *party.Share, err error) {
+	run := mpc.run(ctx)
+	defer run.finish(&err)
+	return run.bitsAND(bits), nil
+}
+
+// EMult returns an encryption of the product of the encrypted values
+func (mpc *MPC) EMult(a, b *paillier.Ciphertext) (*paillier.Ciphertext, error) {
+	return mpc.EMultContext(mpc.ctx, a, b)
+}
+
+func (mpc *MPC) EMultContext(ctx context.Context, a, b *paillier.Ciphertext) (res *paillier.Ciphertext, err error) {
+	run := mpc.run(ctx)
+	defer run.finish(&err)
+	return run.eMult(a, b), nil
+}
+
+// ERandomMultShare returns a random encrypted integer and c*r
+// in {1...Pk.N}, jointly generated by all parties
+func (mpc *MPC) ERandomMultShare(c *paillier.Ciphertext) (*paillier.Ciphertext, *paillier.Ciphertext, error) {
+	return mpc.ERandomMultShareContext(mpc.ctx, c)
+}
+
+func (mpc *MPC) ERandomMultShareContext(ctx context.Context, c *paillier.Ciphertext) (res1 *paillier.Ciphertext, res2 *paillier.Ciphertext, err error) {
+	run := mpc.run(ctx)
+	defer run.finish(&err)
+	res1, res2 = run.eRandomMultShare(c)
+	return res1, res2, nil
+}
+
+// ECMultFP multiplies an encrypted fixed point value by fp
+func (mpc *MPC) ECMultFP(ct *paillier.Ciphertext, fp *big.Float) (*paillier.Ciphertext, error) {
+	return mpc.ECMultFPContext(mpc.ctx, ct, fp)
+}
+
+func (mpc *MPC) ECMultFPContext(ctx context.Context, ct *paillier.Ciphertext, fp *big.Float) (res *paillier.Ciphertext, err error) {
+	run := mpc.run(ctx)
+	defer run.finish(&err)
+	return run.eCMultFP(ct, fp), nil
+}
+
+// EFPMult returns an encryption of the product of the encrypted fixed point values
+func (mpc *MPC) EFPMult(a, b *paillier.Ciphertext) (*paillier.Ciphertext, error) {
+	return mpc.EFPMultContext(mpc.ctx, a, b)
+}
+
+func (mpc *MPC) EFPMultContext(ctx context.Context, a, b *paillier.Ciphertext) (res *paillier.Ciphertext, err error) {
+	run := mpc.run(ctx)
+	defer run.finish(&err)
+	return run.eFPMult(a, b), nil
+}
+
+// ETruncPR truncates a bitwise sharing where the last bit is
+// probabilistically rounded up or down
+func (mpc *MPC) ETruncPR(a *paillier.Ciphertext, k, m int) (*paillier.Ciphertext, error) {
+	return mpc.ETruncPRContext(mpc.ctx, a, k, m)
+}
+
+func (mpc *MPC) ETruncPRContext(ctx context.Context, a *paillier.Ciphertext, k, m int) (res *paillier.Ciphertext, err error) {
+	run := mpc.run(ctx)
+	defer run.finish(&err)
+	return run.eTruncPR(a, k, m), nil
+}
+
+// ERandom returns a random encrypted integer
+// in {1...Pk.T}, jointly generated by all parties
+func (mpc *MPC) ERandom(bound *big.Int) (*paillier.Ciphertext, error) {
+	return mpc.ERandomContext(mpc.ctx, bound)
+}
+
+func (mpc *MPC) ERandomContext(ctx context.Context, bound *big.Int) (res *paillier.Ciphertext, err error) {
+	run := mpc.run(ctx)
+	defer run.finish(&err)
+	return run.eRandom(bound), nil
+}
+
+// ERandomAndShare returns a random encrypted integer (in paillier)
+// and the corresponding values shared in Shamir, both jointly generated by all parties
+func (mpc *MPC) ERandomAndShare(bound *big.Int) (*paillier.Ciphertext, *party.Share, error) {
+	return mpc.ERandomAndShareContext(mpc.ctx, bound)
+}
+
+func (mpc *MPC) ERandomAndShareContext(ctx context.Context, bound *big.Int) (res1 *paillier.Ciphertext, res2 *party.Share, err error) {
+	run := mpc.run(ctx)
+	defer run.finish(&err)
+	res1, res2 = run.eRandomAndShare(bound)
+	return res1, res2, nil
+}
+
+// PaillierToShare converts an encryption of a value in [-2^K, 2^K) into
+// a sharing of it. The value is only opened under a statistical mask
+func (mpc *MPC) PaillierToShare(ct *paillier.Ciphertext) (*party.Share, error) {
+	return mpc.PaillierToShareContext(mpc.ctx, ct)
+}
+
+func (mpc *MPC) PaillierToShareContext(ctx context.Context, ct *paillier.Ciphertext) (res *party.Share, err error) {
+	run := mpc.run(ctx)
+	defer run.finish(&err)
+	return run.paillierToShare(ct), nil
+}
+
+// DistributedKeyGen generates a threshold Paillier key of the given size
+// among the parties, so that nobody learns the factorization of N or a key
+// share other than its own, and installs the key shares at the parties.
+// Every party must be online and the share modulus must be at least
+// KeyGenModulusBits long. It tries about (bits/2)^2/8 candidate moduli
+func (mpc *MPC) DistributedKeyGen(bits int) (*paillier.ThresholdKey, error) {
+	return mpc.DistributedKeyGenContext(mpc.ctx, bits)
+}
+
+func (mpc *MPC) DistributedKeyGenContext(ctx context.Context, bits int) (res *paillier.ThresholdKey, err error) {
+	run := mpc.run(ctx)
+	defer run.finish(&err)
+	return run.distributedKeyGen(bits), nil
+}
+
+// Refresh starts a new epoch. The parties re-randomize their shares of the
+// Paillier decryption key and the given shares, along with their MACs and
+// the MAC key, without changing the public key or any shared value, so
+// that shares stolen from different parties in different epochs do not
+// combine. Every party must be online and no other protocol may run
+// meanwhile. mpc.Tk gets the new verification keys. A key can be
+// refreshed party.MaxRefreshes times
+func (mpc *MPC) Refresh(shares ...*party.Share) error {
+	return mpc.RefreshContext(mpc.ctx, shares...)
+}
+
+func (mpc *MPC) RefreshContext(ctx context.Context, shares ...*party.Share) (err error) {
+	run := mpc.run(ctx)
+	defer run.finish(&err)
+	requireShares(shares...)
+	mpc.Tk = run.refresh(shares)
+	return nil
+}
+
+// ShareToPaillier converts a sharing of a value in [-2^K, 2^K) into an
+// encryption of it. The value is only opened under a statistical mask.
+// With MACs enabled, it checks the MACs of every value opened so far
+func (mpc *MPC) ShareToPaillier(share *party.Share) (*paillier.Ciphertext, error) {
+	return mpc.ShareToPaillierContext(mpc.ctx, share)
+}
+
+func (mpc *MPC) ShareToPaillierContext(ctx context.Context, share *party.Share) (res *paillier.Ciphertext, err error) {
+	run := mpc.run(ctx)
+	defer run.finish(&err)
+	res = run.shareToPaillier(share)
+	run.checkMACs()
+	return res, nil
+}
+
+// ShareToPaillierFP converts a sharing of a fixed point value with scale
+// bits of precision into an encryption of it with FPPrecBits of precision,
+// the precision the Paillier protocols work with
+func (mpc *MPC) ShareToPaillierFP(share *party.Share, scale int) (*paillier.Ciphertext, error) {
+	return mpc.ShareToPaillierFPContext(mpc.ctx, share, scale)
+}
+
+func (mpc *MPC) ShareToPaillierFPContext(ctx context.Context, share *party.Share, scale int) (res *paillier.Ciphertext, err error) {
+	run := mpc.run(ctx)
+	defer run.finish(&err)
+	res = run.shareToPaillierFP(share, scale)
+	run.checkMACs()
+	return res, nil
+}
+
+// ERandomInvertibleShare returns a random encrypted integer
+// in {1...Pk.T} and its inverse (mod Pk.N)
+func (mpc *MPC) ERandomInvertibleShare() (*paillier.Ciphertext, *paillier.Ciphertext, error) {
+	return mpc.ERandomInvertibleShareContext(mpc.ctx)
+}
+
+func (mpc *MPC) ERandomInvertibleShareContext(ctx context.Context) (res1 *paillier.Ciphertext, res2 *paillier.Ciphertext, err error) {
+	run := mpc.run(ctx)
+	defer run.finish(&err)
+	return run.eRandomInvertibleShare()
+}
+
+// ERandomBits returns a random bit vector from {0,1}^l
+func (mpc *MPC) ERandomBits(m int) ([]*paillier.Ciphertext, error) {
+	return mpc.ERandomBitsContext(mpc.ctx, m)
+}
+
+func (mpc *MPC) ERandomBitsContext(ctx context.Context, m int) (res []*paillier.Ciphertext, err error) {
+	run := mpc.run(ctx)
+	defer run.finish(&err)
+	return run.eRandomBits(m), nil
+}
+
+// ESolvedBits returns a random bit string from {0,1}^m and the corresponding
+// encrypted integer
+func (mpc *MPC) ESolvedBits(m int) ([]*paillier.Ciphertext, *paillier.Ciphertext, error) {
+	return mpc.ESolvedBitsContext(mpc.ctx, m)
+}
+
+func (mpc *MPC) ESolvedBitsContext(ctx context.Context, m int) (res1 []*paillier.Ciphertext, res2 *paillier.Ciphertext, err error) {
+	run := mpc.run(ctx)
+	defer run.finish(&err)
+	return run.eSolvedBits(m)
+}
+
+// EFanInMULT efficiently computes [x,x^2,x^3...x^n] where n = len(elements)
+// Note: can be used as a PrefixAND when elements are binary
+func (mpc *MPC) EFanInMULT(elements []*paillier.Ciphertext) ([]*paillier.Ciphertext, error) {
+	return mpc.EFanInMULTContext(mpc.ctx, elements)
+}
+
+func (mpc *MPC) EFanInMULTContext(ctx context.Context, elements []*paillier.Ciphertext) (res []*paillier.Ciphertext, err error) {
+	run := mpc.run(ctx)
+	defer run.finish(&err)
+	return run.eFanInMULT(elements), nil
+}
+
+// RevealInt decrypts the ciphertext with the partial decryptions
+// of the first parties to answer
+func (mpc *MPC) RevealInt(ciphertext *paillier.Ciphertext) (*big.Int, error) {
+	return mpc.RevealIntContext(mpc.ctx, ciphertext)
+}
+
+func (mpc *MPC) RevealIntContext(ctx context.Context, ciphertext *paillier.Ciphertext) (res *big.Int, err error) {
+	run := mpc.run(ctx)
+	defer run.finish(&err)
+	return run.revealInt(ciphertext), nil
+}
+
+// RevealFP decrypts a fixed point ciphertext with scale bits of precision
+func (mpc *MPC) RevealFP(ciphertext *paillier.Ciphertext, scale int) (*big.Float, error) {
+	return mpc.RevealFPContext(mpc.ctx, ciphertext, scale)
+}
+
+func (mpc *MPC) RevealFPContext(ctx context.Context, ciphertext *paillier.Ciphertext, scale int) (res *big.Float, err error) {
+	run := mpc.run(ctx)
+	defer run.finish(&err)
+	return run.revealFP(ciphertext, scale), nil
+}
+
+// EBitsXOR computes the XOR of all the bits
+func (mpc *MPC) EBitsXOR(bits []*paillier.Ciphertext) (*paillier.Ciphertext, error) {
+	return mpc.EBitsXORContext(mpc.ctx, bits)
+}
+
+func (mpc *MPC) EBitsXORContext(ctx context.Context, bits []*paillier.Ciphertext) (res *paillier.Ciphertext, err error) {
+	run := mpc.run(ctx)
+	defer run.finish(&err)
+	return run.eBitsXOR(bits), nil
+}
+
+// VerifyRanges has every online party check that cts[i] encrypts a value
+// in the fixed point interval of the dataset according to proofs[i], made
+// in the party.UploadContext of the dataset, and reports which ciphertexts
+// all of them accept. The parties take the interval from the preregistered
+// specs of the dataset, or without preregistration from the one they were
+// configured with, see MPCKeyGenParams.Interval. Run it on uploaded data
+// before computing
+func (mpc *MPC) VerifyRanges(dataset string, cts []*paillier.Ciphertext, proofs []*zkp.IntervalProof) ([]bool, error) {
+	return mpc.VerifyRangesContext(mpc.ctx, dataset, cts, proofs)
+}
+
+func (mpc *MPC) VerifyRangesContext(ctx context.Context, dataset string, cts []*paillier.Ciphertext, proofs []*zkp.IntervalProof) (res []bool, err error) {
+	run := mpc.run(ctx)
+	defer run.finish(&err)
+	return run.verifyRanges(dataset, cts, proofs), nil
+}
+
+// VerifyOneHot has every online party check that rows[i] encrypts a
+// one-hot vector in fixed point according to proofs[i], made in the
+// party.UploadContext of the dataset, and reports which rows all of them
+// accept. Run it on uploaded categorical data before computing
+func (mpc *MPC) VerifyOneHot(dataset string, rows [][]*paillier.Ciphertext, proofs []*zkp.OneHotProof) ([]bool, error) {
+	return mpc.VerifyOneHotContext(mpc.ctx, dataset, rows, proofs)
+}
+
+func (mpc *MPC) VerifyOneHotContext(ctx context.Context, dataset string, rows [][]*paillier.Ciphertext, proofs []*zkp.OneHotProof) (res []bool, err error) {
+	run := mpc.run(ctx)
+	defer run.finish(&err)
+	return run.verifyOneHot(dataset, rows, proofs), nil
+}
+
+// EnableMACs has the parties generate a secret MAC key and maintain a MAC
+// for every share created from then on, so that a party returning a wrong
+// value or resharing a wrong product is caught. The MACs of all the values
+// opened so far are checked in one batch before RevealShare and
+// RevealShareFP return, which fail with ErrMACCheck if a check fails
+func (mpc *MPC) EnableMACs() error {
+	return mpc.EnableMACsContext(mpc.ctx)
+}
+
+func (mpc *MPC) EnableMACsContext(ctx context.Context) (err error) {
+	run := mpc.run(ctx)
+	defer run.finish(&err)
+	run.enableMACs()
+	return nil
+}
+
+// Preregister has every online party record the spec of a test, signed by
+// the analyst with party.SignSpec. The parties check the signature against
+// the analyst key they are configured with, and take specs until Seal, so
+// preregister every test before uploading the dataset
+func (mpc *MPC) Preregister(signed *party.SignedSpec) error {
+	return mpc.PreregisterContext(mpc.ctx, signed)
+}
+
+func (mpc *MPC) PreregisterContext(ctx context.Context, signed *party.SignedSpec) (err error) {
+	run := mpc.run(ctx)
+	defer run.finish(&err)
+	run.preregister(signed)
+	return nil
+}
+
+// Seal has every online party stop taking specs, which must happen before
+// data is uploaded. VerifyRanges and VerifyOneHot seal the parties too
+func (mpc *MPC) Seal() error {
+	return mpc.SealContext(mpc.ctx)
+}
+
+func (mpc *MPC) SealContext(ctx context.Context) (err error) {
+	run := mpc.run(ctx)
+	defer run.finish(&err)
+	run.seal()
+	return nil
+}
+
+// Upload has every online party record the rows of a dataset, which seals
+// them. A preregistered test runs only on an uploaded dataset, whose shape
+// sets what the test may open, and the parties never decrypt the rows
+// themselves
+func (mpc *MPC) Upload(dataset string, rows [][]*paillier.Ciphertext) error {
+	return mpc.UploadContext(mpc.ctx, dataset, rows)
+}
+
+func (mpc *MPC) UploadContext(ctx context.Context, dataset string, rows [][]*paillier.Ciphertext) (err error) {
+	run := mpc.run(ctx)
+	defer run.finish(&err)
+	run.upload(dataset, rows)
+	return nil
+}
+
+// BeginTest starts the preregistered test at every online party. Until
+// EndTest, the parties open values for that test only
+func (mpc *MPC) BeginTest(spec *party.Spec) error {
+	return mpc.BeginTestContext(mpc.ctx, spec)
+}
+
+func (mpc *MPC) BeginTestContext(ctx context.Context, spec *party.Spec) (err error) {
+	run := mpc.run(ctx)
+	defer run.finish(&err)
+	run.beginTest(spec)
+	return nil
+}
+
+// EndTest closes the running test at every online party, which will not
+// run it again, and reports the outcome of the test. The parties record
+// it in the ledger of the dataset and return the entry, which holds the
+// p-value and the significance threshold after correction for the tests
+// that ran on the dataset before
+func (mpc *MPC) EndTest(spec *party.Spec, out *party.Outcome) (*party.LedgerEntry, error) {
+	return mpc.EndTestContext(mpc.ctx, spec, out)
+}
+
+func (mpc *MPC) EndTestContext(ctx context.Context, spec *party.Spec, out *party.Outcome) (res *party.LedgerEntry, err error) {
+	run := mpc.run(ctx)
+	defer run.finish(&err)
+	return run.endTest(spec, out), nil
+}
+
+// RevealStatistic opens the share of the statistic of a test, a signed
+// fixed point number with scale fractional bits, as the last step of the
+// test. Every online party signs its piece of the share and records the
+// opening with the head of mpc.Transcript, and the parties certify only a
+// statistic they saw opened this way
+func (mpc *MPC) RevealStatistic(share *party.Share, scale int) (*big.Float, error) {
+	return mpc.RevealStatisticContext(mpc.ctx, share, scale)
+}
+
+func (mpc *MPC) RevealStatisticContext(ctx context.Context, share *party.Share, scale int) (res *big.Float, err error) {
+	run := mpc.run(ctx)
+	defer run.finish(&err)
+	return run.revealStatistic(share, scale), nil
+}
+
+// Certify has every online party sign the statement of a test result with
+// its identity key and returns the certificate. It fails unless at least
+// Threshold parties sign. Use Certificate.Check to verify it offline
+func (mpc *MPC) Certify(st *party.Statement) (*Certificate, error) {
+	return mpc.CertifyContext(mpc.ctx, st)
+}
+
+func (mpc *MPC) CertifyContext(ctx context.Context, st *party.Statement) (res *Certificate, err error) {
+	run := mpc.run(ctx)
+	defer run.finish(&err)
+	return run.certify(st), nil
+}
@@ -0,0 +1,341 @@
+package custodes
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+
+	"custodes/party"
+
+	"github.com/sachaservan/paillier"
+)
+
+// Auditor checks a transcript without any key share. NewAuditor checks
+// that the output of every entry follows from its inputs, its masks and
+// the decryptions recorded before it, and that every decryption comes with
+// valid proofs. The auditor then replays the public steps of the
+// computation, the homomorphic sums and multiplications by public
+// constants, calling the methods of Auditor in place of the protocols.
+// They look the outputs up in the transcript and fail with ErrAudit if the
+// computation did not run on the values the replay arrives at. Statistics
+// returns the statistics the parties opened at the end of the computation,
+// which a report of the run must agree with.
+//
+// The masks are taken at face value: a transcript proves that the parties
+// ran the protocols correctly, not that they picked their randomness well
+type Auditor struct {
+	Tk *paillier.ThresholdKey
+	Pk *paillier.PublicKey
+
+	decrypted map[string]*big.Int              // plaintext of every decrypted ciphertext
+	entries   map[string][]*MPCTranscriptEntry // by protocol, parameters and inputs, in recorded order
+	reveals   []*MPCTranscriptEntry            // openings of statistics, in recorded order
+}
+
+// NewAuditor returns an auditor for the computation recorded in trans
+// under tk, or an error wrapping ErrTranscript or ErrAudit if the
+// transcript does not hold up. Decryptions must have been recorded with
+// mpc.Verify set, since only their proofs tie them to the key
+func NewAuditor(tk *paillier.ThresholdKey, trans *MPCTranscript) (*Auditor, error) {
+
+	err := trans.Check()
+	if err != nil {
+		return nil, err
+	}
+
+	a := &Auditor{
+		Tk:        tk,
+		Pk:        &tk.PublicKey,
+		decrypted: make(map[string]*big.Int),
+		entries:   make(map[string][]*MPCTranscriptEntry),
+	}
+
+	for i, entry := range trans.Entries {
+		err = a.check(entry)
+		if err != nil {
+			return nil, fmt.Errorf("%w: entry %d (%v): %v", ErrAudit, i, entry.Protocol, err)
+		}
+
+		switch entry.Protocol {
+		case Decrypt:
+			a.decrypted[ctKey(entry.CtIn[0])] = entry.PtOut
+		case Reveal:
+			a.reveals = append(a.reveals, entry)
+			continue
+		}
+		key := entryKey(entry.Protocol, entry.Params, entry.CtIn...)
+		a.entries[key] = append(a.entries[key], entry)
+	}
+
+	return a, nil
+}
+
+// ETruncPR returns the output of the truncation of ct recorded in the transcript
+func (a *Auditor) ETruncPR(ct *paillier.Ciphertext, k, m int) (*paillier.Ciphertext, error) {
+	entry, err := a.find(ETruncPR, []int{k, m}, ct)
+	if err != nil {
+		return nil, err
+	}
+	return entry.CtOut[0], nil
+}
+
+// ETruncPRs returns the outputs of every truncation of ct recorded in the
+// transcript, in the order they were recorded, for computations that
+// truncate the same value several times
+func (a *Auditor) ETruncPRs(ct *paillier.Ciphertext, k, m int) ([]*paillier.Ciphertext, error) {
+	entries, err := a.findAll(ETruncPR, []int{k, m}, ct)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*paillier.Ciphertext, len(entries))
+	for i, entry := range entries {
+		res[i] = entry.CtOut[0]
+	}
+	return res, nil
+}
+
+// EMult returns the output of the multiplication of x and y recorded in the transcript
+func (a *Auditor) EMult(x, y *paillier.Ciphertext) (*paillier.Ciphertext, error) {
+	entry, err := a.find(EMult, nil, x, y)
+	if err != nil {
+		return nil, err
+	}
+	return entry.CtOut[0], nil
+}
+
+// PaillierToShare checks that the transcript converted ct into a sharing
+// of a value in [-2^k, 2^k)
+func (a *Auditor) PaillierToShare(ct *paillier.Ciphertext, k int) error {
+	_, err := a.find(PaillierToShare, []int{k}, ct)
+	return err
+}
+
+// RevealInt returns the decryption of ct recorded in the transcript
+func (a *Auditor) RevealInt(ct *paillier.Ciphertext) (*big.Int, error) {
+	entry, err := a.find(Decrypt, nil, ct)
+	if err != nil {
+		return nil, err
+	}
+	return big.NewInt(0).Set(entry.PtOut), nil
+}
+
+// Statistics returns the statistics the transcript opened from shares, in
+// the order they were opened, once the signed pieces of every opening
+// check out against the identities in roster and reconstruct its value
+// mod p, the share modulus
+func (a *Auditor) Statistics(p *big.Int, roster party.Roster) ([]*big.Float, error) {
+
+	res := make([]*big.Float, len(a.reveals))
+	for i, entry := range a.reveals {
+		opening := &party.Opening{Pieces: entry.Pieces, Scale: entry.Params[0]}
+		value, err := opening.Value(p, a.Tk.Threshold, roster)
+		if err != nil {
+			return nil, fmt.Errorf("%w: opening %d: %v", ErrAudit, i, err)
+		}
+		if value.Cmp(big.NewInt(0).Mod(entry.PtOut, p)) != 0 {
+			return nil, fmt.Errorf("%w: opening %d does not match its pieces", ErrAudit, i)
+		}
+		res[i] = party.Statistic(value, p, opening.Scale)
+	}
+	return res, nil
+}
+
+// find returns an entry of the protocol with the given parameters and
+// inputs. Any of them will do, since NewAuditor checked all of them
+func (a *Auditor) find(protocol ProtocolType, params []int, cts ...*paillier.Ciphertext) (*MPCTranscriptEntry, error) {
+	entries, err := a.findAll(protocol, params, cts...)
+	if err != nil {
+		return nil, err
+	}
+	return entries[0], nil
+}
+
+// findAll returns every entry of the protocol with the given parameters and inputs
+func (a *Auditor) findAll(protocol ProtocolType, params []int, cts ...*paillier.Ciphertext) ([]*MPCTranscriptEntry, error) {
+	entries := a.entries[entryKey(protocol, params, cts...)]
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("%w: the transcript has no %v%v of the replayed inputs", ErrAudit, protocol, params)
+	}
+	return entries, nil
+}
+
+// check verifies that entry follows from its inputs and the decryptions
+// recorded before it, the way the protocol computes it
+func (a *Auditor) check(entry *MPCTranscriptEntry) error {
+
+	shape := func(params, in, aux, out int) error {
+		if len(entry.Params) != params || len(entry.CtIn) != in || len(entry.CtAux) != aux || len(entry.CtOut) != out {
+			return fmt.Errorf("malformed entry")
+		}
+		for _, cts := range [][]*paillier.Ciphertext{entry.CtIn, entry.CtAux, entry.CtOut} {
+			for _, ct := range cts {
+				if ct == nil || ct.C == nil {
+					return fmt.Errorf("malformed entry")
+				}
+			}
+		}
+		return nil
+	}
+
+	// decrypted returns the plaintext of a ciphertext the protocol opened
+	decrypted := func(ct *paillier.Ciphertext) (*big.Int, error) {
+		val, ok := a.decrypted[ctKey(ct)]
+		if !ok {
+			return nil, fmt.Errorf("masked value was not decrypted before the entry")
+		}
+		return big.NewInt(0).Set(val), nil
+	}
+
+	switch entry.Protocol {
+	case Decrypt:
+		if err := shape(0, 1, 0, 0); err != nil {
+			return err
+		}
+		return a.checkDecryption(entry)
+
+	case EMult:
+		if err := shape(0, 2, 2, 1); err != nil {
+			return err
+		}
+		x, y := entry.CtIn[0], entry.CtIn[1]
+		mask, val := entry.CtAux[0], entry.CtAux[1]
+
+		rev, err := decrypted(a.Pk.EAdd(y, mask))
+		if err != nil {
+			return err
+		}
+		res := a.Pk.ESub(a.Pk.ECMult(x, rev), val)
+		if res.C.Cmp(entry.CtOut[0].C) != 0 {
+			return fmt.Errorf("output does not match")
+		}
+
+	case ETruncPR:
+		if err := shape(2, 1, 2, 1); err != nil {
+			return err
+		}
+		k, m := entry.Params[0], entry.Params[1]
+		if m <= 0 || m > k || k >= a.Pk.N.BitLen() {
+			return fmt.Errorf("cannot truncate %d bits of a %d bit value", m, k)
+		}
+		x := entry.CtIn[0]
+		r, rnd := entry.CtAux[0], entry.CtAux[1]
+
+		big2m := big.NewInt(0).Lsh(big.NewInt(1), uint(m))
+		b := a.Pk.EAdd(a.Pk.EncryptWithR(big.NewInt(0).Lsh(big.NewInt(1), uint(k-1)), big.NewInt(1)), x)
+		mask := a.Pk.EAdd(a.Pk.ECMult(rnd, big2m), r)
+
+		c, err := decrypted(a.Pk.EAdd(b, mask))
+		if err != nil {
+			return err
+		}
+		c.Mod(c, big2m)
+
+		res := a.Pk.ESub(a.Pk.EncryptWithR(c, big.NewInt(1)), r)
+		res = a.Pk.ESub(x, res)
+		res = a.Pk.ECMult(res, big.NewInt(0).ModInverse(big2m, a.Pk.N))
+		if res.C.Cmp(entry.CtOut[0].C) != 0 {
+			return fmt.Errorf("output does not match")
+		}
+
+	case PaillierToShare:
+		if err := shape(1, 1, 1, 0); err != nil {
+			return err
+		}
+		k := entry.Params[0]
+		if k <= 0 || k >= a.Pk.N.BitLen() {
+			return fmt.Errorf("message space of %d bits does not fit the plaintext space", k)
+		}
+		big2K := big.NewInt(0).Lsh(big.NewInt(1), uint(k))
+		masked := a.Pk.EAdd(a.Pk.EAdd(entry.CtIn[0], entry.CtAux[0]), a.Pk.EncryptWithR(big2K, big.NewInt(1)))
+		if _, err := decrypted(masked); err != nil {
+			return err
+		}
+
+	case Reveal:
+		if err := shape(1, 0, 0, 0); err != nil {
+			return err
+		}
+		if entry.Params[0] < 0 || entry.PtOut == nil {
+			return fmt.Errorf("malformed entry")
+		}
+		if len(entry.Pieces) < a.Tk.Threshold {
+			return fmt.Errorf("%d pieces of the statistic, need %d", len(entry.Pieces), a.Tk.Threshold)
+		}
+
+	default:
+		return fmt.Errorf("unknown protocol")
+	}
+
+	return nil
+}
+
+// checkDecryption verifies that the entry holds valid proofs of partial
+// decryptions by Threshold distinct parties that combine into its plaintext
+func (a *Auditor) checkDecryption(entry *MPCTranscriptEntry) error {
+
+	ct := entry.CtIn[0]
+	if entry.PtOut == nil {
+		return fmt.Errorf("no plaintext")
+	}
+	if len(entry.Proofs) < a.Tk.Threshold {
+		return fmt.Errorf("%d decryption proofs, need %d; record the transcript with Verify set", len(entry.Proofs), a.Tk.Threshold)
+	}
+
+	seen := make(map[int]bool)
+	partials := make([]*paillier.PartialDecryption, len(entry.Proofs))
+	for k, proof := range entry.Proofs {
+		if proof == nil || proof.Id < 1 || proof.Id > a.Tk.TotalNumberOfDecryptionServers || seen[proof.Id] {
+			return fmt.Errorf("%w: malformed decryption proof", ErrInvalidProof)
+		}
+		seen[proof.Id] = true
+
+		if !verifyPartial(a.Tk, proof.Id-1, ct, proof) {
+			return &PartyError{Party: proof.Id - 1, Err: ErrInvalidProof}
+		}
+		partials[k] = &proof.PartialDecryption
+	}
+
+	val, err := a.Tk.CombinePartialDecryptions(partials)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrDecryption, err)
+	}
+	if val.Cmp(entry.PtOut) != 0 {
+		return fmt.Errorf("plaintext does not match the partial decryptions")
+	}
+
+	return nil
+}
+
+// CommitDataset returns a hash of the encrypted dataset, which a test
+// report or certificate carries to name the data the test ran on
+func CommitDataset(data [][]*paillier.Ciphertext) []byte {
+
+	h := sha256.New()
+	h.Write([]byte("custodes dataset"))
+	binary.Write(h, binary.BigEndian, int64(len(data)))
+	for _, row := range data {
+		binary.Write(h, binary.BigEndian, int64(len(row)))
+		for _, ct := range row {
+			b := ct.C.Bytes()
+			binary.Write(h, binary.BigEndian, int64(len(b)))
+			h.Write(b)
+		}
+	}
+
+	return h.Sum(nil)
+}
+
+// ctKey identifies a ciphertext in the lookup tables of the auditor
+func ctKey(ct *paillier.Ciphertext) string {
+	return ct.C.Text(16)
+}
+
+// entryKey identifies the invocation of a protocol on the given inputs
+func entryKey(protocol ProtocolType, params []int, cts ...*paillier.Ciphertext) string {
+	key := fmt.Sprint(int(protocol), params)
+	for _, ct := range cts {
+		key += ":" + ctKey(ct)
+	}
+	return key
+}
@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"math"
 	"math/big"
-	"sync"
 
 	"custodes/party"
 )
@@ -23,26 +22,26 @@ const (
 	BooleanXOR BooleanFunction = iota
 )
 
-// RandomBits returns a random bit vector from {0,1}^l
-func (mpc *MPC) RandomBits(m int) []*party.Share {
+func (mpc *MPC) randomBits(m int) []*party.Share {
 
+	require(m > 0, "cannot generate %d bits", m)
 	bits := make([]*party.Share, m)
 	twoInv := big.NewInt(0).ModInverse(big.NewInt(2), mpc.P)
-	one := mpc.CreateShares(big.NewInt(1))
+	one := mpc.createShares(big.NewInt(1))
 
 	var c *big.Int
 	for i := 0; i < m; i++ {
 
 		for {
-			a := mpc.RandomShare(mpc.P)
-			a2 := mpc.Mult(a, a)
-			c = mpc.RevealShare(a2)
+			a := mpc.randomShare(mpc.P)
+			a2 := mpc.mult(a, a)
+			c = mpc.revealShare(a2)
 			if c.Cmp(big0) != 0 {
 				c.ModSqrt(c, mpc.P)
 				c.ModInverse(c, mpc.P)
-				b := mpc.MultC(a, c)
-				b = mpc.Add(b, one)
-				bits[i] = mpc.MultC(b, twoInv)
+				b := mpc.multC(a, c)
+				b = mpc.add(b, one)
+				bits[i] = mpc.multC(b, twoInv)
 				break
 			}
 		}
@@ -51,70 +50,65 @@ func (mpc *MPC) RandomBits(m int) []*party.Share {
 	return bits
 }
 
-// RandomShare returns a shared random value between 0...n*bound
-func (mpc *MPC) RandomShare(bound *big.Int) *party.Share {
+func (mpc *MPC) randomShare(bound *big.Int) *party.Share {
 
-	id := party.NewShareID()
+	r := mpc.newShare(true, func(t party.Transport, id int) error {
+		_, err := t.CreateRandomShare(mpc.ctx, bound, id)
+		return err
+	})
 
-	var r *party.Share
-	for i := 0; i < len(mpc.Parties); i++ {
-		r = mpc.Parties[i].CreateRandomShare(bound, id)
-	}
+	mpc.recordRounds(1)
+	mpc.recordShareMessages(mpc.peerMessages())
 
 	return r
 }
 
-// RandomInvertibleShare returns a random encrypted integer
-// in {1...P} and its inverse (mod P)
-func (mpc *MPC) RandomInvertibleShare() (*party.Share, *party.Share, error) {
+func (mpc *MPC) randomInvertibleShare() (*party.Share, *party.Share, error) {
 
-	a := mpc.RandomShare(mpc.P)
-	b := mpc.RandomShare(mpc.P)
-	m := mpc.Mult(a, b)
-	c := mpc.RevealShare(m)
+	a := mpc.randomShare(mpc.P)
+	b := mpc.randomShare(mpc.P)
+	m := mpc.mult(a, b)
+	c := mpc.revealShare(m)
 
 	if c.Int64() == 0 {
 		return nil, nil, errors.New("abort")
 	}
 
 	cInv := big.NewInt(0).ModInverse(c, mpc.P)
-	aInv := mpc.MultC(b, cInv)
+	aInv := mpc.multC(b, cInv)
 
 	return a, aInv, nil
 }
 
-// SolvedBits returns a random bit string from {0,1}^m and the corresponding
-func (mpc *MPC) SolvedBits(m int) ([]*party.Share, *party.Share, error) {
+func (mpc *MPC) solvedBits(m int) ([]*party.Share, *party.Share, error) {
 
-	bits := mpc.RandomBits(m)
+	bits := mpc.randomBits(m)
 
 	// convert bits to an encrypted integer
-	val := mpc.BitsToEInteger(bits)
+	val := mpc.bitsToEInteger(bits)
 
 	return bits, val, nil
 }
 
-// BitsExp returns 2^x where x = integer(bits)
-func (mpc *MPC) BitsExp(bits []*party.Share) *party.Share {
+func (mpc *MPC) bitsExp(bits []*party.Share) *party.Share {
 
 	base := big.NewInt(2)
-	one := mpc.CreateShares(big.NewInt(1))
-	res := mpc.CreateShares(big.NewInt(1))
+	one := mpc.createShares(big.NewInt(1))
+	res := mpc.createShares(big.NewInt(1))
 
 	for i := 0; i < len(bits); i++ {
 
-		pow := mpc.MultC(bits[i], base)
-		t1 := mpc.Mult(res, pow)
-		t2 := mpc.Mult(mpc.Sub(one, bits[i]), one)
-		t2 = mpc.Mult(t2, res)
-		res = mpc.Add(t1, t2)
+		pow := mpc.multC(bits[i], base)
+		t1 := mpc.mult(res, pow)
+		t2 := mpc.mult(mpc.sub(one, bits[i]), one)
+		t2 = mpc.mult(t2, res)
+		res = mpc.add(t1, t2)
 		base = base.Exp(base, big.NewInt(2), mpc.P)
 	}
 	return res
 }
 
-//BitsMult returns the bitwise sharing of a*b (note: a*b < pk.T)
-func (mpc *MPC) BitsMult(a, b []*party.Share) []*party.Share {
+func (mpc *MPC) bitsMult(a, b []*party.Share) []*party.Share {
 
 	length := len(a) + 1
 	l2 := int(math.Floor(float64(length) / 2.0))
@@ -122,7 +116,7 @@ func (mpc *MPC) BitsMult(a, b []*party.Share) []*party.Share {
 	resBits := make([]*party.Share, length)
 	partialSum := make([]*party.Share, length)
 
-	zero := mpc.CreateShares(big.NewInt(0))
+	zero := mpc.createShares(big.NewInt(0))
 	for i := 0; i < length; i++ {
 		partialSum[i] = zero
 		resBits[i] = zero
@@ -130,14 +124,14 @@ func (mpc *MPC) BitsMult(a, b []*party.Share) []*party.Share {
 
 	for i := l2; i >= 0; i-- {
 		for k := l2; k >= 0; k-- {
-			c := mpc.Mult(a[i], b[k])
+			c := mpc.mult(a[i], b[k])
 			partialSum[i+k] = c
 		}
 
 		if i == l2 {
 			resBits = partialSum
 		} else {
-			resBits = mpc.BitsADD(resBits, partialSum)
+			resBits = mpc.bitsADD(resBits, partialSum)
 			resBits = resBits[0:mpc.K]
 		}
 	}
@@ -146,53 +140,52 @@ func (mpc *MPC) BitsMult(a, b []*party.Share) []*party.Share {
 
 }
 
-//BitsToEInteger returns the integer (in Zn) representation of an encrypted binary string
-func (mpc *MPC) BitsToEInteger(bits []*party.Share) *party.Share {
+func (mpc *MPC) bitsToEInteger(bits []*party.Share) *party.Share {
 
-	acc := mpc.CreateShares(big.NewInt(0))
+	acc := mpc.createShares(big.NewInt(0))
 	base := big.NewInt(2)
 	for i := len(bits) - 1; i >= 0; i-- {
-		res := mpc.MultC(acc, base)
-		acc = mpc.Add(res, bits[i])
+		res := mpc.multC(acc, base)
+		acc = mpc.add(res, bits[i])
 	}
 
 	return acc
 }
 
-//BitsDec returns a bit representation of an integer in {0...T}
-func (mpc *MPC) BitsDec(a *party.Share, m int) []*party.Share {
+func (mpc *MPC) bitsDec(a *party.Share, m int) []*party.Share {
+
+	mpc = mpc.scope("BitsDec")
 
 	// get solved bits
-	solvedBits, d, err := mpc.SolvedBits(m)
+	solvedBits, d, err := mpc.solvedBits(m)
 	for err != nil {
-		solvedBits, d, err = mpc.SolvedBits(m)
+		solvedBits, d, err = mpc.solvedBits(m)
 	}
 
 	bound := big.NewInt(0).Exp(big2, big.NewInt(int64(mpc.S+mpc.K-m)), nil)
-	r := mpc.RandomShare(bound)
-	q := mpc.MultC(r, big.NewInt(0).Exp(big2, big.NewInt(int64(m)), nil))
-	r = mpc.Add(q, d)
+	r := mpc.randomShare(bound)
+	q := mpc.multC(r, big.NewInt(0).Exp(big2, big.NewInt(int64(m)), nil))
+	r = mpc.add(q, d)
 
 	max := big.NewInt(0).Exp(big2, big.NewInt(int64(mpc.K+mpc.S)), nil)
 	max.Add(max, big.NewInt(0).Exp(big2, big.NewInt(int64(mpc.K)), nil))
 
 	// compute 2^(k + s + v) + 2^k + a - r where d is the integer returned from solvedbits
-	maxShare := mpc.CreateShares(max)
-	rev := mpc.RevealShare(mpc.Sub(mpc.Add(maxShare, a), d))
+	maxShare := mpc.createShares(max)
+	rev := mpc.revealShare(mpc.sub(mpc.add(maxShare, a), d))
 
 	// only keep the m least significant bits
 	rev.Mod(rev, big.NewInt(0).Exp(big2, big.NewInt(int64(m)), nil))
 
-	revBits := mpc.BitsBigEndian(rev, m+1)
-	sumBits := mpc.BitsADD(revBits, solvedBits)
+	revBits := mpc.bitsBigEndian(rev, m+1)
+	sumBits := mpc.bitsADD(revBits, solvedBits)
 
 	return sumBits[0:m]
 }
 
-// FanInMULT efficiently computes [x,x^2,x^3...x^n] where n = len(elements)
-// Note: can be used as a PrefixAND when elements are binary
-func (mpc *MPC) FanInMULT(elements []*party.Share) []*party.Share {
+func (mpc *MPC) fanInMULT(elements []*party.Share) []*party.Share {
 
+	require(len(elements) > 0, "no elements to multiply")
 	n := len(elements)
 	res := make([]*party.Share, n)
 	res[0] = elements[0]
@@ -204,122 +197,80 @@ func (mpc *MPC) FanInMULT(elements []*party.Share) []*party.Share {
 	shares := make([]*party.Share, n)
 	sharesInv := make([]*party.Share, n)
 
-	var wg sync.WaitGroup
-	for i := 0; i < n; i++ {
-		wg.Add(1)
-
-		go func(i int) {
-			defer wg.Done()
-			var err error
-			shares[i], sharesInv[i], err = mpc.RandomInvertibleShare()
-			for err != nil {
-				shares[i], sharesInv[i], err = mpc.RandomInvertibleShare()
-			}
-		}(i)
-	}
-
-	wg.Wait()
+	mpc.parallel(n, func(mpc *MPC, i int) {
+		var err error
+		shares[i], sharesInv[i], err = mpc.randomInvertibleShare()
+		for err != nil {
+			shares[i], sharesInv[i], err = mpc.randomInvertibleShare()
+		}
+	})
 
 	d := make([]*party.Share, n)
 	d[0] = shares[0]
-	for i := 1; i < n; i++ {
-		wg.Add(1)
-		go func(i int) {
-			defer wg.Done()
-			d[i] = mpc.Mult(shares[i], sharesInv[i-1])
-		}(i)
-
-	}
-	wg.Wait()
+	mpc.parallel(n-1, func(mpc *MPC, k int) {
+		i := k + 1
+		d[i] = mpc.mult(shares[i], sharesInv[i-1])
+	})
 
 	c := make([]*big.Int, n)
-	for i := 0; i < n; i++ {
-		wg.Add(1)
-		go func(i int) {
-			defer wg.Done()
-			q := mpc.Mult(d[i], elements[i])
-			c[i] = mpc.RevealShare(q)
-		}(i)
-	}
-	wg.Wait()
+	mpc.parallel(n, func(mpc *MPC, i int) {
+		q := mpc.mult(d[i], elements[i])
+		c[i] = mpc.revealShare(q)
+	})
 
 	acc := c[0]
 	for i := 1; i < n; i++ {
 		acc.Mul(acc, c[i])
-		res[i] = mpc.MultC(sharesInv[i], acc)
+		res[i] = mpc.multC(sharesInv[i], acc)
 	}
 
 	return res
 }
 
-func (mpc *MPC) BitsPrefixOR(bits []*party.Share) []*party.Share {
+func (mpc *MPC) bitsPrefixOR(bits []*party.Share) []*party.Share {
+
+	mpc = mpc.scope("BitsPrefixOR")
 
 	degree := len(bits)
 
 	// find the nearest square to len(bits)
 	lambda := int(math.Ceil(math.Sqrt(float64(len(bits)))))
-	zero := mpc.CreateShares(big.NewInt(0))
+	zero := mpc.createShares(big.NewInt(0))
 
 	diff := lambda*lambda - len(bits)
 	for i := 0; i < diff; i++ {
 		bits = append(bits, zero)
 	}
 
-	var wg sync.WaitGroup
 	// Compute Row wise OR of elements in A
 	rowOr := make([]*party.Share, lambda)
-	for i := 0; i < lambda; i++ {
-		wg.Add(1)
+	mpc.parallel(lambda, func(mpc *MPC, i int) {
 		row := make([]*party.Share, lambda)
 		for j := 0; j < lambda; j++ {
 			row[j] = bits[i*lambda+j]
 		}
-
-		go func(i int, row []*party.Share) {
-			defer wg.Done()
-
-			res := mpc.BitsOR(row)
-			rowOr[i] = res
-
-		}(i, row)
-	}
-
-	wg.Wait()
+		rowOr[i] = mpc.bitsOR(row)
+	})
 
 	// Compute ORs of Xis
 	rowRes := make([]*party.Share, lambda)
 	rowRes[0] = rowOr[0]
-
-	wg.Add(lambda - 1)
-	for n := 1; n < lambda; n++ {
-
-		row := make([]*party.Share, n+1)
-		for i := 0; i <= n; i++ {
-			row[i] = rowOr[i]
-		}
-
-		go func(n int, row []*party.Share) {
-			defer wg.Done()
-
-			res := mpc.BitsOR(row)
-			rowRes[n] = res
-
-		}(n, row)
-	}
-
-	wg.Wait()
+	mpc.parallel(lambda-1, func(mpc *MPC, k int) {
+		n := k + 1
+		rowRes[n] = mpc.bitsOR(rowOr[0 : n+1])
+	})
 
 	f := make([]*party.Share, lambda)
 	f[0] = rowOr[0]
 	for i := 1; i < lambda; i++ {
-		f[i] = mpc.Sub(rowRes[i], rowRes[i-1])
+		f[i] = mpc.sub(rowRes[i], rowRes[i-1])
 	}
 
 	g := make([]*party.Share, lambda)
 	for j := 0; j < lambda; j++ {
 		sum := zero
 		for i := 0; i < lambda; i++ {
-			sum = mpc.Add(sum, mpc.Mult(bits[i*lambda+j], f[i]))
+			sum = mpc.add(sum, mpc.mult(bits[i*lambda+j], f[i]))
 		}
 		g[j] = sum
 	}
@@ -328,28 +279,14 @@ func (mpc *MPC) BitsPrefixOR(bits []*party.Share) []*party.Share {
 	b := make([]*party.Share, lambda)
 	b[0] = g[0]
 
-	wg.Add(lambda - 1)
-	for n := 1; n < lambda; n++ {
-
-		row := make([]*party.Share, n+1)
-		for i := 0; i <= n; i++ {
-			row[i] = g[i]
-		}
-
-		go func(n int, row []*party.Share) {
-			defer wg.Done()
-
-			res := mpc.BitsOR(row)
-			b[n] = res
-
-		}(n, row)
-	}
-
-	wg.Wait()
+	mpc.parallel(lambda-1, func(mpc *MPC, k int) {
+		n := k + 1
+		b[n] = mpc.bitsOR(g[0 : n+1])
+	})
 
 	s := make([]*party.Share, lambda)
 	for i := 0; i < lambda; i++ {
-		s[i] = mpc.Sub(rowRes[i], f[i])
+		s[i] = mpc.sub(rowRes[i], f[i])
 	}
 
 	result := make([]*party.Share, lambda*lambda)
@@ -360,8 +297,8 @@ func (mpc *MPC) BitsPrefixOR(bits []*party.Share) []*party.Share {
 				break
 			}
 
-			sum := mpc.Mult(b[j], f[i])
-			sum = mpc.Add(sum, s[i])
+			sum := mpc.mult(b[j], f[i])
+			sum = mpc.add(sum, s[i])
 
 			res := sum
 
@@ -372,36 +309,19 @@ func (mpc *MPC) BitsPrefixOR(bits []*party.Share) []*party.Share {
 	return result[0:degree]
 }
 
-func (mpc *MPC) BitsPrefixSPK(bits []*spk) []*spk {
+func (mpc *MPC) bitsPrefixSPK(bits []*spk) []*spk {
 
 	degree := len(bits)
 
-	var wg sync.WaitGroup
-	wg.Add(degree)
-
 	res := make([]*spk, degree)
-	for i := 0; i < degree; i++ {
-		go func(i int) {
-			defer wg.Done()
-
-			row := make([]*spk, i+1)
-			for k := 0; k <= i; k++ {
-				row[k] = bits[k]
-			}
-
-			spk := mpc.BitsSPK(row)
-			res[i] = spk
-
-		}(i)
-	}
-
-	wg.Wait()
+	mpc.parallel(degree, func(mpc *MPC, i int) {
+		res[i] = mpc.bitsSPK(bits[0 : i+1])
+	})
 
 	return res
 }
 
-// BitsADD outputs the bitwise representation of a+b
-func (mpc *MPC) BitsADD(a, b []*party.Share) []*party.Share {
+func (mpc *MPC) bitsADD(a, b []*party.Share) []*party.Share {
 
 	if len(a) < len(b) {
 		a = mpc.makeEqualLength(a, b)
@@ -410,25 +330,26 @@ func (mpc *MPC) BitsADD(a, b []*party.Share) []*party.Share {
 	}
 
 	degree := len(a)
-	carries := mpc.BitsCarries(a, b)
+	carries := mpc.bitsCarries(a, b)
 
 	sum := make([]*party.Share, degree+1)
-	lsb := mpc.Add(a[0], b[0])
-	lsb = mpc.Sub(lsb, mpc.MultC(carries[0], big.NewInt(2)))
+	lsb := mpc.add(a[0], b[0])
+	lsb = mpc.sub(lsb, mpc.multC(carries[0], big.NewInt(2)))
 	sum[0] = lsb
 	sum[degree] = carries[degree-1]
 
 	for i := 1; i < degree; i++ {
-		sum[i] = mpc.Add(a[i], b[i])
-		sum[i] = mpc.Add(sum[i], carries[i-1])
-		sum[i] = mpc.Sub(sum[i], mpc.MultC(carries[i], big.NewInt(2)))
+		sum[i] = mpc.add(a[i], b[i])
+		sum[i] = mpc.add(sum[i], carries[i-1])
+		sum[i] = mpc.sub(sum[i], mpc.multC(carries[i], big.NewInt(2)))
 	}
 
 	return sum
 }
 
-// BitsLT returns [0] if a > b, [1] otherwise
-func (mpc *MPC) BitsLT(a, b []*party.Share) *party.Share {
+func (mpc *MPC) bitsLT(a, b []*party.Share) *party.Share {
+
+	mpc = mpc.scope("BitsLT")
 
 	if len(a) < len(b) {
 		a = mpc.makeEqualLength(a, b)
@@ -439,53 +360,37 @@ func (mpc *MPC) BitsLT(a, b []*party.Share) *party.Share {
 	degree := len(a) // len(a) = len(b) now
 	e := make([]*party.Share, degree)
 
-	var wg sync.WaitGroup
-
-	for i := 0; i < degree; i++ {
-		wg.Add(1)
-		go func(i int) {
-			defer wg.Done()
-			d := mpc.Sub(a[i], b[i])
-			d2 := mpc.Mult(d, d)
-			e[degree-i-1] = d2
-		}(i)
-	}
-
-	wg.Wait()
+	mpc.parallel(degree, func(mpc *MPC, i int) {
+		d := mpc.sub(a[i], b[i])
+		d2 := mpc.mult(d, d)
+		e[degree-i-1] = d2
+	})
 
-	f := mpc.BitsPrefixOR(e)
+	f := mpc.bitsPrefixOR(e)
 
 	g := make([]*party.Share, degree)
 	g[0] = f[0]
 	for i := degree - 1; i > 0; i-- {
-		g[i] = mpc.Sub(f[i], f[i-1])
+		g[i] = mpc.sub(f[i], f[i-1])
 	}
 
-	wg.Wait()
-
 	h := make([]*party.Share, degree)
-	for i := 0; i < degree; i++ {
-		wg.Add(1)
-		go func(i int) {
-			defer wg.Done()
-			h[i] = mpc.Mult(g[degree-i-1], b[i])
-		}(i)
-	}
+	mpc.parallel(degree, func(mpc *MPC, i int) {
+		h[i] = mpc.mult(g[degree-i-1], b[i])
+	})
 
-	wg.Wait()
-
-	res := mpc.CreateShares(big.NewInt(0))
+	res := mpc.createShares(big.NewInt(0))
 
 	for i := 0; i < degree; i++ {
-		res = mpc.Add(res, h[i])
+		res = mpc.add(res, h[i])
 	}
 
 	return res
 }
 
-func (mpc *MPC) BitsCarries(a, b []*party.Share) []*party.Share {
+func (mpc *MPC) bitsCarries(a, b []*party.Share) []*party.Share {
 
-	one := mpc.CreateShares(big.NewInt(1))
+	one := mpc.createShares(big.NewInt(1))
 	degree := len(a) // len(a) = len(b) now
 
 	s := make([]*party.Share, degree)
@@ -494,23 +399,23 @@ func (mpc *MPC) BitsCarries(a, b []*party.Share) []*party.Share {
 	spks := make([]*spk, degree)
 
 	for i := 0; i < degree; i++ {
-		s[i] = mpc.Mult(a[i], b[i])
+		s[i] = mpc.mult(a[i], b[i])
 	}
 
 	for i := 0; i < degree; i++ {
 		// compute propagate bit
-		d := mpc.Add(a[i], b[i])
-		q := mpc.MultC(s[i], big.NewInt(2))
-		p[i] = mpc.Sub(d, q)
+		d := mpc.add(a[i], b[i])
+		q := mpc.multC(s[i], big.NewInt(2))
+		p[i] = mpc.sub(d, q)
 
 		// compute kill bit
-		d = mpc.Add(s[i], p[i])
-		k[i] = mpc.Sub(one, d)
+		d = mpc.add(s[i], p[i])
+		k[i] = mpc.sub(one, d)
 
 		spks[i] = &spk{s: s[i], p: p[i], k: k[i]}
 	}
 
-	f := mpc.BitsPrefixSPK(spks)
+	f := mpc.bitsPrefixSPK(spks)
 
 	res := make([]*party.Share, degree)
 
@@ -521,48 +426,41 @@ func (mpc *MPC) BitsCarries(a, b []*party.Share) []*party.Share {
 	return res
 }
 
-func (mpc *MPC) BitsSPK(tups []*spk) *spk {
+func (mpc *MPC) bitsSPK(tups []*spk) *spk {
 
 	//fmt.Println("[DEBUG]:  bitsSPK()")
 
 	size := len(tups)
 
-	b := mpc.CopyShare(tups[0].p)
+	b := mpc.copyShare(tups[0].p)
 	for i := 1; i < size; i++ {
-		b = mpc.Mult(b, tups[i].p) // equiv to AND operation
+		b = mpc.mult(b, tups[i].p) // equiv to AND operation
 	}
 
 	allPs := make([]*party.Share, size)
 	for i := 0; i < size; i++ {
-		allPs[i] = mpc.CopyShare(tups[size-i-1].p)
+		allPs[i] = mpc.copyShare(tups[size-i-1].p)
 	}
 
-	preAnd := mpc.ReverseBits(mpc.FanInMULT(allPs))
+	preAnd := mpc.ReverseBits(mpc.fanInMULT(allPs))
 
 	carries := make([]*party.Share, size)
 	carries[size-1] = tups[size-1].k
 
-	var wg sync.WaitGroup
-	wg.Add(size - 1)
-	for i := 0; i < size-1; i++ {
-		go func(i int) {
-			defer wg.Done()
-			carries[i] = mpc.Mult(tups[i].k, preAnd[i+1]) // equiv to AND operation
-		}(i)
-	}
-
-	wg.Wait()
+	mpc.parallel(size-1, func(mpc *MPC, i int) {
+		carries[i] = mpc.mult(tups[i].k, preAnd[i+1]) // equiv to AND operation
+	})
 
-	zero := mpc.CreateShares(big.NewInt(0))
-	one := mpc.CreateShares(big.NewInt(1))
+	zero := mpc.createShares(big.NewInt(0))
+	one := mpc.createShares(big.NewInt(1))
 
 	sum := zero
 	for i := 0; i < size; i++ {
-		sum = mpc.Add(sum, carries[i])
+		sum = mpc.add(sum, carries[i])
 	}
 
-	diff := mpc.Add(b, sum)
-	a := mpc.Sub(one, diff)
+	diff := mpc.add(b, sum)
+	a := mpc.sub(one, diff)
 
 	// cleanup
 	return &spk{s: a, p: b, k: sum}
@@ -579,18 +477,17 @@ func (mpc *MPC) ReverseBits(bits []*party.Share) []*party.Share {
 	return bitsR
 }
 
-// BitsBigEndian returns the n-bit (encrypted) representation of an integer a
-func (mpc *MPC) BitsBigEndian(a *big.Int, n int) []*party.Share {
+func (mpc *MPC) bitsBigEndian(a *big.Int, n int) []*party.Share {
 
 	s := fmt.Sprintf("%b", a)
 	bits := make([]*party.Share, len(s))
 	k := 0
 	for i := len(s) - 1; i >= 0; i-- {
-		bits[k] = mpc.CreateShares(big.NewInt(int64(s[i] - '0')))
+		bits[k] = mpc.createShares(big.NewInt(int64(s[i] - '0')))
 		k++
 	}
 
-	zero := mpc.CreateShares(big.NewInt(0))
+	zero := mpc.createShares(big.NewInt(0))
 	for i := n - len(s) - 1; i >= 0; i-- {
 		bits = append(bits, zero)
 	}
@@ -598,12 +495,11 @@ func (mpc *MPC) BitsBigEndian(a *big.Int, n int) []*party.Share {
 	return bits
 }
 
-// BitsZero returns the n-bit vector of zeros
-func (mpc *MPC) BitsZero() []*party.Share {
+func (mpc *MPC) bitsZero() []*party.Share {
 
 	n := mpc.K
 	bits := make([]*party.Share, n)
-	zero := mpc.CreateShares(big.NewInt(0))
+	zero := mpc.createShares(big.NewInt(0))
 
 	for i := 0; i < n; i++ {
 		bits[i] = zero
@@ -616,9 +512,9 @@ func (mpc *MPC) symmetricBooleanFunction(bits []*party.Share, f BooleanFunction)
 
 	n := len(bits)
 
-	sum := mpc.CreateShares(big.NewInt(1))
+	sum := mpc.createShares(big.NewInt(1))
 	for i := 0; i < n; i++ {
-		s := mpc.Add(sum, bits[i])
+		s := mpc.add(sum, bits[i])
 		sum = s
 	}
 
@@ -627,7 +523,7 @@ func (mpc *MPC) symmetricBooleanFunction(bits []*party.Share, f BooleanFunction)
 		a[i] = sum
 	}
 
-	mul := mpc.FanInMULT(a)
+	mul := mpc.fanInMULT(a)
 
 	var poly []*big.Int
 	if f == BooleanOR {
@@ -636,40 +532,37 @@ func (mpc *MPC) symmetricBooleanFunction(bits []*party.Share, f BooleanFunction)
 		poly = funcXORInterpolation(n, mpc.P)
 	}
 
-	res := mpc.CreateShares(poly[n])
+	res := mpc.createShares(poly[n])
 	for i := 1; i <= n; i++ {
-		c := mpc.MultC(mul[i-1], poly[n-i])
-		res = mpc.Add(res, c)
+		c := mpc.multC(mul[i-1], poly[n-i])
+		res = mpc.add(res, c)
 	}
 
 	return res
 }
 
-// BitsXOR computes the XOR of all the bits
-func (mpc *MPC) BitsXOR(bits []*party.Share) *party.Share {
+func (mpc *MPC) bitsXOR(bits []*party.Share) *party.Share {
 	return mpc.symmetricBooleanFunction(bits, BooleanXOR)
 }
 
-// BitsOR computes the OR of all the bits
-func (mpc *MPC) BitsOR(bits []*party.Share) *party.Share {
+func (mpc *MPC) bitsOR(bits []*party.Share) *party.Share {
 	return mpc.symmetricBooleanFunction(bits, BooleanOR)
 }
 
-// BitsAND computes the AND of all the bits
-func (mpc *MPC) BitsAND(bits []*party.Share) *party.Share {
+func (mpc *MPC) bitsAND(bits []*party.Share) *party.Share {
 
 	degree := len(bits)
 
 	res := bits[0]
 	for i := 1; i < degree; i++ {
-		res = mpc.Mult(res, bits[i])
+		res = mpc.mult(res, bits[i])
 	}
 
 	return res
 }
 
 func (mpc *MPC) makeEqualLength(a, b []*party.Share) []*party.Share {
-	zero := mpc.CreateShares(big.NewInt(0))
+	zero := mpc.createShares(big.NewInt(0))
 	delta := len(b) - len(a)
 	zeroArray := make([]*party.Share, delta)
 	for i := 0; i < delta; i++ {
@@ -0,0 +1,190 @@
+package custodes
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"sort"
+	"sync"
+
+	"custodes/party"
+)
+
+// Certificate is the portable record of the result of a test: a
+// statement of the result and the signatures of the parties on its
+// canonical encoding, by party id. It is checked offline with the
+// certificates of the parties' identities alone
+type Certificate struct {
+	Statement  *party.Statement
+	Signatures map[int][]byte
+}
+
+// revealStatistic opens the share of the statistic of a test, a signed
+// fixed point number with scale fractional bits. Every online party signs
+// its piece of the share, and the parties record the opening, with the
+// head of the transcript if mpc keeps one, before they sign a statement
+// of the statistic. The transcript records the opening as a Reveal
+func (mpc *MPC) revealStatistic(share *party.Share, scale int) *big.Float {
+
+	requireShares(share)
+	require(scale >= 0, "negative scale %d", scale)
+	mpc = mpc.scope("RevealStatistic")
+
+	var online []int
+	var pieces []*party.Piece
+	err := mpc.retry(func() error {
+		got := make([]*party.Piece, len(mpc.Parties))
+		err := mpc.each(false, func(i int, t party.Transport) error {
+			piece, err := t.RevealPiece(mpc.ctx, share)
+			got[i] = piece
+			return err
+		})
+		online = mpc.Online()
+		pieces = make([]*party.Piece, len(online))
+		for k, i := range online {
+			pieces[k] = got[i]
+		}
+		return err
+	})
+	if err != nil {
+		fail(err)
+	}
+	mpc.recordRounds(1)
+
+	if len(pieces) < mpc.Threshold {
+		fail(errTooFewParties)
+	}
+	ids := make([]int, len(pieces))
+	values := make([]*big.Int, len(pieces))
+	for k, piece := range pieces {
+		i := online[k]
+		if piece == nil || piece.Party != i || piece.Share != share.ID || piece.Value == nil {
+			fail(&PartyError{Party: i, Err: fmt.Errorf("%w: malformed piece of share %d", ErrParameterMismatch, share.ID)})
+		}
+		ids[k], values[k] = piece.Party, piece.Value
+	}
+	if !mpc.onPolynomial(ids, values) {
+		fail(fmt.Errorf("%w: the pieces of the statistic do not agree", ErrInvalidShare))
+	}
+
+	coeffs := mpc.lagrange(ids[:mpc.Threshold])
+	terms := make([]*big.Int, mpc.Threshold)
+	for k := range terms {
+		terms[k] = big.NewInt(0).Mul(values[k], coeffs[k])
+	}
+	value := mpc.ReconstructShare(terms)
+	mpc.macs.record(share.ID, value)
+	mpc.checkMACs()
+
+	// the head the parties record commits to the opening itself
+	mpc.record(&MPCTranscriptEntry{Protocol: Reveal, Params: []int{scale}, PtOut: value, Pieces: pieces})
+
+	opening := &party.Opening{Pieces: pieces, Scale: scale}
+	if mpc.Transcript != nil {
+		opening.Head = mpc.Transcript.Head()
+	}
+	mpc.broadcast(func(i int, t party.Transport) error {
+		return t.RecordStatistic(mpc.ctx, opening)
+	})
+
+	return party.Statistic(value, mpc.P, scale)
+}
+
+// certify has every online party sign the statement
+func (mpc *MPC) certify(st *party.Statement) *Certificate {
+
+	require(st != nil, "nil statement")
+	require(mpc.Roster != nil, "no roster to check the signatures against")
+	mpc = mpc.scope("Certify")
+
+	var mu sync.Mutex
+	sigs := make(map[int][]byte)
+	err := mpc.retry(func() error {
+		return mpc.each(false, func(i int, t party.Transport) error {
+			sig, err := t.SignStatement(mpc.ctx, st)
+			if err == nil {
+				mu.Lock()
+				sigs[i] = sig
+				mu.Unlock()
+			}
+			return err
+		})
+	})
+	if err != nil {
+		fail(err)
+	}
+	mpc.recordRounds(1)
+
+	// parties that signed and were then taken offline still vouch for the result
+	cert := &Certificate{Statement: st, Signatures: sigs}
+	if len(cert.Signers(mpc.Roster)) < mpc.Threshold {
+		fail(errTooFewParties)
+	}
+
+	return cert
+}
+
+// Signers returns the ids of the parties whose signature on the statement
+// verifies under their certificate in roster, in increasing order
+func (cert *Certificate) Signers(roster party.Roster) []int {
+
+	if cert.Statement == nil {
+		return nil
+	}
+	msg := cert.Statement.Encode()
+
+	var ids []int
+	for id, sig := range cert.Signatures {
+		// the coordinator is in the roster but does not vouch for results
+		if id == party.Coordinator {
+			continue
+		}
+		key, err := roster.PublicKey(id)
+		if err == nil && ed25519.Verify(key, msg, sig) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+
+	return ids
+}
+
+// Check returns an error wrapping ErrCertificate unless at least need
+// parties in roster signed the statement
+func (cert *Certificate) Check(roster party.Roster, need int) error {
+	signers := cert.Signers(roster)
+	if len(signers) < need {
+		return fmt.Errorf("%w: %d valid signatures, need %d", ErrCertificate, len(signers), need)
+	}
+	return nil
+}
+
+// WriteFile writes the certificate to filename as JSON
+func (cert *Certificate) WriteFile(filename string) error {
+	data, err := json.MarshalIndent(cert, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filename, data, 0644)
+}
+
+// ReadCertificate reads a certificate written by WriteFile. Check it
+// before trusting its statement
+func ReadCertificate(filename string) (*Certificate, error) {
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	cert := &Certificate{}
+	err = json.Unmarshal(data, cert)
+	if err != nil {
+		return nil, err
+	}
+
+	return cert, nil
+}
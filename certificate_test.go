@@ -0,0 +1,65 @@
+package custodes
+
+import (
+	"custodes/party"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func TestCertifyOpenedStatistic(t *testing.T) {
+
+	mpc := newTestMPC(t)
+	mpc.Transcript = NewMPCTranscript()
+
+	// -3.5 with 4 fractional bits
+	share := mpc.MustCreateShares(new(big.Int).Sub(mpc.P, big.NewInt(56)))
+	stat, err := mpc.RevealStatistic(share, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.Cmp(big.NewFloat(-3.5)) != 0 {
+		t.Fatalf("opened %v, want -3.5", stat)
+	}
+
+	// the transcript records the opening for auditors
+	auditor, err := NewAuditor(mpc.Tk, mpc.Transcript)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats, err := auditor.Statistics(mpc.P, mpc.Roster)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 1 || stats[0].Cmp(stat) != 0 {
+		t.Fatalf("transcript opened %v, want [%v]", stats, stat)
+	}
+
+	statement := func(stat string, head []byte) *party.Statement {
+		return &party.Statement{
+			Test:           "T-Test",
+			KeyFingerprint: party.KeyFingerprint(mpc.Pk),
+			Statistic:      stat,
+			TranscriptHash: head,
+		}
+	}
+
+	cert, err := mpc.Certify(statement(stat.Text('g', -1), mpc.Transcript.Head()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cert.Check(mpc.Roster, len(mpc.Parties)); err != nil {
+		t.Fatal(err)
+	}
+
+	// the parties sign neither a statistic nor a transcript they did not see
+	for _, st := range []*party.Statement{
+		statement("3.5", mpc.Transcript.Head()),
+		statement(stat.Text('g', -1), []byte("another transcript")),
+	} {
+		_, err := mpc.Certify(st)
+		if !errors.Is(err, ErrParameterMismatch) {
+			t.Fatalf("statistic %s with transcript %x: got %v, want ErrParameterMismatch", st.Statistic, st.TranscriptHash, err)
+		}
+	}
+}
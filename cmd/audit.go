@@ -0,0 +1,344 @@
+package main
+
+import (
+	"bytes"
+	"custodes"
+	"errors"
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/sachaservan/paillier"
+)
+
+// TestSpec is the public description of a test run: which test ran on
+// which encrypted dataset. With the public parameters and the transcript
+// it is everything an auditor needs
+type TestSpec struct {
+	Test        string // T-Test, Pearson or Chi-Squared
+	NumRows     int
+	NumCols     int
+	DatasetHash []byte // custodes.CommitDataset of the encrypted dataset
+}
+
+// runVerify audits a test run from its public parameters, its encrypted
+// dataset, its specification and its transcript, and prints whether it
+// accepts the run. No key share is involved
+func runVerify(args []string) {
+
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	publicFile := fs.String("public", "", "path to the public parameters, public.json or the file saved with the report.")
+	datasetFile := fs.String("dataset", "", "path to the encrypted dataset saved with the report.")
+	specFile := fs.String("spec", "", "path to the test specification saved with the report.")
+	transcriptFile := fs.String("transcript", "", "path to the transcript saved with the report.")
+	reportFile := fs.String("report", "", "optional path to the report, which gets the verdict and the audit runtime.")
+	fs.Parse(args)
+
+	start := time.Now()
+	stat, err := audit(*publicFile, *datasetFile, *specFile, *transcriptFile, *reportFile)
+	auditTime := time.Now().Sub(start)
+
+	if *reportFile != "" {
+		r := &TestReport{}
+		rerr := readJSONFile(*reportFile, r)
+		if rerr == nil {
+			r.Audit = "accept"
+			if err != nil {
+				r.Audit = "reject"
+			}
+			r.AuditRuntime = auditTime.Seconds()
+			rerr = replaceJSONFile(*reportFile, r, 0644)
+		}
+		if rerr != nil {
+			fmt.Println(rerr)
+		}
+	}
+
+	fmt.Println("************************************************")
+	if err != nil {
+		fmt.Println("Audit:                       REJECT")
+		fmt.Println("Reason:                      " + err.Error())
+	} else {
+		fmt.Println("Audit:                       ACCEPT")
+		fmt.Println("Statistic:                   " + stat.Text('g', -1))
+	}
+	fmt.Printf("Audit runtime (s):           %f\n", auditTime.Seconds())
+	fmt.Println("************************************************")
+
+	if err != nil {
+		os.Exit(1)
+	}
+}
+
+// audit returns the statistic the parties opened if the transcript shows
+// that the test in the specification ran on the committed dataset and
+// opened the statistic the report states, and why not otherwise
+func audit(publicFile, datasetFile, specFile, transcriptFile, reportFile string) (*big.Float, error) {
+
+	pub := &PublicParamsFile{}
+	err := readJSONFile(publicFile, pub)
+	if err != nil {
+		return nil, err
+	}
+
+	ds := &EncryptedDataset{}
+	err = readJSONFile(datasetFile, ds)
+	if err != nil {
+		return nil, err
+	}
+
+	spec := &TestSpec{}
+	err = readJSONFile(specFile, spec)
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.Equal(custodes.CommitDataset(ds.Data), spec.DatasetHash) {
+		return nil, errors.New("the dataset does not match the commitment in the specification")
+	}
+	if ds.NumRows != spec.NumRows || ds.NumCols != spec.NumCols {
+		return nil, errors.New("the dataset does not have the dimensions in the specification")
+	}
+
+	trans, err := custodes.ReadTranscript(transcriptFile)
+	if err != nil {
+		return nil, err
+	}
+
+	// the report must name this transcript
+	var r *TestReport
+	if reportFile != "" {
+		r = &TestReport{}
+		err = readJSONFile(reportFile, r)
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(r.TranscriptHash, trans.Head()) {
+			return nil, errors.New("the transcript is not the one the report commits to")
+		}
+	}
+
+	auditor, err := custodes.NewAuditor(pub.Tk, trans)
+	if err != nil {
+		return nil, err
+	}
+
+	switch spec.Test {
+	case "T-Test":
+		err = auditTTest(auditor, pub.Params, ds)
+	case "Pearson":
+		err = auditPearson(auditor, pub.Params, ds)
+	case "Chi-Squared":
+		err = auditChiSq(auditor, pub.Params, ds)
+	default:
+		err = fmt.Errorf("unknown test %q", spec.Test)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// every test opens its statistic once, at the end
+	stats, err := auditor.Statistics(pub.P, pub.Roster)
+	if err != nil {
+		return nil, err
+	}
+	if len(stats) != 1 {
+		return nil, fmt.Errorf("the transcript opens %d statistics, a test opens one", len(stats))
+	}
+	if r != nil {
+		if r.Value == nil {
+			return nil, errors.New("the report states no statistic")
+		}
+		// the report holds the statistic at the precision of a float64
+		got, _ := stats[0].Float64()
+		want, _ := r.Value.Float64()
+		if got != want {
+			return nil, fmt.Errorf("the report states statistic %v, the parties opened %v", r.Value, stats[0])
+		}
+	}
+
+	return stats[0], nil
+}
+
+// auditTTest replays the public steps of TTestSimulation up to the
+// conversion of the numerator and denominator to shares
+func auditTTest(a *custodes.Auditor, params *custodes.MPCKeyGenParams, ds *EncryptedDataset) error {
+
+	if len(ds.Data) != 2 || len(ds.Data[0]) != ds.NumRows || len(ds.Data[1]) != ds.NumRows || ds.NumRows < 2 {
+		return errors.New("malformed dataset")
+	}
+	k, fp := params.MessageBits, params.FPPrecisionBits
+	eX, eY := ds.Data[0], ds.Data[1]
+
+	invNumRowsEncoded := a.Pk.EncodeFixedPoint(big.NewFloat(1.0/float64(ds.NumRows)), fp)
+
+	meanX, err := a.ETruncPR(a.Pk.ECMult(a.Pk.EAdd(eX...), invNumRowsEncoded), 2*k, fp)
+	if err != nil {
+		return err
+	}
+	meanY, err := a.ETruncPR(a.Pk.ECMult(a.Pk.EAdd(eY...), invNumRowsEncoded), 2*k, fp)
+	if err != nil {
+		return err
+	}
+
+	sumdX := make([]*paillier.Ciphertext, ds.NumRows)
+	sumdY := make([]*paillier.Ciphertext, ds.NumRows)
+	for i := 0; i < ds.NumRows; i++ {
+		dx := a.Pk.ESub(eX[i], meanX)
+		dy := a.Pk.ESub(eY[i], meanY)
+		if sumdX[i], err = a.EMult(dx, dx); err != nil {
+			return err
+		}
+		if sumdY[i], err = a.EMult(dy, dy); err != nil {
+			return err
+		}
+	}
+
+	dX, err := a.ETruncPR(a.Pk.EAdd(sumdX...), 2*k, fp)
+	if err != nil {
+		return err
+	}
+	dY, err := a.ETruncPR(a.Pk.EAdd(sumdY...), 2*k, fp)
+	if err != nil {
+		return err
+	}
+
+	numerator := a.Pk.ESub(meanX, meanY)
+
+	df1 := a.Pk.EncodeFixedPoint(big.NewFloat(1.0/float64(ds.NumRows-1)), fp)
+	df2 := a.Pk.EncodeFixedPoint(big.NewFloat(1.0/float64(ds.NumRows)), fp)
+	denominator, err := a.ETruncPR(a.Pk.ECMult(a.Pk.EAdd(dX, dY), df1), 2*k, fp)
+	if err != nil {
+		return err
+	}
+	denominator, err = a.ETruncPR(a.Pk.ECMult(denominator, df2), 2*k, fp)
+	if err != nil {
+		return err
+	}
+
+	if err = a.PaillierToShare(numerator, k); err != nil {
+		return err
+	}
+	return a.PaillierToShare(denominator, k)
+}
+
+// auditPearson replays the public steps of PearsonsTestSimulation up to
+// the conversion of the numerator and denominator to shares
+func auditPearson(a *custodes.Auditor, params *custodes.MPCKeyGenParams, ds *EncryptedDataset) error {
+
+	if len(ds.Data) != 2 || len(ds.Data[0]) != ds.NumRows || len(ds.Data[1]) != ds.NumRows || ds.NumRows < 2 {
+		return errors.New("malformed dataset")
+	}
+	k, fp := params.MessageBits, params.FPPrecisionBits
+	eX, eY := ds.Data[0], ds.Data[1]
+
+	invNumRowsEncoded := a.Pk.EncodeFixedPoint(big.NewFloat(1.0/float64(ds.NumRows)), fp)
+
+	meanX, err := a.ETruncPR(a.Pk.ECMult(a.Pk.EAdd(eX...), invNumRowsEncoded), k, fp)
+	if err != nil {
+		return err
+	}
+	meanY, err := a.ETruncPR(a.Pk.ECMult(a.Pk.EAdd(eY...), invNumRowsEncoded), k, fp)
+	if err != nil {
+		return err
+	}
+
+	prodsXY := make([]*paillier.Ciphertext, ds.NumRows)
+	devsX2 := make([]*paillier.Ciphertext, ds.NumRows)
+	devsY2 := make([]*paillier.Ciphertext, ds.NumRows)
+	for i := 0; i < ds.NumRows; i++ {
+		devX := a.Pk.ESub(eX[i], meanX)
+		devY := a.Pk.ESub(eY[i], meanY)
+		if devsX2[i], err = a.EMult(devX, devX); err != nil {
+			return err
+		}
+		if devsY2[i], err = a.EMult(devY, devY); err != nil {
+			return err
+		}
+		if prodsXY[i], err = a.EMult(devX, devY); err != nil {
+			return err
+		}
+	}
+
+	numerator := a.Pk.EAdd(prodsXY...)
+
+	sumDevX2, err := a.ETruncPR(a.Pk.EAdd(devsX2...), 2*k, fp)
+	if err != nil {
+		return err
+	}
+	sumDevY2, err := a.ETruncPR(a.Pk.EAdd(devsY2...), 2*k, fp)
+	if err != nil {
+		return err
+	}
+
+	denominator, err := a.EMult(sumDevX2, sumDevY2)
+	if err != nil {
+		return err
+	}
+	denominator, err = a.ETruncPR(denominator, 2*k, fp)
+	if err != nil {
+		return err
+	}
+
+	if err = a.PaillierToShare(numerator, k); err != nil {
+		return err
+	}
+	return a.PaillierToShare(denominator, k)
+}
+
+// auditChiSq replays the public steps of ChiSquaredTestSimulation up to
+// the conversion of the residuals and expected values to shares
+func auditChiSq(a *custodes.Auditor, params *custodes.MPCKeyGenParams, ds *EncryptedDataset) error {
+
+	if len(ds.Data) != ds.NumRows || ds.NumRows == 0 || ds.NumCols == 0 {
+		return errors.New("malformed dataset")
+	}
+	for _, row := range ds.Data {
+		if len(row) != ds.NumCols {
+			return errors.New("malformed dataset")
+		}
+	}
+	k, fp := params.MessageBits, params.FPPrecisionBits
+
+	h := make([]*paillier.Ciphertext, ds.NumCols)
+	for i := 0; i < ds.NumCols; i++ {
+		categorySum := a.Pk.EncryptWithR(big.NewInt(0), big.NewInt(1))
+		for j := 0; j < ds.NumRows; j++ {
+			categorySum = a.Pk.EAdd(categorySum, ds.Data[j][i])
+		}
+		h[i] = categorySum
+	}
+
+	sumTotal := a.Pk.EAdd(h...)
+	w := a.Pk.EncodeFixedPoint(big.NewFloat(1.0/float64(ds.NumCols)), fp)
+
+	// every category truncates the same value, one category after the
+	// other, so the truncations are matched to the categories by the
+	// order they were recorded in
+	expectedValues, err := a.ETruncPRs(a.Pk.ECMult(sumTotal, w), k, fp)
+	if err != nil {
+		return err
+	}
+	if len(expectedValues) != ds.NumCols {
+		return fmt.Errorf("the transcript has %d expected values for %d categories", len(expectedValues), ds.NumCols)
+	}
+
+	for i := 0; i < ds.NumCols; i++ {
+		res := a.Pk.ESub(h[i], expectedValues[i])
+		residual, err := a.EMult(res, res)
+		if err != nil {
+			return fmt.Errorf("the residual of category %d is not in the transcript: %w", i, err)
+		}
+
+		if err = a.PaillierToShare(residual, k); err != nil {
+			return err
+		}
+		if err = a.PaillierToShare(expectedValues[i], k); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"custodes"
+	"errors"
+	"math"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var (
+	testMPCOnce sync.Once
+	testMPC     *custodes.MPC
+	testMPCErr  error
+)
+
+// sharedMPC returns the system every test runs on, as the command line
+// tool runs all its tests on one
+func sharedMPC(t *testing.T) *custodes.MPC {
+	t.Helper()
+
+	testMPCOnce.Do(func() {
+		testMPC, testMPCErr = custodes.NewMPCKeyGen(&custodes.MPCKeyGenParams{
+			NumParties:      3,
+			Threshold:       2,
+			KeyBits:         512,
+			MessageBits:     100,
+			SecurityBits:    40,
+			FPPrecisionBits: 30,
+			Verify:          true})
+	})
+	if testMPCErr != nil {
+		t.Fatal(testMPCErr)
+	}
+
+	return testMPC
+}
+
+// saveRun runs test on the example dataset with a transcript and saves it
+// the way -save does, in a temporary directory that becomes the working
+// directory. It returns the report and the file it was saved to
+func saveRun(t *testing.T, test string) (string, *TestReport) {
+	t.Helper()
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	mpc := sharedMPC(t)
+	mpc.Transcript = custodes.NewMPCTranscript()
+	defer func() { mpc.Transcript = nil }()
+
+	bounds, err := parseInterval("-1000,1000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var encD *EncryptedDataset
+	if test == "Chi-Squared" {
+		encD, _, err = encryptCategoricalDataset(mpc, "example categorical", "", true, false)
+	} else {
+		encD, _, err = encryptDataset(mpc, "example", "", true, false, bounds)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var res *TestResult
+	switch test {
+	case "T-Test":
+		res = TTestSimulation(mpc, encD, false)
+	case "Pearson":
+		res = PearsonsTestSimulation(mpc, encD, false)
+	case "Chi-Squared":
+		res = ChiSquaredTestSimulation(mpc, encD, false)
+	}
+
+	r := &TestReport{
+		Test:       test,
+		Value:      res.Value,
+		NumParties: len(mpc.Parties),
+		NumRows:    encD.NumRows,
+		NumCols:    encD.NumCols,
+	}
+	writeTestResultsToFile(mpc, r, encD, res.Transcript)
+
+	return reportBase(r) + ".json", r
+}
+
+// verify audits a saved run as custodes verify does
+func verify(reportFile string, r *TestReport) (*big.Float, error) {
+	return audit(r.PublicParams, r.Dataset, r.Spec, r.Transcript, reportFile)
+}
+
+// forge saves a copy of the transcript of a run that tamper changed, with
+// a valid hash chain, as the coordinator that records it could, and a
+// copy of the report that commits to it
+func forge(t *testing.T, r *TestReport, tamper func(entries []*custodes.MPCTranscriptEntry)) (string, *TestReport) {
+	t.Helper()
+
+	trans, err := custodes.ReadTranscript(r.Transcript)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tamper(trans.Entries)
+
+	forged := custodes.NewMPCTranscript()
+	for _, entry := range trans.Entries {
+		forged.Append(entry)
+	}
+
+	fr := *r
+	fr.Transcript = "forged_transcript.json"
+	fr.TranscriptHash = forged.Head()
+	if err := forged.WriteFile(fr.Transcript); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeJSONFile("forged.json", &fr, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return "forged.json", &fr
+}
+
+// protocolEntries returns the indices of the entries of the protocol
+func protocolEntries(entries []*custodes.MPCTranscriptEntry, protocol custodes.ProtocolType) []int {
+	var ids []int
+	for i, entry := range entries {
+		if entry.Protocol == protocol {
+			ids = append(ids, i)
+		}
+	}
+	return ids
+}
+
+// checkAudit has the audit accept an honest run of test, which finds
+// about the statistic want, and reject it once a product in its
+// transcript or the statistic in its report changed
+func checkAudit(t *testing.T, test string, want float64) *TestReport {
+	t.Helper()
+
+	reportFile, r := saveRun(t, test)
+
+	stat, err := verify(reportFile, r)
+	if err != nil {
+		t.Fatalf("honest %s run rejected: %v", test, err)
+	}
+	got, _ := stat.Float64()
+	if reported, _ := r.Value.Float64(); got != reported {
+		t.Fatalf("audit of %s found statistic %v, the run reported %v", test, stat, r.Value)
+	}
+	if math.Abs(got-want) > 0.01 {
+		t.Fatalf("%s statistic of the example is %v, want %v", test, got, want)
+	}
+
+	pub := &PublicParamsFile{}
+	if err := readJSONFile(r.PublicParams, pub); err != nil {
+		t.Fatal(err)
+	}
+
+	// the first product now encrypts one more than it should
+	forgedFile, forged := forge(t, r, func(entries []*custodes.MPCTranscriptEntry) {
+		entry := entries[protocolEntries(entries, custodes.EMult)[0]]
+		entry.CtOut[0] = pub.Tk.EAdd(entry.CtOut[0], pub.Tk.Encrypt(big.NewInt(1)))
+	})
+	_, err = verify(forgedFile, forged)
+	if !errors.Is(err, custodes.ErrAudit) {
+		t.Fatalf("%s run with a modified product: got %v, want ErrAudit", test, err)
+	}
+
+	misreported := *r
+	misreported.Value = new(big.Float).Add(r.Value, big.NewFloat(0.5))
+	if err := writeJSONFile("misreported.json", &misreported, 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err = verify("misreported.json", &misreported)
+	if err == nil || !strings.Contains(err.Error(), "the report states statistic") {
+		t.Fatalf("%s run with a misreported statistic: got %v, want a mismatch", test, err)
+	}
+
+	return r
+}
+
+func TestAuditTTest(t *testing.T) {
+	checkAudit(t, "T-Test", 1.99)
+}
+
+func TestAuditPearson(t *testing.T) {
+	checkAudit(t, "Pearson", 0.29)
+}
+
+func TestAuditChiSquared(t *testing.T) {
+
+	r := checkAudit(t, "Chi-Squared", 2.0/3)
+
+	// the expected values of the two categories trade places, each along
+	// with the decryption of its masked value right before it, so that
+	// every entry still checks out on its own
+	forgedFile, forged := forge(t, r, func(entries []*custodes.MPCTranscriptEntry) {
+		ids := protocolEntries(entries, custodes.ETruncPR)
+		if len(ids) != 2 {
+			t.Fatalf("Chi-Squared on two categories recorded %d truncations, want 2", len(ids))
+		}
+		i, j := ids[0], ids[1]
+		if entries[i-1].Protocol != custodes.Decrypt || entries[j-1].Protocol != custodes.Decrypt {
+			t.Fatal("a truncation does not follow the decryption of its masked value")
+		}
+		entries[i-1], entries[j-1] = entries[j-1], entries[i-1]
+		entries[i], entries[j] = entries[j], entries[i]
+	})
+	_, err := verify(forgedFile, forged)
+	if err == nil || !strings.Contains(err.Error(), "the residual of category 0") {
+		t.Fatalf("Chi-Squared run with reordered truncations: got %v, want a missing residual", err)
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"custodes"
+	"custodes/party"
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
@@ -10,37 +11,18 @@ import (
 	"math/big"
 	"os"
 	"strconv"
-	"sync"
 	"time"
 
 	"github.com/sachaservan/paillier"
 )
 
-type ProtocolType int
-
-// simulates records stored on the blockchain
-const (
-	ETruncPR ProtocolType = iota
-	EMult
-	Decrypt
-)
-
-type MPCTranscriptEntry struct {
-	Protocol ProtocolType           // type of protocol
-	CtIn     []*paillier.Ciphertext // inputs to the protocol
-	CtOut    []*paillier.Ciphertext // ciphertext output
-	PtOut    *big.Int               // plaintext output
-}
-
-type MPCTranscript struct {
-	Entries []*MPCTranscriptEntry
-	Next    int
-}
-
 type EncryptedDataset struct {
-	Data    [][]*paillier.Ciphertext
-	NumRows int
-	NumCols int
+	Data     [][]*paillier.Ciphertext
+	NumRows  int
+	NumCols  int
+	Rejected int // rows dropped because the parties rejected their proofs
+
+	rows [][]*paillier.Ciphertext // the ciphertexts row by row, as uploaded
 }
 
 type TestResult struct {
@@ -51,7 +33,8 @@ type TestResult struct {
 	SignExtractionRuntime time.Duration
 	DivRuntime            time.Duration
 	NumSharesCreated      int
-	Transcript            *MPCTranscript // transcript of all MPC protocols
+	Comm                  *custodes.CommStats     // communication per protocol
+	Transcript            *custodes.MPCTranscript // transcript of all MPC protocols, nil unless recorded
 }
 
 type TestReport struct {
@@ -62,23 +45,40 @@ type TestReport struct {
 	ComputeRuntime        float64
 	SignExtractionRuntime float64
 	DivRuntime            float64
-	AuditRuntime          float64
+	AuditRuntime          float64 // time custodes verify took to audit the run
+	Audit                 string  `json:",omitempty"` // accept or reject, set by custodes verify
 	NumParties            int
 	NumRows               int
 	NumCols               int
+	RejectedRows          int
 	NumSharesCreated      int
+	Rounds                int64
+	Messages              int64
+	Bytes                 int64
+	Protocols             *custodes.CommStats // breakdown per protocol
+	Transcript            string              `json:",omitempty"` // file holding the transcript
+	TranscriptHash        []byte              `json:",omitempty"` // head of the transcript's hash chain
+	PublicParams          string              `json:",omitempty"` // file holding the public parameters
+	Dataset               string              `json:",omitempty"` // file holding the encrypted dataset
+	Spec                  string              `json:",omitempty"` // file holding the test specification
+	Certificate           string              `json:",omitempty"` // file holding the certificate signed by the parties
+	Ledger                *party.LedgerEntry  `json:",omitempty"` // entry of the preregistered test in the parties' ledger
 	RunId                 int
 }
 
 func runChiSqBechmarks(
 	mpc *custodes.MPC,
 	filename string,
+	dataset string,
 	numParties int,
 	latency time.Duration,
 	debug bool,
 	writeToFile bool,
 	runId int,
-	example bool) {
+	example bool,
+	proofs bool,
+	certify bool,
+	spec *party.Spec) {
 
 	//**************************************************************************************
 	//**************************************************************************************
@@ -90,28 +90,50 @@ func runChiSqBechmarks(
 	fmt.Println("Running Chi^2 Test...")
 	fmt.Println("------------------------------------------------")
 
-	encD, setupTime := encryptCategoricalDataset(mpc, filename, example)
-	testResult := ChiSquaredTestSimulation(mpc, encD, debug)
+	encD, setupTime, err := encryptCategoricalDataset(mpc, dataset, filename, example, proofs)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	testResult, entry, err := runPreregistered(mpc, spec, encD, func() *TestResult {
+		return ChiSquaredTestSimulation(mpc, encD, debug)
+	})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	r := &TestReport{
+		Test:             "Chi-Squared",
+		Value:            testResult.Value,
+		TotalRuntime:     testResult.TotalRuntime.Seconds(),
+		SetupTime:        setupTime.Seconds(),
+		ComputeRuntime:   testResult.ComputeRuntime.Seconds(),
+		DivRuntime:       testResult.DivRuntime.Seconds(),
+		NumParties:       numParties,
+		NumRows:          encD.NumRows,
+		NumCols:          encD.NumCols,
+		RejectedRows:     encD.Rejected,
+		NumSharesCreated: testResult.NumSharesCreated,
+		Rounds:           testResult.Comm.Rounds,
+		Messages:         testResult.Comm.Messages,
+		Bytes:            testResult.Comm.Bytes,
+		Protocols:        testResult.Comm,
+		Ledger:           entry,
+		RunId:            runId,
+	}
+
+	if certify {
+		certifyResult(mpc, r, encD, testResult.Transcript, entry)
+	}
 
 	if writeToFile {
-		r := &TestReport{
-			Test:             "Chi-Squared",
-			Value:            testResult.Value,
-			TotalRuntime:     testResult.TotalRuntime.Seconds(),
-			SetupTime:        setupTime.Seconds(),
-			ComputeRuntime:   testResult.ComputeRuntime.Seconds(),
-			DivRuntime:       testResult.DivRuntime.Seconds(),
-			NumParties:       numParties,
-			NumRows:          encD.NumRows,
-			NumCols:          encD.NumCols,
-			NumSharesCreated: testResult.NumSharesCreated,
-			RunId:            runId,
-		}
-		writeTestResultsToFile(r)
+		writeTestResultsToFile(mpc, r, encD, testResult.Transcript)
 	} else {
 		fmt.Println("************************************************")
 		fmt.Println("Chi^2 statistic:             " + testResult.Value.String())
 		fmt.Printf("Dataset size:                %d\n", encD.NumRows)
+		fmt.Printf("Rows rejected:               %d\n", encD.Rejected)
 		fmt.Printf("Number of categories:        %d\n", encD.NumCols)
 		fmt.Printf("Number of parties:           %d\n", numParties)
 		fmt.Printf("Threshold:                   %d\n", mpc.Threshold)
@@ -121,6 +143,17 @@ func runChiSqBechmarks(
 		fmt.Printf("---Computation runtime (s):  %f\n", testResult.ComputeRuntime.Seconds())
 		fmt.Printf("---Division runtime (s):     %f\n", testResult.DivRuntime.Seconds())
 		fmt.Printf("Network latency (s):         %f\n", latency.Seconds())
+		fmt.Printf("Communication rounds:        %d\n", testResult.Comm.Rounds)
+		fmt.Printf("Messages sent:               %d\n", testResult.Comm.Messages)
+		fmt.Printf("Bytes sent:                  %d\n", testResult.Comm.Bytes)
+		if entry != nil {
+			fmt.Printf("p-value:                     %g\n", entry.PValue)
+			fmt.Printf("Threshold after correction:  %g (test %d on the dataset)\n", entry.Threshold, entry.Index)
+			fmt.Printf("Significant:                 %t\n", entry.Rejected)
+		}
+		if r.Certificate != "" {
+			fmt.Println("Certificate:                 " + r.Certificate)
+		}
 		fmt.Println("************************************************")
 	}
 }
@@ -128,12 +161,17 @@ func runChiSqBechmarks(
 func runTTestBechmarks(
 	mpc *custodes.MPC,
 	filename string,
+	dataset string,
 	numParties int,
 	latency time.Duration,
 	debug bool,
 	writeToFile bool,
 	runId int,
-	example bool) {
+	example bool,
+	proofs bool,
+	bounds interval,
+	certify bool,
+	spec *party.Spec) {
 
 	//**************************************************************************************
 	//**************************************************************************************
@@ -145,34 +183,56 @@ func runTTestBechmarks(
 	fmt.Println("Running T-Test...")
 	fmt.Println("------------------------------------------------")
 
-	encD, setupTime := encryptDataset(mpc, filename, example)
+	encD, setupTime, err := encryptDataset(mpc, dataset, filename, example, proofs, bounds)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
 
 	if debug {
 		fmt.Println("[DEBUG] Finished encrypting dataset")
 	}
 
-	testResult := TTestSimulation(mpc, encD, debug)
+	testResult, entry, err := runPreregistered(mpc, spec, encD, func() *TestResult {
+		return TTestSimulation(mpc, encD, debug)
+	})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	r := &TestReport{
+		Test:                  "T-Test",
+		Value:                 testResult.Value,
+		TotalRuntime:          testResult.TotalRuntime.Seconds(),
+		SetupTime:             setupTime.Seconds(),
+		ComputeRuntime:        testResult.ComputeRuntime.Seconds(),
+		SignExtractionRuntime: testResult.SignExtractionRuntime.Seconds(),
+		DivRuntime:            testResult.DivRuntime.Seconds(),
+		NumParties:            numParties,
+		NumRows:               encD.NumRows,
+		NumCols:               encD.NumCols,
+		RejectedRows:          encD.Rejected,
+		NumSharesCreated:      testResult.NumSharesCreated,
+		Rounds:                testResult.Comm.Rounds,
+		Messages:              testResult.Comm.Messages,
+		Bytes:                 testResult.Comm.Bytes,
+		Protocols:             testResult.Comm,
+		Ledger:                entry,
+		RunId:                 runId,
+	}
+
+	if certify {
+		certifyResult(mpc, r, encD, testResult.Transcript, entry)
+	}
 
 	if writeToFile {
-		r := &TestReport{
-			Test:                  "T-Test",
-			Value:                 testResult.Value,
-			TotalRuntime:          testResult.TotalRuntime.Seconds(),
-			SetupTime:             setupTime.Seconds(),
-			ComputeRuntime:        testResult.ComputeRuntime.Seconds(),
-			SignExtractionRuntime: testResult.SignExtractionRuntime.Seconds(),
-			DivRuntime:            testResult.DivRuntime.Seconds(),
-			NumParties:            numParties,
-			NumRows:               encD.NumRows,
-			NumCols:               encD.NumCols,
-			NumSharesCreated:      testResult.NumSharesCreated,
-			RunId:                 runId,
-		}
-		writeTestResultsToFile(r)
+		writeTestResultsToFile(mpc, r, encD, testResult.Transcript)
 	} else {
 		fmt.Println("************************************************")
 		fmt.Println("T-Test statistic:            " + testResult.Value.String())
 		fmt.Printf("Dataset size:                %d\n", encD.NumRows)
+		fmt.Printf("Rows rejected:               %d\n", encD.Rejected)
 		fmt.Printf("Number of parties:           %d\n", numParties)
 		fmt.Printf("Threshold:                   %d\n", mpc.Threshold)
 		fmt.Printf("Total number of shares:      %d\n", testResult.NumSharesCreated)
@@ -182,6 +242,17 @@ func runTTestBechmarks(
 		fmt.Printf("---Sign Bit runtime (s):     %f\n", testResult.SignExtractionRuntime.Seconds())
 		fmt.Printf("---Division runtime (s):     %f\n", testResult.DivRuntime.Seconds())
 		fmt.Printf("Network latency (s):         %f\n", latency.Seconds())
+		fmt.Printf("Communication rounds:        %d\n", testResult.Comm.Rounds)
+		fmt.Printf("Messages sent:               %d\n", testResult.Comm.Messages)
+		fmt.Printf("Bytes sent:                  %d\n", testResult.Comm.Bytes)
+		if entry != nil {
+			fmt.Printf("p-value:                     %g\n", entry.PValue)
+			fmt.Printf("Threshold after correction:  %g (test %d on the dataset)\n", entry.Threshold, entry.Index)
+			fmt.Printf("Significant:                 %t\n", entry.Rejected)
+		}
+		if r.Certificate != "" {
+			fmt.Println("Certificate:                 " + r.Certificate)
+		}
 		fmt.Println("************************************************")
 	}
 }
@@ -189,12 +260,17 @@ func runTTestBechmarks(
 func runPearsonsBechmarks(
 	mpc *custodes.MPC,
 	filename string,
+	dataset string,
 	numParties int,
 	latency time.Duration,
 	debug bool,
 	writeToFile bool,
 	runId int,
-	example bool) {
+	example bool,
+	proofs bool,
+	bounds interval,
+	certify bool,
+	spec *party.Spec) {
 
 	//**************************************************************************************
 	//**************************************************************************************
@@ -206,34 +282,56 @@ func runPearsonsBechmarks(
 	fmt.Println("Running Pearson's Coorelation Test...")
 	fmt.Println("------------------------------------------------")
 
-	encD, setupTime := encryptDataset(mpc, filename, example)
+	encD, setupTime, err := encryptDataset(mpc, dataset, filename, example, proofs, bounds)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
 
 	if debug {
 		fmt.Println("[DEBUG] Finished encrypting dataset")
 	}
 
-	testResult := PearsonsTestSimulation(mpc, encD, debug)
+	testResult, entry, err := runPreregistered(mpc, spec, encD, func() *TestResult {
+		return PearsonsTestSimulation(mpc, encD, debug)
+	})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	r := &TestReport{
+		Test:                  "Pearson",
+		Value:                 testResult.Value,
+		TotalRuntime:          testResult.TotalRuntime.Seconds(),
+		SetupTime:             setupTime.Seconds(),
+		ComputeRuntime:        testResult.ComputeRuntime.Seconds(),
+		SignExtractionRuntime: testResult.SignExtractionRuntime.Seconds(),
+		DivRuntime:            testResult.DivRuntime.Seconds(),
+		NumParties:            numParties,
+		NumRows:               encD.NumRows,
+		NumCols:               encD.NumCols,
+		RejectedRows:          encD.Rejected,
+		NumSharesCreated:      testResult.NumSharesCreated,
+		Rounds:                testResult.Comm.Rounds,
+		Messages:              testResult.Comm.Messages,
+		Bytes:                 testResult.Comm.Bytes,
+		Protocols:             testResult.Comm,
+		Ledger:                entry,
+		RunId:                 runId,
+	}
+
+	if certify {
+		certifyResult(mpc, r, encD, testResult.Transcript, entry)
+	}
 
 	if writeToFile {
-		r := &TestReport{
-			Test:                  "Pearson",
-			Value:                 testResult.Value,
-			TotalRuntime:          testResult.TotalRuntime.Seconds(),
-			SetupTime:             setupTime.Seconds(),
-			ComputeRuntime:        testResult.ComputeRuntime.Seconds(),
-			SignExtractionRuntime: testResult.SignExtractionRuntime.Seconds(),
-			DivRuntime:            testResult.DivRuntime.Seconds(),
-			NumParties:            numParties,
-			NumRows:               encD.NumRows,
-			NumCols:               encD.NumCols,
-			NumSharesCreated:      testResult.NumSharesCreated,
-			RunId:                 runId,
-		}
-		writeTestResultsToFile(r)
+		writeTestResultsToFile(mpc, r, encD, testResult.Transcript)
 	} else {
 		fmt.Println("************************************************")
 		fmt.Println("Pearson's statistic:         " + testResult.Value.String())
 		fmt.Printf("Dataset size:                %d\n", encD.NumRows)
+		fmt.Printf("Rows rejected:               %d\n", encD.Rejected)
 		fmt.Printf("Number of parties:           %d\n", numParties)
 		fmt.Printf("Threshold:                   %d\n", mpc.Threshold)
 		fmt.Printf("Total number of shares:      %d\n", testResult.NumSharesCreated)
@@ -243,27 +341,61 @@ func runPearsonsBechmarks(
 		fmt.Printf("---Sign Bit runtime (s):     %f\n", testResult.SignExtractionRuntime.Seconds())
 		fmt.Printf("---Division runtime (s):     %f\n", testResult.DivRuntime.Seconds())
 		fmt.Printf("Network latency (s):         %f\n", latency.Seconds())
+		fmt.Printf("Communication rounds:        %d\n", testResult.Comm.Rounds)
+		fmt.Printf("Messages sent:               %d\n", testResult.Comm.Messages)
+		fmt.Printf("Bytes sent:                  %d\n", testResult.Comm.Bytes)
+		if entry != nil {
+			fmt.Printf("p-value:                     %g\n", entry.PValue)
+			fmt.Printf("Threshold after correction:  %g (test %d on the dataset)\n", entry.Threshold, entry.Index)
+			fmt.Printf("Significant:                 %t\n", entry.Rejected)
+		}
+		if r.Certificate != "" {
+			fmt.Println("Certificate:                 " + r.Certificate)
+		}
 		fmt.Println("************************************************")
 	}
 }
 
-func newMPCTranscript(size int) *MPCTranscript {
-	return &MPCTranscript{make([]*MPCTranscriptEntry, size), 0}
-}
+// runPreregistered uploads the dataset and runs the simulation on it as
+// the preregistered test spec. It returns the entry of the test in the parties' ledger, or
+// runs it as is if spec is nil
+func runPreregistered(
+	mpc *custodes.MPC,
+	spec *party.Spec,
+	encD *EncryptedDataset,
+	simulate func() *TestResult) (*TestResult, *party.LedgerEntry, error) {
 
-func (trans *MPCTranscript) setEntryAtIndex(entry *MPCTranscriptEntry, i int) {
-	trans.Entries[i] = entry
-}
+	if spec == nil {
+		return simulate(), nil, nil
+	}
+
+	err := mpc.Upload(spec.Dataset, encD.rows)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = mpc.BeginTest(spec)
+	if err != nil {
+		return nil, nil, err
+	}
+	res := simulate()
+
+	entry, err := mpc.EndTest(spec, &party.Outcome{
+		NumRows: encD.NumRows,
+		NumCols: encD.NumCols,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
 
-func (trans *MPCTranscript) addEntry(entry *MPCTranscriptEntry) {
-	trans.Entries[trans.Next] = entry
-	trans.Next++
+	return res, entry, nil
 }
 
 func encryptCategoricalDataset(
 	mpc *custodes.MPC,
+	dataset string,
 	filepath string,
-	example bool) (*EncryptedDataset, time.Duration) {
+	example bool,
+	proofs bool) (*EncryptedDataset, time.Duration, error) {
 	dealerSetupStart := time.Now()
 
 	var x [][]int64
@@ -272,7 +404,10 @@ func encryptCategoricalDataset(
 	if !example {
 		x, err = parseCategoricalDataset(filepath)
 		if err != nil {
-			panic(err)
+			return nil, 0, err
+		}
+		if len(x) == 0 {
+			return nil, 0, fmt.Errorf("%s: empty dataset", filepath)
 		}
 	} else {
 		// Test dataset (result should be 0.666...)
@@ -297,39 +432,32 @@ func encryptCategoricalDataset(
 	}
 
 	numCategories := len(x[0])
-	numRows := len(x)
-
-	var eX [][]*paillier.Ciphertext
-	eX = make([][]*paillier.Ciphertext, numRows)
 
-	var wg sync.WaitGroup
-	for i := 0; i < numRows; i++ {
-		wg.Add(1)
-		go func(i int) {
-			defer wg.Done()
-			eX[i] = make([]*paillier.Ciphertext, numCategories)
-			for j := 0; j < numCategories; j++ {
-				pt := mpc.Pk.EncodeFixedPoint(
-					big.NewFloat(float64(x[i][j])), mpc.FPPrecBits)
-				eX[i][j] = mpc.Pk.Encrypt(pt)
-			}
-		}(i)
+	eX, rejected, err := ingestCategorical(mpc, dataset, x, proofs)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %v", filepath, err)
+	}
+	if len(eX) == 0 {
+		return nil, 0, fmt.Errorf("%s: no row passed verification", filepath)
 	}
-
-	wg.Wait()
 
 	return &EncryptedDataset{
-			Data:    eX,
-			NumRows: numRows,
-			NumCols: numCategories,
+			Data:     eX,
+			NumRows:  len(eX),
+			NumCols:  numCategories,
+			Rejected: rejected,
+			rows:     eX,
 		},
-		time.Now().Sub(dealerSetupStart)
+		time.Now().Sub(dealerSetupStart), nil
 }
 
 func encryptDataset(
 	mpc *custodes.MPC,
+	dataset string,
 	filepath string,
-	example bool) (*EncryptedDataset, time.Duration) {
+	example bool,
+	proofs bool,
+	bounds interval) (*EncryptedDataset, time.Duration, error) {
 
 	dealerSetupStart := time.Now()
 
@@ -340,7 +468,10 @@ func encryptDataset(
 	if !example {
 		x, y, err = parseDataset(filepath)
 		if err != nil {
-			panic(err)
+			return nil, 0, err
+		}
+		if len(y) < 2 {
+			return nil, 0, fmt.Errorf("%s: need at least two rows", filepath)
 		}
 	} else {
 		// Test dataset (result should be 1.99 for t-test, 0.29... for pearson)
@@ -371,7 +502,20 @@ func encryptDataset(
 		fmt.Println("   ----------------------------------------")
 	}
 
-	numRows := len(y)
+	values := make([][]*big.Float, len(y))
+	for i := 0; i < len(y); i++ {
+		values[i] = []*big.Float{big.NewFloat(x[i]), big.NewFloat(y[i])}
+	}
+
+	rows, rejected, err := ingestNumerical(mpc, dataset, values, proofs, bounds)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %v", filepath, err)
+	}
+	if len(rows) < 2 {
+		return nil, 0, fmt.Errorf("%s: fewer than two rows passed verification", filepath)
+	}
+
+	numRows := len(rows)
 
 	var eX []*paillier.Ciphertext
 	eX = make([]*paillier.Ciphertext, numRows)
@@ -379,19 +523,18 @@ func encryptDataset(
 	eY = make([]*paillier.Ciphertext, numRows)
 
 	for i := 0; i < numRows; i++ {
-
-		plaintextX := mpc.Pk.EncodeFixedPoint(big.NewFloat(x[i]), mpc.FPPrecBits)
-		plaintextY := mpc.Pk.EncodeFixedPoint(big.NewFloat(y[i]), mpc.FPPrecBits)
-		eX[i] = mpc.Pk.Encrypt(plaintextX)
-		eY[i] = mpc.Pk.Encrypt(plaintextY)
+		eX[i] = rows[i][0]
+		eY[i] = rows[i][1]
 	}
 
 	return &EncryptedDataset{
-			Data:    [][]*paillier.Ciphertext{eX, eY},
-			NumRows: numRows,
-			NumCols: 2,
+			Data:     [][]*paillier.Ciphertext{eX, eY},
+			NumRows:  numRows,
+			NumCols:  2,
+			Rejected: rejected,
+			rows:     rows,
 		},
-		time.Now().Sub(dealerSetupStart)
+		time.Now().Sub(dealerSetupStart), nil
 }
 
 func parseCategoricalDataset(file string) ([][]int64, error) {
@@ -405,7 +548,7 @@ func parseCategoricalDataset(file string) ([][]int64, error) {
 	csvr := csv.NewReader(f)
 	data := make([][]int64, 0)
 
-	for {
+	for line := 1; ; line++ {
 		row, err := csvr.Read()
 
 		if err != nil {
@@ -420,7 +563,7 @@ func parseCategoricalDataset(file string) ([][]int64, error) {
 		for i := 0; i < len(row); i++ {
 			var val int64
 			if val, err = strconv.ParseInt(row[i], 10, 64); err != nil {
-				panic("could not parse dataset")
+				return nil, fmt.Errorf("%s:%d: could not parse dataset: %v", file, line, err)
 			}
 
 			values[i] = val
@@ -444,7 +587,7 @@ func parseDataset(file string) ([]float64, []float64, error) {
 	data1 := make([]float64, 0)
 	data2 := make([]float64, 0)
 
-	for {
+	for line := 1; ; line++ {
 		row, err := csvr.Read()
 		if err != nil {
 			if err == io.EOF {
@@ -454,6 +597,10 @@ func parseDataset(file string) ([]float64, []float64, error) {
 			return data1, data2, err
 		}
 
+		if len(row) < 2 {
+			return nil, nil, fmt.Errorf("%s:%d: expected two columns", file, line)
+		}
+
 		var val1 float64
 		var val2 float64
 
@@ -471,12 +618,55 @@ func parseDataset(file string) ([]float64, []float64, error) {
 
 }
 
+// writeTestResultsToFile writes the report of a test. With a transcript,
+// it also writes the transcript, the public parameters, the encrypted
+// dataset and the test specification next to the report, which is what
+// custodes verify audits. With a certificate, it writes the public
+// parameters, which hold the identities of the parties
 func writeTestResultsToFile(
-	r *TestReport) {
-	filename := "./" + strconv.Itoa(r.RunId) + "_" +
-		r.Test + "_[" + strconv.Itoa(r.NumRows) + "_" +
-		strconv.Itoa(r.NumCols) + "]_n=" +
-		strconv.Itoa(r.NumParties) + "_" + ".json"
+	mpc *custodes.MPC,
+	r *TestReport,
+	encD *EncryptedDataset,
+	transcript *custodes.MPCTranscript) {
+	base := reportBase(r)
+	filename := base + ".json"
+
+	// the report names the transcript and commits to it by its head hash
+	if transcript != nil {
+		r.Transcript = base + "transcript.json"
+		r.TranscriptHash = transcript.Head()
+		r.Dataset = base + "dataset.json"
+		r.Spec = base + "spec.json"
+
+		spec := &TestSpec{
+			Test:        r.Test,
+			NumRows:     encD.NumRows,
+			NumCols:     encD.NumCols,
+			DatasetHash: custodes.CommitDataset(encD.Data),
+		}
+
+		err := transcript.WriteFile(r.Transcript)
+		if err == nil {
+			err = writeJSONFile(r.Dataset, encD, 0644)
+		}
+		if err == nil {
+			err = writeJSONFile(r.Spec, spec, 0644)
+		}
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+
+	// auditors and certificate checkers need the key and the identities
+	if transcript != nil || r.Certificate != "" {
+		r.PublicParams = base + "public.json"
+		err := writeJSONFile(r.PublicParams, publicParams(mpc), 0644)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
 
 	reportJson, _ := json.MarshalIndent(r, "", "\t")
 	err := ioutil.WriteFile(
@@ -488,3 +678,29 @@ func writeTestResultsToFile(
 		return
 	}
 }
+
+// reportBase returns the prefix of the names of the files written for a test
+func reportBase(r *TestReport) string {
+	return "./" + strconv.Itoa(r.RunId) + "_" +
+		r.Test + "_[" + strconv.Itoa(r.NumRows) + "_" +
+		strconv.Itoa(r.NumCols) + "]_n=" +
+		strconv.Itoa(r.NumParties) + "_"
+}
+
+// publicParams returns the public parameters of mpc in the form keygen writes them
+func publicParams(mpc *custodes.MPC) *PublicParamsFile {
+	return &PublicParamsFile{
+		Tk:  mpc.Tk,
+		P:   mpc.P,
+		VSS: mpc.Party.VSS,
+		Params: &custodes.MPCKeyGenParams{
+			NumParties:      len(mpc.Parties),
+			Threshold:       mpc.Threshold,
+			KeyBits:         mpc.Pk.N.BitLen(),
+			SecurityBits:    mpc.S,
+			MessageBits:     mpc.K,
+			FPPrecisionBits: mpc.FPPrecBits,
+		},
+		Roster: mpc.Roster,
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"custodes"
+	"custodes/party"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// certifyResult has the parties sign the result of a test and writes the
+// certificate next to the report, which names it
+func certifyResult(
+	mpc *custodes.MPC,
+	r *TestReport,
+	encD *EncryptedDataset,
+	transcript *custodes.MPCTranscript,
+	entry *party.LedgerEntry) {
+
+	st := &party.Statement{
+		Test:            r.Test,
+		NumRows:         encD.NumRows,
+		NumCols:         encD.NumCols,
+		MessageBits:     mpc.K,
+		FPPrecisionBits: mpc.FPPrecBits,
+		DatasetHash:     custodes.CommitDataset(encD.Data),
+		KeyFingerprint:  party.KeyFingerprint(mpc.Pk),
+		Statistic:       r.Value.Text('g', -1),
+	}
+	if transcript != nil {
+		st.TranscriptHash = transcript.Head()
+	}
+	if entry != nil {
+		entry.Annotate(st)
+	}
+
+	cert, err := mpc.Certify(st)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	filename := reportBase(r) + "certificate.json"
+	err = cert.WriteFile(filename)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	r.Certificate = filename
+}
+
+// runCheckCertificate checks the signatures on a certificate against the
+// identities of the parties in the public parameters and prints its statement
+func runCheckCertificate(args []string) {
+
+	fs := flag.NewFlagSet("checkcert", flag.ExitOnError)
+	certFile := fs.String("cert", "", "path to the certificate.")
+	publicFile := fs.String("public", "keys/public.json", "path to the public parameters holding the parties' identities.")
+	need := fs.Int("need", 0, "number of parties that must have signed; defaults to the threshold.")
+	fs.Parse(args)
+
+	pub := &PublicParamsFile{}
+	err := readJSONFile(*publicFile, pub)
+	if err != nil {
+		panic(err)
+	}
+
+	cert, err := custodes.ReadCertificate(*certFile)
+	if err != nil {
+		panic(err)
+	}
+
+	threshold := *need
+	if threshold == 0 {
+		threshold = pub.Params.Threshold
+	}
+
+	st := cert.Statement
+	fmt.Println("************************************************")
+	if st != nil {
+		fmt.Println("Test:                        " + st.Test)
+		fmt.Printf("Dataset size:                %d\n", st.NumRows)
+		fmt.Printf("Number of columns:           %d\n", st.NumCols)
+		fmt.Println("Statistic:                   " + st.Statistic)
+		fmt.Println("Dataset hash:                " + hex.EncodeToString(st.DatasetHash))
+		fmt.Println("Transcript hash:             " + hex.EncodeToString(st.TranscriptHash))
+		fmt.Println("Spec hash:                   " + hex.EncodeToString(st.SpecHash))
+		if st.SpecHash != nil {
+			fmt.Printf("Test on the dataset:         %d\n", st.TestIndex)
+			fmt.Println("p-value:                     " + st.PValue)
+			fmt.Println("Threshold after correction:  " + st.Threshold)
+		}
+	}
+	fmt.Printf("Signed by parties:           %v\n", cert.Signers(pub.Roster))
+
+	if st != nil && pub.Tk != nil && !bytes.Equal(st.KeyFingerprint, party.KeyFingerprint(&pub.Tk.PublicKey)) {
+		err = fmt.Errorf("%w: the statement is about another key", custodes.ErrCertificate)
+	} else {
+		err = cert.Check(pub.Roster, threshold)
+	}
+
+	if err != nil {
+		fmt.Println("Certificate:                 INVALID")
+		fmt.Println("Reason:                      " + err.Error())
+	} else {
+		fmt.Println("Certificate:                 VALID")
+	}
+	fmt.Println("************************************************")
+
+	if err != nil {
+		os.Exit(1)
+	}
+}
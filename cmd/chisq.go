@@ -20,7 +20,9 @@ func ChiSquaredTestSimulation(
 	// raw data
 	eX := encD.Data
 
-	// keep track of runtime
+	// keep track of runtime and communication
+	mpc.ResetCommStats()
+	mpc.ResetTranscript()
 	startTime := time.Now()
 
 	// compute encrypted histogram
@@ -44,20 +46,15 @@ func ChiSquaredTestSimulation(
 	// compute the expected value
 	sumTotal := mpc.Pk.EAdd(h...)
 
+	// the truncations run one category after the other, so that the
+	// transcript records them in category order for the auditor
 	expectedValues := make([]*paillier.Ciphertext, encD.NumCols)
-	wg.Add(encD.NumCols)
 	for i := 0; i < encD.NumCols; i++ {
-		go func(i int) {
-			defer wg.Done()
-
-			w := mpc.Pk.EncodeFixedPoint(expectedPercentage[i], mpc.FPPrecBits)
-			expectedValueTmp := mpc.Pk.ECMult(sumTotal, w)
-			expectedValues[i] = mpc.ETruncPR(expectedValueTmp, mpc.K, mpc.FPPrecBits)
-		}(i)
+		w := mpc.Pk.EncodeFixedPoint(expectedPercentage[i], mpc.FPPrecBits)
+		expectedValueTmp := mpc.Pk.ECMult(sumTotal, w)
+		expectedValues[i] = mpc.MustETruncPR(expectedValueTmp, mpc.K, mpc.FPPrecBits)
 	}
 
-	wg.Wait()
-
 	// compute the residuals
 	residual := make([]*paillier.Ciphertext, encD.NumCols)
 	wg.Add(encD.NumCols)
@@ -66,7 +63,7 @@ func ChiSquaredTestSimulation(
 			defer wg.Done()
 
 			res := mpc.Pk.ESub(h[i], expectedValues[i])
-			residual[i] = mpc.EMult(res, res)
+			residual[i] = mpc.MustEMult(res, res)
 		}(i)
 	}
 
@@ -79,8 +76,8 @@ func ChiSquaredTestSimulation(
 		wg.Add(1)
 		go func(i int) {
 			defer wg.Done()
-			residualShares[i] = mpc.PaillierToShare(residual[i])
-			expectedValueShares[i] = mpc.PaillierToShare(expectedValues[i])
+			residualShares[i] = mpc.MustPaillierToShare(residual[i])
+			expectedValueShares[i] = mpc.MustPaillierToShare(expectedValues[i])
 		}(i)
 	}
 	wg.Wait()
@@ -93,17 +90,17 @@ func ChiSquaredTestSimulation(
 		wg.Add(1)
 		go func(i int) {
 			defer wg.Done()
-			xi[i] = mpc.FPDivision(residualShares[i], expectedValueShares[i])
+			xi[i] = mpc.MustFPDivision(residualShares[i], expectedValueShares[i])
 		}(i)
 	}
 	wg.Wait()
 
-	chi2 := mpc.CreateShares(big.NewInt(0))
+	chi2 := mpc.MustCreateShares(big.NewInt(0))
 	for i := 0; i < encD.NumCols; i++ {
-		chi2 = mpc.Add(chi2, xi[i])
+		chi2 = mpc.MustAdd(chi2, xi[i])
 	}
 
-	chi2Stat := mpc.RevealShareFP(chi2, mpc.K/2+mpc.FPPrecBits)
+	chi2Stat := mpc.MustRevealStatistic(chi2, mpc.K/2+mpc.FPPrecBits)
 	endTime := time.Now()
 
 	if debug {
@@ -115,12 +112,16 @@ func ChiSquaredTestSimulation(
 	divTime := time.Now().Sub(endTimePaillier)
 	paillierTime := endTimePaillier.Sub(startTime)
 
+	comm := mpc.CommStats()
+
 	return &TestResult{
 		Test:             "CHI2",
 		Value:            chi2Stat,
 		TotalRuntime:     totalTime,
 		ComputeRuntime:   paillierTime,
 		DivRuntime:       divTime,
-		NumSharesCreated: mpc.DeleteAllShares(),
+		NumSharesCreated: mpc.MustDeleteAllShares(),
+		Comm:             comm,
+		Transcript:       mpc.Transcript,
 	}
 }
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"custodes"
+	"custodes/party"
+	"custodes/zkp"
+	"errors"
+	"math/big"
+	"strings"
+	"sync"
+
+	"github.com/sachaservan/paillier"
+)
+
+// ingestBatch is the number of rows the parties verify per request. A
+// batch is verified as soon as its rows are encrypted, so verification
+// overlaps with the encryption of the rest of the dataset
+const ingestBatch = 64
+
+// upload is a row of a dataset as a data owner submits it
+type upload struct {
+	cts    []*paillier.Ciphertext
+	ranges []*zkp.IntervalProof // one per value of a numerical row
+	oneHot *zkp.OneHotProof     // for a categorical row
+}
+
+// interval is the range a data owner declares the values of a numerical
+// dataset to lie in
+type interval struct {
+	lo, hi *big.Float
+}
+
+// parseInterval parses an interval given as lo,hi
+func parseInterval(s string) (interval, error) {
+	bounds := strings.Split(s, ",")
+	if len(bounds) != 2 {
+		return interval{}, errors.New("interval must be given as lo,hi")
+	}
+
+	var in interval
+	var ok bool
+	if in.lo, ok = new(big.Float).SetString(strings.TrimSpace(bounds[0])); !ok {
+		return interval{}, errors.New("malformed lower bound " + bounds[0])
+	}
+	if in.hi, ok = new(big.Float).SetString(strings.TrimSpace(bounds[1])); !ok {
+		return interval{}, errors.New("malformed upper bound " + bounds[1])
+	}
+	if in.lo.Cmp(in.hi) > 0 {
+		return interval{}, errors.New("empty interval " + s)
+	}
+
+	return in, nil
+}
+
+// fixedPoint returns the interval in fixed point with precBits bits of
+// precision, as signed integers rather than mod N
+func (in interval) fixedPoint(precBits int) *party.Interval {
+	scale := new(big.Float).SetInt(new(big.Int).Lsh(big.NewInt(1), uint(precBits)))
+	encode := func(x *big.Float) *big.Int {
+		v, _ := new(big.Float).SetPrec(x.Prec()+uint(precBits)+64).Mul(x, scale).Int(nil)
+		return v
+	}
+	return &party.Interval{Lo: encode(in.lo), Hi: encode(in.hi)}
+}
+
+// ingestNumerical encrypts the fixed point encoding of every value as a
+// data owner of the dataset would. With proofs, each value comes with a
+// proof that it lies in the declared interval, which must leave the
+// statistics room in the message space, and the parties drop the rows
+// whose proofs fail
+func ingestNumerical(mpc *custodes.MPC, dataset string, values [][]*big.Float, proofs bool, bounds interval) ([][]*paillier.Ciphertext, int, error) {
+
+	// no test is preregistered once data is on its way
+	if err := mpc.Seal(); err != nil {
+		return nil, 0, err
+	}
+
+	// the parties hold the proofs to the interval they know for the
+	// dataset, which must be this one
+	in := bounds.fixedPoint(mpc.FPPrecBits)
+	lo, hi := in.Lo, in.Hi
+	zc := party.UploadContext(dataset)
+	if proofs {
+		if err := mpc.CheckInterval(lo, hi, len(values)); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	encrypt := func(i int) *upload {
+		u := &upload{
+			cts:    make([]*paillier.Ciphertext, len(values[i])),
+			ranges: make([]*zkp.IntervalProof, len(values[i])),
+		}
+		for j, v := range values[i] {
+			pt := mpc.Pk.EncodeFixedPoint(v, mpc.FPPrecBits)
+			if !proofs {
+				u.cts[j] = mpc.Pk.Encrypt(pt)
+				continue
+			}
+
+			ct, proof, err := zkp.EncryptInInterval(mpc.Pk, zc, pt, lo, hi)
+			if err != nil {
+				// the value cannot be proven in range, upload it with a
+				// proof the parties reject
+				ct, proof = mpc.Pk.Encrypt(pt), &zkp.IntervalProof{}
+			}
+			u.cts[j], u.ranges[j] = ct, proof
+		}
+		return u
+	}
+
+	verify := func(rows []*upload) ([]bool, error) {
+		var cts []*paillier.Ciphertext
+		var ranges []*zkp.IntervalProof
+		for _, u := range rows {
+			cts = append(cts, u.cts...)
+			ranges = append(ranges, u.ranges...)
+		}
+
+		valid, err := mpc.VerifyRanges(dataset, cts, ranges)
+		if err != nil {
+			return nil, err
+		}
+
+		accepted := make([]bool, len(rows))
+		k := 0
+		for i, u := range rows {
+			accepted[i] = true
+			for range u.cts {
+				accepted[i] = accepted[i] && valid[k]
+				k++
+			}
+		}
+		return accepted, nil
+	}
+
+	if !proofs {
+		verify = nil
+	}
+	return ingest(len(values), encrypt, verify)
+}
+
+// ingestCategorical encrypts every row of a categorical dataset in fixed
+// point as a data owner would. With proofs, each row comes with a proof
+// that it is one-hot and the parties drop the rows whose proofs fail
+func ingestCategorical(mpc *custodes.MPC, dataset string, x [][]int64, proofs bool) ([][]*paillier.Ciphertext, int, error) {
+
+	if err := mpc.Seal(); err != nil {
+		return nil, 0, err
+	}
+
+	zc := party.UploadContext(dataset)
+
+	encrypt := func(i int) *upload {
+		if proofs {
+			cts, proof, err := zkp.EncryptOneHot(mpc.Pk, zc, x[i], mpc.FPPrecBits)
+			if err == nil {
+				return &upload{cts: cts, oneHot: proof}
+			}
+			// the row is not one-hot, upload it with a proof the parties reject
+		}
+
+		u := &upload{cts: make([]*paillier.Ciphertext, len(x[i])), oneHot: &zkp.OneHotProof{}}
+		for j := 0; j < len(x[i]); j++ {
+			pt := mpc.Pk.EncodeFixedPoint(big.NewFloat(float64(x[i][j])), mpc.FPPrecBits)
+			u.cts[j] = mpc.Pk.Encrypt(pt)
+		}
+		return u
+	}
+
+	verify := func(rows []*upload) ([]bool, error) {
+		cts := make([][]*paillier.Ciphertext, len(rows))
+		oneHots := make([]*zkp.OneHotProof, len(rows))
+		for i, u := range rows {
+			cts[i], oneHots[i] = u.cts, u.oneHot
+		}
+		return mpc.VerifyOneHot(dataset, cts, oneHots)
+	}
+
+	if !proofs {
+		verify = nil
+	}
+	return ingest(len(x), encrypt, verify)
+}
+
+// ingest encrypts the n rows of a dataset concurrently with encrypt and,
+// unless verify is nil, has the parties check batches of rows with verify
+// while the remaining rows are still being encrypted. It returns the
+// accepted rows in order along with the number of rejected ones
+func ingest(
+	n int,
+	encrypt func(i int) *upload,
+	verify func(rows []*upload) ([]bool, error)) ([][]*paillier.Ciphertext, int, error) {
+
+	uploads := make([]*upload, n)
+	accepted := make([]bool, n)
+
+	var mu sync.Mutex
+	var failure error
+
+	// rows are handed over for verification as soon as they are encrypted
+	encrypted := make(chan int, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			uploads[i] = encrypt(i)
+			encrypted <- i
+		}(i)
+	}
+	go func() {
+		wg.Wait()
+		close(encrypted)
+	}()
+
+	var checks sync.WaitGroup
+	check := func(batch []int) {
+		checks.Add(1)
+		go func() {
+			defer checks.Done()
+
+			if verify == nil {
+				for _, i := range batch {
+					accepted[i] = true
+				}
+				return
+			}
+
+			rows := make([]*upload, len(batch))
+			for k, i := range batch {
+				rows[k] = uploads[i]
+			}
+
+			valid, err := verify(rows)
+			if err != nil {
+				mu.Lock()
+				failure = err
+				mu.Unlock()
+				return
+			}
+			for k, i := range batch {
+				accepted[i] = valid[k]
+			}
+		}()
+	}
+
+	var batch []int
+	for i := range encrypted {
+		batch = append(batch, i)
+		if len(batch) == ingestBatch {
+			check(batch)
+			batch = nil
+		}
+	}
+	if len(batch) > 0 {
+		check(batch)
+	}
+	checks.Wait()
+
+	if failure != nil {
+		return nil, 0, failure
+	}
+
+	var kept [][]*paillier.Ciphertext
+	for i := 0; i < n; i++ {
+		if accepted[i] {
+			kept = append(kept, uploads[i].cts)
+		}
+	}
+
+	return kept, n - len(kept), nil
+}
@@ -1,9 +1,14 @@
 package main
 
 import (
+	"context"
+	"crypto/ed25519"
 	"custodes"
+	"custodes/party"
 	"flag"
 	"fmt"
+	"os"
+	"path/filepath"
 	"runtime"
 	"time"
 )
@@ -11,18 +16,64 @@ import (
 func main() {
 	printWelcome()
 
+	// subcommands for running custodes as independent custodians
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "keygen":
+			runKeyGen(os.Args[2:])
+			return
+		case "party":
+			runPartyDaemon(os.Args[2:])
+			return
+		case "distkeygen":
+			runDistKeyGen(os.Args[2:])
+			return
+		case "refresh":
+			runRefresh(os.Args[2:])
+			return
+		case "verify":
+			runVerify(os.Args[2:])
+			return
+		case "checkcert":
+			runCheckCertificate(os.Args[2:])
+			return
+		}
+	}
+
 	// Command line arguments
 	example := flag.Bool("example", false, "run an examples of all three statistical tests.")
 	rootDirCmd := flag.String("rootdir", "", "full path to project dir where datasets are located.")
 	numPartiesCmd := flag.Int("parties", 3, "integer number of parties >= 3.")
 	thresholdCmd := flag.Int("threshold", 2, "integer number of threshold >= 2.")
 	networkLatencyCmd := flag.Int("netlat", 0, "average network latency for party communication.")
+	topologyCmd := flag.String("topology", "", "path to a JSON network topology to emulate; overrides -netlat.")
+	batching := flag.Bool("batch", false, "coalesce the concurrent requests of each round into one message per party.")
+	timeout := flag.Duration("timeout", 0, "how long to wait for a party before treating it as offline, e.g. 30s; 0 waits forever.")
+	verify := flag.Bool("verify", false, "check the proofs of every partial decryption and random bit the parties contribute, and exclude parties whose proofs fail.")
+	proofs := flag.Bool("proofs", false, "have data owners prove their values lie in -interval and categorical rows are one-hot; parties drop rows whose proofs fail.")
+	intervalCmd := flag.String("interval", "-1000,1000", "interval lo,hi the values of the numerical datasets are declared to lie in, proven with -proofs.")
+	macs := flag.Bool("macs", false, "authenticate every share with a MAC and check the MACs before revealing a value.")
+	vss := flag.Bool("vss", false, "commit to every dealt polynomial so that parties check their shares on receipt.")
+	distKeyGen := flag.Bool("distkeygen", false, "generate the Paillier key among the parties instead of dealing it; slow at 1024 bits.")
+	transcript := flag.Bool("transcript", false, "record every EMult, ETruncPR, PaillierToShare and decryption in a hash-chained transcript, saved with -save for custodes verify; implies -verify.")
+	certify := flag.Bool("certify", false, "have the parties sign a certificate of each result, written next to the report.")
+	prereg := flag.Bool("prereg", false, "preregister every test before uploading data; the parties open values for preregistered tests only.")
+	analystFile := flag.String("analyst", "", "path to analyst.json written by keygen, whose key signs the preregistered tests; defaults to the directory of -remote, or a fresh key without -remote.")
+	window := flag.Duration("window", 24*time.Hour, "how long each preregistered test may open values once it begins.")
+	alpha := flag.Float64("alpha", 0.05, "significance level of the preregistered tests.")
+	sides := flag.Int("sides", 2, "1 for one-sided preregistered tests, 2 for two-sided ones.")
+	correction := flag.String("correction", party.Bonferroni, "correction of the preregistered tests on a dataset for each other: Bonferroni, Holm or AlphaInvesting.")
+	budget := flag.Int("budget", 0, "number of tests each dataset takes; 0 for the number of tests preregistered on it.")
+	deadline := flag.Duration("deadline", 0, "abandon the computation after this long, e.g. 10m; 0 never gives up.")
 	debug := flag.Bool("debug", false, "print debug statements during computation.")
 	runId := flag.Int("runId", 0, "unique id of the test/benchmark run")
 	writeToFile := flag.Bool("save", false, "save tests results to a json file")
 	ttest := flag.Bool("ttest", false, "run Student's T-test simulation")
 	corrtest := flag.Bool("pearsontest", false, "run Pearson's Correlation test simulation")
 	chisqtest := flag.Bool("chisqtest", false, "run Chi^2 test simulation")
+	remote := flag.String("remote", "", "path to public.json written by keygen; runs against party daemons.")
+	peers := flag.String("peers", "", "comma separated addresses of the party daemons, ordered by party id.")
+	identity := flag.String("identity", "", "path to coordinator.json written by keygen; defaults to the directory of -remote.")
 
 	flag.Parse()
 
@@ -33,6 +84,16 @@ func main() {
 	networkLatency := time.Duration(*networkLatencyCmd)
 	allTests := !(*ttest || *corrtest || *chisqtest)
 
+	bounds, err := parseInterval(*intervalCmd)
+	if err != nil {
+		panic(err)
+	}
+
+	// the parties open only the values the preregistered tests budget for
+	if *prereg && *debug {
+		panic("-debug opens values that no preregistered test budgets for")
+	}
+
 	// ensure threshsold is ok for the given number of parties
 	if numParties < 2*threshold-1 {
 		panic("Threshold is too high compared to the number of parties!")
@@ -41,6 +102,11 @@ func main() {
 	// number of cores
 	runtime.GOMAXPROCS(2 * numParties)
 
+	topo, err := loadTopology(*topologyCmd)
+	if err != nil {
+		panic(err)
+	}
+
 	// system parameters
 	var params *custodes.MPCKeyGenParams
 
@@ -65,13 +131,74 @@ func main() {
 			NetworkLatency:  0}
 	}
 
-	fmt.Print("System setup in progress...")
-	mpc, err := custodes.NewMPCKeyGen(params)
+	params.Topology = topo
+	params.Batching = *batching
+	params.Timeout = *timeout
+	// only decryptions with proofs can be audited
+	params.Verify = *verify || *transcript
+	params.VSS = *vss
+	params.DistributedKeyGen = *distKeyGen
+	params.Preregistration = *prereg
+	params.Interval = bounds.fixedPoint(params.FPPrecisionBits)
+
+	// the analyst signs the specs of preregistered tests with a key of its
+	// own, which the parties check them against
+	var analyst *party.Identity
+	if *prereg {
+		if *analystFile == "" && *remote != "" {
+			*analystFile = filepath.Join(filepath.Dir(*remote), "analyst.json")
+		}
+		if *analystFile != "" {
+			analyst = &party.Identity{}
+			err = readJSONFile(*analystFile, analyst)
+		} else {
+			analyst, err = party.NewIdentity(party.AnalystID)
+		}
+		if err != nil {
+			panic(err)
+		}
+		params.Analyst = analyst.Key.Public().(ed25519.PublicKey)
+	}
+
+	var mpc *custodes.MPC
+
+	if *remote != "" {
+		fmt.Print("Connecting to parties...")
+		identityFile := *identity
+		if identityFile == "" {
+			identityFile = filepath.Join(filepath.Dir(*remote), "coordinator.json")
+		}
+		mpc, err = newRemoteMPC(*remote, identityFile, *peers, topo, *batching)
+		if err == nil {
+			mpc.Timeout = *timeout
+			mpc.Verify = params.Verify
+		}
+	} else {
+		fmt.Print("System setup in progress...")
+		mpc, err = custodes.NewMPCKeyGen(params)
+	}
 	if err != nil {
 		panic(err)
 	}
+	numParties = len(mpc.Parties)
 	fmt.Println("done.")
 
+	if *macs {
+		if err := mpc.EnableMACs(); err != nil {
+			panic(err)
+		}
+	}
+
+	if *transcript {
+		mpc.Transcript = custodes.NewMPCTranscript()
+	}
+
+	if *deadline > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), *deadline)
+		defer cancel()
+		mpc = mpc.WithContext(ctx)
+	}
+
 	filename_abalone := rootDir + "/cmd/datasets/abalone_height_vs_weight.csv"
 	filenameChiSq_pittsburgh := rootDir + "/cmd/datasets/pittsburgh_bridges_categorical.csv"
 
@@ -92,15 +219,18 @@ func main() {
 	filenameChiSq10000_10 := rootDir + "/cmd/datasets/benchmark_chisq_10000_10.csv"
 	filenameChiSq10000_20 := rootDir + "/cmd/datasets/benchmark_chisq_10000_20.csv"
 
+	// the tests to run, in order
+	var runs []testRun
+
 	if *ttest || allTests {
 		if *example {
-			runTTestBechmarks(mpc, "", numParties, networkLatency*time.Millisecond, *debug, *writeToFile, *runId, *example)
+			runs = append(runs, testRun{"T-Test", ""})
 		} else {
 			/* Student's t-test */
-			//runTTestBechmarks(mpc, filename_abalone, numParties, networkLatency*time.Millisecond, *debug, *writeToFile, *runId, *example)
-			runTTestBechmarks(mpc, filename1000, numParties, networkLatency*time.Millisecond, *debug, *writeToFile, *runId, *example)
-			runTTestBechmarks(mpc, filename5000, numParties, networkLatency*time.Millisecond, *debug, *writeToFile, *runId, *example)
-			runTTestBechmarks(mpc, filename10000, numParties, networkLatency*time.Millisecond, *debug, *writeToFile, *runId, *example)
+			//runs = append(runs, testRun{"T-Test", filename_abalone})
+			runs = append(runs, testRun{"T-Test", filename1000})
+			runs = append(runs, testRun{"T-Test", filename5000})
+			runs = append(runs, testRun{"T-Test", filename10000})
 		}
 
 	}
@@ -108,14 +238,14 @@ func main() {
 	if *corrtest || allTests {
 
 		if *example {
-			runPearsonsBechmarks(mpc, "", numParties, networkLatency*time.Millisecond, *debug, *writeToFile, *runId, *example)
+			runs = append(runs, testRun{"Pearson", ""})
 		} else {
 
 			/* Pearson's correlation test */
-			runPearsonsBechmarks(mpc, filename_abalone, numParties, networkLatency*time.Millisecond, *debug, *writeToFile, *runId, *example)
-			runPearsonsBechmarks(mpc, filename1000, numParties, networkLatency*time.Millisecond, *debug, *writeToFile, *runId, *example)
-			runPearsonsBechmarks(mpc, filename5000, numParties, networkLatency*time.Millisecond, *debug, *writeToFile, *runId, *example)
-			runPearsonsBechmarks(mpc, filename10000, numParties, networkLatency*time.Millisecond, *debug, *writeToFile, *runId, *example)
+			runs = append(runs, testRun{"Pearson", filename_abalone})
+			runs = append(runs, testRun{"Pearson", filename1000})
+			runs = append(runs, testRun{"Pearson", filename5000})
+			runs = append(runs, testRun{"Pearson", filename10000})
 		}
 
 	}
@@ -123,25 +253,99 @@ func main() {
 	if *chisqtest || allTests {
 
 		if *example {
-			runChiSqBechmarks(mpc, "", numParties, networkLatency*time.Millisecond, *debug, *writeToFile, *runId, *example)
+			runs = append(runs, testRun{"Chi-Squared", ""})
 
 		} else {
-			runChiSqBechmarks(mpc, filenameChiSq_pittsburgh, numParties, networkLatency*time.Millisecond, *debug, *writeToFile, *runId, *example)
+			runs = append(runs, testRun{"Chi-Squared", filenameChiSq_pittsburgh})
 
 			/* Chi-squared test */
-			runChiSqBechmarks(mpc, filenameChiSq1000_5, numParties, networkLatency*time.Millisecond, *debug, *writeToFile, *runId, *example)
-			runChiSqBechmarks(mpc, filenameChiSq1000_10, numParties, networkLatency*time.Millisecond, *debug, *writeToFile, *runId, *example)
-			runChiSqBechmarks(mpc, filenameChiSq1000_20, numParties, networkLatency*time.Millisecond, *debug, *writeToFile, *runId, *example)
+			runs = append(runs, testRun{"Chi-Squared", filenameChiSq1000_5})
+			runs = append(runs, testRun{"Chi-Squared", filenameChiSq1000_10})
+			runs = append(runs, testRun{"Chi-Squared", filenameChiSq1000_20})
+
+			runs = append(runs, testRun{"Chi-Squared", filenameChiSq5000_5})
+			runs = append(runs, testRun{"Chi-Squared", filenameChiSq5000_10})
+			runs = append(runs, testRun{"Chi-Squared", filenameChiSq5000_20})
 
-			runChiSqBechmarks(mpc, filenameChiSq5000_5, numParties, networkLatency*time.Millisecond, *debug, *writeToFile, *runId, *example)
-			runChiSqBechmarks(mpc, filenameChiSq5000_10, numParties, networkLatency*time.Millisecond, *debug, *writeToFile, *runId, *example)
-			runChiSqBechmarks(mpc, filenameChiSq5000_20, numParties, networkLatency*time.Millisecond, *debug, *writeToFile, *runId, *example)
+			runs = append(runs, testRun{"Chi-Squared", filenameChiSq10000_5})
+			runs = append(runs, testRun{"Chi-Squared", filenameChiSq10000_10})
+			runs = append(runs, testRun{"Chi-Squared", filenameChiSq10000_20})
+		}
+	}
 
-			runChiSqBechmarks(mpc, filenameChiSq10000_5, numParties, networkLatency*time.Millisecond, *debug, *writeToFile, *runId, *example)
-			runChiSqBechmarks(mpc, filenameChiSq10000_10, numParties, networkLatency*time.Millisecond, *debug, *writeToFile, *runId, *example)
-			runChiSqBechmarks(mpc, filenameChiSq10000_20, numParties, networkLatency*time.Millisecond, *debug, *writeToFile, *runId, *example)
+	// with -prereg, every test is committed to before any data is uploaded
+	specs := make(map[testRun]*party.Spec)
+	if *prereg {
+		budgets := make(map[string]int)
+		for _, run := range runs {
+			budgets[run.dataset()]++
+		}
+		for _, run := range runs {
+			if *budget > 0 {
+				budgets[run.dataset()] = *budget
+			}
+			specs[run] = run.spec(*alpha, *sides, *correction, budgets[run.dataset()], *window, bounds.fixedPoint(mpc.FPPrecBits))
+			err = mpc.Preregister(party.SignSpec(specs[run], analyst))
+			if err != nil {
+				panic(err)
+			}
 		}
 	}
+
+	for _, run := range runs {
+		switch run.test {
+		case "T-Test":
+			runTTestBechmarks(mpc, run.filename, run.dataset(), numParties, networkLatency*time.Millisecond, *debug, *writeToFile, *runId, *example, *proofs, bounds, *certify, specs[run])
+		case "Pearson":
+			runPearsonsBechmarks(mpc, run.filename, run.dataset(), numParties, networkLatency*time.Millisecond, *debug, *writeToFile, *runId, *example, *proofs, bounds, *certify, specs[run])
+		case "Chi-Squared":
+			runChiSqBechmarks(mpc, run.filename, run.dataset(), numParties, networkLatency*time.Millisecond, *debug, *writeToFile, *runId, *example, *proofs, *certify, specs[run])
+		}
+	}
+
+	if accused := mpc.Accused(); len(accused) > 0 {
+		fmt.Printf("Parties excluded for invalid proofs: %v\n", accused)
+	}
+}
+
+// testRun is a test to run on a dataset, the example one if filename is empty
+type testRun struct {
+	test     string
+	filename string
+}
+
+// dataset returns the name of the dataset of the test
+func (run testRun) dataset() string {
+	switch {
+	case run.filename != "":
+		return filepath.Base(run.filename)
+	case run.test == "Chi-Squared":
+		return "example categorical"
+	}
+	return "example"
+}
+
+// spec returns the spec of the test for preregistration, which holds the
+// values of a numerical dataset to the interval
+func (run testRun) spec(alpha float64, sides int, correction string, budget int, window time.Duration, in *party.Interval) *party.Spec {
+
+	spec := &party.Spec{
+		Test:       run.test,
+		Dataset:    run.dataset(),
+		Alpha:      alpha,
+		Sides:      sides,
+		Correction: correction,
+		Budget:     budget,
+		Window:     window,
+	}
+
+	// the chi-squared test uses every category
+	if run.test != "Chi-Squared" {
+		spec.Columns = []int{0, 1}
+		spec.Interval = in
+	}
+
+	return spec
 }
 
 func printWelcome() {
@@ -0,0 +1,611 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"custodes"
+	"custodes/party"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/sachaservan/paillier"
+)
+
+// PartyKeyFile is everything a single custodian needs to serve its key share
+type PartyKeyFile struct {
+	ID         int
+	Sk         *paillier.ThresholdPrivateKey // nil until distkeygen has run
+	P          *big.Int                      // secret share prime modulus
+	BetaT      *big.Int
+	BetaN      *big.Int
+	Threshold  int
+	NumParties int
+	Identity   *party.Identity
+}
+
+// PublicParamsFile is everything a coordinator needs to drive the custodians
+type PublicParamsFile struct {
+	Tk      *paillier.ThresholdKey // nil until distkeygen has run
+	P       *big.Int
+	KeyGenP *big.Int         // share modulus of distributed key generation, nil once the key exists
+	VSS     *party.VSSParams // nil unless shares are dealt verifiably
+	Params  *custodes.MPCKeyGenParams
+	Roster  party.Roster // certificates of the coordinator and all parties
+}
+
+// runKeyGen generates a fresh system and writes one key file per party,
+// the identities of the coordinator and the analyst and the public
+// parameters. With -distributed
+// the key files hold no key share, and the parties generate the key among
+// themselves once their daemons are up, see runDistKeyGen
+func runKeyGen(args []string) {
+
+	fs := flag.NewFlagSet("keygen", flag.ExitOnError)
+	dir := fs.String("dir", "keys", "directory in which to write the key files.")
+	numParties := fs.Int("parties", 3, "integer number of parties >= 3.")
+	threshold := fs.Int("threshold", 2, "integer number of threshold >= 2.")
+	keyBits := fs.Int("keybits", 512, "Paillier modulus size in bits.")
+	vss := fs.Bool("vss", false, "commit to every dealt polynomial so that parties check their shares on receipt.")
+	distributed := fs.Bool("distributed", false, "leave the key to the party daemons, which generate it among themselves with distkeygen.")
+	intervalCmd := fs.String("interval", "-1000,1000", "interval lo,hi the parties hold the values of the numerical datasets to, without -prereg.")
+	fs.Parse(args)
+
+	if *numParties < 2**threshold-1 {
+		panic("Threshold is too high compared to the number of parties!")
+	}
+
+	bounds, err := parseInterval(*intervalCmd)
+	if err != nil {
+		panic(err)
+	}
+
+	params := &custodes.MPCKeyGenParams{
+		NumParties:      *numParties,
+		Threshold:       *threshold,
+		KeyBits:         *keyBits,
+		MessageBits:     100,
+		SecurityBits:    40,
+		FPPrecisionBits: 30,
+		VSS:             *vss}
+	params.Interval = bounds.fixedPoint(params.FPPrecisionBits)
+
+	if *distributed {
+		err := writeKeylessSystem(*dir, params)
+		if err == nil {
+			err = writeAnalyst(*dir)
+		}
+		if err != nil {
+			panic(err)
+		}
+		fmt.Printf("Wrote %d party key files without key shares, coordinator.json, analyst.json, analystkey.json and public.json to %s; start the party daemons and run distkeygen\n", *numParties, *dir)
+		return
+	}
+
+	fmt.Print("System setup in progress...")
+	mpc, err := custodes.NewMPCKeyGen(params)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println("done.")
+
+	err = os.MkdirAll(*dir, 0700)
+	if err != nil {
+		panic(err)
+	}
+
+	for i := 0; i < *numParties; i++ {
+		p := mpc.Parties[i].(*party.Party)
+		kf := &PartyKeyFile{
+			ID:         p.ID,
+			Sk:         p.Sk,
+			P:          p.P,
+			BetaT:      p.BetaT,
+			BetaN:      p.BetaN,
+			Threshold:  p.Threshold,
+			NumParties: *numParties,
+			Identity:   p.Identity,
+		}
+
+		err = writeJSONFile(filepath.Join(*dir, "party"+strconv.Itoa(i)+".json"), kf, 0600)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	err = writeJSONFile(filepath.Join(*dir, "coordinator.json"), mpc.Identity, 0600)
+	if err != nil {
+		panic(err)
+	}
+
+	err = writeAnalyst(*dir)
+	if err != nil {
+		panic(err)
+	}
+
+	pub := &PublicParamsFile{Tk: mpc.Tk, P: mpc.P, VSS: mpc.Party.VSS, Params: params, Roster: mpc.Roster}
+	err = writeJSONFile(filepath.Join(*dir, "public.json"), pub, 0644)
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Printf("Wrote %d party key files, coordinator.json, analyst.json, analystkey.json and public.json to %s\n", *numParties, *dir)
+}
+
+// writeAnalyst writes a fresh identity for the analyst, who signs the
+// specs of preregistered tests, to analyst.json and its public key, which
+// the party daemons check the specs against, to analystkey.json
+func writeAnalyst(dir string) error {
+
+	analyst, err := party.NewIdentity(party.AnalystID)
+	if err != nil {
+		return err
+	}
+
+	err = writeJSONFile(filepath.Join(dir, "analyst.json"), analyst, 0600)
+	if err != nil {
+		return err
+	}
+
+	return writeJSONFile(filepath.Join(dir, "analystkey.json"), analyst.Key.Public(), 0644)
+}
+
+// readAnalystKey reads the public key of the analyst from a file written
+// by writeAnalyst
+func readAnalystKey(filename string) (ed25519.PublicKey, error) {
+
+	var key ed25519.PublicKey
+	err := readJSONFile(filename, &key)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != ed25519.PublicKeySize {
+		return nil, errors.New(filename + " holds no analyst key")
+	}
+
+	return key, nil
+}
+
+// writeKeylessSystem writes the key files, the coordinator's identity and
+// the public parameters of a system whose Paillier key the parties are to
+// generate among themselves, over a share modulus of KeyGenModulusBits
+func writeKeylessSystem(dir string, params *custodes.MPCKeyGenParams) error {
+
+	params.DistributedKeyGen = true
+
+	p, err := rand.Prime(rand.Reader, params.KeyBits)
+	if err != nil {
+		return err
+	}
+	keyGenP, err := rand.Prime(rand.Reader, custodes.KeyGenModulusBits(params))
+	if err != nil {
+		return err
+	}
+
+	var vss *party.VSSParams
+	if params.VSS {
+		vss, err = party.NewVSSParams(p)
+		if err != nil {
+			return err
+		}
+	}
+
+	identities := make([]*party.Identity, params.NumParties+1)
+	for i := range identities {
+		identities[i], err = party.NewIdentity(i - 1)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = os.MkdirAll(dir, 0700)
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < params.NumParties; i++ {
+		kf := &PartyKeyFile{
+			ID:         i,
+			P:          p,
+			BetaT:      party.LagrangeCoefficient(i, partyIDs(params.Threshold), p),
+			BetaN:      party.LagrangeCoefficient(i, partyIDs(params.NumParties), p),
+			Threshold:  params.Threshold,
+			NumParties: params.NumParties,
+			Identity:   identities[i+1],
+		}
+
+		err = writeJSONFile(filepath.Join(dir, "party"+strconv.Itoa(i)+".json"), kf, 0600)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = writeJSONFile(filepath.Join(dir, "coordinator.json"), identities[0], 0600)
+	if err != nil {
+		return err
+	}
+
+	pub := &PublicParamsFile{P: p, KeyGenP: keyGenP, VSS: vss, Params: params, Roster: party.NewRoster(identities...)}
+	return writeJSONFile(filepath.Join(dir, "public.json"), pub, 0644)
+}
+
+// runPartyDaemon loads a single party's key share and answers requests
+// from the coordinator and the other parties until killed
+func runPartyDaemon(args []string) {
+
+	fs := flag.NewFlagSet("party", flag.ExitOnError)
+	keyFile := fs.String("key", "", "path to this party's key file.")
+	publicFile := fs.String("public", "keys/public.json", "path to public.json written by keygen.")
+	listen := fs.String("listen", ":9000", "address to listen on.")
+	peers := fs.String("peers", "", "comma separated addresses of all parties, ordered by party id.")
+	topology := fs.String("topology", "", "path to a JSON network topology to emulate on links to peers.")
+	batching := fs.Bool("batch", false, "coalesce concurrent requests to each peer into one message.")
+	prereg := fs.Bool("prereg", false, "open values only for tests the analyst preregistered before uploading data.")
+	analystFile := fs.String("analyst", "", "path to analystkey.json written by keygen, which -prereg checks specs against; defaults to the directory of -public.")
+	fs.Parse(args)
+
+	kf := &PartyKeyFile{}
+	err := readJSONFile(*keyFile, kf)
+	if err != nil {
+		panic(err)
+	}
+
+	pub := &PublicParamsFile{}
+	err = readJSONFile(*publicFile, pub)
+	if err != nil {
+		panic(err)
+	}
+
+	if kf.Identity == nil || pub.Roster == nil {
+		panic("key files do not contain identities; rerun keygen")
+	}
+
+	addrs, err := parsePeers(*peers, kf.NumParties)
+	if err != nil {
+		panic(err)
+	}
+
+	p := &party.Party{
+		ID:           kf.ID,
+		Sk:           kf.Sk,
+		P:            kf.P,
+		BetaT:        kf.BetaT,
+		BetaN:        kf.BetaN,
+		Threshold:    kf.Threshold,
+		SecurityBits: pub.Params.SecurityBits,
+		VSS:          pub.VSS,
+		Identity:     kf.Identity,
+		Roster:       pub.Roster,
+	}
+
+	// without preregistration, uploaded values must lie in the interval
+	// fixed at keygen rather than in one the coordinator names
+	if in := pub.Params.Interval; in != nil {
+		p.Intervals = func(string) *party.Interval { return in }
+	}
+
+	keyless := kf.Sk == nil
+	if keyless {
+		if pub.KeyGenP == nil {
+			panic("key file holds no key share and public.json no key generation modulus; rerun keygen")
+		}
+		// until the key exists, shares live mod the key generation
+		// modulus, to which the VSS parameters do not apply
+		p.P, p.VSS = pub.KeyGenP, nil
+		p.BetaT = party.LagrangeCoefficient(kf.ID, partyIDs(kf.Threshold), p.P)
+		p.BetaN = party.LagrangeCoefficient(kf.ID, partyIDs(kf.NumParties), p.P)
+	} else {
+		p.Pk = &kf.Sk.PublicKey
+	}
+
+	if *prereg {
+		if *analystFile == "" {
+			*analystFile = filepath.Join(filepath.Dir(*publicFile), "analystkey.json")
+		}
+		p.Analyst, err = readAnalystKey(*analystFile)
+		if err != nil {
+			panic(err)
+		}
+
+		// the specs and ledgers must survive a restart, or a test could
+		// run again. They live next to the key file
+		preregFile := strings.TrimSuffix(*keyFile, filepath.Ext(*keyFile)) + ".prereg.json"
+		state := &party.PreregState{}
+		err = readJSONFile(preregFile, state)
+		switch {
+		case err == nil:
+			err = p.RestorePrereg(state)
+			if err != nil {
+				panic(err)
+			}
+			fmt.Printf("Party %d restored its preregistrations from %s\n", kf.ID, preregFile)
+		case !os.IsNotExist(err):
+			panic(err)
+		}
+		p.SavePrereg = func(state *party.PreregState) error {
+			return replaceJSONFile(preregFile, state, 0600)
+		}
+	}
+
+	// key generation and refreshes replace the key share, which must
+	// survive a restart. The key file holds this party's share only
+	p.SaveKey = func(sk *paillier.ThresholdPrivateKey) error {
+		next := *kf
+		next.Sk = sk
+		err := replaceJSONFile(*keyFile, &next, 0600)
+		if err == nil && keyless {
+			fmt.Printf("Party %d saved its key share to %s; restart it once distkeygen is done\n", kf.ID, *keyFile)
+		}
+		return err
+	}
+
+	topo, err := loadTopology(*topology)
+	if err != nil {
+		panic(err)
+	}
+
+	p.Parties = make([]party.Transport, kf.NumParties)
+	for i := 0; i < kf.NumParties; i++ {
+		if i == kf.ID {
+			p.Parties[i] = p
+		} else {
+			p.Parties[i] = dialParty(i, addrs[i], kf.Identity, pub.Roster, topo, *batching)
+		}
+	}
+
+	if keyless {
+		fmt.Printf("Party %d holds no key share, waiting for distkeygen\n", kf.ID)
+	}
+	fmt.Printf("Party %d listening on %s\n", kf.ID, *listen)
+	err = party.ListenAndServe(p, *listen, kf.Identity, pub.Roster)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// runRefresh drives the party daemons through a refresh epoch, after which
+// they hold fresh shares of the same decryption key, and writes the new
+// verification keys to public.json
+func runRefresh(args []string) {
+
+	fs := flag.NewFlagSet("refresh", flag.ExitOnError)
+	publicFile := fs.String("public", "keys/public.json", "path to public.json written by keygen.")
+	identity := fs.String("identity", "", "path to coordinator.json written by keygen; defaults to the directory of -public.")
+	peers := fs.String("peers", "", "comma separated addresses of the party daemons, ordered by party id.")
+	batching := fs.Bool("batch", false, "coalesce the concurrent requests of each round into one message per party.")
+	fs.Parse(args)
+
+	identityFile := *identity
+	if identityFile == "" {
+		identityFile = filepath.Join(filepath.Dir(*publicFile), "coordinator.json")
+	}
+
+	mpc, err := newRemoteMPC(*publicFile, identityFile, *peers, nil, *batching)
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Print("Refreshing key shares...")
+	err = mpc.Refresh()
+	if err != nil {
+		panic(err)
+	}
+	_, err = mpc.DeleteAllShares()
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println("done.")
+
+	pub := &PublicParamsFile{}
+	err = readJSONFile(*publicFile, pub)
+	if err != nil {
+		panic(err)
+	}
+	pub.Tk = mpc.Tk
+
+	err = replaceJSONFile(*publicFile, pub, 0644)
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Printf("Wrote the new verification keys to %s; copy it to every custodian\n", *publicFile)
+}
+
+// runDistKeyGen drives the party daemons of a system written by keygen
+// -distributed through distributed key generation. Every daemon keeps its
+// own key share, and the public key goes to public.json
+func runDistKeyGen(args []string) {
+
+	fs := flag.NewFlagSet("distkeygen", flag.ExitOnError)
+	publicFile := fs.String("public", "keys/public.json", "path to public.json written by keygen -distributed.")
+	identity := fs.String("identity", "", "path to coordinator.json written by keygen; defaults to the directory of -public.")
+	peers := fs.String("peers", "", "comma separated addresses of the party daemons, ordered by party id.")
+	batching := fs.Bool("batch", false, "coalesce the concurrent requests of each round into one message per party.")
+	fs.Parse(args)
+
+	identityFile := *identity
+	if identityFile == "" {
+		identityFile = filepath.Join(filepath.Dir(*publicFile), "coordinator.json")
+	}
+
+	pub := &PublicParamsFile{}
+	err := readJSONFile(*publicFile, pub)
+	if err != nil {
+		panic(err)
+	}
+	if pub.Tk != nil || pub.KeyGenP == nil {
+		panic("public.json already holds a key; keygen -distributed writes one without")
+	}
+
+	id := &party.Identity{}
+	err = readJSONFile(identityFile, id)
+	if err != nil {
+		panic(err)
+	}
+
+	addrs, err := parsePeers(*peers, pub.Params.NumParties)
+	if err != nil {
+		panic(err)
+	}
+
+	parties := make([]party.Transport, pub.Params.NumParties)
+	for i := range parties {
+		parties[i] = dialParty(i, addrs[i], id, pub.Roster, nil, *batching)
+	}
+
+	// the coordinator deals shares of public values only
+	dealer := &party.Party{
+		ID:        0,
+		P:         pub.KeyGenP,
+		Threshold: pub.Params.Threshold,
+		Parties:   parties,
+	}
+
+	mpc := custodes.NewKeyGenMPC(dealer, parties, pub.Params)
+	mpc.Identity = id
+	mpc.Roster = pub.Roster
+
+	fmt.Print("Generating the key among the parties...")
+	tk, err := mpc.DistributedKeyGen(pub.Params.KeyBits)
+	if err != nil {
+		panic(err)
+	}
+	_, err = mpc.DeleteAllShares()
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println("done.")
+
+	pub.Tk = tk
+	pub.KeyGenP = nil
+	err = replaceJSONFile(*publicFile, pub, 0644)
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Printf("Wrote the public key to %s; copy it to every custodian and restart the party daemons\n", *publicFile)
+}
+
+// newRemoteMPC returns an MPC instance that drives party daemons using
+// the public parameters and coordinator identity written by keygen
+func newRemoteMPC(publicFile, identityFile, peers string, topo *party.Topology, batching bool) (*custodes.MPC, error) {
+
+	pub := &PublicParamsFile{}
+	err := readJSONFile(publicFile, pub)
+	if err != nil {
+		return nil, err
+	}
+	if pub.Tk == nil {
+		return nil, errors.New(publicFile + " holds no key yet; run distkeygen first")
+	}
+
+	id := &party.Identity{}
+	err = readJSONFile(identityFile, id)
+	if err != nil {
+		return nil, err
+	}
+
+	addrs, err := parsePeers(peers, pub.Params.NumParties)
+	if err != nil {
+		return nil, err
+	}
+
+	parties := make([]party.Transport, pub.Params.NumParties)
+	for i := 0; i < pub.Params.NumParties; i++ {
+		parties[i] = dialParty(i, addrs[i], id, pub.Roster, topo, batching)
+	}
+
+	// the coordinator deals shares of public values only
+	dealer := &party.Party{
+		ID:        0,
+		Pk:        &pub.Tk.PublicKey,
+		P:         pub.P,
+		Threshold: pub.Params.Threshold,
+		VSS:       pub.VSS,
+		Parties:   parties,
+	}
+
+	mpc := custodes.NewMPC(dealer, parties, pub.Tk, pub.P, pub.Params)
+	mpc.Identity = id
+	mpc.Roster = pub.Roster
+
+	return mpc, nil
+}
+
+// dialParty returns the transport from self to party id at addr,
+// over the emulated network if a topology is given
+func dialParty(id int, addr string, self *party.Identity, roster party.Roster, topo *party.Topology, batching bool) party.Transport {
+
+	var t party.Transport = party.NewRemoteParty(id, addr, self, roster)
+	if topo != nil {
+		t = party.NewEmulatedTransport(t, topo, self.ID, id)
+	}
+	if batching {
+		t = party.NewBatcher(t, party.DefaultBatchWindow)
+	}
+
+	return t
+}
+
+// loadTopology returns the topology in filename, or nil if no file is given
+func loadTopology(filename string) (*party.Topology, error) {
+	if filename == "" {
+		return nil, nil
+	}
+
+	return party.LoadTopology(filename)
+}
+
+// partyIDs returns the ids of the first n parties
+func partyIDs(n int) []int {
+	ids := make([]int, n)
+	for i := range ids {
+		ids[i] = i
+	}
+	return ids
+}
+
+func parsePeers(peers string, numParties int) ([]string, error) {
+	addrs := strings.Split(peers, ",")
+	if len(addrs) != numParties {
+		return nil, errors.New("expected " + strconv.Itoa(numParties) + " peer addresses")
+	}
+
+	return addrs, nil
+}
+
+func writeJSONFile(filename string, v interface{}, perm os.FileMode) error {
+	data, err := json.MarshalIndent(v, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filename, data, perm)
+}
+
+// replaceJSONFile writes v to filename through a temporary file, so that a
+// crash leaves either the old contents or the new ones
+func replaceJSONFile(filename string, v interface{}, perm os.FileMode) error {
+	err := writeJSONFile(filename+".new", v, perm)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(filename+".new", filename)
+}
+
+func readJSONFile(filename string, v interface{}) error {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, v)
+}
@@ -19,6 +19,8 @@ func PearsonsTestSimulation(
 	eX := dataset.Data[0]
 	eY := dataset.Data[1]
 
+	mpc.ResetCommStats()
+	mpc.ResetTranscript()
 	startTime := time.Now()
 	invNumRows := big.NewFloat(1.0 / float64(dataset.NumRows))
 	invNumRowsEncoded := mpc.Pk.EncodeFixedPoint(invNumRows, mpc.FPPrecBits)
@@ -30,15 +32,15 @@ func PearsonsTestSimulation(
 	meanXTmp := mpc.Pk.ECMult(sumX, invNumRowsEncoded)
 	meanYTmp := mpc.Pk.ECMult(sumY, invNumRowsEncoded)
 
-	meanX := mpc.ETruncPR(meanXTmp, mpc.K, mpc.FPPrecBits)
-	meanY := mpc.ETruncPR(meanYTmp, mpc.K, mpc.FPPrecBits)
+	meanX := mpc.MustETruncPR(meanXTmp, mpc.K, mpc.FPPrecBits)
+	meanY := mpc.MustETruncPR(meanYTmp, mpc.K, mpc.FPPrecBits)
 
 	if debug {
 		// sanity check
 		fmt.Printf("[DEBUG] MEAN X: %s\n",
-			mpc.RevealFP(meanX, mpc.FPPrecBits).String())
+			mpc.MustRevealFP(meanX, mpc.FPPrecBits).String())
 		fmt.Printf("[DEBUG] MEAN Y: %s\n",
-			mpc.RevealFP(meanY, mpc.FPPrecBits).String())
+			mpc.MustRevealFP(meanY, mpc.FPPrecBits).String())
 	}
 
 	// compute (x_i - mean_x)(y_i - mean_y)
@@ -58,9 +60,9 @@ func PearsonsTestSimulation(
 			defer wg.Done()
 			devX := mpc.Pk.ESub(eX[i], meanX)
 			devY := mpc.Pk.ESub(eY[i], meanY)
-			devsX2[i] = mpc.EMult(devX, devX)
-			devsY2[i] = mpc.EMult(devY, devY)
-			prodsXY[i] = mpc.EMult(devX, devY)
+			devsX2[i] = mpc.MustEMult(devX, devX)
+			devsY2[i] = mpc.MustEMult(devY, devY)
+			prodsXY[i] = mpc.MustEMult(devX, devY)
 
 		}(i)
 	}
@@ -72,26 +74,26 @@ func PearsonsTestSimulation(
 
 	sumDevX2 := mpc.Pk.EAdd(devsX2...)
 	sumDevY2 := mpc.Pk.EAdd(devsY2...)
-	sumDevX2 = mpc.ETruncPR(sumDevX2, 2*mpc.K, mpc.FPPrecBits)
-	sumDevY2 = mpc.ETruncPR(sumDevY2, 2*mpc.K, mpc.FPPrecBits)
+	sumDevX2 = mpc.MustETruncPR(sumDevX2, 2*mpc.K, mpc.FPPrecBits)
+	sumDevY2 = mpc.MustETruncPR(sumDevY2, 2*mpc.K, mpc.FPPrecBits)
 
 	// compute the numerator = [sum for all i (x_i - mean_x)(y_i - mean_y)]
 	numerator := sumXY
 
-	denominatorTmp := mpc.EMult(sumDevX2, sumDevY2)
-	denominator := mpc.ETruncPR(denominatorTmp, 2*mpc.K, mpc.FPPrecBits)
+	denominatorTmp := mpc.MustEMult(sumDevX2, sumDevY2)
+	denominator := mpc.MustETruncPR(denominatorTmp, 2*mpc.K, mpc.FPPrecBits)
 
 	if debug {
 		// sanity check
 		fmt.Printf("[DEBUG] NUMERATOR:   %s\n",
-			mpc.RevealFP(numerator, mpc.FPPrecBits).String())
+			mpc.MustRevealFP(numerator, mpc.FPPrecBits).String())
 		fmt.Printf("[DEBUG] DENOMINATOR: %s\n",
-			mpc.RevealFP(denominator, mpc.FPPrecBits).String())
+			mpc.MustRevealFP(denominator, mpc.FPPrecBits).String())
 	}
 
 	// convert to shares
-	numeratorShare := mpc.PaillierToShare(numerator)
-	denominatorShare := mpc.PaillierToShare(denominator)
+	numeratorShare := mpc.MustPaillierToShare(numerator)
+	denominatorShare := mpc.MustPaillierToShare(denominator)
 
 	// done with paillier computations
 	endTimePaillier := time.Now()
@@ -101,37 +103,39 @@ func PearsonsTestSimulation(
 	if debug {
 		// sanity check
 		fmt.Printf("[DEBUG] NUMERATOR (Share):   %s\n",
-			mpc.RevealShareFP(numeratorShare, mpc.FPPrecBits).String())
+			mpc.MustRevealShareFP(numeratorShare, mpc.FPPrecBits).String())
 		fmt.Printf("[DEBUG] DENOMINATOR (Share): %s\n",
-			mpc.RevealShareFP(denominatorShare, mpc.FPPrecBits).String())
+			mpc.MustRevealShareFP(denominatorShare, mpc.FPPrecBits).String())
 	}
 
-	signbit := mpc.SignBit(numeratorShare)
+	signbit := mpc.MustSignBit(numeratorShare)
 
 	// reveal the sign bit since it's made public at the end regardless
-	isNegative := mpc.RevealShare(signbit).Int64()
+	isNegative := mpc.MustRevealShare(signbit).Int64()
 
 	endTimeSign := time.Now()
 
-	rcpr := mpc.FPSqrtReciprocal(denominatorShare)
+	rcpr := mpc.MustFPSqrtReciprocal(denominatorShare)
 
 	if isNegative == 1 {
-		numeratorShare = mpc.MultC(numeratorShare, new(big.Int).Sub(mpc.P, big.NewInt(1)))
+		numeratorShare = mpc.MustMultC(numeratorShare, new(big.Int).Sub(mpc.P, big.NewInt(1)))
 		if debug {
 			fmt.Printf("[DEBUG] NUMERATOR (abs): %s\n",
-				mpc.RevealShare(numeratorShare).String())
+				mpc.MustRevealShare(numeratorShare).String())
 		}
 	}
-	numeratorShare = mpc.TruncPR(numeratorShare, 2*mpc.K, mpc.FPPrecBits)
+	numeratorShare = mpc.MustTruncPR(numeratorShare, 2*mpc.K, mpc.FPPrecBits)
 	precAdjust := big.NewInt(0).Exp(big.NewInt(2), big.NewInt(int64(mpc.K/2-mpc.FPPrecBits)), nil)
-	numeratorShare = mpc.MultC(numeratorShare, precAdjust)
+	numeratorShare = mpc.MustMultC(numeratorShare, precAdjust)
 
-	res := mpc.Mult(numeratorShare, rcpr)
-	rstat := mpc.RevealShareFP(res, mpc.K)
+	res := mpc.MustMult(numeratorShare, rcpr)
 
+	// restore the sign before the statistic is opened, so that the
+	// parties see the value they certify
 	if isNegative == 1 {
-		rstat.Mul(rstat, big.NewFloat(-1))
+		res = mpc.MustMultC(res, new(big.Int).Sub(mpc.P, big.NewInt(1)))
 	}
+	rstat := mpc.MustRevealStatistic(res, mpc.K)
 
 	endTime := time.Now()
 
@@ -145,6 +149,8 @@ func PearsonsTestSimulation(
 	divTime := time.Now().Sub(endTimeSign)
 	paillierTime := endTimePaillier.Sub(startTime)
 
+	comm := mpc.CommStats()
+
 	return &TestResult{
 		Test:                  "PEARSON",
 		Value:                 rstat,
@@ -152,6 +158,8 @@ func PearsonsTestSimulation(
 		ComputeRuntime:        paillierTime,
 		SignExtractionRuntime: signExtractionTime,
 		DivRuntime:            divTime,
-		NumSharesCreated:      mpc.DeleteAllShares(),
+		NumSharesCreated:      mpc.MustDeleteAllShares(),
+		Comm:                  comm,
+		Transcript:            mpc.Transcript,
 	}
 }
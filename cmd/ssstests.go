@@ -35,7 +35,7 @@ func ChiSquaredSecretSharingSimulation(mpc *custodes.MPC, filepath string, debug
 		eX[i] = make([]*party.Share, numCategories)
 		for j := 0; j < numCategories; j++ {
 			pt := mpc.Pk.EncodeFixedPoint(big.NewFloat(float64(x[i][j])), mpc.FPPrecBits)
-			eX[i][j] = mpc.CreateShares(pt)
+			eX[i][j] = mpc.MustCreateShares(pt)
 		}
 	}
 
@@ -57,14 +57,14 @@ func ChiSquaredSecretSharingSimulation(mpc *custodes.MPC, filepath string, debug
 	startTime := time.Now()
 
 	// encryption of zero for init value
-	e0 := mpc.CreateShares(big.NewInt(0))
+	e0 := mpc.MustCreateShares(big.NewInt(0))
 
 	// compute encrypted histogram
 	h := make([]*party.Share, numCategories)
 	for i := 0; i < numCategories; i++ {
 		categorySum := e0
 		for j := 0; j < numRows; j++ {
-			categorySum = mpc.Add(categorySum, eX[j][i])
+			categorySum = mpc.MustAdd(categorySum, eX[j][i])
 		}
 		h[i] = categorySum
 	}
@@ -78,7 +78,7 @@ func ChiSquaredSecretSharingSimulation(mpc *custodes.MPC, filepath string, debug
 	// compute the expected value
 	sumTotal := e0
 	for i := 0; i < numCategories; i++ {
-		sumTotal = mpc.Add(sumTotal, h[i])
+		sumTotal = mpc.MustAdd(sumTotal, h[i])
 	}
 
 	expectedValues := make([]*party.Share, numCategories)
@@ -86,8 +86,8 @@ func ChiSquaredSecretSharingSimulation(mpc *custodes.MPC, filepath string, debug
 		wg.Add(1)
 		go func(i int) {
 			defer wg.Done()
-			expected := mpc.MultC(sumTotal, mpc.EncodeFixedPoint(expectedPercentage[i], mpc.FPPrecBits))
-			expectedValues[i] = mpc.TruncPR(expected, 2*mpc.K, mpc.FPPrecBits)
+			expected := mpc.MustMultC(sumTotal, mpc.EncodeFixedPoint(expectedPercentage[i], mpc.FPPrecBits))
+			expectedValues[i] = mpc.MustTruncPR(expected, 2*mpc.K, mpc.FPPrecBits)
 		}(i)
 	}
 	wg.Wait()
@@ -98,8 +98,8 @@ func ChiSquaredSecretSharingSimulation(mpc *custodes.MPC, filepath string, debug
 		wg.Add(1)
 		go func(i int) {
 			defer wg.Done()
-			diff := mpc.Sub(h[i], expectedValues[i])
-			residual[i] = mpc.Mult(diff, diff)
+			diff := mpc.MustSub(h[i], expectedValues[i])
+			residual[i] = mpc.MustMult(diff, diff)
 		}(i)
 	}
 	wg.Wait()
@@ -112,18 +112,18 @@ func ChiSquaredSecretSharingSimulation(mpc *custodes.MPC, filepath string, debug
 		wg.Add(1)
 		go func(i int) {
 			defer wg.Done()
-			xi[i] = mpc.FPDivision(residual[i], expectedValues[i])
+			xi[i] = mpc.MustFPDivision(residual[i], expectedValues[i])
 		}(i)
 	}
 	wg.Wait()
 
-	chi2 := mpc.CreateShares(big.NewInt(0))
+	chi2 := mpc.MustCreateShares(big.NewInt(0))
 	for i := 0; i < numCategories; i++ {
-		chi2 = mpc.Add(chi2, xi[i])
+		chi2 = mpc.MustAdd(chi2, xi[i])
 	}
 
-	chi2 = mpc.TruncPR(chi2, 2*mpc.K, mpc.FPPrecBits)
-	chi2Stat := mpc.RevealShareFP(chi2, mpc.FPPrecBits)
+	chi2 = mpc.MustTruncPR(chi2, 2*mpc.K, mpc.FPPrecBits)
+	chi2Stat := mpc.MustRevealShareFP(chi2, mpc.FPPrecBits)
 
 	endTime := time.Now()
 	totalTime := endTime.Sub(startTime)
@@ -135,7 +135,7 @@ func ChiSquaredSecretSharingSimulation(mpc *custodes.MPC, filepath string, debug
 		log.Println("[DEBUG] RUNTIME: " + endTime.Sub(startTime).String())
 	}
 
-	return chi2Stat, numRows, numCategories, dealerSetupTime, totalTime, paillierTime, divTime, mpc.DeleteAllShares()
+	return chi2Stat, numRows, numCategories, dealerSetupTime, totalTime, paillierTime, divTime, mpc.MustDeleteAllShares()
 }
 
 func TTestSecretSharingSimulation(mpc *custodes.MPC, filepath string, debug bool) (*big.Float, int, time.Duration, time.Duration, time.Duration, time.Duration, int) {
@@ -168,8 +168,8 @@ func TTestSecretSharingSimulation(mpc *custodes.MPC, filepath string, debug bool
 	for i := 0; i < numRows; i++ {
 		plaintextX := mpc.Pk.EncodeFixedPoint(big.NewFloat(x[i]), mpc.FPPrecBits)
 		plaintextY := mpc.Pk.EncodeFixedPoint(big.NewFloat(y[i]), mpc.FPPrecBits)
-		eX[i] = mpc.CreateShares(plaintextX)
-		eY[i] = mpc.CreateShares(plaintextY)
+		eX[i] = mpc.MustCreateShares(plaintextX)
+		eY[i] = mpc.MustCreateShares(plaintextY)
 	}
 
 	dealerSetupTime := time.Now().Sub(dealerSetupStart)
@@ -191,25 +191,25 @@ func TTestSecretSharingSimulation(mpc *custodes.MPC, filepath string, debug bool
 	invNumRows := mpc.Pk.EncodeFixedPoint(big.NewFloat(1.0/float64(numRows)), mpc.FPPrecBits)
 
 	// an encryption of zero to be used as initial value
-	enc0 := mpc.CreateShares(mpc.Pk.EncodeFixedPoint(big.NewFloat(0.0), mpc.FPPrecBits))
+	enc0 := mpc.MustCreateShares(mpc.Pk.EncodeFixedPoint(big.NewFloat(0.0), mpc.FPPrecBits))
 
 	// sum of the squares
 	sumX := enc0
 	sumY := enc0
 
 	for i := 0; i < numRows; i++ {
-		sumX = mpc.Add(sumX, eX[i])
-		sumY = mpc.Add(sumY, eY[i])
+		sumX = mpc.MustAdd(sumX, eX[i])
+		sumY = mpc.MustAdd(sumY, eY[i])
 	}
-	meanX := mpc.MultC(sumX, invNumRows)
-	meanX = mpc.TruncPR(meanX, 2*mpc.K, mpc.FPPrecBits)
-	meanY := mpc.MultC(sumY, invNumRows)
-	meanY = mpc.TruncPR(meanY, 2*mpc.K, mpc.FPPrecBits)
+	meanX := mpc.MustMultC(sumX, invNumRows)
+	meanX = mpc.MustTruncPR(meanX, 2*mpc.K, mpc.FPPrecBits)
+	meanY := mpc.MustMultC(sumY, invNumRows)
+	meanY = mpc.MustTruncPR(meanY, 2*mpc.K, mpc.FPPrecBits)
 
 	if debug {
 		// sanity check
-		fmt.Printf("[DEBUG] MEAN X:   %s\n", mpc.RevealShareFP(meanX, mpc.FPPrecBits).String())
-		fmt.Printf("[DEBUG] MEAN Y:   %s\n", mpc.RevealShareFP(meanY, mpc.FPPrecBits).String())
+		fmt.Printf("[DEBUG] MEAN X:   %s\n", mpc.MustRevealShareFP(meanX, mpc.FPPrecBits).String())
+		fmt.Printf("[DEBUG] MEAN Y:   %s\n", mpc.MustRevealShareFP(meanY, mpc.FPPrecBits).String())
 	}
 
 	sumsSdX := make([]*party.Share, numRows)
@@ -220,10 +220,10 @@ func TTestSecretSharingSimulation(mpc *custodes.MPC, filepath string, debug bool
 		wg.Add(1)
 		go func(i int) {
 			defer wg.Done()
-			sdx := mpc.Sub(eX[i], meanX)
-			sdy := mpc.Sub(eY[i], meanY)
-			sumsSdX[i] = mpc.Mult(sdx, sdx)
-			sumsSdY[i] = mpc.Mult(sdy, sdy)
+			sdx := mpc.MustSub(eX[i], meanX)
+			sdy := mpc.MustSub(eY[i], meanY)
+			sumsSdX[i] = mpc.MustMult(sdx, sdx)
+			sumsSdY[i] = mpc.MustMult(sdy, sdy)
 		}(i)
 	}
 
@@ -233,41 +233,41 @@ func TTestSecretSharingSimulation(mpc *custodes.MPC, filepath string, debug bool
 	sdX := enc0
 	sdY := enc0
 	for i := 0; i < numRows; i++ {
-		sdX = mpc.Add(sdX, sumsSdX[i])
-		sdY = mpc.Add(sdY, sumsSdY[i])
+		sdX = mpc.MustAdd(sdX, sumsSdX[i])
+		sdY = mpc.MustAdd(sdY, sumsSdY[i])
 	}
 
-	sdX = mpc.TruncPR(sdX, 2*mpc.K, mpc.FPPrecBits)
-	sdY = mpc.TruncPR(sdY, 2*mpc.K, mpc.FPPrecBits)
-	sdX = mpc.MultC(sdX, mpc.EncodeFixedPoint(big.NewFloat(1.0/float64(numRows-1)), mpc.FPPrecBits))
-	sdX = mpc.TruncPR(sdX, 2*mpc.K, mpc.FPPrecBits)
-	sdY = mpc.MultC(sdY, mpc.EncodeFixedPoint(big.NewFloat(1.0/float64(numRows-1)), mpc.FPPrecBits))
-	sdY = mpc.TruncPR(sdY, 2*mpc.K, mpc.FPPrecBits)
+	sdX = mpc.MustTruncPR(sdX, 2*mpc.K, mpc.FPPrecBits)
+	sdY = mpc.MustTruncPR(sdY, 2*mpc.K, mpc.FPPrecBits)
+	sdX = mpc.MustMultC(sdX, mpc.EncodeFixedPoint(big.NewFloat(1.0/float64(numRows-1)), mpc.FPPrecBits))
+	sdX = mpc.MustTruncPR(sdX, 2*mpc.K, mpc.FPPrecBits)
+	sdY = mpc.MustMultC(sdY, mpc.EncodeFixedPoint(big.NewFloat(1.0/float64(numRows-1)), mpc.FPPrecBits))
+	sdY = mpc.MustTruncPR(sdY, 2*mpc.K, mpc.FPPrecBits)
 
-	numerator := mpc.Sub(meanX, meanY)
-	numerator = mpc.Mult(numerator, numerator)
-	numerator = mpc.TruncPR(numerator, 2*mpc.K, mpc.FPPrecBits)
+	numerator := mpc.MustSub(meanX, meanY)
+	numerator = mpc.MustMult(numerator, numerator)
+	numerator = mpc.MustTruncPR(numerator, 2*mpc.K, mpc.FPPrecBits)
 
-	tx := mpc.Sub(mpc.MultC(sdX, big.NewInt(int64(numRows))), sdX)
-	ty := mpc.Sub(mpc.MultC(sdY, big.NewInt(int64(numRows))), sdY)
+	tx := mpc.MustSub(mpc.MustMultC(sdX, big.NewInt(int64(numRows))), sdX)
+	ty := mpc.MustSub(mpc.MustMultC(sdY, big.NewInt(int64(numRows))), sdY)
 
-	denominator := mpc.Add(tx, ty)
+	denominator := mpc.MustAdd(tx, ty)
 
 	df := 1.0 / float64(numRows*numRows-numRows)
-	denominator = mpc.MultC(denominator, mpc.EncodeFixedPoint(big.NewFloat(df), mpc.FPPrecBits))
-	denominator = mpc.TruncPR(denominator, 2*mpc.K, mpc.FPPrecBits)
+	denominator = mpc.MustMultC(denominator, mpc.EncodeFixedPoint(big.NewFloat(df), mpc.FPPrecBits))
+	denominator = mpc.MustTruncPR(denominator, 2*mpc.K, mpc.FPPrecBits)
 
 	if debug {
 		// sanity check
-		fmt.Printf("[DEBUG] NUMERATOR: %s\n", mpc.RevealShare(numerator).String())
-		fmt.Printf("[DEBUG] DENOMINATOR: %s\n", mpc.RevealShare(denominator).String())
+		fmt.Printf("[DEBUG] NUMERATOR: %s\n", mpc.MustRevealShare(numerator).String())
+		fmt.Printf("[DEBUG] DENOMINATOR: %s\n", mpc.MustRevealShare(denominator).String())
 	}
 
 	endTimeComp := time.Now()
 
-	res := mpc.FPDivision(numerator, denominator)
+	res := mpc.MustFPDivision(numerator, denominator)
 
-	tstat2 := mpc.RevealShareFP(res, mpc.FPPrecBits)
+	tstat2 := mpc.MustRevealShareFP(res, mpc.FPPrecBits)
 	endTime := time.Now()
 
 	tstat := tstat2.Sqrt(tstat2)
@@ -281,7 +281,7 @@ func TTestSecretSharingSimulation(mpc *custodes.MPC, filepath string, debug bool
 	divTime := time.Now().Sub(endTimeComp)
 	compTime := endTimeComp.Sub(startTime)
 
-	numShares := mpc.DeleteAllShares()
+	numShares := mpc.MustDeleteAllShares()
 
 	return tstat, len(x), dealerSetupTime, totalTime, compTime, divTime, numShares
 }
@@ -317,8 +317,8 @@ func PearsonsTestSecretSharingSimulation(mpc *custodes.MPC, filepath string, deb
 	for i := 0; i < numRows; i++ {
 		plaintextX := mpc.Pk.EncodeFixedPoint(big.NewFloat(x[i]), mpc.FPPrecBits)
 		plaintextY := mpc.Pk.EncodeFixedPoint(big.NewFloat(y[i]), mpc.FPPrecBits)
-		eX[i] = mpc.CreateShares(plaintextX)
-		eY[i] = mpc.CreateShares(plaintextY)
+		eX[i] = mpc.MustCreateShares(plaintextX)
+		eY[i] = mpc.MustCreateShares(plaintextY)
 	}
 
 	dealerSetupTime := time.Now().Sub(dealerSetupStart)
@@ -336,26 +336,26 @@ func PearsonsTestSecretSharingSimulation(mpc *custodes.MPC, filepath string, deb
 	invNumRows := mpc.Pk.EncodeFixedPoint(big.NewFloat(1.0/float64(numRows)), mpc.FPPrecBits)
 
 	// an encryption of zero to be used as initial value
-	enc0 := mpc.CreateShares(mpc.Pk.EncodeFixedPoint(big.NewFloat(0.0), mpc.FPPrecBits))
+	enc0 := mpc.MustCreateShares(mpc.Pk.EncodeFixedPoint(big.NewFloat(0.0), mpc.FPPrecBits))
 
 	// sum of the squares
 	sumX := enc0
 	sumY := enc0
 
 	for i := 0; i < numRows; i++ {
-		sumX = mpc.Add(sumX, eX[i])
-		sumY = mpc.Add(sumY, eY[i])
+		sumX = mpc.MustAdd(sumX, eX[i])
+		sumY = mpc.MustAdd(sumY, eY[i])
 	}
 
-	meanX := mpc.MultC(sumX, invNumRows)
-	meanX = mpc.TruncPR(meanX, 2*mpc.K, mpc.FPPrecBits)
-	meanY := mpc.MultC(sumY, invNumRows)
-	meanY = mpc.TruncPR(meanY, 2*mpc.K, mpc.FPPrecBits)
+	meanX := mpc.MustMultC(sumX, invNumRows)
+	meanX = mpc.MustTruncPR(meanX, 2*mpc.K, mpc.FPPrecBits)
+	meanY := mpc.MustMultC(sumY, invNumRows)
+	meanY = mpc.MustTruncPR(meanY, 2*mpc.K, mpc.FPPrecBits)
 
 	if debug {
 		// sanity check
-		fmt.Printf("[DEBUG] MEAN X:   %s\n", mpc.RevealShareFP(meanX, mpc.FPPrecBits).String())
-		fmt.Printf("[DEBUG] MEAN Y:   %s\n", mpc.RevealShareFP(meanY, mpc.FPPrecBits).String())
+		fmt.Printf("[DEBUG] MEAN X:   %s\n", mpc.MustRevealShareFP(meanX, mpc.FPPrecBits).String())
+		fmt.Printf("[DEBUG] MEAN Y:   %s\n", mpc.MustRevealShareFP(meanY, mpc.FPPrecBits).String())
 	}
 
 	// compute (x_i - mean_x)(y_i - mean_y)
@@ -372,11 +372,11 @@ func PearsonsTestSecretSharingSimulation(mpc *custodes.MPC, filepath string, deb
 		wg.Add(1)
 		go func(i int) {
 			defer wg.Done()
-			devX := mpc.Sub(eX[i], meanX)
-			devY := mpc.Sub(eY[i], meanY)
-			devsX2[i] = mpc.Mult(devX, devX)
-			devsY2[i] = mpc.Mult(devY, devY)
-			prodsXY[i] = mpc.Mult(devX, devY)
+			devX := mpc.MustSub(eX[i], meanX)
+			devY := mpc.MustSub(eY[i], meanY)
+			devsX2[i] = mpc.MustMult(devX, devX)
+			devsY2[i] = mpc.MustMult(devY, devY)
+			prodsXY[i] = mpc.MustMult(devX, devY)
 		}(i)
 	}
 
@@ -388,26 +388,26 @@ func PearsonsTestSecretSharingSimulation(mpc *custodes.MPC, filepath string, deb
 	sumDevY2 := enc0
 
 	for i := 0; i < numRows; i++ {
-		sumXY = mpc.Add(sumXY, prodsXY[i])
-		sumDevX2 = mpc.Add(sumDevX2, devsX2[i])
-		sumDevY2 = mpc.Add(sumDevY2, devsY2[i])
+		sumXY = mpc.MustAdd(sumXY, prodsXY[i])
+		sumDevX2 = mpc.MustAdd(sumDevX2, devsX2[i])
+		sumDevY2 = mpc.MustAdd(sumDevY2, devsY2[i])
 	}
 
 	// adjust the prec after mult
-	sumXY = mpc.TruncPR(sumXY, 2*mpc.K, mpc.FPPrecBits)
-	sumDevX2 = mpc.TruncPR(sumDevX2, 2*mpc.K, mpc.FPPrecBits)
-	sumDevY2 = mpc.TruncPR(sumDevY2, 2*mpc.K, mpc.FPPrecBits)
+	sumXY = mpc.MustTruncPR(sumXY, 2*mpc.K, mpc.FPPrecBits)
+	sumDevX2 = mpc.MustTruncPR(sumDevX2, 2*mpc.K, mpc.FPPrecBits)
+	sumDevY2 = mpc.MustTruncPR(sumDevY2, 2*mpc.K, mpc.FPPrecBits)
 
 	// compute the numerator = [sum for all i (x_i - mean_x)(y_i - mean_y)]
 	numerator := sumXY
 
-	denominator := mpc.Mult(sumDevX2, sumDevY2)
-	denominator = mpc.TruncPR(denominator, 2*mpc.K, mpc.FPPrecBits)
+	denominator := mpc.MustMult(sumDevX2, sumDevY2)
+	denominator = mpc.MustTruncPR(denominator, 2*mpc.K, mpc.FPPrecBits)
 
 	if debug {
 		// sanity check
-		fmt.Printf("[DEBUG] NUMERATOR:   %s\n", mpc.RevealShare(numerator).String())
-		fmt.Printf("[DEBUG] DENOMINATOR: %s\n", mpc.RevealShare(denominator).String())
+		fmt.Printf("[DEBUG] NUMERATOR:   %s\n", mpc.MustRevealShare(numerator).String())
+		fmt.Printf("[DEBUG] DENOMINATOR: %s\n", mpc.MustRevealShare(denominator).String())
 	}
 
 	startCmpTime := time.Now()
@@ -416,28 +416,28 @@ func PearsonsTestSecretSharingSimulation(mpc *custodes.MPC, filepath string, deb
 	threshold := big.NewInt(0).Div(big.NewInt(0).Exp(big.NewInt(2), big.NewInt(int64(mpc.K)), nil), big.NewInt(2))
 
 	//extract the sign bit
-	numeratorBits := mpc.BitsDec(numerator, mpc.K)
-	sign := mpc.BitsLT(mpc.BitsBigEndian(threshold, mpc.P.BitLen()), numeratorBits)
+	numeratorBits := mpc.MustBitsDec(numerator, mpc.K)
+	sign := mpc.MustBitsLT(mpc.MustBitsBigEndian(threshold, mpc.P.BitLen()), numeratorBits)
 
 	endCmpTime := time.Now()
 
 	if debug {
 		// sanity check
-		fmt.Printf("[DEBUG] SIGN BIT (Share):    %s\n", mpc.RevealShare(sign).String())
+		fmt.Printf("[DEBUG] SIGN BIT (Share):    %s\n", mpc.MustRevealShare(sign).String())
 	}
 
 	// square the numerator
-	numerator = mpc.Mult(numerator, numerator)
-	numerator = mpc.TruncPR(numerator, 2*mpc.K, mpc.FPPrecBits)
+	numerator = mpc.MustMult(numerator, numerator)
+	numerator = mpc.MustTruncPR(numerator, 2*mpc.K, mpc.FPPrecBits)
 
 	// done with computations
 	endTimeComp := time.Now()
 
-	res := mpc.FPDivision(numerator, denominator)
+	res := mpc.MustFPDivision(numerator, denominator)
 
-	signBit := mpc.RevealShare(sign)
+	signBit := mpc.MustRevealShare(sign)
 
-	pstat2 := mpc.RevealShareFP(res, mpc.FPPrecBits)
+	pstat2 := mpc.MustRevealShareFP(res, mpc.FPPrecBits)
 	pstat := pstat2.Sqrt(pstat2)
 	pstat = big.NewFloat(0).Sub(pstat, big.NewFloat(0).Mul(big.NewFloat(2*float64(signBit.Int64())), pstat)) // pstat - 2*sign*pstat
 
@@ -454,7 +454,7 @@ func PearsonsTestSecretSharingSimulation(mpc *custodes.MPC, filepath string, deb
 	computeTime := endTimeComp.Sub(startTime)
 	computeTime = computeTime - cmpTime
 
-	numShares := mpc.DeleteAllShares()
+	numShares := mpc.MustDeleteAllShares()
 
 	return pstat, len(x), dealerSetupTime, totalTime, computeTime, divTime, numShares
 }
@@ -18,6 +18,8 @@ func TTestSimulation(
 	eX := dataset.Data[0]
 	eY := dataset.Data[1]
 
+	mpc.ResetCommStats()
+	mpc.ResetTranscript()
 	startTime := time.Now()
 	invNumRows := big.NewFloat(1.0 / float64(dataset.NumRows))
 	invNumRowsEncoded := mpc.Pk.EncodeFixedPoint(invNumRows, mpc.FPPrecBits)
@@ -29,13 +31,13 @@ func TTestSimulation(
 	meanXTmp := mpc.Pk.ECMult(sumX, invNumRowsEncoded)
 	meanYTmp := mpc.Pk.ECMult(sumY, invNumRowsEncoded)
 
-	meanX := mpc.ETruncPR(meanXTmp, 2*mpc.K, mpc.FPPrecBits)
-	meanY := mpc.ETruncPR(meanYTmp, 2*mpc.K, mpc.FPPrecBits)
+	meanX := mpc.MustETruncPR(meanXTmp, 2*mpc.K, mpc.FPPrecBits)
+	meanY := mpc.MustETruncPR(meanYTmp, 2*mpc.K, mpc.FPPrecBits)
 
 	if debug {
 		// sanity check
-		fmt.Printf("[DEBUG] MEAN X: %s\n", mpc.RevealFP(meanX, mpc.FPPrecBits).String())
-		fmt.Printf("[DEBUG] MEAN Y: %s\n", mpc.RevealFP(meanY, mpc.FPPrecBits).String())
+		fmt.Printf("[DEBUG] MEAN X: %s\n", mpc.MustRevealFP(meanX, mpc.FPPrecBits).String())
+		fmt.Printf("[DEBUG] MEAN Y: %s\n", mpc.MustRevealFP(meanY, mpc.FPPrecBits).String())
 	}
 
 	sumdX := make([]*paillier.Ciphertext, dataset.NumRows)
@@ -48,8 +50,8 @@ func TTestSimulation(
 			defer wg.Done()
 			dx := mpc.Pk.ESub(eX[i], meanX)
 			dy := mpc.Pk.ESub(eY[i], meanY)
-			sumdX[i] = mpc.EMult(dx, dx)
-			sumdY[i] = mpc.EMult(dy, dy)
+			sumdX[i] = mpc.MustEMult(dx, dx)
+			sumdY[i] = mpc.MustEMult(dy, dy)
 
 		}(i)
 	}
@@ -59,8 +61,8 @@ func TTestSimulation(
 	dXtmp := mpc.Pk.EAdd(sumdX...)
 	dYtmp := mpc.Pk.EAdd(sumdY...)
 
-	dX := mpc.ETruncPR(dXtmp, 2*mpc.K, mpc.FPPrecBits)
-	dY := mpc.ETruncPR(dYtmp, 2*mpc.K, mpc.FPPrecBits)
+	dX := mpc.MustETruncPR(dXtmp, 2*mpc.K, mpc.FPPrecBits)
+	dY := mpc.MustETruncPR(dYtmp, 2*mpc.K, mpc.FPPrecBits)
 
 	// compute numerator
 	numerator := mpc.Pk.ESub(meanX, meanY)
@@ -76,32 +78,31 @@ func TTestSimulation(
 		big.NewFloat(1.0/float64(dataset.NumRows)),
 		mpc.FPPrecBits)
 	denominator = mpc.Pk.ECMult(denominator, df1)
-	denominator = mpc.ETruncPR(denominator, 2*mpc.K, mpc.FPPrecBits)
+	denominator = mpc.MustETruncPR(denominator, 2*mpc.K, mpc.FPPrecBits)
 	denominator = mpc.Pk.ECMult(denominator, df2)
-	denominator = mpc.ETruncPR(denominator, 2*mpc.K, mpc.FPPrecBits)
+	denominator = mpc.MustETruncPR(denominator, 2*mpc.K, mpc.FPPrecBits)
 
 	if debug {
 		// sanity check
 		fmt.Printf("[DEBUG] NUMERATOR: %s\n",
-			mpc.RevealFP(numerator, mpc.FPPrecBits).String())
+			mpc.MustRevealFP(numerator, mpc.FPPrecBits).String())
 		fmt.Printf("[DEBUG] DENOMINATOR: %s\n",
-			mpc.RevealFP(denominator, mpc.FPPrecBits).String())
+			mpc.MustRevealFP(denominator, mpc.FPPrecBits).String())
 	}
 
 	// convert to shares for division
-	numeratorShare := mpc.PaillierToShare(numerator)
-	denominatorShare := mpc.PaillierToShare(denominator)
-
-	numerator = mpc.EMult(numerator, numerator)
-	fmt.Printf("[DEBUG] NUMERATOR SQ (abs): %s\n",
-		new(big.Int).Sqrt(mpc.RevealInt(numerator)).String())
+	numeratorShare := mpc.MustPaillierToShare(numerator)
+	denominatorShare := mpc.MustPaillierToShare(denominator)
 
 	if debug {
 		// sanity check
+		numeratorSq := mpc.MustEMult(numerator, numerator)
+		fmt.Printf("[DEBUG] NUMERATOR SQ (abs): %s\n",
+			new(big.Int).Sqrt(mpc.MustRevealInt(numeratorSq)).String())
 		fmt.Printf("[DEBUG] NUMERATOR (share): %s\n",
-			mpc.RevealShare(numeratorShare).String())
+			mpc.MustRevealShare(numeratorShare).String())
 		fmt.Printf("[DEBUG] DENOMINATOR (share): %s\n",
-			mpc.RevealShare(denominatorShare).String())
+			mpc.MustRevealShare(denominatorShare).String())
 	}
 
 	// end paillier benchmark
@@ -109,34 +110,35 @@ func TTestSimulation(
 
 	startTimeSign := time.Now()
 
-	signbit := mpc.SignBit(numeratorShare)
+	signbit := mpc.MustSignBit(numeratorShare)
 
 	// reveal the sign bit since it's made public at the end regardless
-	isNegative := mpc.RevealShare(signbit).Int64()
+	isNegative := mpc.MustRevealShare(signbit).Int64()
 
 	if isNegative == 1 {
-		numeratorShare = mpc.MultC(numeratorShare, new(big.Int).Sub(mpc.P, big.NewInt(1)))
+		numeratorShare = mpc.MustMultC(numeratorShare, new(big.Int).Sub(mpc.P, big.NewInt(1)))
 
 		if debug {
 			fmt.Printf("[DEBUG] NUMERATOR (abs): %s\n",
-				mpc.RevealShare(numeratorShare).String())
+				mpc.MustRevealShare(numeratorShare).String())
 		}
 	}
 
 	endTimeSign := time.Now()
 
-	rcpr := mpc.FPSqrtReciprocal(denominatorShare)
+	rcpr := mpc.MustFPSqrtReciprocal(denominatorShare)
 
 	precAdjust := big.NewInt(0).Exp(big.NewInt(2), big.NewInt(int64(mpc.K/2-mpc.FPPrecBits)), nil)
-	numeratorShare = mpc.MultC(numeratorShare, precAdjust)
-
-	res := mpc.Mult(numeratorShare, rcpr)
+	numeratorShare = mpc.MustMultC(numeratorShare, precAdjust)
 
-	tstat := mpc.RevealShareFP(res, mpc.K)
+	res := mpc.MustMult(numeratorShare, rcpr)
 
+	// restore the sign before the statistic is opened, so that the
+	// parties see the value they certify
 	if isNegative == 1 {
-		tstat.Mul(tstat, big.NewFloat(-1))
+		res = mpc.MustMultC(res, new(big.Int).Sub(mpc.P, big.NewInt(1)))
 	}
+	tstat := mpc.MustRevealStatistic(res, mpc.K)
 
 	// end division benchmark
 	endTime := time.Now()
@@ -152,6 +154,8 @@ func TTestSimulation(
 	divTime := time.Now().Sub(endTimeSign)
 	paillierTime := endTimePaillier.Sub(startTime)
 
+	comm := mpc.CommStats()
+
 	return &TestResult{
 		Test:                  "T-TEST",
 		Value:                 tstat,
@@ -159,6 +163,8 @@ func TTestSimulation(
 		ComputeRuntime:        paillierTime,
 		SignExtractionRuntime: signExtractionTime,
 		DivRuntime:            divTime,
-		NumSharesCreated:      mpc.DeleteAllShares(),
+		NumSharesCreated:      mpc.MustDeleteAllShares(),
+		Comm:                  comm,
+		Transcript:            mpc.Transcript,
 	}
 }
@@ -0,0 +1,138 @@
+package custodes
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"custodes/party"
+)
+
+// discardTimeout bounds the requests deleting the shares of a failed protocol
+const discardTimeout = 10 * time.Second
+
+// protocolError carries the failure of a request out of a protocol. The
+// protocols compose each other directly, so a failure unwinds the stack
+// to the exported method that started the protocol, which returns it
+type protocolError struct {
+	err error
+}
+
+// fail aborts the running protocol with err
+func fail(err error) {
+	panic(protocolError{err})
+}
+
+// shareLog records the ids of the shares created by a protocol
+type shareLog struct {
+	mu  sync.Mutex
+	ids []int
+}
+
+func (log *shareLog) add(id int) {
+	if log == nil {
+		return
+	}
+	log.mu.Lock()
+	log.ids = append(log.ids, id)
+	log.mu.Unlock()
+}
+
+// WithContext returns a copy of mpc whose protocols are abandoned once ctx is done
+func (mpc *MPC) WithContext(ctx context.Context) *MPC {
+	if ctx == nil {
+		panic("nil context")
+	}
+	cpy := *mpc
+	cpy.ctx = ctx
+	return &cpy
+}
+
+// Context returns the context the protocols of mpc run under
+func (mpc *MPC) Context() context.Context {
+	return mpc.ctx
+}
+
+// run returns a copy of mpc that runs a protocol under ctx and
+// records the shares it creates
+func (mpc *MPC) run(ctx context.Context) *MPC {
+	run := mpc.WithContext(ctx)
+	run.created = &shareLog{}
+	return run
+}
+
+// finish must be deferred by the caller of run. If the protocol failed,
+// its error is stored in err and the shares it created are deleted
+func (mpc *MPC) finish(err *error) {
+	if r := recover(); r != nil {
+		perr, ok := r.(protocolError)
+		if !ok {
+			panic(r)
+		}
+		*err = perr.err
+	}
+
+	if *err != nil {
+		mpc.macs.forget(mpc.created.ids)
+		mpc.discard(mpc.created.ids...)
+	}
+}
+
+// discard deletes the shares with the given ids at the online parties
+// without waiting for them. Parties that miss the request keep orphaned
+// shares, which are cleared by the next DeleteAllShares
+func (mpc *MPC) discard(ids ...int) {
+
+	if len(ids) == 0 {
+		return
+	}
+
+	for _, i := range mpc.Online() {
+		go func(t party.Transport) {
+			ctx, cancel := context.WithTimeout(context.Background(), discardTimeout)
+			defer cancel()
+
+			for _, id := range ids {
+				if t.DeleteShare(ctx, &party.Share{PartyID: mpc.Party.ID, ID: id}) != nil {
+					return
+				}
+			}
+		}(mpc.Parties[i])
+	}
+}
+
+// parallel runs f for every i in [0, n) concurrently and waits for all of
+// them. The first call to fail cancels the others and aborts the protocol
+func (mpc *MPC) parallel(n int, f func(mpc *MPC, i int)) {
+
+	ctx, cancel := context.WithCancel(mpc.ctx)
+	defer cancel()
+
+	sub := *mpc
+	sub.ctx = ctx
+
+	var once sync.Once
+	var failure interface{}
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					once.Do(func() {
+						failure = r
+						cancel()
+					})
+				}
+			}()
+			f(&sub, i)
+		}(i)
+	}
+	wg.Wait()
+
+	if failure != nil {
+		panic(failure)
+	}
+}
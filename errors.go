@@ -0,0 +1,91 @@
+package custodes
+
+import (
+	"custodes/party"
+	"errors"
+	"fmt"
+)
+
+// The errors returned by the protocols. They usually come wrapped with
+// details, so use errors.Is to tell them apart
+var (
+	// ErrShareNotFound is returned when a party does not hold a share the protocol refers to
+	ErrShareNotFound = party.ErrShareNotFound
+
+	// ErrParameterMismatch is returned when the arguments of a protocol do
+	// not fit each other or the parameters of the system
+	ErrParameterMismatch = party.ErrParameterMismatch
+
+	// ErrDecryption is returned when the partial decryptions of a ciphertext
+	// cannot be combined
+	ErrDecryption = errors.New("partial decryptions could not be combined")
+
+	// ErrPartyUnreachable is returned when too many parties stop answering
+	// for the protocol to carry on
+	ErrPartyUnreachable = errors.New("party unreachable")
+
+	// ErrInvalidProof is returned when a party answers with a proof that
+	// does not verify, which only a misbehaving party does
+	ErrInvalidProof = errors.New("invalid proof")
+
+	// ErrInvalidShare is returned when a party receives a share that does
+	// not match the commitments of its dealer, which only a misbehaving
+	// dealer or recipient causes
+	ErrInvalidShare = party.ErrInvalidShare
+
+	// ErrMACCheck is returned when the MACs of the opened values do not
+	// match, which means a party tampered with a share
+	ErrMACCheck = errors.New("MAC check failed")
+
+	// ErrTranscript is returned when the entries of a transcript do not
+	// hash to the chain they claim, which means it was tampered with
+	ErrTranscript = errors.New("transcript hash chain is broken")
+
+	// ErrAudit is returned when a transcript does not support the
+	// computation an auditor replays against it
+	ErrAudit = errors.New("audit failed")
+
+	// ErrCertificate is returned when a certificate does not carry enough
+	// valid signatures of the parties
+	ErrCertificate = errors.New("certificate not signed by enough parties")
+
+	// ErrPreregistration is returned when the parties refuse to open a
+	// value outside of a preregistered test, or to run a test twice
+	ErrPreregistration = party.ErrPreregistration
+)
+
+// PartyError is returned when a party that is online refuses a request
+// or answers it with an invalid proof
+type PartyError struct {
+	Party int
+	Err   error
+}
+
+func (e *PartyError) Error() string {
+	return fmt.Sprintf("party %d: %v", e.Party, e.Err)
+}
+
+func (e *PartyError) Unwrap() error {
+	return e.Err
+}
+
+// refused reports whether err is the answer of a party that handled the
+// request, as opposed to a failure to reach the party
+func refused(err error) bool {
+	return errors.Is(err, ErrShareNotFound) || errors.Is(err, ErrParameterMismatch) || errors.Is(err, ErrInvalidShare) ||
+		errors.Is(err, ErrPreregistration)
+}
+
+// requireShares aborts the running protocol unless every share is set
+func requireShares(shares ...*party.Share) {
+	for _, share := range shares {
+		require(share != nil, "nil share")
+	}
+}
+
+// require aborts the running protocol with ErrParameterMismatch unless ok
+func require(ok bool, format string, args ...interface{}) {
+	if !ok {
+		fail(fmt.Errorf("%w: %s", ErrParameterMismatch, fmt.Sprintf(format, args...)))
+	}
+}
@@ -0,0 +1,389 @@
+package custodes
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"custodes/party"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	mathbits "math/bits"
+	"sync"
+
+	"github.com/sachaservan/paillier"
+)
+
+// keyGenBatch is the number of candidate moduli tried concurrently
+const keyGenBatch = 16
+
+// sieveBound bounds the primes candidate moduli are trial divided by
+// before the more expensive biprimality test
+const sieveBound = 2000
+
+var errKeyGenOffline = fmt.Errorf("%w: a party holding part of the key went offline", ErrPartyUnreachable)
+
+// smallPrimorial is the product of the odd primes below sieveBound
+var smallPrimorial = func() *big.Int {
+	composite := make([]bool, sieveBound)
+	product := big.NewInt(1)
+	for i := 3; i < sieveBound; i += 2 {
+		if composite[i] {
+			continue
+		}
+		product.Mul(product, big.NewInt(int64(i)))
+		for j := i * i; j < sieveBound; j += 2 * i {
+			composite[j] = true
+		}
+	}
+	return product
+}()
+
+// rsaModulus is a candidate modulus N = (c + p)(c + q) for the public
+// offset c and the jointly dealt p and q, whose additive parts the parties keep
+type rsaModulus struct {
+	n      *big.Int
+	offset *big.Int
+	p, q   *party.Share
+}
+
+// KeyGenModulusBits returns the length of the share modulus P that
+// DistributedKeyGen needs for the given parameters. The masked decryption
+// key and the integer shares it is dealt as must not wrap around P
+func KeyGenModulusBits(params *MPCKeyGenParams) int {
+	l := mathbits.Len(uint(params.NumParties))
+	return 3*params.KeyBits + 3*params.SecurityBits + (params.Threshold+2)*l + 8
+}
+
+// generateKey runs DistributedKeyGen among the parties over a share
+// modulus large enough for it, and switches them back to their own
+// modulus afterwards. VSS is off meanwhile, its parameters being tied to
+// the share modulus
+func generateKey(parties []*party.Party, transports []party.Transport, params *MPCKeyGenParams) (*paillier.ThresholdKey, error) {
+
+	p, err := rand.Prime(rand.Reader, KeyGenModulusBits(params))
+	if err != nil {
+		return nil, err
+	}
+
+	own, vss := parties[0].P, parties[0].VSS
+	setModulus(parties, p, nil)
+	defer setModulus(parties, own, vss)
+
+	mpc := NewKeyGenMPC(parties[0], transports, params)
+	tk, err := mpc.DistributedKeyGen(params.KeyBits)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = mpc.DeleteAllShares()
+	return tk, err
+}
+
+// NewKeyGenMPC returns an MPC instance that drives the parties through
+// DistributedKeyGen before any Paillier key exists, over the share modulus
+// of the dealer, which must be at least KeyGenModulusBits long. The
+// dealer is used to create shares of public values and holds no key share
+func NewKeyGenMPC(dealer *party.Party, parties []party.Transport, params *MPCKeyGenParams) *MPC {
+
+	return &MPC{
+		Party:      dealer,
+		Parties:    parties,
+		Threshold:  params.Threshold,
+		K:          params.MessageBits,
+		S:          params.SecurityBits,
+		P:          dealer.P,
+		FPPrecBits: params.FPPrecisionBits,
+		Timeout:    params.Timeout,
+
+		ctx:           context.Background(),
+		stats:         newCommStats(),
+		liveness:      &liveness{},
+		macs:          &macState{},
+		lagrangeCache: &sync.Map{},
+	}
+}
+
+// setModulus switches the parties to shares mod p
+func setModulus(parties []*party.Party, p *big.Int, vss *party.VSSParams) {
+	n := len(parties)
+	for i, pt := range parties {
+		pt.P = p
+		pt.VSS = vss
+		pt.BetaT = party.LagrangeCoefficient(i, firstIDs(pt.Threshold), p)
+		pt.BetaN = party.LagrangeCoefficient(i, firstIDs(n), p)
+	}
+}
+
+func (mpc *MPC) distributedKeyGen(bits int) *paillier.ThresholdKey {
+
+	mpc = mpc.scope("KeyGen")
+
+	require(bits >= 64 && bits%2 == 0, "cannot generate a %d bit modulus", bits)
+	require(len(mpc.Online()) == len(mpc.Parties), "every party must take part in key generation")
+	need := KeyGenModulusBits(&MPCKeyGenParams{NumParties: len(mpc.Parties), Threshold: mpc.Threshold, KeyBits: bits, SecurityBits: mpc.S})
+	require(mpc.P.BitLen() >= need, "key generation needs a share modulus of %d bits", need)
+
+	for {
+		var mu sync.Mutex
+		var found *rsaModulus
+
+		mpc.parallel(keyGenBatch, func(mpc *MPC, i int) {
+			c := mpc.rsaCandidate(bits)
+			if c == nil {
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if found == nil {
+				found = c
+			}
+		})
+
+		if found == nil {
+			continue
+		}
+
+		if tk := mpc.thresholdKey(found, bits); tk != nil {
+			return tk
+		}
+	}
+}
+
+// rsaCandidate multiplies two jointly dealt candidate primes of bits/2
+// bits, and returns the result if it passes trial division and the
+// biprimality test. The shares of a rejected candidate are discarded
+func (mpc *MPC) rsaCandidate(bits int) *rsaModulus {
+
+	// a candidate of its own records its shares, to discard them if it fails
+	cand := *mpc
+	cand.created = &shareLog{}
+
+	// the parties deal multiples of 4 small enough that the public offset
+	// 3*2^(h-2) + 3 fixes the top bits of the primes and leaves them 3 mod 4
+	h := bits / 2
+	partBits := h - 3 - mathbits.Len(uint(len(mpc.Parties)))
+	offset := big.NewInt(0).Lsh(big.NewInt(3), uint(h-2))
+	offset.Add(offset, big.NewInt(3))
+
+	deal := func(t party.Transport, id int) error {
+		_, err := t.DealPrimeShare(mpc.ctx, partBits, id)
+		return err
+	}
+	c := &rsaModulus{offset: offset, p: cand.newShare(true, deal), q: cand.newShare(true, deal)}
+	cand.recordRounds(1)
+	cand.recordShareMessages(cand.peerMessages())
+
+	// N = c^2 + c(p+q) + pq, where only the last two terms are shared
+	lin := cand.add(cand.mult(c.p, c.q), cand.multC(cand.add(c.p, c.q), offset))
+	c.n = cand.revealShare(lin)
+	c.n.Add(c.n, big.NewInt(0).Mul(offset, offset))
+
+	if new(big.Int).GCD(nil, nil, c.n, smallPrimorial).Cmp(big.NewInt(1)) != 0 || !cand.biprime(c) {
+		mpc.discard(cand.created.ids...)
+		return nil
+	}
+
+	for _, id := range cand.created.ids {
+		mpc.created.add(id)
+	}
+	return c
+}
+
+// biprime runs the test of Boneh and Franklin on N. For g with Jacobi
+// symbol 1, g^(phi(N)/4) = ±1 mod N if N is the product of two primes that
+// are 3 mod 4, and the test fails with probability at least 1/2 for other
+// N but those of a special form, which random candidates almost never have.
+// The parties hold additive parts of phi(N)/4 = (N+1-2c)/4 - (p+q)/4, so
+// they raise g to their parts of (p+q)/4 and the coordinator compares the
+// product with g^((N+1-2c)/4)
+func (mpc *MPC) biprime(c *rsaModulus) bool {
+
+	gs := make([]*big.Int, 0, mpc.S)
+	for len(gs) < mpc.S {
+		g, err := rand.Int(rand.Reader, c.n)
+		if err != nil {
+			fail(err)
+		}
+		if g.Sign() > 0 && big.Jacobi(g, c.n) == 1 {
+			gs = append(gs, g)
+		}
+	}
+
+	vs := make([][]*big.Int, len(mpc.Parties))
+	err := mpc.each(false, func(i int, t party.Transport) error {
+		v, err := t.BiprimeTest(mpc.ctx, c.p, c.q, c.n, gs)
+		vs[i] = v
+		return err
+	})
+	if err != nil {
+		fail(err)
+	}
+	mpc.recordRounds(1)
+
+	e := big.NewInt(0).Add(c.n, big.NewInt(1))
+	e.Sub(e, big.NewInt(0).Lsh(c.offset, 1))
+	e.Rsh(e, 2)
+
+	for k, g := range gs {
+		prod := big.NewInt(1)
+		for i := range vs {
+			if vs[i] == nil {
+				fail(errKeyGenOffline)
+			}
+			require(len(vs[i]) == len(gs), "party %d answered %d of %d biprimality tests", i, len(vs[i]), len(gs))
+			prod.Mul(prod, vs[i][k])
+			prod.Mod(prod, c.n)
+		}
+
+		lhs := big.NewInt(0).Exp(g, e, c.n)
+		if lhs.Cmp(prod) != 0 && lhs.Cmp(prod.Sub(c.n, prod)) != 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// thresholdKey shares the decryption key d = 0 mod phi(N), d = 1 mod N
+// among the parties and installs it, or returns nil if phi(N) turns out
+// not to be invertible mod N. The parties multiply phi(N) by a random beta
+// and open theta = phi*beta mod N under a mask that is a multiple of N, so
+// that d = phi*beta*theta^-1 mod P. They then convert the shares of d mod P
+// into the integer shares threshold Paillier decrypts with
+func (mpc *MPC) thresholdKey(c *rsaModulus, bits int) *paillier.ThresholdKey {
+
+	n := c.n
+	l := mathbits.Len(uint(len(mpc.Parties)))
+
+	// phi = N + 1 - 2c - (p+q)
+	public := big.NewInt(0).Add(n, big.NewInt(1))
+	public.Sub(public, big.NewInt(0).Lsh(c.offset, 1))
+	phi := mpc.sub(mpc.createShares(public), mpc.add(c.p, c.q))
+
+	beta := mpc.randomShare(big.NewInt(0).Lsh(n, uint(mpc.S)))
+	pb := mpc.mult(phi, beta)
+
+	// N*t hides phi*beta < n*N^2*2^S statistically
+	t := mpc.randomShare(big.NewInt(0).Lsh(n, uint(2*mpc.S+l)))
+	z := mpc.revealShare(mpc.add(pb, mpc.multC(t, n)))
+
+	theta := z.Mod(z, n)
+	thetaInv := big.NewInt(0).ModInverse(theta, n)
+	if thetaInv == nil {
+		return nil
+	}
+	d := mpc.multC(pb, thetaInv)
+
+	// open d + r for a mask r of S more bits than d, which every party
+	// holds an additive part of
+	dBits := 3*bits + mpc.S + l
+	mask := mpc.newShare(true, func(t party.Transport, id int) error {
+		_, err := t.DealKeyMask(mpc.ctx, dBits+mpc.S, id)
+		return err
+	})
+	mpc.recordRounds(1)
+	mpc.recordShareMessages(mpc.peerMessages())
+	y := mpc.revealShare(mpc.add(d, mask))
+
+	v := verificationBase(n)
+	vis := make([]*big.Int, len(mpc.Parties))
+	err := mpc.retry(func() error {
+		id := party.NewShareID()
+		mpc.created.add(id)
+		err := mpc.each(true, func(i int, t party.Transport) error {
+			vi, err := t.DealKeyShare(mpc.ctx, mask, y, n, v, dBits+2*mpc.S, id)
+			vis[i] = vi
+			return err
+		})
+		if err != nil {
+			mpc.discard(id)
+		}
+		return err
+	})
+	if err != nil {
+		fail(err)
+	}
+	mpc.recordRounds(1)
+	mpc.recordShareMessages(mpc.peerMessages())
+
+	for _, vi := range vis {
+		if vi == nil {
+			fail(errKeyGenOffline)
+		}
+	}
+
+	tk := &paillier.ThresholdKey{
+		PublicKey:                      paillier.PublicKey{N: n},
+		TotalNumberOfDecryptionServers: len(mpc.Parties),
+		Threshold:                      mpc.Threshold,
+		V:                              v,
+		Vi:                             vis,
+	}
+
+	mpc.installKey(tk)
+
+	return tk
+}
+
+// installKey has every party install tk along with the share of the
+// decryption key it set aside. A test ciphertext whose plaintext the
+// coordinator picks is decrypted under the set aside shares first, and
+// every party checks that all the partial decryptions prove correct under
+// tk and combine to the plaintext before it installs the key
+func (mpc *MPC) installKey(tk *paillier.ThresholdKey) {
+
+	// the parties decrypt the test before any proof exists, and a remote
+	// party could not receive a list of missing ones
+	probe := &party.KeyTest{
+		M: party.CryptoRandom(tk.N),
+		R: party.CryptoRandom(tk.N),
+	}
+	probe.R.Add(probe.R, big.NewInt(1))
+
+	proofs := make([]*paillier.PartialDecryptionZKP, len(mpc.Parties))
+	err := mpc.each(false, func(i int, t party.Transport) error {
+		proof, err := t.TestKeyShare(mpc.ctx, tk, probe)
+		proofs[i] = proof
+		return err
+	})
+	if err != nil {
+		fail(err)
+	}
+	mpc.recordRounds(1)
+
+	for i, proof := range proofs {
+		if proof == nil {
+			fail(fmt.Errorf("%w: party %d did not decrypt the test ciphertext", ErrDecryption, i))
+		}
+	}
+	test := &party.KeyTest{M: probe.M, R: probe.R, Proofs: proofs}
+
+	err = mpc.each(false, func(i int, t party.Transport) error {
+		return t.SetThresholdKey(mpc.ctx, tk, test)
+	})
+	if err != nil {
+		fail(err)
+	}
+	mpc.recordRounds(1)
+}
+
+// verificationBase derives the base of the verification keys of the
+// decryption proofs from N, by hashing N into a square mod N^2
+func verificationBase(n *big.Int) *big.Int {
+
+	nsq := big.NewInt(0).Mul(n, n)
+
+	var buf []byte
+	for block := uint64(0); len(buf)*8 < nsq.BitLen()+128; block++ {
+		h := sha256.New()
+		h.Write([]byte("custodes verification key"))
+		binary.Write(h, binary.BigEndian, block)
+		h.Write(n.Bytes())
+		buf = h.Sum(buf)
+	}
+
+	v := big.NewInt(0).SetBytes(buf)
+	v.Mod(v, nsq)
+	return v.Exp(v, big.NewInt(2), nsq)
+}
@@ -0,0 +1,37 @@
+package custodes
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestDistributedKeyGen(t *testing.T) {
+
+	mpc, err := NewMPCKeyGen(&MPCKeyGenParams{
+		NumParties:        3,
+		Threshold:         2,
+		KeyBits:           256,
+		MessageBits:       32,
+		SecurityBits:      40,
+		FPPrecisionBits:   10,
+		Verify:            true,
+		DistributedKeyGen: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := mpc.Pk.N.BitLen(); got != 256 {
+		t.Fatalf("generated a %d bit modulus, want 256", got)
+	}
+
+	// the parties decrypt under the key shares they generated among themselves
+	for _, m := range []int64{0, 1, 12345} {
+		got, err := mpc.RevealInt(mpc.Pk.Encrypt(big.NewInt(m)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Cmp(big.NewInt(m)) != 0 {
+			t.Fatalf("threshold decryption gave %v, want %d", got, m)
+		}
+	}
+}
@@ -0,0 +1,361 @@
+package custodes
+
+import (
+	"context"
+	"custodes/party"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+)
+
+// errRetry is returned when a request failed at a party that is still
+// online, or when a party went offline halfway through dealing shares.
+// Either way the other parties may hold partial state, so the request
+// is repeated with a fresh share id
+var errRetry = errors.New("request failed at a party that is still online")
+
+var errTimeout = fmt.Errorf("%w: no answer in time", ErrPartyUnreachable)
+
+var errTooFewParties = fmt.Errorf("%w: too few parties online", ErrPartyUnreachable)
+
+// liveness tracks which parties are taking part in the computation.
+// It is shared by every scope of an MPC instance
+type liveness struct {
+	mu      sync.Mutex
+	offline []bool
+	accused []int // parties taken offline for an invalid proof
+}
+
+// Online returns the ids of the parties taking part in the computation
+func (mpc *MPC) Online() []int {
+	mpc.liveness.mu.Lock()
+	defer mpc.liveness.mu.Unlock()
+
+	ids := make([]int, 0, len(mpc.Parties))
+	for i := 0; i < len(mpc.Parties); i++ {
+		if mpc.liveness.offline == nil || !mpc.liveness.offline[i] {
+			ids = append(ids, i)
+		}
+	}
+	return ids
+}
+
+// Accused returns the ids of the parties taken offline because they
+// answered with an invalid proof
+func (mpc *MPC) Accused() []int {
+	mpc.liveness.mu.Lock()
+	defer mpc.liveness.mu.Unlock()
+
+	return append([]int(nil), mpc.liveness.accused...)
+}
+
+// dispatchWithin answers msg using t, giving up after timeout if it is
+// positive or as soon as ctx is done
+func dispatchWithin(ctx context.Context, t party.Transport, msg *party.Message, timeout time.Duration) (*party.Result, error) {
+
+	if timeout <= 0 && ctx.Done() == nil {
+		return party.Dispatch(ctx, t, msg)
+	}
+
+	parent := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(parent, timeout)
+		defer cancel()
+	}
+
+	type reply struct {
+		res *party.Result
+		err error
+	}
+
+	done := make(chan reply, 1)
+	go func() {
+		res, err := party.Dispatch(ctx, t, msg)
+		done <- reply{res, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.res, r.err
+	case <-ctx.Done():
+		if parent.Err() != nil {
+			return &party.Result{}, parent.Err()
+		}
+		return &party.Result{}, errTimeout
+	}
+}
+
+// each runs f concurrently for every online party. Parties whose request
+// fails are probed and taken offline if they no longer answer. If dealing
+// is set, f makes the parties deal shares to each other and any failure
+// results in errRetry
+func (mpc *MPC) each(dealing bool, f func(i int, t party.Transport) error) error {
+
+	ids := mpc.Online()
+	errs := make([]error, len(ids))
+
+	var wg sync.WaitGroup
+	for k, i := range ids {
+		wg.Add(1)
+		go func(k, i int) {
+			defer wg.Done()
+			errs[k] = f(i, mpc.to(i))
+		}(k, i)
+	}
+	wg.Wait()
+
+	// a cancelled request says nothing about the party
+	if err := mpc.ctx.Err(); err != nil {
+		return err
+	}
+
+	for k, err := range errs {
+		if refused(err) {
+			return &PartyError{Party: ids[k], Err: err}
+		}
+	}
+
+	var failed []int
+	for k, err := range errs {
+		if err != nil {
+			failed = append(failed, ids[k])
+		}
+	}
+
+	if len(failed) == 0 {
+		return nil
+	}
+
+	err := mpc.handleFailures(failed)
+	if err == nil && dealing {
+		return errRetry
+	}
+	return err
+}
+
+// quorum runs f concurrently for every online party and returns the ids
+// of the first need parties to succeed, without waiting for the others.
+// Parties for which f fails with ErrInvalidProof are accused rather than
+// probed, and the others are asked in their place
+func (mpc *MPC) quorum(need int, f func(i int, t party.Transport) error) ([]int, error) {
+
+	ids := mpc.Online()
+	if len(ids) < need {
+		return nil, errTooFewParties
+	}
+
+	type answer struct {
+		id  int
+		err error
+	}
+
+	answers := make(chan answer, len(ids))
+	for _, i := range ids {
+		go func(i int) {
+			answers <- answer{i, f(i, mpc.to(i))}
+		}(i)
+	}
+
+	var responders, failed, accused []int
+	for range ids {
+		a := <-answers
+		if refused(a.err) {
+			return nil, &PartyError{Party: a.id, Err: a.err}
+		}
+		switch {
+		case errors.Is(a.err, ErrInvalidProof):
+			accused = append(accused, a.id)
+		case a.err != nil:
+			failed = append(failed, a.id)
+		default:
+			responders = append(responders, a.id)
+		}
+
+		if len(responders) == need {
+			break
+		}
+		if len(ids)-len(failed)-len(accused) < need {
+			break
+		}
+	}
+
+	if err := mpc.ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(accused) > 0 {
+		err := mpc.accuse(accused...)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(failed) > 0 {
+		err := mpc.handleFailures(failed)
+		if err != nil && err != errRetry {
+			return nil, err
+		}
+	}
+
+	if len(responders) < need {
+		return nil, errRetry
+	}
+
+	return responders, nil
+}
+
+// retry calls f until it returns something other than errRetry. Every
+// retry follows a failure, so the number of attempts is bounded by the
+// number of parties
+func (mpc *MPC) retry(f func() error) error {
+
+	err := f()
+	for attempt := 0; err == errRetry && attempt < len(mpc.Parties); attempt++ {
+		err = f()
+	}
+	return err
+}
+
+// handleFailures probes the parties whose request failed and takes offline
+// the ones that do not answer. It returns errRetry if a failed party is
+// still online
+func (mpc *MPC) handleFailures(failed []int) error {
+
+	down := make([]bool, len(failed))
+
+	var wg sync.WaitGroup
+	for k, i := range failed {
+		wg.Add(1)
+		go func(k, i int) {
+			defer wg.Done()
+			down[k] = mpc.to(i).Ping(mpc.ctx) != nil
+		}(k, i)
+	}
+	wg.Wait()
+
+	if err := mpc.ctx.Err(); err != nil {
+		return err
+	}
+
+	var offline []int
+	for k, i := range failed {
+		if down[k] {
+			offline = append(offline, i)
+		}
+	}
+
+	err := mpc.takeOffline(offline...)
+	if err != nil {
+		return err
+	}
+
+	if len(offline) < len(failed) {
+		return errRetry
+	}
+	return nil
+}
+
+// accuse takes offline the parties that answered with an invalid proof
+func (mpc *MPC) accuse(ids ...int) error {
+
+	mpc.liveness.mu.Lock()
+	mpc.liveness.accused = append(mpc.liveness.accused, ids...)
+	mpc.liveness.mu.Unlock()
+
+	return mpc.takeOffline(ids...)
+}
+
+// takeOffline excludes the parties from the rest of the computation and
+// tells the remaining parties to stop dealing shares to them
+func (mpc *MPC) takeOffline(ids ...int) error {
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	mpc.liveness.mu.Lock()
+	if mpc.liveness.offline == nil {
+		mpc.liveness.offline = make([]bool, len(mpc.Parties))
+	}
+	for _, i := range ids {
+		mpc.liveness.offline[i] = true
+	}
+	mpc.liveness.mu.Unlock()
+
+	online := mpc.Online()
+	if len(online) < mpc.Threshold {
+		return errTooFewParties
+	}
+
+	err := mpc.Party.SetParties(mpc.ctx, online)
+	if err != nil {
+		return err
+	}
+
+	var failed []int
+	for _, i := range online {
+		if mpc.to(i).SetParties(mpc.ctx, online) != nil {
+			failed = append(failed, i)
+		}
+	}
+
+	if err := mpc.ctx.Err(); err != nil {
+		return err
+	}
+
+	return mpc.takeOffline(failed...)
+}
+
+// newShare runs f with a fresh share id at every online party until it
+// succeeds everywhere, and returns the resulting share
+func (mpc *MPC) newShare(dealing bool, f func(t party.Transport, id int) error) *party.Share {
+
+	var id int
+	err := mpc.retry(func() error {
+		id = party.NewShareID()
+		mpc.created.add(id)
+		err := mpc.each(dealing, func(i int, t party.Transport) error {
+			return f(t, id)
+		})
+		if err != nil {
+			// also wakes the parties waiting for the pieces of the share
+			mpc.discard(id)
+		}
+		return err
+	})
+	if err != nil {
+		fail(err)
+	}
+
+	return &party.Share{PartyID: mpc.Party.ID, ID: id}
+}
+
+// lagrange returns the coefficients that reconstruct a shared value from
+// the shares of the given parties, in the order of ids
+func (mpc *MPC) lagrange(ids []int) []*big.Int {
+
+	sorted := append([]int(nil), ids...)
+	sort.Ints(sorted)
+	key := fmt.Sprint(sorted)
+
+	if cached, ok := mpc.lagrangeCache.Load(key); ok {
+		coeffs := cached.(map[int]*big.Int)
+		res := make([]*big.Int, len(ids))
+		for k, i := range ids {
+			res[k] = coeffs[i]
+		}
+		return res
+	}
+
+	coeffs := make(map[int]*big.Int)
+	for _, i := range sorted {
+		coeffs[i] = party.LagrangeCoefficient(i, sorted, mpc.P)
+	}
+	mpc.lagrangeCache.Store(key, coeffs)
+
+	return mpc.lagrange(ids)
+}
@@ -0,0 +1,223 @@
+package custodes
+
+import (
+	"fmt"
+	"math/big"
+	"sync"
+
+	"custodes/party"
+)
+
+// macState tracks the MAC key and the values opened since the last MAC
+// check. It is shared by every scope of an MPC instance
+type macState struct {
+	mu     sync.Mutex
+	key    *party.Share // nil while MACs are off
+	opened []opening
+}
+
+// opening is a shared value revealed before its MAC was checked
+type opening struct {
+	id    int
+	value *big.Int
+}
+
+func (state *macState) enabled() bool {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	return state.key != nil
+}
+
+// record queues an opened value for the next MAC check
+func (state *macState) record(id int, value *big.Int) {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	// callers are free to modify the value they got back
+	if state.key != nil {
+		state.opened = append(state.opened, opening{id, new(big.Int).Set(value)})
+	}
+}
+
+// take returns the queued openings and clears the queue
+func (state *macState) take() []opening {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	opened := state.opened
+	state.opened = nil
+	return opened
+}
+
+// forget drops the queued openings of the given shares, which were deleted
+func (state *macState) forget(ids []int) {
+
+	if len(ids) == 0 {
+		return
+	}
+
+	deleted := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		deleted[id] = true
+	}
+
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	kept := state.opened[:0]
+	for _, o := range state.opened {
+		if !deleted[o.id] {
+			kept = append(kept, o)
+		}
+	}
+	state.opened = kept
+}
+
+// enableMACs has the parties generate a secret MAC key and authenticate
+// every share created from then on. Shares created earlier have no MAC
+// and cannot be revealed while MACs are on
+func (mpc *MPC) enableMACs() {
+
+	mpc = mpc.scope("EnableMACs")
+
+	// the key is random and dealt jointly, so no party knows it
+	key := mpc.randomShare(mpc.P)
+
+	err := mpc.retry(func() error {
+		return mpc.each(false, func(i int, t party.Transport) error {
+			return t.SetMACKey(mpc.ctx, key)
+		})
+	})
+	if err != nil {
+		fail(err)
+	}
+
+	mpc.macs.mu.Lock()
+	mpc.macs.key = key
+	mpc.macs.opened = nil
+	mpc.macs.mu.Unlock()
+}
+
+// authenticate has the parties compute the MAC of a share the dealer stored
+func (mpc *MPC) authenticate(share *party.Share) error {
+
+	// the MAC is a product with the key, which needs degree reduction
+	if len(mpc.Online()) < 2*mpc.Threshold-1 {
+		return errTooFewParties
+	}
+
+	err := mpc.each(true, func(i int, t party.Transport) error {
+		return t.Authenticate(mpc.ctx, &party.Share{PartyID: i, ID: share.ID})
+	})
+
+	mpc.recordRounds(2)
+	mpc.recordShareMessages(mpc.peerMessages())
+
+	return err
+}
+
+// checkMACs checks the MACs of all the values opened since the last check
+// in one batch, and aborts with ErrMACCheck if any of them was tampered
+// with. The opened values are combined with random coefficients, so one
+// check covers them all except with probability 1/P
+func (mpc *MPC) checkMACs() {
+
+	opened := mpc.macs.take()
+	if len(opened) == 0 {
+		return
+	}
+
+	mpc = mpc.scope("MACCheck")
+
+	ids := make([]int, len(opened))
+	values := make([]*big.Int, len(opened))
+	coeffs := make([]*big.Int, len(opened))
+	for k, o := range opened {
+		ids[k] = o.id
+		values[k] = o.value
+		coeffs[k] = party.CryptoRandom(mpc.P)
+	}
+
+	sigma := mpc.newShare(true, func(t party.Transport, id int) error {
+		_, err := t.MACCheck(mpc.ctx, ids, values, coeffs, id)
+		return err
+	})
+	mpc.recordRounds(2)
+	mpc.recordShareMessages(mpc.peerMessages())
+	defer mpc.discard(sigma.ID)
+
+	// open sigma at every party, so that a party lying about its share
+	// of it is caught as well
+	var online []int
+	var shares []*big.Int
+	err := mpc.retry(func() error {
+		vals := make([]*big.Int, len(mpc.Parties))
+		err := mpc.each(false, func(i int, t party.Transport) error {
+			val, err := t.RevealShare(mpc.ctx, sigma)
+			vals[i] = val
+			return err
+		})
+		online = mpc.Online()
+		shares = make([]*big.Int, len(online))
+		for k, i := range online {
+			shares[k] = vals[i]
+		}
+		return err
+	})
+	if err != nil {
+		fail(err)
+	}
+	mpc.recordRounds(1)
+
+	if !mpc.onPolynomial(online, shares) {
+		fail(fmt.Errorf("%w: inconsistent shares", ErrMACCheck))
+	}
+
+	coeffsT := mpc.lagrange(online[:mpc.Threshold])
+	terms := make([]*big.Int, mpc.Threshold)
+	for k := range terms {
+		terms[k] = big.NewInt(0).Mul(shares[k], coeffsT[k])
+	}
+	if mpc.ReconstructShare(terms).Sign() != 0 {
+		fail(ErrMACCheck)
+	}
+}
+
+// onPolynomial reports whether the shares values[k] held by the parties
+// ids[k] lie on a single polynomial of degree below Threshold
+func (mpc *MPC) onPolynomial(ids []int, values []*big.Int) bool {
+
+	t := mpc.Threshold
+	if len(ids) < t {
+		return false
+	}
+
+	// interpolate the polynomial through the first t shares at the others
+	for k := t; k < len(ids); k++ {
+		x := big.NewInt(int64(ids[k] + 1))
+		sum := big.NewInt(0)
+		for a := 0; a < t; a++ {
+			xa := big.NewInt(int64(ids[a] + 1))
+			num := big.NewInt(1)
+			den := big.NewInt(1)
+			for b := 0; b < t; b++ {
+				if a == b {
+					continue
+				}
+				xb := big.NewInt(int64(ids[b] + 1))
+				num.Mul(num, big.NewInt(0).Sub(x, xb))
+				den.Mul(den, big.NewInt(0).Sub(xa, xb))
+			}
+			den.Mod(den, mpc.P)
+			num.Mul(num, den.ModInverse(den, mpc.P))
+			sum.Add(sum, num.Mul(num, values[a]))
+		}
+
+		if sum.Mod(sum, mpc.P).Cmp(big.NewInt(0).Mod(values[k], mpc.P)) != 0 {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,89 @@
+package custodes
+
+import (
+	"context"
+	"custodes/party"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+// newMACMPC returns a system of three in-process parties with MACs on
+func newMACMPC(t *testing.T) *MPC {
+	t.Helper()
+
+	mpc := newTestMPC(t)
+	if err := mpc.EnableMACs(); err != nil {
+		t.Fatal(err)
+	}
+	return mpc
+}
+
+func TestMACsHonest(t *testing.T) {
+
+	mpc := newMACMPC(t)
+
+	a := mpc.MustCreateShares(big.NewInt(6))
+	b := mpc.MustCreateShares(big.NewInt(7))
+	if got := mpc.MustRevealShare(mpc.MustMult(a, b)); got.Cmp(big.NewInt(42)) != 0 {
+		t.Fatalf("product of authenticated shares is %v, want 42", got)
+	}
+}
+
+func TestMACsDetectTamperedShare(t *testing.T) {
+
+	mpc := newMACMPC(t)
+	ctx := context.Background()
+	a := mpc.MustCreateShares(big.NewInt(6))
+
+	// every party adds 1 to its share, so that any quorum opens 7, but
+	// nobody can add the key to the MAC
+	for i, tr := range mpc.Parties {
+		share := &party.Share{PartyID: i, ID: a.ID}
+		v, err := tr.RevealShare(ctx, share)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := tr.Store(ctx, share, v.Add(v, big.NewInt(1)), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := mpc.RevealShare(a); !errors.Is(err, ErrMACCheck) {
+		t.Fatalf("reveal of a tampered share: got %v, want ErrMACCheck", err)
+	}
+}
+
+func TestMACsDetectTamperedMAC(t *testing.T) {
+
+	mpc := newMACMPC(t)
+	ctx := context.Background()
+	a := mpc.MustCreateShares(big.NewInt(6))
+
+	// party 1 overwrites its share of the MAC, held under the negated id
+	mac := &party.Share{PartyID: 1, ID: -a.ID}
+	if err := mpc.Parties[1].Store(ctx, mac, party.CryptoRandom(mpc.P), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := mpc.RevealShare(a); !errors.Is(err, ErrMACCheck) {
+		t.Fatalf("reveal of a share with a tampered MAC: got %v, want ErrMACCheck", err)
+	}
+}
+
+func TestMACsNeedDegreeReduction(t *testing.T) {
+
+	mpc := newMACMPC(t)
+	a := mpc.MustCreateShares(big.NewInt(6))
+
+	// two of three parties can open a share, but not multiply it with the key
+	if err := mpc.takeOffline(2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mpc.CreateShares(big.NewInt(7)); !errors.Is(err, ErrPartyUnreachable) {
+		t.Fatalf("authenticated share created by two of three parties: got %v, want ErrPartyUnreachable", err)
+	}
+	if err := mpc.authenticate(a); !errors.Is(err, ErrPartyUnreachable) {
+		t.Fatalf("share authenticated by two of three parties: got %v, want ErrPartyUnreachable", err)
+	}
+}
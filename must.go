@@ -0,0 +1,503 @@
+package custodes
+
+import (
+	"math/big"
+
+	"custodes/party"
+	"custodes/zkp"
+
+	"github.com/sachaservan/paillier"
+)
+
+// MustFoo runs protocol Foo and panics if it fails. It suits programs
+// for which a failed protocol is fatal anyway, such as simulations
+
+func (mpc *MPC) MustRevealShareFP(share *party.Share, scale int) *big.Float {
+	res, err := mpc.RevealShareFP(share, scale)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+func (mpc *MPC) MustRevealShare(share *party.Share) *big.Int {
+	res, err := mpc.RevealShare(share)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+func (mpc *MPC) MustDeleteAllShares() int {
+	res, err := mpc.DeleteAllShares()
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+func (mpc *MPC) MustCopyShare(share *party.Share) *party.Share {
+	res, err := mpc.CopyShare(share)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+func (mpc *MPC) MustCreateShares(value *big.Int) *party.Share {
+	res, err := mpc.CreateShares(value)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+func (mpc *MPC) MustAdd(share1, share2 *party.Share) *party.Share {
+	res, err := mpc.Add(share1, share2)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+func (mpc *MPC) MustSub(share1, share2 *party.Share) *party.Share {
+	res, err := mpc.Sub(share1, share2)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+func (mpc *MPC) MustMultC(share *party.Share, c *big.Int) *party.Share {
+	res, err := mpc.MultC(share, c)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+func (mpc *MPC) MustMult(share1, share2 *party.Share) *party.Share {
+	res, err := mpc.Mult(share1, share2)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+func (mpc *MPC) MustFPNormalize(b *party.Share) (*party.Share, *party.Share) {
+	res1, res2, err := mpc.FPNormalize(b)
+	if err != nil {
+		panic(err)
+	}
+	return res1, res2
+}
+
+func (mpc *MPC) MustFPReciprocal(b *party.Share) *party.Share {
+	res, err := mpc.FPReciprocal(b)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+func (mpc *MPC) MustFPDivision(a, b *party.Share) *party.Share {
+	res, err := mpc.FPDivision(a, b)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+func (mpc *MPC) MustFPSqrtReciprocal(a *party.Share) *party.Share {
+	res, err := mpc.FPSqrtReciprocal(a)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+func (mpc *MPC) MustTruncPR(a *party.Share, k, m int) *party.Share {
+	res, err := mpc.TruncPR(a, k, m)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+func (mpc *MPC) MustSignBit(a *party.Share) *party.Share {
+	res, err := mpc.SignBit(a)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+func (mpc *MPC) MustRandomBits(m int) []*party.Share {
+	res, err := mpc.RandomBits(m)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+func (mpc *MPC) MustRandomShare(bound *big.Int) *party.Share {
+	res, err := mpc.RandomShare(bound)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+func (mpc *MPC) MustRandomInvertibleShare() (*party.Share, *party.Share) {
+	res1, res2, err := mpc.RandomInvertibleShare()
+	if err != nil {
+		panic(err)
+	}
+	return res1, res2
+}
+
+func (mpc *MPC) MustSolvedBits(m int) ([]*party.Share, *party.Share) {
+	res1, res2, err := mpc.SolvedBits(m)
+	if err != nil {
+		panic(err)
+	}
+	return res1, res2
+}
+
+func (mpc *MPC) MustBitsExp(bits []*party.Share) *party.Share {
+	res, err := mpc.BitsExp(bits)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+func (mpc *MPC) MustBitsMult(a, b []*party.Share) []*party.Share {
+	res, err := mpc.BitsMult(a, b)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+func (mpc *MPC) MustBitsToEInteger(bits []*party.Share) *party.Share {
+	res, err := mpc.BitsToEInteger(bits)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+func (mpc *MPC) MustBitsDec(a *party.Share, m int) []*party.Share {
+	res, err := mpc.BitsDec(a, m)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+func (mpc *MPC) MustFanInMULT(elements []*party.Share) []*party.Share {
+	res, err := mpc.FanInMULT(elements)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+func (mpc *MPC) MustBitsPrefixOR(bits []*party.Share) []*party.Share {
+	res, err := mpc.BitsPrefixOR(bits)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+func (mpc *MPC) MustBitsADD(a, b []*party.Share) []*party.Share {
+	res, err := mpc.BitsADD(a, b)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+func (mpc *MPC) MustBitsLT(a, b []*party.Share) *party.Share {
+	res, err := mpc.BitsLT(a, b)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+func (mpc *MPC) MustBitsCarries(a, b []*party.Share) []*party.Share {
+	res, err := mpc.BitsCarries(a, b)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+func (mpc *MPC) MustBitsBigEndian(a *big.Int, n int) []*party.Share {
+	res, err := mpc.BitsBigEndian(a, n)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+func (mpc *MPC) MustBitsZero() []*party.Share {
+	res, err := mpc.BitsZero()
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+func (mpc *MPC) MustBitsXOR(bits []*party.Share) *party.Share {
+	res, err := mpc.BitsXOR(bits)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+func (mpc *MPC) MustBitsOR(bits []*party.Share) *party.Share {
+	res, err := mpc.BitsOR(bits)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+func (mpc *MPC) MustBitsAND(bits []*party.Share) *party.Share {
+	res, err := mpc.BitsAND(bits)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+func (mpc *MPC) MustEMult(a, b *paillier.Ciphertext) *paillier.Ciphertext {
+	res, err := mpc.EMult(a, b)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+func (mpc *MPC) MustERandomMultShare(c *paillier.Ciphertext) (*paillier.Ciphertext, *paillier.Ciphertext) {
+	res1, res2, err := mpc.ERandomMultShare(c)
+	if err != nil {
+		panic(err)
+	}
+	return res1, res2
+}
+
+func (mpc *MPC) MustECMultFP(ct *paillier.Ciphertext, fp *big.Float) *paillier.Ciphertext {
+	res, err := mpc.ECMultFP(ct, fp)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+func (mpc *MPC) MustEFPMult(a, b *paillier.Ciphertext) *paillier.Ciphertext {
+	res, err := mpc.EFPMult(a, b)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+func (mpc *MPC) MustETruncPR(a *paillier.Ciphertext, k, m int) *paillier.Ciphertext {
+	res, err := mpc.ETruncPR(a, k, m)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+func (mpc *MPC) MustERandom(bound *big.Int) *paillier.Ciphertext {
+	res, err := mpc.ERandom(bound)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+func (mpc *MPC) MustERandomAndShare(bound *big.Int) (*paillier.Ciphertext, *party.Share) {
+	res1, res2, err := mpc.ERandomAndShare(bound)
+	if err != nil {
+		panic(err)
+	}
+	return res1, res2
+}
+
+func (mpc *MPC) MustPaillierToShare(ct *paillier.Ciphertext) *party.Share {
+	res, err := mpc.PaillierToShare(ct)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+func (mpc *MPC) MustDistributedKeyGen(bits int) *paillier.ThresholdKey {
+	res, err := mpc.DistributedKeyGen(bits)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+func (mpc *MPC) MustRefresh(shares ...*party.Share) {
+	err := mpc.Refresh(shares...)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (mpc *MPC) MustShareToPaillier(share *party.Share) *paillier.Ciphertext {
+	res, err := mpc.ShareToPaillier(share)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+func (mpc *MPC) MustShareToPaillierFP(share *party.Share, scale int) *paillier.Ciphertext {
+	res, err := mpc.ShareToPaillierFP(share, scale)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+func (mpc *MPC) MustERandomInvertibleShare() (*paillier.Ciphertext, *paillier.Ciphertext) {
+	res1, res2, err := mpc.ERandomInvertibleShare()
+	if err != nil {
+		panic(err)
+	}
+	return res1, res2
+}
+
+func (mpc *MPC) MustERandomBits(m int) []*paillier.Ciphertext {
+	res, err := mpc.ERandomBits(m)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+func (mpc *MPC) MustESolvedBits(m int) ([]*paillier.Ciphertext, *paillier.Ciphertext) {
+	res1, res2, err := mpc.ESolvedBits(m)
+	if err != nil {
+		panic(err)
+	}
+	return res1, res2
+}
+
+func (mpc *MPC) MustEFanInMULT(elements []*paillier.Ciphertext) []*paillier.Ciphertext {
+	res, err := mpc.EFanInMULT(elements)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+func (mpc *MPC) MustRevealInt(ciphertext *paillier.Ciphertext) *big.Int {
+	res, err := mpc.RevealInt(ciphertext)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+func (mpc *MPC) MustRevealFP(ciphertext *paillier.Ciphertext, scale int) *big.Float {
+	res, err := mpc.RevealFP(ciphertext, scale)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+func (mpc *MPC) MustEBitsXOR(bits []*paillier.Ciphertext) *paillier.Ciphertext {
+	res, err := mpc.EBitsXOR(bits)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+func (mpc *MPC) MustVerifyRanges(dataset string, cts []*paillier.Ciphertext, proofs []*zkp.IntervalProof) []bool {
+	res, err := mpc.VerifyRanges(dataset, cts, proofs)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+func (mpc *MPC) MustVerifyOneHot(dataset string, rows [][]*paillier.Ciphertext, proofs []*zkp.OneHotProof) []bool {
+	res, err := mpc.VerifyOneHot(dataset, rows, proofs)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+func (mpc *MPC) MustEnableMACs() {
+	err := mpc.EnableMACs()
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (mpc *MPC) MustRevealStatistic(share *party.Share, scale int) *big.Float {
+	res, err := mpc.RevealStatistic(share, scale)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+func (mpc *MPC) MustCertify(st *party.Statement) *Certificate {
+	res, err := mpc.Certify(st)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+func (mpc *MPC) MustPreregister(signed *party.SignedSpec) {
+	err := mpc.Preregister(signed)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (mpc *MPC) MustSeal() {
+	err := mpc.Seal()
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (mpc *MPC) MustUpload(dataset string, rows [][]*paillier.Ciphertext) {
+	err := mpc.Upload(dataset, rows)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (mpc *MPC) MustBeginTest(spec *party.Spec) {
+	err := mpc.BeginTest(spec)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (mpc *MPC) MustEndTest(spec *party.Spec, out *party.Outcome) *party.LedgerEntry {
+	res, err := mpc.EndTest(spec, out)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
@@ -2,7 +2,9 @@ package custodes
 
 import (
 	"custodes/party"
+	"custodes/zkp"
 	"errors"
+	"fmt"
 	"math/big"
 	"sync"
 
@@ -11,58 +13,68 @@ import (
 
 var funcEXORCoefficientCache sync.Map
 
-func (mpc *MPC) EMult(a, b *paillier.Ciphertext) *paillier.Ciphertext {
-	mask, val := mpc.ERandomMultShare(a)
+func (mpc *MPC) eMult(a, b *paillier.Ciphertext) *paillier.Ciphertext {
+	mpc = mpc.scope("EMult")
+	mask, val := mpc.eRandomMultShare(a)
 	c := mpc.Pk.EAdd(b, mask)
-	rev := mpc.RevealInt(c)
+	rev := mpc.revealInt(c)
 	res := mpc.Pk.ECMult(a, rev)
 	res = mpc.Pk.ESub(res, val)
+	mpc.record(&MPCTranscriptEntry{
+		Protocol: EMult,
+		CtIn:     []*paillier.Ciphertext{a, b},
+		CtAux:    []*paillier.Ciphertext{mask, val},
+		CtOut:    []*paillier.Ciphertext{res},
+	})
 	return res
 }
 
-// ERandomMultShare returns a random encrypted integer and c*r
-// in {1...Pk.N}, jointly generated by all parties
-func (mpc *MPC) ERandomMultShare(c *paillier.Ciphertext) (*paillier.Ciphertext, *paillier.Ciphertext) {
+func (mpc *MPC) eRandomMultShare(c *paillier.Ciphertext) (*paillier.Ciphertext, *paillier.Ciphertext) {
 
-	randomValues := make([]*paillier.Ciphertext, len(mpc.Parties))
-	partialMult := make([]*paillier.Ciphertext, len(mpc.Parties))
-
-	var wg sync.WaitGroup
-	for i := 0; i < len(mpc.Parties); i++ {
-		wg.Add(1)
-		go func(i int) {
-			defer wg.Done()
+	var randomValues, partialMult []*paillier.Ciphertext
+	err := mpc.retry(func() error {
+		randomValues = make([]*paillier.Ciphertext, len(mpc.Parties))
+		partialMult = make([]*paillier.Ciphertext, len(mpc.Parties))
+		return mpc.each(false, func(i int, t party.Transport) error {
 			cpy := &paillier.Ciphertext{C: big.NewInt(0).Set(c.C)}
-			r, mult := mpc.Parties[i].GetRandomMultEnc(cpy)
-			randomValues[i] = r
-			partialMult[i] = mult
-		}(i)
+			r, mult, err := t.GetRandomMultEnc(mpc.ctx, cpy)
+			if err == nil {
+				randomValues[i] = r
+				partialMult[i] = mult
+			}
+			return err
+		})
+	})
+	if err != nil {
+		fail(err)
 	}
-	wg.Wait()
+	mpc.recordRounds(1)
 
-	randSum := mpc.Pk.EAdd(randomValues...)
-	multSum := mpc.Pk.EAdd(partialMult...)
+	randSum := mpc.Pk.EAdd(answered(randomValues)...)
+	multSum := mpc.Pk.EAdd(answered(partialMult)...)
 
 	return randSum, multSum
 }
-func (mpc *MPC) ECMultFP(ct *paillier.Ciphertext, fp *big.Float) *paillier.Ciphertext {
+func (mpc *MPC) eCMultFP(ct *paillier.Ciphertext, fp *big.Float) *paillier.Ciphertext {
 	e := mpc.Pk.EncodeFixedPoint(fp, mpc.FPPrecBits)
 	c := mpc.Pk.ECMult(ct, e)
-	return mpc.ETruncPR(c, mpc.K, mpc.FPPrecBits)
+	return mpc.eTruncPR(c, mpc.K, mpc.FPPrecBits)
 }
 
-func (mpc *MPC) EFPMult(a, b *paillier.Ciphertext) *paillier.Ciphertext {
-	res := mpc.EMult(a, b)
-	res = mpc.ETruncPR(res, mpc.K, mpc.FPPrecBits)
+func (mpc *MPC) eFPMult(a, b *paillier.Ciphertext) *paillier.Ciphertext {
+	res := mpc.eMult(a, b)
+	res = mpc.eTruncPR(res, mpc.K, mpc.FPPrecBits)
 	return res
 }
 
-// ETruncPR truncates a bitwise sharing where the last bit is
-// probabilistically rounded up or down
-func (mpc *MPC) ETruncPR(a *paillier.Ciphertext, k, m int) *paillier.Ciphertext {
+func (mpc *MPC) eTruncPR(a *paillier.Ciphertext, k, m int) *paillier.Ciphertext {
 
-	// get 2^k-1 + a
-	b := mpc.Pk.Encrypt(big.NewInt(0).Exp(big2, big.NewInt(int64(k-1)), nil))
+	require(0 < m && m <= k, "cannot truncate %d bits of a %d bit value", m, k)
+	mpc = mpc.scope("ETruncPR")
+
+	// get 2^k-1 + a. Public constants are encrypted with randomness 1 so
+	// that an auditor can recompute the ciphertexts
+	b := mpc.Pk.EncryptWithR(big.NewInt(0).Exp(big2, big.NewInt(int64(k-1)), nil), big1)
 	b = mpc.Pk.EAdd(b, a)
 
 	// 2^m
@@ -70,105 +82,181 @@ func (mpc *MPC) ETruncPR(a *paillier.Ciphertext, k, m int) *paillier.Ciphertext
 	big2mInv := big.NewInt(0).ModInverse(big2m, mpc.Pk.N)
 
 	// get random r \in [0, 2^m)
-	r := mpc.ERandom(big2m)
+	r := mpc.eRandom(big2m)
 
 	exp := big.NewInt(0).Exp(big2, big.NewInt(int64(mpc.S+k-m)), nil)
-	rnd := mpc.ERandom(exp)
+	rnd := mpc.eRandom(exp)
 
 	// 2^m*rnd + r
 	mask := mpc.Pk.ECMult(rnd, big2m)
 	mask = mpc.Pk.EAdd(mask, r)
 
-	c := mpc.RevealInt(mpc.Pk.EAdd(b, mask))
+	c := mpc.revealInt(mpc.Pk.EAdd(b, mask))
 	c = c.Mod(c, big2m)
 
-	res := mpc.Pk.Encrypt(c)
+	res := mpc.Pk.EncryptWithR(c, big1)
 	res = mpc.Pk.ESub(res, r)
 	res = mpc.Pk.ESub(a, res)
 	res = mpc.Pk.ECMult(res, big2mInv)
+	mpc.record(&MPCTranscriptEntry{
+		Protocol: ETruncPR,
+		Params:   []int{k, m},
+		CtIn:     []*paillier.Ciphertext{a},
+		CtAux:    []*paillier.Ciphertext{r, rnd},
+		CtOut:    []*paillier.Ciphertext{res},
+	})
 
 	return res
 }
 
-// ERandom returns a random encrypted integer
-// in {1...Pk.T}, jointly generated by all parties
-func (mpc *MPC) ERandom(bound *big.Int) *paillier.Ciphertext {
-
-	shares := make([]*paillier.Ciphertext, len(mpc.Parties))
+func (mpc *MPC) eRandom(bound *big.Int) *paillier.Ciphertext {
 
-	var wg sync.WaitGroup
-	for i := 0; i < len(mpc.Parties); i++ {
-		wg.Add(1)
-		go func(i int) {
-			defer wg.Done()
-			share := mpc.Parties[i].GetRandomEnc(bound)
-			shares[i] = share
-		}(i)
+	var shares []*paillier.Ciphertext
+	err := mpc.retry(func() error {
+		shares = make([]*paillier.Ciphertext, len(mpc.Parties))
+		return mpc.each(false, func(i int, t party.Transport) error {
+			share, err := t.GetRandomEnc(mpc.ctx, bound)
+			if err == nil {
+				shares[i] = share
+			}
+			return err
+		})
+	})
+	if err != nil {
+		fail(err)
 	}
-
-	wg.Wait()
+	mpc.recordRounds(1)
 
 	shareSum := mpc.Pk.Encrypt(big.NewInt(0))
-	for i := 0; i < len(mpc.Parties); i++ {
-		shareSum = mpc.Pk.EAdd(shareSum, shares[i])
+	for _, share := range answered(shares) {
+		shareSum = mpc.Pk.EAdd(shareSum, share)
 	}
 
 	return shareSum
 }
 
-// ERandomAndShare returns a random encrypted integer (in paillier)
-// and the corresponding values shared in Shamir, both jointly generated by all parties
-func (mpc *MPC) ERandomAndShare(bound *big.Int) (*paillier.Ciphertext, *party.Share) {
-
-	id := party.NewShareID()
-	rand := make([]*paillier.Ciphertext, len(mpc.Parties))
-	var randShare *party.Share
-
-	var wg sync.WaitGroup
-	for i := 0; i < len(mpc.Parties); i++ {
-		wg.Add(1)
-		go func(i int) {
-			defer wg.Done()
-			enc, share := mpc.Parties[i].GetRandomEncAndShare(id, bound)
-			randShare = share
-			rand[i] = enc
-		}(i)
+func (mpc *MPC) eRandomAndShare(bound *big.Int) (*paillier.Ciphertext, *party.Share) {
+
+	var id int
+	var rand []*paillier.Ciphertext
+	err := mpc.retry(func() error {
+		id = party.NewShareID()
+		mpc.created.add(id)
+		rand = make([]*paillier.Ciphertext, len(mpc.Parties))
+		err := mpc.each(true, func(i int, t party.Transport) error {
+			enc, _, err := t.GetRandomEncAndShare(mpc.ctx, id, bound)
+			if err == nil {
+				rand[i] = enc
+			}
+			return err
+		})
+		if err != nil {
+			mpc.discard(id)
+		}
+		return err
+	})
+	if err != nil {
+		fail(err)
 	}
-
-	wg.Wait()
+	mpc.recordRounds(1)
+	mpc.recordShareMessages(mpc.peerMessages())
 
 	sum := mpc.Pk.Encrypt(big.NewInt(0))
-	for i := 0; i < len(mpc.Parties); i++ {
-		sum = mpc.Pk.EAdd(sum, rand[i])
+	for _, enc := range answered(rand) {
+		sum = mpc.Pk.EAdd(sum, enc)
 	}
 
-	return sum, randShare
+	return sum, &party.Share{PartyID: mpc.Party.ID, ID: id}
 }
 
-func (mpc *MPC) PaillierToShare(ct *paillier.Ciphertext) *party.Share {
+// paillierToShare converts an encryption of x in [-2^K, 2^K) into a
+// sharing of x mod P. Every party contributes K+S random bits to a joint
+// mask r, which statistically hides x + 2^K + r as long as one party picks
+// its bits honestly. The opened value is below N, so it equals x + 2^K + r
+// over the integers and the parties subtract their shares of r mod P
+func (mpc *MPC) paillierToShare(ct *paillier.Ciphertext) *party.Share {
+
+	mpc = mpc.scope("PaillierToShare")
 
 	bound := big.NewInt(0).Exp(big.NewInt(2), big.NewInt(int64(mpc.K+mpc.S)), nil)
 	big2K := big.NewInt(0).Exp(big.NewInt(2), big.NewInt(int64(mpc.K)), nil)
-	r, rshare := mpc.ERandomAndShare(bound)
-	r = mpc.Pk.Encrypt(big.NewInt(0))
-	rshare = mpc.CreateShares(big.NewInt(0))
 
-	r = mpc.Pk.EAdd(r, mpc.Pk.Encrypt(big2K))
-	rshare = mpc.Add(rshare, mpc.CreateShares(big2K))
-	val := mpc.RevealInt(mpc.Pk.EAdd(ct, r))
-	share := mpc.CreateShares(val)
-	res := mpc.Sub(share, rshare)
+	// the largest masked value, x + 2^K + r < 2^(K+1) + n*2^(K+S), must not wrap around N
+	max := big.NewInt(0).Mul(bound, big.NewInt(int64(len(mpc.Parties))))
+	max.Add(max, big.NewInt(0).Lsh(big2K, 1))
+	require(max.Cmp(mpc.Pk.N) < 0, "masked values of %d bits do not fit the plaintext space", max.BitLen())
+
+	r, rshare := mpc.eRandomAndShare(bound)
+	masked := mpc.Pk.EAdd(mpc.Pk.EAdd(ct, r), mpc.Pk.EncryptWithR(big2K, big1))
+	val := mpc.revealInt(masked)
+
+	// val - 2^K = x + r, so removing r leaves x
+	val.Sub(val, big2K)
+	share := mpc.createShares(val.Mod(val, mpc.P))
+	res := mpc.sub(share, rshare)
+	mpc.record(&MPCTranscriptEntry{
+		Protocol: PaillierToShare,
+		Params:   []int{mpc.K},
+		CtIn:     []*paillier.Ciphertext{ct},
+		CtAux:    []*paillier.Ciphertext{r},
+	})
 
 	return res
 }
 
-// RandomInvertibleShare returns a random encrypted integer
-// in {1...Pk.T} and its inverse (mod Pk.N)
-func (mpc *MPC) ERandomInvertibleShare() (*paillier.Ciphertext, *paillier.Ciphertext, error) {
+// shareToPaillier converts a sharing of x in [-2^K, 2^K) into an
+// encryption of x. The parties open x + 2^K + r for a joint mask r that
+// they also hold an encryption of, which hides x statistically as long as
+// one party picks its part of r honestly. The opened value is below P, so
+// it equals x + 2^K + r over the integers and removing 2^K and r under
+// encryption leaves x mod N
+func (mpc *MPC) shareToPaillier(share *party.Share) *paillier.Ciphertext {
+
+	requireShares(share)
+	mpc = mpc.scope("ShareToPaillier")
 
-	a := mpc.ERandom(mpc.Pk.N)
-	b := mpc.ERandom(mpc.Pk.N)
-	c := mpc.RevealInt(mpc.EMult(a, b))
+	bound := big.NewInt(0).Exp(big.NewInt(2), big.NewInt(int64(mpc.K+mpc.S)), nil)
+	big2K := big.NewInt(0).Exp(big.NewInt(2), big.NewInt(int64(mpc.K)), nil)
+
+	// the largest masked value, x + 2^K + r < 2^(K+1) + n*2^(K+S), must not wrap around P
+	max := big.NewInt(0).Mul(bound, big.NewInt(int64(len(mpc.Parties))))
+	max.Add(max, big.NewInt(0).Lsh(big2K, 1))
+	require(max.Cmp(mpc.P) < 0, "masked values of %d bits do not fit the share modulus", max.BitLen())
+
+	r, rshare := mpc.eRandomAndShare(bound)
+	masked := mpc.add(mpc.add(share, rshare), mpc.createShares(big2K))
+	val := mpc.revealShare(masked)
+
+	// val - 2^K = x + r, so removing r leaves x
+	val.Sub(val, big2K)
+	ct := mpc.Pk.Encrypt(val.Mod(val, mpc.Pk.N))
+
+	return mpc.Pk.ESub(ct, r)
+}
+
+// shareToPaillierFP converts a sharing of a fixed point value with scale
+// bits of precision into an encryption of it with FPPrecBits of precision
+func (mpc *MPC) shareToPaillierFP(share *party.Share, scale int) *paillier.Ciphertext {
+
+	requireShares(share)
+	require(scale >= 0, "negative scale %d", scale)
+
+	switch {
+	case scale > mpc.FPPrecBits:
+		// the value is below 2^K in absolute value
+		share = mpc.truncPR(share, mpc.K+1, scale-mpc.FPPrecBits)
+	case scale < mpc.FPPrecBits:
+		share = mpc.multC(share, big.NewInt(0).Lsh(big1, uint(mpc.FPPrecBits-scale)))
+	}
+
+	return mpc.shareToPaillier(share)
+}
+
+func (mpc *MPC) eRandomInvertibleShare() (*paillier.Ciphertext, *paillier.Ciphertext, error) {
+
+	a := mpc.eRandom(mpc.Pk.N)
+	b := mpc.eRandom(mpc.Pk.N)
+	c := mpc.revealInt(mpc.eMult(a, b))
 
 	if c.Int64() == 0 {
 		return nil, nil, errors.New("abort")
@@ -180,50 +268,74 @@ func (mpc *MPC) ERandomInvertibleShare() (*paillier.Ciphertext, *paillier.Cipher
 	return a, aInv, nil
 }
 
-// ERandomBits returns a random bit vector from {0,1}^l
-func (mpc *MPC) ERandomBits(m int) []*paillier.Ciphertext {
-
-	vectors := make([][]*paillier.Ciphertext, len(mpc.Parties))
-
-	var wg sync.WaitGroup
-
-	for i := 0; i < len(mpc.Parties); i++ {
-		wg.Add(1)
-		go func(i int) {
-			defer wg.Done()
-			vec := mpc.Parties[i].GetRandomEncBitVector(m)
-			vectors[i] = vec
-		}(i)
+// eRandomBits XORs the random bit vectors of the parties. A vector comes
+// with proofs that its ciphertexts encrypt bits, which are checked whether
+// or not mpc.Verify is set: a party whose proofs fail is accused and its
+// vector discarded, since a single 2 or -1 would corrupt every comparison
+// built on the bits. The proofs are made in a fresh session and name their
+// prover, so a party cannot hand in the vector of another, which would
+// cancel it out of the XOR, or one from an earlier call
+func (mpc *MPC) eRandomBits(m int) []*paillier.Ciphertext {
+
+	require(m > 0, "cannot generate %d bits", m)
+	var vectors [][]*paillier.Ciphertext
+	var proofs [][]*zkp.BitProof
+	var session []byte
+	err := mpc.retry(func() error {
+		vectors = make([][]*paillier.Ciphertext, len(mpc.Parties))
+		proofs = make([][]*zkp.BitProof, len(mpc.Parties))
+		session = newSession()
+		return mpc.each(false, func(i int, t party.Transport) error {
+			vec, vecProofs, err := t.GetRandomEncBitVector(mpc.ctx, m, session)
+			if err == nil {
+				vectors[i] = vec
+				proofs[i] = vecProofs
+			}
+			return err
+		})
+	})
+	if err != nil {
+		fail(err)
+	}
+	mpc.recordRounds(1)
+
+	var accused []int
+	for i, ok := range mpc.verifyBitVectors(vectors, proofs, m, session) {
+		if !ok {
+			vectors[i] = nil
+			accused = append(accused, i)
+		}
+	}
+	if err := mpc.accuse(accused...); err != nil {
+		fail(err)
 	}
 
-	wg.Wait()
+	// only the parties that answered with valid bits contribute
+	contributed := make([][]*paillier.Ciphertext, 0, len(vectors))
+	for _, vec := range vectors {
+		if vec != nil {
+			contributed = append(contributed, vec)
+		}
+	}
+	require(len(contributed) > 0, "no party contributed random bits")
 
 	bits := make([]*paillier.Ciphertext, m)
 
-	for i := 0; i < m; i++ {
-		wg.Add(1)
-		go func(i int) {
-			defer wg.Done()
+	mpc.parallel(m, func(mpc *MPC, i int) {
+		col := make([]*paillier.Ciphertext, len(contributed))
+		for k := 0; k < len(contributed); k++ {
+			col[k] = contributed[k][i]
+		}
 
-			col := make([]*paillier.Ciphertext, len(mpc.Parties))
-			for k := 0; k < len(mpc.Parties); k++ {
-				col[k] = vectors[k][i]
-			}
-
-			bits[i] = mpc.EBitsXOR(col)
-
-		}(i)
-	}
-
-	wg.Wait()
+		bits[i] = mpc.eBitsXOR(col)
+	})
 
 	return bits
 }
 
-// solvedBits returns a random bit string from {0,1}^m and the corresponding
-func (mpc *MPC) ESolvedBits(m int) ([]*paillier.Ciphertext, *paillier.Ciphertext, error) {
+func (mpc *MPC) eSolvedBits(m int) ([]*paillier.Ciphertext, *paillier.Ciphertext, error) {
 
-	bits := mpc.ERandomBits(m)
+	bits := mpc.eRandomBits(m)
 
 	// convert bits to an encrypted integer
 	val := mpc.EBitsToEInteger(bits)
@@ -231,10 +343,9 @@ func (mpc *MPC) ESolvedBits(m int) ([]*paillier.Ciphertext, *paillier.Ciphertext
 	return bits, val, nil
 }
 
-// EFanInMULT efficiently computes [x,x^2,x^3...x^n] where n = len(elements)
-// Note: can be used as a PrefixAND when elements are binary
-func (mpc *MPC) EFanInMULT(elements []*paillier.Ciphertext) []*paillier.Ciphertext {
+func (mpc *MPC) eFanInMULT(elements []*paillier.Ciphertext) []*paillier.Ciphertext {
 
+	require(len(elements) > 0, "no elements to multiply")
 	n := len(elements)
 	res := make([]*paillier.Ciphertext, n)
 	res[0] = elements[0]
@@ -246,44 +357,26 @@ func (mpc *MPC) EFanInMULT(elements []*paillier.Ciphertext) []*paillier.Cipherte
 	shares := make([]*paillier.Ciphertext, n)
 	sharesInv := make([]*paillier.Ciphertext, n)
 
-	var wg sync.WaitGroup
-	for i := 0; i < n; i++ {
-		wg.Add(1)
-
-		go func(i int) {
-			defer wg.Done()
-			var err error
-			shares[i], sharesInv[i], err = mpc.ERandomInvertibleShare()
-			for err != nil {
-				shares[i], sharesInv[i], err = mpc.ERandomInvertibleShare()
-			}
-		}(i)
-	}
-
-	wg.Wait()
+	mpc.parallel(n, func(mpc *MPC, i int) {
+		var err error
+		shares[i], sharesInv[i], err = mpc.eRandomInvertibleShare()
+		for err != nil {
+			shares[i], sharesInv[i], err = mpc.eRandomInvertibleShare()
+		}
+	})
 
 	d := make([]*paillier.Ciphertext, n)
 	d[0] = shares[0]
-	for i := 1; i < n; i++ {
-		wg.Add(1)
-		go func(i int) {
-			defer wg.Done()
-			d[i] = mpc.EMult(shares[i], sharesInv[i-1])
-		}(i)
-
-	}
-	wg.Wait()
+	mpc.parallel(n-1, func(mpc *MPC, k int) {
+		i := k + 1
+		d[i] = mpc.eMult(shares[i], sharesInv[i-1])
+	})
 
 	c := make([]*big.Int, n)
-	for i := 0; i < n; i++ {
-		wg.Add(1)
-		go func(i int) {
-			defer wg.Done()
-			q := mpc.EMult(d[i], elements[i])
-			c[i] = mpc.RevealInt(q)
-		}(i)
-	}
-	wg.Wait()
+	mpc.parallel(n, func(mpc *MPC, i int) {
+		q := mpc.eMult(d[i], elements[i])
+		c[i] = mpc.revealInt(q)
+	})
 
 	acc := c[0]
 	for i := 1; i < n; i++ {
@@ -294,34 +387,109 @@ func (mpc *MPC) EFanInMULT(elements []*paillier.Ciphertext) []*paillier.Cipherte
 	return res
 }
 
-func (mpc *MPC) RevealInt(ciphertext *paillier.Ciphertext) *big.Int {
+func (mpc *MPC) revealInt(ciphertext *paillier.Ciphertext) *big.Int {
+
+	require(ciphertext != nil, "nil ciphertext")
+	mpc = mpc.scope("RevealInt")
+
+	// combine whichever Threshold partial decryptions arrive first
+	var partials []*paillier.PartialDecryption
+	var proofs []*paillier.PartialDecryptionZKP
+	var ids []int
+	err := mpc.retry(func() error {
+		// late answers of an earlier attempt must not race with this one
+		pds := make([]*paillier.PartialDecryption, len(mpc.Parties))
+		zkps := make([]*paillier.PartialDecryptionZKP, len(mpc.Parties))
+		var err error
+		ids, err = mpc.quorum(mpc.Threshold, func(i int, t party.Transport) error {
+			partial, proof, err := mpc.partialDecrypt(i, t, ciphertext)
+			pds[i], zkps[i] = partial, proof
+			return err
+		})
+		partials, proofs = pds, zkps
+		return err
+	})
+	if err != nil {
+		if accused := mpc.Accused(); len(accused) > 0 && errors.Is(err, ErrPartyUnreachable) {
+			err = fmt.Errorf("%w: parties %v sent invalid decryption proofs, too few valid partial decryptions remain", ErrInvalidProof, accused)
+		}
+		fail(err)
+	}
+	mpc.recordRounds(1)
+
+	partialDecrypts := make([]*paillier.PartialDecryption, len(ids))
+	var used []*paillier.PartialDecryptionZKP
+	for k, i := range ids {
+		partialDecrypts[k] = partials[i]
+		if proofs[i] != nil {
+			used = append(used, proofs[i])
+		}
+	}
+
+	val, err := mpc.Tk.CombinePartialDecryptions(partialDecrypts)
+	if err != nil {
+		fail(fmt.Errorf("%w: %v", ErrDecryption, err))
+	}
+	mpc.record(&MPCTranscriptEntry{
+		Protocol: Decrypt,
+		CtIn:     []*paillier.Ciphertext{ciphertext},
+		PtOut:    val,
+		Proofs:   used,
+	})
 
-	var val *big.Int
-	var err error
+	return val
+}
 
-	partialDecrypts := make([]*paillier.PartialDecryption, len(mpc.Parties))
+// partialDecrypt asks party i for its partial decryption of ciphertext.
+// If mpc.Verify is set the party must prove it correct, and a partial
+// decryption whose proof fails is rejected with ErrInvalidProof. The proof
+// is nil unless mpc.Verify is set
+func (mpc *MPC) partialDecrypt(i int, t party.Transport, ciphertext *paillier.Ciphertext) (*paillier.PartialDecryption, *paillier.PartialDecryptionZKP, error) {
 
-	var wg sync.WaitGroup
-	for i := 0; i < len(mpc.Parties); i++ {
-		wg.Add(1)
-		go func(i int) {
-			defer wg.Done()
-			partialDecrypts[i] = mpc.Parties[i].PartialDecrypt(ciphertext)
-		}(i)
+	if !mpc.Verify {
+		partial, err := t.PartialDecrypt(mpc.ctx, ciphertext)
+		return partial, nil, err
 	}
-	wg.Wait()
 
-	val, err = mpc.Party.Sk.CombinePartialDecryptions(partialDecrypts)
+	proof, err := t.PartialDecryptAndProof(mpc.ctx, ciphertext)
 	if err != nil {
-		panic(err)
+		return nil, nil, err
 	}
 
-	return val
+	if !verifyPartial(mpc.Tk, i, ciphertext, proof) {
+		return nil, nil, &PartyError{Party: i, Err: ErrInvalidProof}
+	}
+
+	return &proof.PartialDecryption, proof, nil
+}
+
+// verifyPartial checks that proof shows the partial decryption of
+// ciphertext by party i under tk to be correct
+func verifyPartial(tk *paillier.ThresholdKey, i int, ciphertext *paillier.Ciphertext, proof *paillier.PartialDecryptionZKP) bool {
+
+	// the proof must be about this ciphertext and the key share of party i
+	if proof == nil || proof.Decryption == nil || proof.C == nil || proof.E == nil || proof.Z == nil {
+		return false
+	}
+	if proof.Id != i+1 || proof.C.Cmp(ciphertext.C) != 0 {
+		return false
+	}
+
+	// a decryption share that is not a unit mod N^2 cannot be checked
+	nsq := new(big.Int).Mul(tk.N, tk.N)
+	if new(big.Int).ModInverse(proof.Decryption, nsq) == nil {
+		return false
+	}
+
+	// verify against our threshold key rather than the one the party sent
+	checked := *proof
+	checked.Key = tk
+	return checked.Verify()
 }
 
-func (mpc *MPC) RevealFP(ciphertext *paillier.Ciphertext, scale int) *big.Float {
+func (mpc *MPC) revealFP(ciphertext *paillier.Ciphertext, scale int) *big.Float {
 
-	val := mpc.RevealInt(ciphertext)
+	val := mpc.revealInt(ciphertext)
 	scaleFactor := big.NewInt(0).Exp(big2, big.NewInt(int64(scale)), nil)
 	fp := big.NewFloat(0.0).SetInt(val)
 	fp.Quo(fp, big.NewFloat(0.0).SetInt(scaleFactor))
@@ -340,8 +508,7 @@ func (mpc *MPC) EBitsToEInteger(bits []*paillier.Ciphertext) *paillier.Ciphertex
 	return acc
 }
 
-// EBitsXOR computes the XOR of all the bits
-func (mpc *MPC) EBitsXOR(bits []*paillier.Ciphertext) *paillier.Ciphertext {
+func (mpc *MPC) eBitsXOR(bits []*paillier.Ciphertext) *paillier.Ciphertext {
 	return mpc.symmetricBooleanFunctionPaillier(bits, BooleanXOR)
 }
 
@@ -359,7 +526,7 @@ func (mpc *MPC) symmetricBooleanFunctionPaillier(bits []*paillier.Ciphertext, f
 		a[i] = sum
 	}
 
-	mul := mpc.EFanInMULT(a)
+	mul := mpc.eFanInMULT(a)
 
 	var poly []*big.Int
 	if f == BooleanOR {
@@ -376,3 +543,14 @@ func (mpc *MPC) symmetricBooleanFunctionPaillier(bits []*paillier.Ciphertext, f
 
 	return res
 }
+
+// answered returns the ciphertexts contributed by the parties that answered
+func answered(cts []*paillier.Ciphertext) []*paillier.Ciphertext {
+	res := make([]*paillier.Ciphertext, 0, len(cts))
+	for _, ct := range cts {
+		if ct != nil {
+			res = append(res, ct)
+		}
+	}
+	return res
+}
@@ -0,0 +1,334 @@
+package custodes
+
+import (
+	"context"
+	"custodes/party"
+	"custodes/zkp"
+	"errors"
+	"math/big"
+	"sort"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sachaservan/paillier"
+)
+
+// openedMask converts x with PaillierToShare and returns the masked value
+// the parties opened along the way, as recorded in the transcript
+func openedMask(t *testing.T, mpc *MPC, x *big.Int) *big.Int {
+	t.Helper()
+
+	mpc.Transcript = NewMPCTranscript()
+	defer func() { mpc.Transcript = nil }()
+
+	_, err := mpc.PaillierToShare(mpc.Pk.Encrypt(x))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var opened []*big.Int
+	for _, entry := range mpc.Transcript.Entries {
+		if entry.Protocol == Decrypt {
+			opened = append(opened, entry.PtOut)
+		}
+	}
+	if len(opened) != 1 {
+		t.Fatalf("PaillierToShare opened %d values, want 1", len(opened))
+	}
+
+	return opened[0]
+}
+
+// ksDistance returns the two-sample Kolmogorov-Smirnov statistic of a and
+// b, the largest gap between their empirical distribution functions
+func ksDistance(a, b []*big.Int) float64 {
+	sort.Slice(a, func(i, j int) bool { return a[i].Cmp(a[j]) < 0 })
+	sort.Slice(b, func(i, j int) bool { return b[i].Cmp(b[j]) < 0 })
+
+	var d float64
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		if a[i].Cmp(b[j]) <= 0 {
+			i++
+		} else {
+			j++
+		}
+		gap := float64(i)/float64(len(a)) - float64(j)/float64(len(b))
+		if gap < 0 {
+			gap = -gap
+		}
+		if gap > d {
+			d = gap
+		}
+	}
+	return d
+}
+
+func TestPaillierToShareHidesInput(t *testing.T) {
+
+	mpc := newTestMPC(t)
+	big2K := new(big.Int).Lsh(big.NewInt(1), uint(mpc.K))
+
+	// the two extremes of [-2^K, 2^K) are the easiest to tell apart
+	lo := new(big.Int).Sub(mpc.Pk.N, big2K)
+	hi := new(big.Int).Sub(big2K, big.NewInt(1))
+
+	const samples = 200
+	var fromLo, fromHi []*big.Int
+	for i := 0; i < samples; i++ {
+		fromLo = append(fromLo, openedMask(t, mpc, lo))
+		fromHi = append(fromHi, openedMask(t, mpc, hi))
+	}
+
+	// every opened value is x + 2^K + r for a mask r < n*2^(K+S), so it
+	// cannot reveal the sign of x by wrapping around
+	bound := new(big.Int).Lsh(big.NewInt(int64(len(mpc.Parties))), uint(mpc.K+mpc.S+1))
+	for _, v := range append(append([]*big.Int(nil), fromLo...), fromHi...) {
+		if v.Sign() < 0 || v.Cmp(bound) >= 0 {
+			t.Fatalf("opened value %v is outside [0, %v)", v, bound)
+		}
+	}
+
+	// the critical value of the two-sample test at significance 0.001 is
+	// 1.95*sqrt(2/samples)
+	if d := ksDistance(fromLo, fromHi); d > 0.195 {
+		t.Fatalf("opened values of %v and %v are distinguishable, KS distance %.3f", lo, hi, d)
+	}
+}
+
+func TestPaillierToShareWrapAround(t *testing.T) {
+
+	mpc := newTestMPC(t)
+	k := mpc.K
+	defer func() { mpc.K = k }()
+
+	// the largest K whose masked values n*2^(K+S) + 2^(K+1) stay below N
+	fits := func(k int) bool {
+		max := new(big.Int).Lsh(big.NewInt(int64(len(mpc.Parties))), uint(k+mpc.S))
+		max.Add(max, new(big.Int).Lsh(big.NewInt(1), uint(k+1)))
+		return max.Cmp(mpc.Pk.N) < 0
+	}
+	mpc.K = mpc.Pk.N.BitLen()
+	for !fits(mpc.K) {
+		mpc.K--
+	}
+
+	big2K := new(big.Int).Lsh(big.NewInt(1), uint(mpc.K))
+	for _, x := range []*big.Int{new(big.Int).Neg(big2K), new(big.Int).Sub(big2K, big.NewInt(1))} {
+		share, err := mpc.PaillierToShare(mpc.Pk.Encrypt(new(big.Int).Mod(x, mpc.Pk.N)))
+		if err != nil {
+			t.Fatalf("K = %d: %v", mpc.K, err)
+		}
+		got, err := mpc.RevealShare(share)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := new(big.Int).Mod(x, mpc.P); got.Cmp(want) != 0 {
+			t.Fatalf("K = %d: converted %v to %v, want %v", mpc.K, x, got, want)
+		}
+	}
+
+	// one more bit and the masked values could wrap around N
+	mpc.K++
+	_, err := mpc.PaillierToShare(mpc.Pk.Encrypt(big.NewInt(1)))
+	if !errors.Is(err, ErrParameterMismatch) {
+		t.Fatalf("K = %d: got %v, want ErrParameterMismatch", mpc.K, err)
+	}
+}
+
+// replayingParty hands in random bits it did not draw in the session it
+// is asked for: those of the party from, or its own from the first call
+type replayingParty struct {
+	party.Transport
+	from   party.Transport
+	cts    []*paillier.Ciphertext
+	proofs []*zkp.BitProof
+}
+
+func (r *replayingParty) GetRandomEncBitVector(ctx context.Context, m int, session []byte) ([]*paillier.Ciphertext, []*zkp.BitProof, error) {
+	if r.from != nil {
+		return r.from.GetRandomEncBitVector(ctx, m, session)
+	}
+	if r.cts == nil {
+		var err error
+		r.cts, r.proofs, err = r.Transport.GetRandomEncBitVector(ctx, m, session)
+		return r.cts, r.proofs, err
+	}
+	return r.cts, r.proofs, nil
+}
+
+func TestRandomBitsRefuseReplayedVectors(t *testing.T) {
+
+	// party 1 copies the bits of party 0, which would cancel them out
+	mpc := newTestMPC(t)
+	mpc.Parties[1] = &replayingParty{Transport: mpc.Parties[1], from: mpc.Parties[0]}
+	if _, err := mpc.ERandomBits(4); err != nil {
+		t.Fatal(err)
+	}
+	if online := mpc.Online(); len(online) != 2 || online[0] != 0 || online[1] != 2 {
+		t.Fatalf("parties %v are online after party 1 copied the bits of party 0, want [0 2]", online)
+	}
+
+	// party 2 hands in the bits of an earlier call again
+	mpc = newTestMPC(t)
+	mpc.Parties[2] = &replayingParty{Transport: mpc.Parties[2]}
+	for call := 0; call < 2; call++ {
+		if _, err := mpc.ERandomBits(4); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if online := mpc.Online(); len(online) != 2 || online[0] != 0 || online[1] != 1 {
+		t.Fatalf("parties %v are online after party 2 replayed its bits, want [0 1]", online)
+	}
+}
+
+// faultyDecrypter answers decryption requests with a partial decryption
+// or a proof that fault corrupts
+type faultyDecrypter struct {
+	party.Transport
+	fault func(proof *paillier.PartialDecryptionZKP)
+	calls int32
+}
+
+func (f *faultyDecrypter) PartialDecryptAndProof(ctx context.Context, ct *paillier.Ciphertext) (*paillier.PartialDecryptionZKP, error) {
+	atomic.AddInt32(&f.calls, 1)
+	proof, err := f.Transport.PartialDecryptAndProof(ctx, ct)
+	if err != nil {
+		return nil, err
+	}
+	bad := *proof
+	f.fault(&bad)
+	return &bad, nil
+}
+
+// slowDecrypter answers decryption requests late, so that the faulty
+// party is among the first to answer
+type slowDecrypter struct {
+	party.Transport
+}
+
+func (s *slowDecrypter) PartialDecryptAndProof(ctx context.Context, ct *paillier.Ciphertext) (*paillier.PartialDecryptionZKP, error) {
+	time.Sleep(100 * time.Millisecond)
+	return s.Transport.PartialDecryptAndProof(ctx, ct)
+}
+
+func TestRevealIntAccusesFaultyParty(t *testing.T) {
+
+	faults := map[string]func(proof *paillier.PartialDecryptionZKP){
+		"partial decryption": func(proof *paillier.PartialDecryptionZKP) {
+			// shifts the plaintext the partial decryptions combine to
+			nsq := new(big.Int).Mul(proof.Key.N, proof.Key.N)
+			shift := new(big.Int).Add(proof.Key.N, big.NewInt(1))
+			proof.Decryption = new(big.Int).Mod(new(big.Int).Mul(proof.Decryption, shift), nsq)
+		},
+		"proof": func(proof *paillier.PartialDecryptionZKP) {
+			proof.Z = new(big.Int).Add(proof.Z, big.NewInt(1))
+		},
+	}
+
+	for name, fault := range faults {
+		mpc := newTestMPC(t)
+		faulty := &faultyDecrypter{Transport: mpc.Parties[1], fault: fault}
+		mpc.Parties[0] = &slowDecrypter{mpc.Parties[0]}
+		mpc.Parties[1] = faulty
+		mpc.Parties[2] = &slowDecrypter{mpc.Parties[2]}
+
+		ct := mpc.Pk.Encrypt(big.NewInt(42))
+		if got := mpc.MustRevealInt(ct); got.Cmp(big.NewInt(42)) != 0 {
+			t.Fatalf("bad %s: decrypted %v, want 42", name, got)
+		}
+		if accused := mpc.Accused(); len(accused) != 1 || accused[0] != 1 {
+			t.Fatalf("bad %s: parties %v accused, want [1]", name, accused)
+		}
+		if online := mpc.Online(); len(online) != 2 || online[0] != 0 || online[1] != 2 {
+			t.Fatalf("bad %s: parties %v online, want [0 2]", name, online)
+		}
+
+		// the accused party is not asked again
+		calls := atomic.LoadInt32(&faulty.calls)
+		if got := mpc.MustRevealInt(ct); got.Cmp(big.NewInt(42)) != 0 {
+			t.Fatalf("bad %s: decrypted %v without the accused party, want 42", name, got)
+		}
+		if atomic.LoadInt32(&faulty.calls) != calls {
+			t.Fatalf("bad %s: the accused party was asked to decrypt again", name)
+		}
+	}
+}
+
+// signed maps x mod m to (-m/2, m/2]
+func signed(x, m *big.Int) *big.Int {
+	x = new(big.Int).Mod(x, m)
+	if x.Cmp(new(big.Int).Rsh(m, 1)) > 0 {
+		x.Sub(x, m)
+	}
+	return x
+}
+
+func TestShareToPaillierRoundTrip(t *testing.T) {
+
+	mpc := newTestMPC(t)
+	big2K := new(big.Int).Lsh(big.NewInt(1), uint(mpc.K))
+
+	for _, x := range []*big.Int{
+		big.NewInt(0),
+		big.NewInt(1),
+		big.NewInt(-7),
+		big.NewInt(123456789),
+		new(big.Int).Neg(big2K),
+		new(big.Int).Sub(big2K, big.NewInt(1)),
+	} {
+		ct, err := mpc.ShareToPaillier(mpc.MustCreateShares(new(big.Int).Mod(x, mpc.P)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := signed(mpc.MustRevealInt(ct), mpc.Pk.N); got.Cmp(x) != 0 {
+			t.Fatalf("share of %v converted to an encryption of %v", x, got)
+		}
+
+		share, err := mpc.PaillierToShare(ct)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := signed(mpc.MustRevealShare(share), mpc.P); got.Cmp(x) != 0 {
+			t.Fatalf("share of %v converted back to a share of %v", x, got)
+		}
+	}
+}
+
+func TestShareToPaillierFPRoundTrip(t *testing.T) {
+
+	mpc := newTestMPC(t)
+	fp := mpc.FPPrecBits
+
+	// TruncPR rounds either way, so the result may be off by one in the
+	// last place
+	near := func(got, want *big.Int) bool {
+		d := new(big.Int).Sub(got, want)
+		return d.CmpAbs(big.NewInt(1)) <= 0
+	}
+
+	for _, x := range []float64{0, 3.25, -2.5, 1000.125} {
+		want := mpc.EncodeFixedPoint(big.NewFloat(x), fp)
+
+		for _, scale := range []int{fp - 10, fp, 2 * fp} {
+			share := mpc.MustCreateShares(new(big.Int).Mod(mpc.EncodeFixedPoint(big.NewFloat(x), scale), mpc.P))
+
+			ct, err := mpc.ShareToPaillierFP(share, scale)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := signed(mpc.MustRevealInt(ct), mpc.Pk.N); !near(got, want) {
+				t.Fatalf("share of %v with scale %d converted to an encryption of %v, want %v", x, scale, got, want)
+			}
+
+			back, err := mpc.PaillierToShare(ct)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := signed(mpc.MustRevealShare(back), mpc.P); !near(got, want) {
+				t.Fatalf("share of %v with scale %d converted back to a share of %v, want %v", x, scale, got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package party
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// DefaultBatchWindow is how long a Batcher waits for the rest of a
+// round to be issued before sending the first request of the round
+const DefaultBatchWindow = 500 * time.Microsecond
+
+// Batcher is a Transport that coalesces the requests issued concurrently
+// to a party into a single message. A request waits up to the batch window
+// for the other requests of its round, so the goroutines of a parallel
+// sub-protocol share one round trip per round instead of one each
+type Batcher struct {
+	Client
+	t      Transport
+	window time.Duration
+
+	mu        sync.Mutex
+	pending   []*batchCall
+	scheduled bool
+}
+
+type batchCall struct {
+	ctx  context.Context
+	msg  *Message
+	res  *Result
+	err  error
+	done chan struct{}
+}
+
+// NewBatcher returns a Transport to t that batches the requests
+// issued within window of each other
+func NewBatcher(t Transport, window time.Duration) *Batcher {
+	b := &Batcher{t: t, window: window}
+	b.Send = b.send
+	return b
+}
+
+func (b *Batcher) send(ctx context.Context, msg *Message) (*Result, error) {
+
+	call := &batchCall{ctx: ctx, msg: msg, done: make(chan struct{})}
+
+	b.mu.Lock()
+	b.pending = append(b.pending, call)
+	if !b.scheduled {
+		b.scheduled = true
+		time.AfterFunc(b.window, b.flush)
+	}
+	b.mu.Unlock()
+
+	// the batch goes out regardless, but a cancelled caller stops waiting,
+	// and the batch is cancelled once no caller waits for it
+	select {
+	case <-call.done:
+		return call.res, call.err
+	case <-ctx.Done():
+		return &Result{}, ctx.Err()
+	}
+}
+
+// flush sends the pending requests as one batch. Batches do not wait
+// for each other, so a slow round does not hold up the next one
+func (b *Batcher) flush() {
+
+	b.mu.Lock()
+	calls := b.pending
+	b.pending = nil
+	b.scheduled = false
+	b.mu.Unlock()
+
+	b.sendBatch(calls)
+}
+
+func (b *Batcher) sendBatch(calls []*batchCall) {
+
+	if len(calls) == 1 {
+		calls[0].res, calls[0].err = Dispatch(calls[0].ctx, b.t, calls[0].msg)
+		close(calls[0].done)
+		return
+	}
+
+	ctx, cancel := batchContext(calls)
+	defer cancel()
+
+	batch := &Message{Op: OpBatch, Batch: make([]*Message, len(calls))}
+	for i, call := range calls {
+		batch.Batch[i] = call.msg
+	}
+
+	res, err := Dispatch(ctx, b.t, batch)
+	if err == nil && len(res.Batch) != len(calls) {
+		err = errors.New("batch reply does not match the request")
+	}
+
+	for i, call := range calls {
+		if err != nil {
+			call.res, call.err = &Result{}, err
+		} else {
+			call.res = res.Batch[i]
+			if call.res.Err != "" {
+				call.err = remoteError(call.res.Err)
+			}
+		}
+		close(call.done)
+	}
+}
+
+// batchContext returns a context for a batch of calls that is cancelled
+// once the context of every call is done, as a batch goes on as long as
+// one of its callers waits for it
+func batchContext(calls []*batchCall) (context.Context, context.CancelFunc) {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		for _, call := range calls {
+			select {
+			case <-call.ctx.Done():
+			case <-ctx.Done():
+				return
+			}
+		}
+		cancel()
+	}()
+
+	return ctx, cancel
+}
+
+// dispatchBatch answers every request of a batch concurrently, since
+// they were issued concurrently and may each wait on other parties
+func dispatchBatch(ctx context.Context, t Transport, msgs []*Message) []*Result {
+
+	results := make([]*Result, len(msgs))
+
+	var wg sync.WaitGroup
+	for i := range msgs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			res, err := Dispatch(ctx, t, msgs[i])
+			if err != nil {
+				res.Err = err.Error()
+			}
+			results[i] = res
+		}(i)
+	}
+	wg.Wait()
+
+	return results
+}
@@ -0,0 +1,86 @@
+package party
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// blockingParty answers pings only once their context is done, and
+// reports when it started and stopped waiting
+type blockingParty struct {
+	Transport
+	started chan struct{}
+	stopped chan struct{}
+}
+
+func newBlockingParty() *blockingParty {
+	return &blockingParty{started: make(chan struct{}, 8), stopped: make(chan struct{}, 8)}
+}
+
+func (p *blockingParty) Ping(ctx context.Context) error {
+	p.started <- struct{}{}
+	<-ctx.Done()
+	p.stopped <- struct{}{}
+	return ctx.Err()
+}
+
+// await waits for n signals on c, or fails the test after a second
+func await(t *testing.T, c chan struct{}, n int, what string) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		select {
+		case <-c:
+		case <-time.After(time.Second):
+			t.Fatalf("%d of %d requests %s", i, n, what)
+		}
+	}
+}
+
+func TestBatcherCancelsAbandonedBatch(t *testing.T) {
+
+	p := newBlockingParty()
+	b := NewBatcher(p, 50*time.Millisecond)
+
+	ctx1, cancel1 := context.WithCancel(context.Background())
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	defer cancel1()
+	defer cancel2()
+
+	errs := make(chan error, 2)
+	go func() { errs <- b.Ping(ctx1) }()
+	go func() { errs <- b.Ping(ctx2) }()
+	await(t, p.started, 2, "reached the party")
+
+	// the batch goes on while one of its callers still waits
+	cancel1()
+	if err := <-errs; err != context.Canceled {
+		t.Fatalf("cancelled caller got %v, want context.Canceled", err)
+	}
+	select {
+	case <-p.stopped:
+		t.Fatal("batch cancelled while a caller still waits for it")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	cancel2()
+	await(t, p.stopped, 2, "were cancelled at the party once every caller gave up")
+	if err := <-errs; err != context.Canceled {
+		t.Fatalf("cancelled caller got %v, want context.Canceled", err)
+	}
+}
+
+func TestBatcherPassesDeadline(t *testing.T) {
+
+	p := newBlockingParty()
+	b := NewBatcher(p, time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if err := b.Ping(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("got %v, want context.DeadlineExceeded", err)
+	}
+	await(t, p.started, 1, "reached the party")
+	await(t, p.stopped, 1, "ran into the deadline at the party")
+}
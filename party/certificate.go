@@ -0,0 +1,271 @@
+package party
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/sachaservan/paillier"
+)
+
+// Statement is the result of a hypothesis test as the parties certify it
+type Statement struct {
+	Test            string // type of test, such as T-Test
+	NumRows         int
+	NumCols         int
+	MessageBits     int
+	FPPrecisionBits int
+	DatasetHash     []byte // commitment to the encrypted dataset the test ran on
+	KeyFingerprint  []byte // KeyFingerprint of the Paillier key the dataset is encrypted under
+	Statistic       string // revealed value of the statistic, in decimal
+	TranscriptHash  []byte // head of the transcript of the run, nil if none was recorded
+	SpecHash        []byte // hash of the preregistered spec of the test, nil if none
+	TestIndex       int    // position of the test among the tests on its dataset
+	Threshold       string // significance threshold after correction for the tests before, in decimal
+	PValue          string // p-value of the statistic, in decimal
+}
+
+// Encode returns the canonical encoding of the statement, which is what
+// the parties sign. Every variable length field is prefixed with its length
+func (st *Statement) Encode() []byte {
+
+	var buf []byte
+	putInt := func(x int) {
+		var b [8]byte
+		binary.BigEndian.PutUint64(b[:], uint64(int64(x)))
+		buf = append(buf, b[:]...)
+	}
+	putBytes := func(b []byte) {
+		putInt(len(b))
+		buf = append(buf, b...)
+	}
+
+	putBytes([]byte("custodes statement"))
+	putBytes([]byte(st.Test))
+	putInt(st.NumRows)
+	putInt(st.NumCols)
+	putInt(st.MessageBits)
+	putInt(st.FPPrecisionBits)
+	putBytes(st.DatasetHash)
+	putBytes(st.KeyFingerprint)
+	putBytes([]byte(st.Statistic))
+	putBytes(st.TranscriptHash)
+	putBytes(st.SpecHash)
+	putInt(st.TestIndex)
+	putBytes([]byte(st.Threshold))
+	putBytes([]byte(st.PValue))
+
+	return buf
+}
+
+// KeyFingerprint returns a hash identifying the Paillier public key
+func KeyFingerprint(pk *paillier.PublicKey) []byte {
+	h := sha256.New()
+	h.Write([]byte("custodes key"))
+	h.Write(pk.N.Bytes())
+	return h.Sum(nil)
+}
+
+// Piece is a party's piece of the share of the statistic of a test, as
+// the party signed it when the statistic was opened
+type Piece struct {
+	Party     int
+	Share     int // id of the share of the statistic
+	Value     *big.Int
+	Signature []byte
+}
+
+// encode returns the canonical encoding of the piece, which is what the
+// party signs
+func (piece *Piece) encode() []byte {
+	buf := []byte("custodes piece")
+	var b [8]byte
+	for _, x := range []int{piece.Party, piece.Share} {
+		binary.BigEndian.PutUint64(b[:], uint64(int64(x)))
+		buf = append(buf, b[:]...)
+	}
+	return append(buf, piece.Value.Bytes()...)
+}
+
+// Opening is the final opening of the statistic of a test: the signed
+// pieces of its share, the number of fractional bits of the statistic and
+// the head of the transcript of the run, nil if none was recorded
+type Opening struct {
+	Pieces []*Piece
+	Scale  int
+	Head   []byte
+}
+
+// result is a statistic a party saw opened and the transcript head the
+// opening came with
+type result struct {
+	statistic string
+	head      string
+}
+
+// results are the statistics a party saw opened
+type results struct {
+	mu   sync.Mutex
+	seen map[result]bool
+}
+
+// Statistic returns the signed fixed point number with scale fractional
+// bits that value encodes mod p. The parties and the coordinator read the
+// opening of a statistic with it, so that they agree on its decimal form
+func Statistic(value, p *big.Int, scale int) *big.Float {
+	v := new(big.Int).Mod(value, p)
+	if v.Cmp(new(big.Int).Rsh(p, 1)) > 0 {
+		v.Sub(v, p)
+	}
+	fp := big.NewFloat(0).SetInt(v)
+	return fp.Quo(fp, big.NewFloat(0).SetInt(new(big.Int).Lsh(big.NewInt(1), uint(scale))))
+}
+
+// RevealPiece reveals the party's piece of the share of a statistic,
+// signed so that the other parties can check the opening
+func (party *Party) RevealPiece(ctx context.Context, share *Share) (*Piece, error) {
+
+	if party.Identity == nil {
+		return nil, fmt.Errorf("%w: party %d has no identity key", ErrParameterMismatch, party.ID)
+	}
+	value, err := party.RevealShare(ctx, share)
+	if err != nil {
+		return nil, err
+	}
+
+	piece := &Piece{Party: party.ID, Share: share.ID, Value: value}
+	piece.Signature = ed25519.Sign(party.Identity.Key, piece.encode())
+	return piece, nil
+}
+
+// Value returns the value the opening reconstructs mod p, once its pieces
+// are of one share, signed by at least threshold distinct nodes of roster
+// and lie on one polynomial of degree below threshold
+func (opening *Opening) Value(p *big.Int, threshold int, roster Roster) (*big.Int, error) {
+
+	if opening == nil || len(opening.Pieces) < threshold || opening.Scale < 0 {
+		return nil, fmt.Errorf("%w: the opening has too few pieces", ErrParameterMismatch)
+	}
+
+	ids := make([]int, len(opening.Pieces))
+	seen := make(map[int]bool)
+	for k, piece := range opening.Pieces {
+		if piece == nil || piece.Value == nil || seen[piece.Party] || piece.Share != opening.Pieces[0].Share {
+			return nil, fmt.Errorf("%w: malformed piece %d of the opening", ErrParameterMismatch, k)
+		}
+		key, err := roster.PublicKey(piece.Party)
+		if err != nil || piece.Party < 0 || !ed25519.Verify(key, piece.encode(), piece.Signature) {
+			return nil, fmt.Errorf("%w: piece of party %d is not signed by it", ErrParameterMismatch, piece.Party)
+		}
+		seen[piece.Party] = true
+		ids[k] = piece.Party
+	}
+
+	// any threshold pieces of a sharing give the same value, so
+	// replacing the last of the first threshold with any other does too
+	t := threshold
+	reconstruct := func(ks []int) *big.Int {
+		sub := make([]int, len(ks))
+		for j, k := range ks {
+			sub[j] = ids[k]
+		}
+		value := big.NewInt(0)
+		for _, k := range ks {
+			term := LagrangeCoefficient(ids[k], sub, p)
+			term.Mul(term, opening.Pieces[k].Value)
+			value.Add(value, term)
+		}
+		return value.Mod(value, p)
+	}
+	first := make([]int, t)
+	for k := range first {
+		first[k] = k
+	}
+	value := reconstruct(first)
+	for k := t; k < len(ids); k++ {
+		first[t-1] = k
+		if reconstruct(first).Cmp(value) != 0 {
+			return nil, fmt.Errorf("%w: the pieces of the opening do not agree", ErrParameterMismatch)
+		}
+	}
+
+	return value, nil
+}
+
+// RecordStatistic records the opening of a statistic, once the pieces are
+// signed by at least Threshold distinct parties and lie on one polynomial.
+// The party signs statements only of a statistic it recorded, with the
+// transcript head that came with it. Under preregistration, the statistic
+// is the one of the running test, and goes into its ledger entry
+func (party *Party) RecordStatistic(ctx context.Context, opening *Opening) error {
+
+	value, err := opening.Value(party.P, party.Threshold, party.Roster)
+	if err != nil {
+		return err
+	}
+
+	stat := Statistic(value, party.P, opening.Scale).Text('g', -1)
+	if party.Analyst != nil {
+		return party.recordStatistic(stat, opening.Head)
+	}
+
+	party.results.mu.Lock()
+	defer party.results.mu.Unlock()
+
+	if party.results.seen == nil {
+		party.results.seen = make(map[result]bool)
+	}
+	party.results.seen[result{stat, string(opening.Head)}] = true
+
+	return nil
+}
+
+// sawStatistic reports whether the party recorded an opening of the
+// statistic with the given transcript head
+func (party *Party) sawStatistic(stat string, head []byte) bool {
+	party.results.mu.Lock()
+	defer party.results.mu.Unlock()
+
+	return party.results.seen[result{stat, string(head)}]
+}
+
+// SignStatement signs the encoding of the statement with the identity
+// key of the party, once the statement names the key the party holds a
+// share of. The party vouches for a result of the computation it took
+// part in; it does not recompute the result, but it signs only a
+// statistic it saw opened, with the transcript head that came with the
+// opening. Under preregistration, the statement must name a spec of the
+// same test that ran to the end and match the entry of the test in the
+// ledger, which holds the statistic the test opened
+func (party *Party) SignStatement(ctx context.Context, st *Statement) ([]byte, error) {
+
+	if party.Identity == nil {
+		return nil, fmt.Errorf("%w: party %d has no identity key", ErrParameterMismatch, party.ID)
+	}
+	if st == nil || party.Pk == nil || !bytes.Equal(st.KeyFingerprint, KeyFingerprint(party.Pk)) {
+		return nil, fmt.Errorf("%w: the statement is not about the key of party %d", ErrParameterMismatch, party.ID)
+	}
+	if party.Analyst == nil && !party.sawStatistic(st.Statistic, st.TranscriptHash) {
+		return nil, fmt.Errorf("%w: party %d saw no opening of statistic %q with transcript %x", ErrParameterMismatch, party.ID, st.Statistic, st.TranscriptHash)
+	}
+	if party.Analyst != nil {
+		spec, entry, ok := party.ran(st.SpecHash)
+		if !ok || spec.Test != st.Test {
+			return nil, fmt.Errorf("%w: party %d ran no %s test with spec %x", ErrPreregistration, party.ID, st.Test, st.SpecHash)
+		}
+		want := *st
+		entry.Annotate(&want)
+		if st.Statistic != entry.Statistic || !bytes.Equal(st.TranscriptHash, entry.TranscriptHash) ||
+			st.NumRows != entry.NumRows || st.NumCols != entry.NumCols ||
+			st.TestIndex != want.TestIndex || st.Threshold != want.Threshold || st.PValue != want.PValue {
+			return nil, fmt.Errorf("%w: the statement does not match the ledger of party %d", ErrPreregistration, party.ID)
+		}
+	}
+
+	return ed25519.Sign(party.Identity.Key, st.Encode()), nil
+}
@@ -0,0 +1,30 @@
+package party
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrShareNotFound is returned when a party does not hold a share a request refers to
+var ErrShareNotFound = errors.New("share not found")
+
+// ErrParameterMismatch is returned when a request does not fit the parameters of the party
+var ErrParameterMismatch = errors.New("parameter mismatch")
+
+// remoteErrors are the errors callers tell apart. Only their message
+// reaches a remote caller, so remoteError turns it back into the error
+var remoteErrors = []error{ErrShareNotFound, ErrParameterMismatch, ErrInvalidShare, ErrPreregistration}
+
+// remoteError returns the error a party answered with msg
+func remoteError(msg string) error {
+	for _, known := range remoteErrors {
+		if msg == known.Error() {
+			return known
+		}
+		if strings.HasPrefix(msg, known.Error()+": ") {
+			return fmt.Errorf("%w%s", known, strings.TrimPrefix(msg, known.Error()))
+		}
+	}
+	return errors.New(msg)
+}
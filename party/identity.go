@@ -0,0 +1,142 @@
+package party
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"strconv"
+	"time"
+)
+
+// Identity is the long term key of a node (a party or the coordinator)
+// and the self-signed certificate other nodes pin it to
+type Identity struct {
+	ID   int
+	Cert []byte // DER encoded certificate
+	Key  ed25519.PrivateKey
+}
+
+// Roster maps the id of every node in the system to its certificate
+type Roster map[int][]byte
+
+// NewIdentity generates a fresh identity for the node
+func NewIdentity(id int) (*Identity, error) {
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(int64(id) + 2),
+		Subject:      pkix.Name{CommonName: nodeName(id)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().AddDate(10, 0, 0),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+
+	cert, err := x509.CreateCertificate(rand.Reader, template, template, pub, priv)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Identity{ID: id, Cert: cert, Key: priv}, nil
+}
+
+// NewRoster returns the roster of the given identities
+func NewRoster(ids ...*Identity) Roster {
+	roster := make(Roster)
+	for _, id := range ids {
+		roster[id.ID] = id.Cert
+	}
+	return roster
+}
+
+// Lookup returns the id of the node holding the certificate
+func (roster Roster) Lookup(cert []byte) (int, error) {
+	for id, c := range roster {
+		if bytes.Equal(c, cert) {
+			return id, nil
+		}
+	}
+	return 0, errors.New("certificate does not belong to any known party")
+}
+
+// PublicKey returns the key in the certificate of node id
+func (roster Roster) PublicKey(id int) (ed25519.PublicKey, error) {
+
+	der, ok := roster[id]
+	if !ok {
+		return nil, errors.New("unknown party " + strconv.Itoa(id))
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+
+	key, ok := cert.PublicKey.(ed25519.PublicKey)
+	if !ok {
+		return nil, errors.New("party " + strconv.Itoa(id) + " does not have an Ed25519 identity")
+	}
+
+	return key, nil
+}
+
+// ServerConfig returns the TLS config of a node accepting connections
+// only from nodes in the roster
+func (id *Identity) ServerConfig(roster Roster) *tls.Config {
+	return &tls.Config{
+		Certificates: []tls.Certificate{id.tlsCertificate()},
+		ClientAuth:   tls.RequireAnyClientCert,
+		MinVersion:   tls.VersionTLS13,
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 {
+				return errors.New("no client certificate")
+			}
+			_, err := roster.Lookup(rawCerts[0])
+			return err
+		},
+	}
+}
+
+// ClientConfig returns the TLS config of a node connecting to node peer.
+// The connection fails unless the server presents peer's certificate
+func (id *Identity) ClientConfig(roster Roster, peer int) *tls.Config {
+	return &tls.Config{
+		Certificates: []tls.Certificate{id.tlsCertificate()},
+		MinVersion:   tls.VersionTLS13,
+		// the certificate is pinned below instead of verified against a CA
+		InsecureSkipVerify: true,
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			expected, ok := roster[peer]
+			if !ok {
+				return errors.New("unknown party " + strconv.Itoa(peer))
+			}
+			if len(rawCerts) == 0 || !bytes.Equal(rawCerts[0], expected) {
+				return errors.New("certificate of party " + strconv.Itoa(peer) + " does not match the roster")
+			}
+			return nil
+		},
+	}
+}
+
+func (id *Identity) tlsCertificate() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{id.Cert}, PrivateKey: id.Key}
+}
+
+func nodeName(id int) string {
+	switch id {
+	case Coordinator:
+		return "custodes coordinator"
+	case AnalystID:
+		return "custodes analyst"
+	}
+	return "custodes party " + strconv.Itoa(id)
+}
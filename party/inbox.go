@@ -0,0 +1,192 @@
+package party
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+var errDealerOffline = errors.New("a party dealing the share went offline")
+
+var errInboxDeleted = errors.New("share deleted before all pieces arrived")
+
+// inbox collects the pieces of a share that is dealt jointly, as in the
+// degree reduction of Mult. Every online party deals a sharing of its
+// contribution, and the receiving party adds up its pieces once all of
+// them have arrived
+type inbox struct {
+	dealers map[int]bool     // parties expected to deal a piece
+	pieces  map[int]*big.Int // pieces received so far, by dealer
+	dealt   map[int][]byte   // hashes of the commitments of the pieces, by dealer, with VSS
+	done    chan struct{}    // closed once the share is stored or err is set
+	err     error
+}
+
+// Reshare delivers the piece of share dealt to the party by party from.
+// A piece that does not match the commitments of its dealer fails the share
+func (party *Party) Reshare(ctx context.Context, share *Share, from int, value *big.Int, proof *VSSProof) error {
+
+	// checking the commitments is slow, so do it before taking the lock
+	valid := party.validShare(value, proof)
+
+	party.inboxMu.Lock()
+	defer party.inboxMu.Unlock()
+
+	// the inbox of a complete share is gone once its pieces were combined
+	if _, ok := party.inboxes[share.ID]; !ok {
+		if _, ok := party.shares.Load(share.ID); ok {
+			return fmt.Errorf("%w: share %d is already complete", ErrParameterMismatch, share.ID)
+		}
+	}
+
+	box := party.inbox(share.ID)
+	if box.err != nil {
+		return box.err
+	}
+	if box.pieces == nil {
+		return fmt.Errorf("%w: share %d is already complete", ErrParameterMismatch, share.ID)
+	}
+	if !box.dealers[from] {
+		return fmt.Errorf("%w: party %d does not deal share %d", ErrParameterMismatch, from, share.ID)
+	}
+	if _, ok := box.pieces[from]; ok {
+		return fmt.Errorf("%w: party %d already dealt share %d", ErrParameterMismatch, from, share.ID)
+	}
+	if !valid {
+		err := fmt.Errorf("%w: party %d rejected the piece of share %d dealt by party %d", ErrInvalidShare, party.ID, share.ID, from)
+		box.fail(err)
+		return err
+	}
+
+	box.pieces[from] = value
+	if party.VSS != nil {
+		box.dealt[from] = proof.hash()
+	}
+	if len(box.pieces) < len(box.dealers) {
+		return nil
+	}
+
+	sum := big.NewInt(0)
+	for _, piece := range box.pieces {
+		sum.Add(sum, piece)
+	}
+	sum.Mod(sum, party.P)
+
+	if party.VSS == nil {
+		party.store(share.ID, sum)
+	} else {
+		party.storeDealt(share.ID, sum, dealingsHash(box.dealt))
+	}
+	box.pieces = nil
+	close(box.done)
+	return nil
+}
+
+// deal shares s with the online parties as this party's piece of share id
+func (party *Party) deal(ctx context.Context, s *big.Int, id int) error {
+	_, values, proofs, _ := party.CreateVerifiableShares(s, id)
+	return party.DistributeReshares(ctx, id, values, proofs)
+}
+
+// DistributeReshares deals values[i] to every online party i as this
+// party's piece of share id. It delivers every piece even if one is
+// refused, so that no party is left waiting, and returns the first error
+func (party *Party) DistributeReshares(ctx context.Context, id int, values []*big.Int, proofs []*VSSProof) error {
+	var first error
+	for i := 0; i < len(party.Parties); i++ {
+		if !party.isOnline(i) {
+			continue
+		}
+		err := party.Parties[i].Reshare(ctx, &Share{PartyID: i, ID: id}, party.ID, values[i], proofs[i])
+		if err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// awaitReshares waits until every piece of share id has arrived and
+// drops the inbox once the share is stored. With VSS, the share is then
+// confirmed against the other parties. A failed inbox stays until the
+// share is deleted, so that late pieces are refused
+func (party *Party) awaitReshares(ctx context.Context, id int) error {
+
+	party.inboxMu.Lock()
+	box := party.inbox(id)
+	party.inboxMu.Unlock()
+
+	select {
+	case <-box.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	party.inboxMu.Lock()
+	if box.err != nil {
+		party.inboxMu.Unlock()
+		return box.err
+	}
+	if party.inboxes[id] == box {
+		delete(party.inboxes, id)
+	}
+	party.inboxMu.Unlock()
+
+	if party.VSS == nil {
+		return nil
+	}
+	return party.confirm(ctx, id)
+}
+
+// inbox returns the inbox of share id, expecting a piece from every
+// online party if it is new. The caller must hold inboxMu
+func (party *Party) inbox(id int) *inbox {
+
+	if box, ok := party.inboxes[id]; ok {
+		return box
+	}
+
+	box := &inbox{
+		dealers: make(map[int]bool),
+		pieces:  make(map[int]*big.Int),
+		dealt:   make(map[int][]byte),
+		done:    make(chan struct{}),
+	}
+	for i := 0; i < len(party.Parties); i++ {
+		if party.isOnline(i) {
+			box.dealers[i] = true
+		}
+	}
+
+	if party.inboxes == nil {
+		party.inboxes = make(map[int]*inbox)
+	}
+	party.inboxes[id] = box
+	return box
+}
+
+// failInboxes gives up on the incomplete inboxes for which f returns an error
+func (party *Party) failInboxes(f func(box *inbox) error) {
+
+	party.inboxMu.Lock()
+	defer party.inboxMu.Unlock()
+
+	for _, box := range party.inboxes {
+		if box.pieces == nil || box.err != nil {
+			continue
+		}
+		if err := f(box); err != nil {
+			box.fail(err)
+		}
+	}
+}
+
+// fail gives up on the inbox unless it is complete. The caller must hold inboxMu
+func (box *inbox) fail(err error) {
+	if box.pieces == nil || box.err != nil {
+		return
+	}
+	box.err = err
+	box.pieces = nil
+	close(box.done)
+}
@@ -0,0 +1,298 @@
+package party
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/sachaservan/paillier"
+)
+
+// keyGen is what a party keeps to itself during distributed key
+// generation: its additive parts of the candidate primes and of the masks,
+// by the id of the share they were dealt as, and its share of the
+// decryption key once it has been dealt
+type keyGen struct {
+	mu      sync.Mutex
+	secrets map[int]*big.Int
+	share   *big.Int
+}
+
+// DealPrimeShare picks a random multiple of 4 below 2^bits as this party's
+// additive part of a candidate prime and deals it as share id. The
+// coordinator adds a public offset that is 3 mod 4
+func (party *Party) DealPrimeShare(ctx context.Context, bits int, id int) (*Share, error) {
+
+	if bits < 3 {
+		return nil, fmt.Errorf("%w: %d bit prime shares", ErrParameterMismatch, bits)
+	}
+
+	r := CryptoRandom(new(big.Int).Lsh(big.NewInt(1), uint(bits-2)))
+	return party.dealSecret(ctx, r.Lsh(r, 2), id)
+}
+
+// DealKeyMask picks a random value below 2^bits as this party's additive
+// part of the mask that converts the decryption key into integer shares,
+// and deals it as share id
+func (party *Party) DealKeyMask(ctx context.Context, bits int, id int) (*Share, error) {
+
+	if bits < 1 {
+		return nil, fmt.Errorf("%w: %d bit mask", ErrParameterMismatch, bits)
+	}
+
+	return party.dealSecret(ctx, CryptoRandom(new(big.Int).Lsh(big.NewInt(1), uint(bits))), id)
+}
+
+// dealSecret remembers r and deals it to the online parties as this
+// party's piece of share id
+func (party *Party) dealSecret(ctx context.Context, r *big.Int, id int) (*Share, error) {
+
+	party.keygen.mu.Lock()
+	if party.keygen.secrets == nil {
+		party.keygen.secrets = make(map[int]*big.Int)
+	}
+	party.keygen.secrets[id] = r
+	party.keygen.mu.Unlock()
+
+	err := party.deal(ctx, r, id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = party.awaitReshares(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Share{party.ID, id}, nil
+}
+
+// secret returns this party's additive part of share id
+func (party *Party) secret(id int) (*big.Int, error) {
+
+	party.keygen.mu.Lock()
+	defer party.keygen.mu.Unlock()
+
+	r, ok := party.keygen.secrets[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: no key generation secret for id %d", ErrShareNotFound, id)
+	}
+	return r, nil
+}
+
+// forgetSecret discards this party's additive part of share id, if any
+func (party *Party) forgetSecret(id int) {
+	party.keygen.mu.Lock()
+	delete(party.keygen.secrets, id)
+	party.keygen.mu.Unlock()
+}
+
+// BiprimeTest returns g^((p_i+q_i)/4) mod n for every g in gs, where p_i
+// and q_i are this party's parts of the candidate primes p and q whose
+// product is n. The coordinator compares their product with the powers of
+// g it computes from n alone, as in the test of Boneh and Franklin
+func (party *Party) BiprimeTest(ctx context.Context, p, q *Share, n *big.Int, gs []*big.Int) ([]*big.Int, error) {
+
+	pi, err := party.secret(p.ID)
+	if err != nil {
+		return nil, err
+	}
+	qi, err := party.secret(q.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	e := new(big.Int).Add(pi, qi)
+	e.Rsh(e, 2)
+
+	vs := make([]*big.Int, len(gs))
+	for k, g := range gs {
+		if g == nil || g.Sign() <= 0 || g.Cmp(n) >= 0 {
+			return nil, fmt.Errorf("%w: biprimality base out of range", ErrParameterMismatch)
+		}
+		vs[k] = new(big.Int).Exp(g, e, n)
+	}
+
+	return vs, nil
+}
+
+// DealKeyShare turns the decryption key d into integer shares. Opening
+// d + r for the mask r dealt by DealKeyMask gave y. This party deals an
+// integer polynomial with constant term -r_i, its part of the mask, and
+// coefficients of bits bits, adds up the pieces it receives and y, and
+// keeps the result as its share of d. It returns the verification key
+// v^(delta*share) mod n^2, with delta = n!
+func (party *Party) DealKeyShare(ctx context.Context, mask *Share, y, n, v *big.Int, bits int, id int) (*big.Int, error) {
+
+	r, err := party.secret(mask.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	coeffs := make([]*big.Int, party.Threshold)
+	coeffs[0] = new(big.Int).Neg(r)
+	for k := 1; k < party.Threshold; k++ {
+		coeffs[k] = CryptoRandom(new(big.Int).Lsh(big.NewInt(1), uint(bits)))
+	}
+
+	_, values, proofs := party.verifiableShares(coeffs, id)
+	err = party.DistributeReshares(ctx, id, values, proofs)
+	if err != nil {
+		return nil, err
+	}
+
+	err = party.awaitReshares(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	sum, err := party.getShare(id)
+	if err != nil {
+		return nil, err
+	}
+	party.shares.Delete(id)
+
+	// the integer polynomial has constant term d and positive coefficients,
+	// and it stays below P, so the sum mod P is the integer share
+	share := sum.Add(sum, y)
+	share.Mod(share, party.P)
+
+	party.keygen.mu.Lock()
+	party.keygen.share = share
+	party.keygen.mu.Unlock()
+
+	return verificationKey(v, share, n, len(party.Parties)), nil
+}
+
+// KeyTest is a test decryption under a new threshold key, which every
+// party checks before it installs the key. The ciphertext is the
+// encryption of M with randomness R, which every party computes itself,
+// so the test decrypts nothing the coordinator does not know already
+type KeyTest struct {
+	M      *big.Int
+	R      *big.Int
+	Proofs []*paillier.PartialDecryptionZKP // partial decryptions under the new key, by party
+}
+
+// ciphertext returns the encryption the test decrypts
+func (test *KeyTest) ciphertext(pk *paillier.PublicKey) *paillier.Ciphertext {
+	return pk.EncryptWithR(test.M, test.R)
+}
+
+// check returns an error unless the partial decryptions of every party
+// prove correct under tk and combine to M
+func (test *KeyTest) check(tk *paillier.ThresholdKey) error {
+
+	if test == nil || test.M == nil || test.R == nil || len(test.Proofs) != tk.TotalNumberOfDecryptionServers {
+		return fmt.Errorf("%w: no test decryption under the new key", ErrParameterMismatch)
+	}
+
+	ct := test.ciphertext(&tk.PublicKey)
+	partials := make([]*paillier.PartialDecryption, len(test.Proofs))
+	for j, proof := range test.Proofs {
+		if proof == nil || proof.Id != j+1 || proof.C == nil || proof.C.Cmp(ct.C) != 0 {
+			return fmt.Errorf("%w: party %d did not decrypt the test ciphertext", ErrInvalidShare, j)
+		}
+
+		// the proof is checked against the new key, not the one it carries
+		checked := *proof
+		checked.Key = tk
+		if !checked.Verify() {
+			return fmt.Errorf("%w: the test decryption of party %d does not verify under the new key", ErrInvalidShare, j)
+		}
+		partials[j] = &checked.PartialDecryption
+	}
+
+	m, err := tk.CombinePartialDecryptions(partials)
+	if err != nil || m.Cmp(new(big.Int).Mod(test.M, tk.N)) != 0 {
+		return fmt.Errorf("%w: the new key shares do not decrypt the test ciphertext", ErrInvalidShare)
+	}
+	return nil
+}
+
+// TestKeyShare returns the partial decryption of the test ciphertext under
+// the share of the decryption key this party set aside, with a proof
+// against tk
+func (party *Party) TestKeyShare(ctx context.Context, tk *paillier.ThresholdKey, test *KeyTest) (*paillier.PartialDecryptionZKP, error) {
+
+	share, err := party.pendingKey(tk)
+	if err != nil {
+		return nil, err
+	}
+	if test == nil || test.M == nil || test.R == nil {
+		return nil, fmt.Errorf("%w: no test decryption under the new key", ErrParameterMismatch)
+	}
+
+	sk := &paillier.ThresholdPrivateKey{ThresholdKey: *tk, Id: party.ID + 1, Share: share}
+	return sk.DecryptAndProduceZKP(test.ciphertext(&tk.PublicKey).C)
+}
+
+// SetThresholdKey installs the public key produced by distributed key
+// generation or by a key refresh, along with the share of the decryption
+// key this party set aside, once the test decryption of every party
+// proves correct under the key and combines to the plaintext
+func (party *Party) SetThresholdKey(ctx context.Context, tk *paillier.ThresholdKey, test *KeyTest) error {
+
+	share, err := party.pendingKey(tk)
+	if err != nil {
+		return err
+	}
+	if err := test.check(tk); err != nil {
+		return err
+	}
+
+	sk := &paillier.ThresholdPrivateKey{ThresholdKey: *tk, Id: party.ID + 1, Share: share}
+	if party.SaveKey != nil {
+		err := party.SaveKey(sk)
+		if err != nil {
+			return err
+		}
+	}
+
+	party.Sk = sk
+	party.Pk = &party.Sk.PublicKey
+
+	party.keygen.mu.Lock()
+	party.keygen.share = nil
+	party.keygen.mu.Unlock()
+
+	return nil
+}
+
+// pendingKey returns the share of the decryption key this party set aside
+// if tk fits it. A refresh must keep the modulus and the verification
+// base, and the verification keys must differ from the old ones by a
+// sharing of zero
+func (party *Party) pendingKey(tk *paillier.ThresholdKey) (*big.Int, error) {
+
+	party.keygen.mu.Lock()
+	share := party.keygen.share
+	party.keygen.mu.Unlock()
+
+	if share == nil {
+		return nil, fmt.Errorf("%w: no share of the decryption key", ErrShareNotFound)
+	}
+	if tk == nil || tk.N == nil || tk.V == nil || len(tk.Vi) != len(party.Parties) || tk.TotalNumberOfDecryptionServers != len(party.Parties) || tk.Threshold != party.Threshold {
+		return nil, fmt.Errorf("%w: threshold key does not fit the parties", ErrParameterMismatch)
+	}
+	if party.Sk != nil && (party.Sk.N.Cmp(tk.N) != 0 || party.Sk.V.Cmp(tk.V) != 0) {
+		return nil, fmt.Errorf("%w: threshold key has a different modulus", ErrParameterMismatch)
+	}
+	if tk.Vi[party.ID] == nil || tk.Vi[party.ID].Cmp(verificationKey(tk.V, share, tk.N, len(party.Parties))) != 0 {
+		return nil, fmt.Errorf("%w: threshold key does not match the key share of party %d", ErrParameterMismatch, party.ID)
+	}
+	if party.Sk != nil && !KeyRefreshed(&party.Sk.ThresholdKey, tk, party.Threshold) {
+		return nil, fmt.Errorf("%w: the refreshed key shares do not fit the key", ErrInvalidShare)
+	}
+
+	return share, nil
+}
+
+// verificationKey returns v^(delta*share) mod n^2 for delta = parties!
+func verificationKey(v, share, n *big.Int, parties int) *big.Int {
+	nsq := new(big.Int).Mul(n, n)
+	e := new(big.Int).MulRange(1, int64(parties))
+	e.Mul(e, share)
+	return e.Exp(v, e, nsq)
+}
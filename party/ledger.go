@@ -0,0 +1,275 @@
+package party
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// Every party keeps a ledger of the tests that ran on each dataset. The
+// tests on a dataset share the significance level Alpha of their specs,
+// and the correction the specs name sets the threshold each test is held
+// to from the tests before it:
+//
+//   - Bonferroni holds every test to Alpha/Budget
+//   - Holm holds a test to Alpha/(Budget-r), where r is the number of tests
+//     before it that rejected their null hypothesis. This is Holm's
+//     procedure in the order the tests run, which rejects no more than
+//     Holm's procedure does on all the tests at once
+//   - AlphaInvesting starts with a wealth of Alpha and spreads it evenly
+//     over the tests left in the budget. A test that costs c is held to
+//     c/(1+c), loses c if it does not reject and earns Alpha if it does
+//
+// No dataset takes more tests than its budget
+
+// The corrections for multiple comparisons a spec can name
+const (
+	Bonferroni     = "Bonferroni"
+	Holm           = "Holm"
+	AlphaInvesting = "AlphaInvesting"
+)
+
+// Outcome is the shape of the dataset a test ran on, as the coordinator
+// reports it when the test ends. The statistic is the one the parties saw
+// opened during the test, see RecordStatistic
+type Outcome struct {
+	NumRows int
+	NumCols int
+}
+
+// LedgerEntry is a test that ran, as the ledger of its dataset records it
+type LedgerEntry struct {
+	Spec           []byte // hash of the spec of the test
+	Index          int    // position of the test among the tests on the dataset, from 1
+	Statistic      string // statistic the test opened, in decimal
+	TranscriptHash []byte // head of the transcript the statistic was opened with
+	NumRows        int
+	NumCols        int
+	PValue         float64
+	Threshold      float64 // significance threshold after correction for the tests before
+	Rejected       bool    // whether PValue is within Threshold
+	Wealth         float64 // alpha-wealth left after the test, with AlphaInvesting
+}
+
+// Annotate sets the position, threshold and p-value of the entry in the
+// statement, which the parties check before they sign it
+func (entry *LedgerEntry) Annotate(st *Statement) {
+	st.SpecHash = entry.Spec
+	st.TestIndex = entry.Index
+	st.Threshold = strconv.FormatFloat(entry.Threshold, 'g', -1, 64)
+	st.PValue = strconv.FormatFloat(entry.PValue, 'g', -1, 64)
+}
+
+// newLedgerEntry returns the entry of a test that opened the statistic
+// stat with the given transcript head, on a dataset of the shape out
+// reports, and ran after the tests in ledger
+func newLedgerEntry(spec *Spec, ledger []*LedgerEntry, stat string, head []byte, out *Outcome) (*LedgerEntry, error) {
+
+	if out == nil {
+		return nil, fmt.Errorf("%w: no outcome", ErrParameterMismatch)
+	}
+
+	p, err := pValue(spec, stat, out)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &LedgerEntry{
+		Spec:           spec.Hash(),
+		Index:          len(ledger) + 1,
+		Statistic:      stat,
+		TranscriptHash: head,
+		NumRows:        out.NumRows,
+		NumCols:        out.NumCols,
+		PValue:         p,
+	}
+
+	wealth := spec.Alpha
+	rejected := 0
+	for _, e := range ledger {
+		if e.Rejected {
+			rejected++
+		}
+		wealth = e.Wealth
+	}
+
+	var cost float64
+	switch spec.Correction {
+	case Bonferroni:
+		entry.Threshold = spec.Alpha / float64(spec.Budget)
+	case Holm:
+		entry.Threshold = spec.Alpha / float64(spec.Budget-rejected)
+	case AlphaInvesting:
+		cost = wealth / float64(spec.Budget-len(ledger))
+		entry.Threshold = cost / (1 + cost)
+	}
+	entry.Rejected = p <= entry.Threshold
+
+	if spec.Correction == AlphaInvesting {
+		entry.Wealth = wealth - cost
+		if entry.Rejected {
+			entry.Wealth = wealth + spec.Alpha
+		}
+	}
+
+	return entry, nil
+}
+
+// pValue returns the p-value of the statistic of a test. The T-Test
+// statistic follows a t distribution with 2(n-1) degrees of freedom and
+// the Pearson correlation r gives one with n-2; a one-sided test takes the
+// upper tail. The Chi-Squared statistic has one degree of freedom less
+// than there are categories
+func pValue(spec *Spec, statistic string, out *Outcome) (float64, error) {
+
+	stat, err := strconv.ParseFloat(statistic, 64)
+	if err != nil || math.IsNaN(stat) {
+		return 0, fmt.Errorf("%w: malformed statistic %q", ErrParameterMismatch, statistic)
+	}
+
+	var t, df float64
+	switch spec.Test {
+	case "T-Test":
+		df = float64(2 * (out.NumRows - 1))
+		t = stat
+	case "Pearson":
+		df = float64(out.NumRows - 2)
+		t = math.Copysign(math.Inf(1), stat)
+		if math.Abs(stat) < 1 {
+			t = stat * math.Sqrt(df/(1-stat*stat))
+		}
+	case "Chi-Squared":
+		df = float64(out.NumCols - 1)
+		if df < 1 {
+			return 0, fmt.Errorf("%w: %d categories", ErrParameterMismatch, out.NumCols)
+		}
+		return gammaQ(df/2, math.Max(stat, 0)/2), nil
+	default:
+		return 0, fmt.Errorf("%w: no distribution for a %s statistic", ErrParameterMismatch, spec.Test)
+	}
+
+	if df < 1 {
+		return 0, fmt.Errorf("%w: %d rows", ErrParameterMismatch, out.NumRows)
+	}
+
+	// P(|T| > |t|)
+	p := betaInc(df/2, 0.5, df/(df+t*t))
+	if spec.Sides == 2 {
+		return p, nil
+	}
+	if t >= 0 {
+		return p / 2, nil
+	}
+	return 1 - p/2, nil
+}
+
+const (
+	cfIterations = 300
+	cfEpsilon    = 1e-15
+	cfTiny       = 1e-300
+)
+
+// betaInc returns the regularized incomplete beta function I_x(a, b)
+func betaInc(a, b, x float64) float64 {
+
+	if x <= 0 {
+		return 0
+	}
+	if x >= 1 {
+		return 1
+	}
+
+	lga, _ := math.Lgamma(a)
+	lgb, _ := math.Lgamma(b)
+	lgab, _ := math.Lgamma(a + b)
+	front := math.Exp(lgab - lga - lgb + a*math.Log(x) + b*math.Log1p(-x))
+
+	// the continued fraction converges fast on this side of the mean
+	if x < (a+1)/(a+b+2) {
+		return front * betaCF(a, b, x) / a
+	}
+	return 1 - front*betaCF(b, a, 1-x)/b
+}
+
+// betaCF evaluates the continued fraction of the incomplete beta
+// function by the modified Lentz method
+func betaCF(a, b, x float64) float64 {
+
+	tiny := func(v float64) float64 {
+		if math.Abs(v) < cfTiny {
+			return cfTiny
+		}
+		return v
+	}
+
+	c := 1.0
+	d := 1 / tiny(1-(a+b)*x/(a+1))
+	h := d
+	for m := 1.0; m <= cfIterations; m++ {
+		aa := m * (b - m) * x / ((a + 2*m - 1) * (a + 2*m))
+		d = 1 / tiny(1+aa*d)
+		c = tiny(1 + aa/c)
+		h *= d * c
+
+		aa = -(a + m) * (a + b + m) * x / ((a + 2*m) * (a + 2*m + 1))
+		d = 1 / tiny(1+aa*d)
+		c = tiny(1 + aa/c)
+		del := d * c
+		h *= del
+
+		if math.Abs(del-1) < cfEpsilon {
+			break
+		}
+	}
+
+	return h
+}
+
+// gammaQ returns the regularized upper incomplete gamma function Q(a, x)
+func gammaQ(a, x float64) float64 {
+
+	if x <= 0 {
+		return 1
+	}
+
+	lga, _ := math.Lgamma(a)
+	front := math.Exp(-x + a*math.Log(x) - lga)
+
+	// the series for P converges fast below a+1, the continued fraction for Q above
+	if x < a+1 {
+		sum := 1 / a
+		del := sum
+		for n := 1.0; n <= cfIterations; n++ {
+			del *= x / (a + n)
+			sum += del
+			if math.Abs(del) < math.Abs(sum)*cfEpsilon {
+				break
+			}
+		}
+		return math.Max(0, 1-front*sum)
+	}
+
+	b := x + 1 - a
+	c := 1 / cfTiny
+	d := 1 / b
+	h := d
+	for n := 1.0; n <= cfIterations; n++ {
+		an := -n * (n - a)
+		b += 2
+		d = an*d + b
+		if math.Abs(d) < cfTiny {
+			d = cfTiny
+		}
+		c = b + an/c
+		if math.Abs(c) < cfTiny {
+			c = cfTiny
+		}
+		d = 1 / d
+		del := d * c
+		h *= del
+		if math.Abs(del-1) < cfEpsilon {
+			break
+		}
+	}
+	return front * h
+}
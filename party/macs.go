@@ -0,0 +1,171 @@
+package party
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+)
+
+// With MACs enabled, every share comes with a share of its MAC, the
+// product of its value with a global key that is itself shared among the
+// parties and never revealed. The MAC of share id is held as share
+// macID(id). Linear operations apply to the MACs as they do to the values,
+// products and freshly dealt shares get theirs through a degree reduction
+// with the key, and the coordinator checks the MACs of the values it
+// opened before it reveals an output. A party that tampers with a value
+// cannot adjust its MAC without knowing the key
+
+// macID returns the id under which the MAC of share id is held
+func macID(id int) int {
+	return -id
+}
+
+// SetMACKey makes the party maintain MACs under the key shared as key,
+// or stop maintaining them if key is nil
+func (party *Party) SetMACKey(ctx context.Context, key *Share) error {
+	party.mu.Lock()
+	defer party.mu.Unlock()
+
+	if key == nil {
+		party.macKey = 0
+	} else {
+		party.macKey = key.ID
+	}
+	return nil
+}
+
+// macKeyID returns the id of the share of the MAC key, if MACs are enabled
+func (party *Party) macKeyID() (int, bool) {
+	party.mu.RLock()
+	defer party.mu.RUnlock()
+
+	return party.macKey, party.macKey != 0
+}
+
+// Authenticate computes the MAC of a share dealt without one, such as a
+// share the coordinator's dealer stored at the parties
+func (party *Party) Authenticate(ctx context.Context, share *Share) error {
+
+	key, ok := party.macKeyID()
+	if !ok {
+		return fmt.Errorf("%w: MACs are not enabled", ErrParameterMismatch)
+	}
+
+	return party.authenticate(ctx, share.ID, key)
+}
+
+// authenticate computes the MAC of share id as its product with the key
+func (party *Party) authenticate(ctx context.Context, id, key int) error {
+
+	err := party.dealProduct(ctx, id, key, macID(id))
+	if err != nil {
+		return err
+	}
+
+	return party.awaitReshares(ctx, macID(id))
+}
+
+// MACCheck combines the MACs of the shares ids, whose values were opened
+// as values, into a share of sum_k coeffs[k] * (mac_k - key * values[k]).
+// The result is zero unless a value or a MAC was tampered with. It is
+// reshared as share newId so that the coordinator can open every party's
+// share of it without learning anything else. The party cannot tell an
+// honest check from one that combines values no test opened, so under
+// preregistration opening the result is charged like any other reveal
+func (party *Party) MACCheck(ctx context.Context, ids []int, values []*big.Int, coeffs []*big.Int, newId int) (*Share, error) {
+
+	key, ok := party.macKeyID()
+	if !ok {
+		return nil, fmt.Errorf("%w: MACs are not enabled", ErrParameterMismatch)
+	}
+	if len(values) != len(ids) || len(coeffs) != len(ids) {
+		return nil, fmt.Errorf("%w: %d shares but %d values and %d coefficients", ErrParameterMismatch, len(ids), len(values), len(coeffs))
+	}
+
+	alpha, err := party.getShare(key)
+	if err != nil {
+		return nil, err
+	}
+
+	sigma := big.NewInt(0)
+	for k, id := range ids {
+		mac, err := party.getShare(macID(id))
+		if err != nil {
+			return nil, err
+		}
+
+		diff := new(big.Int).Mul(alpha, values[k])
+		diff.Sub(mac, diff)
+		diff.Mul(diff, coeffs[k])
+		sigma.Add(sigma, diff)
+	}
+	sigma.Mul(sigma, party.betaN())
+	sigma.Mod(sigma, party.P)
+
+	err = party.deal(ctx, sigma, newId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = party.awaitReshares(ctx, newId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Share{party.ID, newId}, nil
+}
+
+// dealProduct deals this party's piece of the degree reduction of the
+// product of shares id1 and id2 as share newId
+func (party *Party) dealProduct(ctx context.Context, id1, id2, newId int) error {
+
+	v1, err := party.getShare(id1)
+	if err != nil {
+		return err
+	}
+	v2, err := party.getShare(id2)
+	if err != nil {
+		return err
+	}
+
+	z := big.NewInt(0).Mul(v1, v2)
+	z.Mul(z, party.betaN())
+
+	return party.deal(ctx, z, newId)
+}
+
+// linear stores f of the values of shares ids as share newId and, if MACs
+// are enabled, f of their MACs as its MAC. f must be linear
+func (party *Party) linear(newId int, f func(v ...*big.Int) *big.Int, ids ...int) error {
+
+	err := party.apply(newId, f, ids...)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := party.macKeyID(); !ok {
+		return nil
+	}
+
+	macs := make([]int, len(ids))
+	for k, id := range ids {
+		macs[k] = macID(id)
+	}
+	return party.apply(macID(newId), f, macs...)
+}
+
+// apply stores f of the values of shares ids as share newId
+func (party *Party) apply(newId int, f func(v ...*big.Int) *big.Int, ids ...int) error {
+
+	values := make([]*big.Int, len(ids))
+	for k, id := range ids {
+		v, err := party.getShare(id)
+		if err != nil {
+			return err
+		}
+		values[k] = v
+	}
+
+	party.store(newId, f(values...))
+	return nil
+}
@@ -0,0 +1,59 @@
+package party
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+)
+
+// Ping answers as long as the party is up
+func (party *Party) Ping(ctx context.Context) error {
+	return nil
+}
+
+// SetParties restricts the parties this party deals shares to after the
+// coordinator has taken the others offline. Degree reduction in Mult then
+// uses the Lagrange coefficients of the remaining parties
+func (party *Party) SetParties(ctx context.Context, ids []int) error {
+
+	offline := make([]bool, len(party.Parties))
+	for i := range offline {
+		offline[i] = true
+	}
+	for _, id := range ids {
+		if id < 0 || id >= len(offline) {
+			return fmt.Errorf("%w: unknown party %d", ErrParameterMismatch, id)
+		}
+		offline[id] = false
+	}
+
+	party.mu.Lock()
+	party.offline = offline
+	party.BetaN = LagrangeCoefficient(party.ID, ids, party.P)
+	party.mu.Unlock()
+
+	// a share dealt by a party that went offline will never be complete
+	party.failInboxes(func(box *inbox) error {
+		for dealer := range box.dealers {
+			if _, ok := box.pieces[dealer]; !ok && offline[dealer] {
+				return errDealerOffline
+			}
+		}
+		return nil
+	})
+	return nil
+}
+
+func (party *Party) isOnline(id int) bool {
+	party.mu.RLock()
+	defer party.mu.RUnlock()
+
+	return party.offline == nil || !party.offline[id]
+}
+
+func (party *Party) betaN() *big.Int {
+	party.mu.RLock()
+	defer party.mu.RUnlock()
+
+	return party.BetaN
+}
@@ -0,0 +1,393 @@
+package party
+
+import (
+	"context"
+	"custodes/zkp"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/sachaservan/paillier"
+)
+
+// Coordinator is the node id of the coordinator in a Topology
+const Coordinator = -1
+
+// AnalystID is the id in the identity of the analyst, who signs the specs
+// of preregistered tests but never connects to a party
+const AnalystID = -2
+
+// DataOwner is the prover id of the data owners, who hold no identity
+const DataOwner = -3
+
+// Duration is a time.Duration that is written as "20ms" in topology files
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	v, err := time.ParseDuration(s)
+	*d = Duration(v)
+	return err
+}
+
+// LinkConfig describes the link between two nodes, in both directions
+type LinkConfig struct {
+	From      int
+	To        int
+	Latency   Duration // one way propagation delay
+	Jitter    Duration // delay is uniform in Latency +/- Jitter
+	Bandwidth int64    // bytes per second, 0 for unlimited
+	Reorder   bool     // allow messages to overtake each other
+}
+
+// Topology describes the emulated network between the coordinator
+// and the parties. Links that are not listed use Default
+type Topology struct {
+	Default LinkConfig
+	Links   []LinkConfig
+
+	mu    sync.Mutex
+	links map[[2]int]*Link
+}
+
+// Link is one direction of an emulated link
+type Link struct {
+	config LinkConfig
+
+	mu           sync.Mutex
+	busyUntil    time.Time
+	lastDelivery time.Time
+}
+
+// UniformTopology returns a topology where every link has the same latency
+func UniformTopology(latency time.Duration) *Topology {
+	return &Topology{Default: LinkConfig{Latency: Duration(latency)}}
+}
+
+// LoadTopology reads a JSON topology file
+func LoadTopology(filename string) (*Topology, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	topo := &Topology{}
+	err = json.Unmarshal(data, topo)
+	return topo, err
+}
+
+// Link returns the link carrying messages from one node to another
+func (topo *Topology) Link(from, to int) *Link {
+	topo.mu.Lock()
+	defer topo.mu.Unlock()
+
+	if topo.links == nil {
+		topo.links = make(map[[2]int]*Link)
+	}
+
+	key := [2]int{from, to}
+	if link, ok := topo.links[key]; ok {
+		return link
+	}
+
+	config := topo.Default
+	for _, lc := range topo.Links {
+		if (lc.From == from && lc.To == to) || (lc.From == to && lc.To == from) {
+			config = lc
+			break
+		}
+	}
+
+	link := &Link{config: config}
+	topo.links[key] = link
+	return link
+}
+
+// Send blocks for as long as it takes a message of the given size to
+// be serialized onto the link and delivered at the other end, or until
+// ctx is done
+func (link *Link) Send(ctx context.Context, size int) error {
+
+	link.mu.Lock()
+
+	now := time.Now()
+	start := now
+	if link.busyUntil.After(start) {
+		start = link.busyUntil
+	}
+
+	// messages queue behind each other on a bandwidth limited link
+	if link.config.Bandwidth > 0 {
+		tx := time.Duration(int64(size) * int64(time.Second) / link.config.Bandwidth)
+		link.busyUntil = start.Add(tx)
+	} else {
+		link.busyUntil = start
+	}
+
+	delay := time.Duration(link.config.Latency)
+	if link.config.Jitter > 0 {
+		jitter := time.Duration(link.config.Jitter)
+		delay += time.Duration(rand.Int63n(int64(2*jitter))) - jitter
+		if delay < 0 {
+			delay = 0
+		}
+	}
+
+	deliver := link.busyUntil.Add(delay)
+	if !link.config.Reorder && deliver.Before(link.lastDelivery) {
+		deliver = link.lastDelivery
+	}
+	link.lastDelivery = deliver
+
+	link.mu.Unlock()
+
+	timer := time.NewTimer(deliver.Sub(now))
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// NewEmulatedTransport returns a Transport to t where every request
+// travels from node from to node to over the topology, and every reply back
+func NewEmulatedTransport(t Transport, topo *Topology, from, to int) *Client {
+	return &Client{Send: func(ctx context.Context, msg *Message) (*Result, error) {
+		if err := topo.Link(from, to).Send(ctx, msg.Size()); err != nil {
+			return &Result{}, err
+		}
+
+		res, err := Dispatch(ctx, t, msg)
+		if linkErr := topo.Link(to, from).Send(ctx, res.Size()); linkErr != nil {
+			return &Result{}, linkErr
+		}
+		return res, err
+	}}
+}
+
+// header is the approximate size of a message envelope in bytes
+const header = 16
+
+// Size returns the approximate encoded size of the message in bytes
+func (msg *Message) Size() int {
+	size := header
+	if msg.Share1 != nil {
+		size += 8
+	}
+	if msg.Share2 != nil {
+		size += 8
+	}
+	size += 8 * len(msg.IDs)
+	for _, v := range msg.Values {
+		size += intSize(v)
+	}
+	for _, v := range msg.Coeffs {
+		size += intSize(v)
+	}
+	for _, ct := range msg.Cts {
+		size += ctSize(ct)
+	}
+	for _, row := range msg.Rows {
+		for _, ct := range row {
+			size += ctSize(ct)
+		}
+	}
+	for _, proof := range msg.Ranges {
+		size += intervalProofSize(proof)
+	}
+	for _, proof := range msg.OneHots {
+		size += oneHotProofSize(proof)
+	}
+	for _, m := range msg.Batch {
+		size += m.Size()
+	}
+	return size + intSize(msg.Value) + vssProofSize(msg.Dealing) + ctSize(msg.Ct) + keySize(msg.Key) + openingSize(msg.Opening) + statementSize(msg.Stmt) + specSize(msg.Spec) + len(msg.Hash) + len(msg.Dataset) + len(msg.Session) + outcomeSize(msg.Outcome) + keyTestSize(msg.Test)
+}
+
+// Size returns the approximate encoded size of the result in bytes
+func (res *Result) Size() int {
+	size := header + intSize(res.Value) + ctSize(res.Ct1) + ctSize(res.Ct2)
+	if res.Share != nil {
+		size += 8
+	}
+	for _, ct := range res.Cts {
+		size += ctSize(ct)
+	}
+	if res.Partial != nil {
+		size += 8 + intSize(res.Partial.Decryption)
+	}
+	size += decryptionProofSize(res.Proof)
+	for _, v := range res.Values {
+		size += intSize(v)
+	}
+	for _, proof := range res.Bits {
+		size += bitProofSize(proof)
+	}
+	size += len(res.Valid) + pieceSize(res.Piece) + len(res.Sig) + len(res.Hash) + ledgerEntrySize(res.Entry)
+	for _, r := range res.Batch {
+		size += r.Size()
+	}
+	return size + len(res.Err)
+}
+
+func intSize(v *big.Int) int {
+	if v == nil {
+		return 0
+	}
+	return (v.BitLen() + 7) / 8
+}
+
+func ctSize(ct *paillier.Ciphertext) int {
+	if ct == nil {
+		return 0
+	}
+	return intSize(ct.C)
+}
+
+func keySize(tk *paillier.ThresholdKey) int {
+	if tk == nil {
+		return 0
+	}
+
+	size := 16 + intSize(tk.N) + intSize(tk.V)
+	for _, v := range tk.Vi {
+		size += intSize(v)
+	}
+	return size
+}
+
+func statementSize(st *Statement) int {
+	if st == nil {
+		return 0
+	}
+	return len(st.Encode())
+}
+
+func pieceSize(piece *Piece) int {
+	if piece == nil {
+		return 0
+	}
+	return 16 + intSize(piece.Value) + len(piece.Signature)
+}
+
+func openingSize(opening *Opening) int {
+	if opening == nil {
+		return 0
+	}
+	size := 8 + len(opening.Head)
+	for _, piece := range opening.Pieces {
+		size += pieceSize(piece)
+	}
+	return size
+}
+
+func specSize(signed *SignedSpec) int {
+	if signed == nil || signed.Spec == nil {
+		return 0
+	}
+	return len(signed.Spec.Encode()) + len(signed.Signature)
+}
+
+func outcomeSize(out *Outcome) int {
+	if out == nil {
+		return 0
+	}
+	return 16
+}
+
+func decryptionProofSize(proof *paillier.PartialDecryptionZKP) int {
+	if proof == nil {
+		return 0
+	}
+	return 8 + intSize(proof.Decryption) + intSize(proof.E) + intSize(proof.Z) + intSize(proof.C)
+}
+
+func keyTestSize(test *KeyTest) int {
+	if test == nil {
+		return 0
+	}
+	size := intSize(test.M) + intSize(test.R)
+	for _, proof := range test.Proofs {
+		size += decryptionProofSize(proof)
+	}
+	return size
+}
+
+func ledgerEntrySize(entry *LedgerEntry) int {
+	if entry == nil {
+		return 0
+	}
+	return 49 + len(entry.Spec) + len(entry.Statistic) + len(entry.TranscriptHash)
+}
+
+func vssProofSize(proof *VSSProof) int {
+	if proof == nil {
+		return 0
+	}
+
+	size := intSize(proof.Blind)
+	for _, c := range proof.Commitments {
+		size += intSize(c)
+	}
+	return size
+}
+
+func intervalProofSize(proof *zkp.IntervalProof) int {
+	if proof == nil {
+		return 0
+	}
+	return rangeProofSize(proof.Above) + rangeProofSize(proof.Below)
+}
+
+func rangeProofSize(proof *zkp.RangeProof) int {
+	if proof == nil {
+		return 0
+	}
+	size := 8 + zeroProofSize(proof.Sum)
+	for _, ct := range proof.BitCts {
+		size += ctSize(ct)
+	}
+	for _, bp := range proof.BitProofs {
+		size += bitProofSize(bp)
+	}
+	return size
+}
+
+func oneHotProofSize(proof *zkp.OneHotProof) int {
+	if proof == nil {
+		return 0
+	}
+	size := zeroProofSize(proof.Sum)
+	for _, bp := range proof.Bits {
+		size += bitProofSize(bp)
+	}
+	return size
+}
+
+func bitProofSize(proof *zkp.BitProof) int {
+	if proof == nil {
+		return 0
+	}
+	return intSize(proof.E0) + intSize(proof.Z0) + intSize(proof.E1) + intSize(proof.Z1)
+}
+
+func zeroProofSize(proof *zkp.ZeroProof) int {
+	if proof == nil {
+		return 0
+	}
+	return intSize(proof.E) + intSize(proof.Z)
+}
@@ -0,0 +1,151 @@
+package party
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// slack is how far a schedule may drift from the ideal one in a test,
+// for the time the test itself takes to send
+const slack = 10 * time.Millisecond
+
+// schedule sends messages of the given sizes on the link at once, without
+// waiting for their delivery, and returns when the link is free again and
+// when the last of them arrives, from the time the first was sent
+func schedule(link *Link, sizes ...int) (free, delivered time.Duration) {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	start := time.Now()
+	for _, size := range sizes {
+		link.Send(ctx, size)
+	}
+
+	return link.busyUntil.Sub(start), link.lastDelivery.Sub(start)
+}
+
+// near fails the test unless got is want, up to slack
+func near(t *testing.T, what string, got, want time.Duration) {
+	t.Helper()
+	if got < want-slack || got > want+slack {
+		t.Fatalf("%s after %v, want %v", what, got, want)
+	}
+}
+
+func TestLinkQueuesOnBandwidth(t *testing.T) {
+
+	// 1000 bytes take 100ms at 10000 bytes per second, one after the other
+	link := &Link{config: LinkConfig{Bandwidth: 10000}}
+	free, delivered := schedule(link, 1000, 1000, 1000)
+	near(t, "three messages went out", free, 300*time.Millisecond)
+	near(t, "the last message arrived", delivered, 300*time.Millisecond)
+
+	// the latency of one message overlaps with the serialization of the next
+	link = &Link{config: LinkConfig{Bandwidth: 10000, Latency: Duration(200 * time.Millisecond)}}
+	free, delivered = schedule(link, 1000, 1000)
+	near(t, "two messages went out", free, 200*time.Millisecond)
+	near(t, "the last message arrived", delivered, 400*time.Millisecond)
+
+	// a link that went idle starts afresh
+	link = &Link{config: LinkConfig{Bandwidth: 10000}}
+	link.busyUntil = time.Now().Add(-time.Second)
+	free, _ = schedule(link, 500)
+	near(t, "a message on an idle link went out", free, 50*time.Millisecond)
+}
+
+func TestLinkWithoutBandwidthLimit(t *testing.T) {
+
+	// messages do not queue, each only takes the latency
+	link := &Link{config: LinkConfig{Latency: Duration(50 * time.Millisecond)}}
+	free, delivered := schedule(link, 1<<20, 1<<20, 1<<20)
+	near(t, "three messages went out", free, 0)
+	near(t, "the last message arrived", delivered, 50*time.Millisecond)
+}
+
+func TestLinkJitter(t *testing.T) {
+
+	latency, jitter := 50*time.Millisecond, 40*time.Millisecond
+	config := LinkConfig{Latency: Duration(latency), Jitter: Duration(jitter)}
+
+	// with reordering each message takes the latency up to the jitter, so
+	// some overtake the one before them
+	config.Reorder = true
+	link := &Link{config: config}
+	var last time.Time
+	overtaken := false
+	for i := 0; i < 50; i++ {
+		_, delivered := schedule(link, 100)
+		if delivered < latency-jitter-slack || delivered > latency+jitter+slack {
+			t.Fatalf("message %d arrived after %v, want %v +/- %v", i, delivered, latency, jitter)
+		}
+		overtaken = overtaken || link.lastDelivery.Before(last)
+		last = link.lastDelivery
+	}
+	if !overtaken {
+		t.Fatal("no message overtook another on a link that reorders")
+	}
+
+	// without reordering every message arrives after the one before it
+	config.Reorder = false
+	link = &Link{config: config}
+	last = time.Time{}
+	for i := 0; i < 50; i++ {
+		schedule(link, 100)
+		if link.lastDelivery.Before(last) {
+			t.Fatalf("message %d arrived before the one before it", i)
+		}
+		last = link.lastDelivery
+	}
+}
+
+func TestLinkSendWaitsForDelivery(t *testing.T) {
+
+	latency := 30 * time.Millisecond
+	link := &Link{config: LinkConfig{Latency: Duration(latency)}}
+
+	start := time.Now()
+	if err := link.Send(context.Background(), 100); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < latency {
+		t.Fatalf("message delivered after %v, before the latency of %v", elapsed, latency)
+	}
+
+	// a sender that gives up does not wait for the delivery
+	link = &Link{config: LinkConfig{Latency: Duration(time.Minute)}}
+	ctx, cancel := context.WithTimeout(context.Background(), latency)
+	defer cancel()
+	start = time.Now()
+	if err := link.Send(ctx, 100); err != context.DeadlineExceeded {
+		t.Fatalf("got %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("send gave up after %v", elapsed)
+	}
+}
+
+func TestTopologyLinks(t *testing.T) {
+
+	slow := LinkConfig{From: Coordinator, To: 1, Latency: Duration(time.Second)}
+	topo := &Topology{Default: LinkConfig{Latency: Duration(time.Millisecond)}, Links: []LinkConfig{slow}}
+
+	// a listed link carries its config both ways, other links the default
+	for _, ends := range [][2]int{{Coordinator, 1}, {1, Coordinator}} {
+		if got := topo.Link(ends[0], ends[1]).config; got != slow {
+			t.Fatalf("link from %d to %d has %+v, want %+v", ends[0], ends[1], got, slow)
+		}
+	}
+	if got := topo.Link(0, 1).config; got != topo.Default {
+		t.Fatalf("unlisted link has %+v, want the default %+v", got, topo.Default)
+	}
+
+	// each direction is one link that all its messages queue on
+	if topo.Link(0, 1) != topo.Link(0, 1) {
+		t.Fatal("messages in one direction go over different links")
+	}
+	if topo.Link(0, 1) == topo.Link(1, 0) {
+		t.Fatal("both directions share a link")
+	}
+}
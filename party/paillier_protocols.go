@@ -1,54 +1,230 @@
 package party
 
 import (
+	"context"
+	"custodes/zkp"
+	"fmt"
 	"math/big"
-	"time"
+	"sync"
 
 	"github.com/sachaservan/paillier"
 )
 
-func (party *Party) GetRandomMultEnc(c *paillier.Ciphertext) (*paillier.Ciphertext, *paillier.Ciphertext) {
-	time.Sleep(party.NetworkLatency)
+// errNoKey is returned by the Paillier operations of a party that holds no
+// key yet, such as a daemon waiting for distributed key generation
+var errNoKey = fmt.Errorf("%w: no Paillier key", ErrShareNotFound)
+
+func (party *Party) GetRandomMultEnc(ctx context.Context, c *paillier.Ciphertext) (*paillier.Ciphertext, *paillier.Ciphertext, error) {
+	if party.Pk == nil {
+		return nil, nil, errNoKey
+	}
 	r := CryptoRandom(party.Pk.N)
 	enc := party.Pk.Encrypt(r)
 	cMult := party.Pk.ECMult(c, r)
 
-	return enc, cMult
+	return enc, cMult, nil
 }
 
-func (party *Party) GetRandomEncAndShare(id int, bound *big.Int) (*paillier.Ciphertext, *Share) {
+func (party *Party) GetRandomEncAndShare(ctx context.Context, id int, bound *big.Int) (*paillier.Ciphertext, *Share, error) {
+	if party.Pk == nil {
+		return nil, nil, errNoKey
+	}
 	r := CryptoRandom(bound)
 	enc := party.Pk.Encrypt(r)
-	shares, values, _ := party.CreateShares(r, id)
-	party.DistributeRandShares(shares, values)
 
-	return enc, shares[party.ID]
+	err := party.deal(ctx, r, id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = party.awaitReshares(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if key, ok := party.macKeyID(); ok {
+		err = party.authenticate(ctx, id, key)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return enc, &Share{party.ID, id}, nil
 }
 
-func (party *Party) GetRandomEncBitVector(m int) []*paillier.Ciphertext {
+// GetRandomEncBitVector returns encryptions of m random bits, each with a
+// proof that it encrypts 0 or 1, made by the party in the given session
+func (party *Party) GetRandomEncBitVector(ctx context.Context, m int, session []byte) ([]*paillier.Ciphertext, []*zkp.BitProof, error) {
+	if party.Pk == nil {
+		return nil, nil, errNoKey
+	}
 	vec := make([]*paillier.Ciphertext, m)
+	proofs := make([]*zkp.BitProof, m)
+	zc := zkp.Context{Prover: party.ID, Session: session}
 	for i := 0; i < m; i++ {
 		bit := CryptoRandom(big.NewInt(2))
-		vec[i] = party.Pk.Encrypt(bit)
+		ct, proof, err := zkp.EncryptBit(party.Pk, zc, bit)
+		if err != nil {
+			return nil, nil, err
+		}
+		vec[i], proofs[i] = ct, proof
 	}
 
-	return vec
+	return vec, proofs, nil
 }
 
-func (party *Party) GetRandomEnc(bound *big.Int) *paillier.Ciphertext {
+func (party *Party) GetRandomEnc(ctx context.Context, bound *big.Int) (*paillier.Ciphertext, error) {
+	if party.Pk == nil {
+		return nil, errNoKey
+	}
 	r := CryptoRandom(bound)
 	enc := party.Pk.Encrypt(r)
-	return enc
+	return enc, nil
 }
 
-func (party *Party) PartialDecrypt(ciphertext *paillier.Ciphertext) *paillier.PartialDecryption {
-	time.Sleep(party.NetworkLatency)
+func (party *Party) PartialDecrypt(ctx context.Context, ciphertext *paillier.Ciphertext) (*paillier.PartialDecryption, error) {
+	if party.Sk == nil {
+		return nil, errNoKey
+	}
+	if err := party.checkReveal(decryptionOpening(ciphertext)); err != nil {
+		return nil, err
+	}
 	partial := party.Sk.Decrypt(ciphertext.C)
-	return partial
+	return partial, nil
+}
+
+func (party *Party) PartialDecryptAndProof(ctx context.Context, ciphertext *paillier.Ciphertext) (*paillier.PartialDecryptionZKP, error) {
+	if party.Sk == nil {
+		return nil, errNoKey
+	}
+	if err := party.checkReveal(decryptionOpening(ciphertext)); err != nil {
+		return nil, err
+	}
+	return party.Sk.DecryptAndProduceZKP(ciphertext.C)
+}
+
+// UploadContext returns the context in which data owners prove the values
+// they upload to a dataset
+func UploadContext(dataset string) zkp.Context {
+	return zkp.Context{Prover: DataOwner, Session: []byte("upload " + dataset)}
+}
+
+// Interval is a fixed point interval [Lo, Hi], in signed integers rather
+// than mod N, that the data owners of a dataset declare its values to lie in
+type Interval struct {
+	Lo, Hi *big.Int
+}
+
+// check returns an error if the interval is empty
+func (in *Interval) check() error {
+	if in.Lo == nil || in.Hi == nil || in.Lo.Cmp(in.Hi) > 0 {
+		return fmt.Errorf("%w: empty interval [%v, %v]", ErrParameterMismatch, in.Lo, in.Hi)
+	}
+	return nil
+}
+
+// equal reports whether the intervals are the same, or both nil
+func (in *Interval) equal(other *Interval) bool {
+	if in == nil || other == nil {
+		return in == other
+	}
+	return in.Lo.Cmp(other.Lo) == 0 && in.Hi.Cmp(other.Hi) == 0
 }
 
-func (party *Party) PartialDecryptAndProof(ciphertext *paillier.Ciphertext) *paillier.PartialDecryptionZKP {
-	zkp, _ := party.Sk.DecryptAndProduceZKP(ciphertext.C)
+// interval returns the interval the values uploaded to the dataset must
+// lie in. Under preregistration it is the one the analyst signed in the
+// specs of the dataset, and otherwise the one the party was configured
+// with, never one the coordinator names
+func (party *Party) interval(dataset string) (*Interval, error) {
 
-	return zkp
+	var in *Interval
+	switch {
+	case party.Analyst != nil:
+		if in = party.prereg.interval(dataset); in == nil {
+			return nil, fmt.Errorf("%w: no test on %s declares an interval", ErrPreregistration, dataset)
+		}
+	case party.Intervals != nil:
+		in = party.Intervals(dataset)
+	}
+	if in == nil {
+		return nil, fmt.Errorf("%w: party %d knows no interval for %s", ErrParameterMismatch, party.ID, dataset)
+	}
+
+	if err := in.check(); err != nil {
+		return nil, err
+	}
+	if zkp.IntervalBits(in.Lo, in.Hi)+1 >= party.Pk.N.BitLen()-1 {
+		return nil, fmt.Errorf("%w: interval [%v, %v] does not fit the plaintext space", ErrParameterMismatch, in.Lo, in.Hi)
+	}
+	return in, nil
+}
+
+// VerifyRanges checks that every cts[i] encrypts a value in the interval
+// of the dataset according to proofs[i], as data owners prove of the
+// values they upload to it. It reports the verdict on each ciphertext
+func (party *Party) VerifyRanges(ctx context.Context, dataset string, cts []*paillier.Ciphertext, proofs []*zkp.IntervalProof) ([]bool, error) {
+
+	if len(cts) != len(proofs) {
+		return nil, fmt.Errorf("%w: %d ciphertexts but %d range proofs", ErrParameterMismatch, len(cts), len(proofs))
+	}
+	if party.Pk == nil {
+		return nil, errNoKey
+	}
+	if err := party.Seal(ctx); err != nil {
+		return nil, err
+	}
+	in, err := party.interval(dataset)
+	if err != nil {
+		return nil, err
+	}
+
+	zc := UploadContext(dataset)
+	return verifyAll(ctx, len(cts), func(i int) bool {
+		return proofs[i].Verify(party.Pk, zc, cts[i], in.Lo, in.Hi)
+	})
+}
+
+// VerifyOneHot checks that every row encrypts a one-hot vector in fixed
+// point with precBits of precision according to proofs[i], as data owners
+// prove of the categorical rows they upload to the dataset. It reports the
+// verdict on each row
+func (party *Party) VerifyOneHot(ctx context.Context, dataset string, rows [][]*paillier.Ciphertext, proofs []*zkp.OneHotProof, precBits int) ([]bool, error) {
+
+	if len(rows) != len(proofs) {
+		return nil, fmt.Errorf("%w: %d rows but %d one-hot proofs", ErrParameterMismatch, len(rows), len(proofs))
+	}
+	if party.Pk == nil {
+		return nil, errNoKey
+	}
+	if err := party.Seal(ctx); err != nil {
+		return nil, err
+	}
+
+	zc := UploadContext(dataset)
+	return verifyAll(ctx, len(rows), func(i int) bool {
+		return proofs[i].Verify(party.Pk, zc, rows[i], precBits)
+	})
+}
+
+// verifyAll runs verify for every i in [0, n) concurrently and returns the verdicts
+func verifyAll(ctx context.Context, n int, verify func(i int) bool) ([]bool, error) {
+
+	valid := make([]bool, n)
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if ctx.Err() == nil {
+				valid[i] = verify(i)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return valid, nil
 }
@@ -0,0 +1,704 @@
+package party
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sachaservan/paillier"
+)
+
+// With preregistration, a party only opens values for tests the analyst
+// committed to before the data was uploaded. The analyst signs the Spec of
+// every test it means to run with its own key, which the parties are
+// configured with, and the parties record them. Seal, which the
+// coordinator sends before the first upload, ends preregistration: from
+// then on a party takes no new specs. A party answers RevealShare,
+// PartialDecrypt and PartialDecryptAndProof only while one of the recorded
+// tests runs, within the window of its spec, and for no more shares and
+// ciphertexts than Openings allows its protocol on the dataset. The specs
+// of a dataset also fix the interval its values lie in, which VerifyRanges
+// holds the proofs of its data owners to. The coordinator Uploads the
+// ciphertexts of a dataset before a test runs on it: the party takes the
+// shape of the dataset from there, and never decrypts one of those
+// ciphertexts. Opening the same value twice, as a
+// retry does, is charged once, and the result of a MAC check is charged
+// like any other share. BeginTest starts a test and
+// EndTest closes it for good, so every spec runs at most once. The tests
+// run on a dataset go into its ledger, see ledger.go, with the
+// statistic the parties saw opened while the test ran. A party persists
+// its specs and ledgers through SavePrereg before it acts on a change to
+// them; after a restart, a test that began but did not end stays closed
+
+// ErrPreregistration is returned when a request is not covered by a
+// preregistered test that has yet to run
+var ErrPreregistration = errors.New("not covered by a preregistered test")
+
+// Spec is a test as the analyst commits to it
+type Spec struct {
+	Test       string        // type of test, such as T-Test
+	Dataset    string        // name of the dataset the test runs on
+	Columns    []int         // columns of the dataset the test uses, nil for all of them
+	Alpha      float64       // significance level of all the tests on the dataset together
+	Sides      int           // 1 for a one-sided test, 2 for a two-sided one
+	Correction string        // Bonferroni, Holm or AlphaInvesting, the same for all the tests on the dataset
+	Budget     int           // number of tests the dataset takes, the same for all of them
+	Window     time.Duration // how long the test may open values once it begins
+	Interval   *Interval     // interval the values of the dataset lie in, nil if it has no numerical values to check
+}
+
+// Encode returns the canonical encoding of the spec, which is what the
+// analyst signs. Every variable length field is prefixed with its length.
+// The interval comes last and only if there is one, so specs without one
+// keep their hash
+func (spec *Spec) Encode() []byte {
+
+	var buf []byte
+	putInt := func(x int) {
+		var b [8]byte
+		binary.BigEndian.PutUint64(b[:], uint64(int64(x)))
+		buf = append(buf, b[:]...)
+	}
+	putBytes := func(b []byte) {
+		putInt(len(b))
+		buf = append(buf, b...)
+	}
+
+	putBytes([]byte("custodes spec"))
+	putBytes([]byte(spec.Test))
+	putBytes([]byte(spec.Dataset))
+	putInt(len(spec.Columns))
+	for _, col := range spec.Columns {
+		putInt(col)
+	}
+	putInt(int(math.Float64bits(spec.Alpha)))
+	putInt(spec.Sides)
+	putBytes([]byte(spec.Correction))
+	putInt(spec.Budget)
+	putInt(int(spec.Window))
+	if spec.Interval != nil {
+		putBytes([]byte(spec.Interval.Lo.String()))
+		putBytes([]byte(spec.Interval.Hi.String()))
+	}
+
+	return buf
+}
+
+// Hash returns the hash that names the spec
+func (spec *Spec) Hash() []byte {
+	h := sha256.Sum256(spec.Encode())
+	return h[:]
+}
+
+// check returns an error if the spec does not describe a test
+func (spec *Spec) check() error {
+	if spec.Test == "" {
+		return fmt.Errorf("%w: spec names no test", ErrParameterMismatch)
+	}
+	if !(spec.Alpha > 0 && spec.Alpha < 1) {
+		return fmt.Errorf("%w: significance level %v is not in (0, 1)", ErrParameterMismatch, spec.Alpha)
+	}
+	if spec.Sides != 1 && spec.Sides != 2 {
+		return fmt.Errorf("%w: a test has one or two sides, not %d", ErrParameterMismatch, spec.Sides)
+	}
+	for _, col := range spec.Columns {
+		if col < 0 {
+			return fmt.Errorf("%w: negative column %d", ErrParameterMismatch, col)
+		}
+	}
+	switch spec.Correction {
+	case Bonferroni, Holm, AlphaInvesting:
+	default:
+		return fmt.Errorf("%w: unknown correction %q", ErrParameterMismatch, spec.Correction)
+	}
+	if spec.Budget < 1 {
+		return fmt.Errorf("%w: budget of %d tests", ErrParameterMismatch, spec.Budget)
+	}
+	if spec.Window <= 0 {
+		return fmt.Errorf("%w: test window of %v", ErrParameterMismatch, spec.Window)
+	}
+	if spec.Interval != nil {
+		return spec.Interval.check()
+	}
+	return nil
+}
+
+// sameFamily reports whether the specs agree on how the tests on their
+// dataset are corrected for each other
+func (spec *Spec) sameFamily(other *Spec) bool {
+	return spec.Alpha == other.Alpha && spec.Correction == other.Correction && spec.Budget == other.Budget
+}
+
+// DefaultOpenings returns the number of shares the protocol of a test
+// reveals and of ciphertexts it decrypts on a dataset with the given
+// shape, for the parameters of the command line tools. The decryptions
+// are fixed by the shape, while sign extraction and division reveal a few
+// shares more or less depending on their random bits, so the reveals
+// leave some room. A test it does not know opens nothing
+func DefaultOpenings(test string, rows, cols int) (int, int) {
+	switch test {
+	case "T-Test":
+		return 23500, 2*rows + 9
+	case "Pearson":
+		return 23500, 3*rows + 8
+	case "Chi-Squared":
+		return 12300*cols + 1, 4 * cols
+	}
+	return 0, 0
+}
+
+// openings returns the budget of the party for a test on a dataset with
+// the given shape
+func (party *Party) openings(test string, rows, cols int) (int, int) {
+	if party.Openings != nil {
+		return party.Openings(test, rows, cols)
+	}
+	return DefaultOpenings(test, rows, cols)
+}
+
+// Dataset is what a party records of an uploaded dataset
+type Dataset struct {
+	Rows, Cols  int
+	Ciphertexts []string // hashes of its ciphertexts, in hex, over every upload
+}
+
+// SignedSpec is a spec with the signature of the analyst on its encoding
+type SignedSpec struct {
+	Spec      *Spec
+	Signature []byte
+}
+
+// SignSpec signs the spec as the analyst
+func SignSpec(spec *Spec, analyst *Identity) *SignedSpec {
+	return &SignedSpec{Spec: spec, Signature: ed25519.Sign(analyst.Key, spec.Encode())}
+}
+
+type specState int
+
+const (
+	specRegistered specState = iota
+	specRunning
+	specDone
+)
+
+// opening is a value a party opens: a share by id and the write that
+// stored its value, or a ciphertext by hash
+type opening struct {
+	decryption bool
+	key        string
+}
+
+// shareOpening returns the opening of the value stored as share id by the
+// given write. A value stored under the id later is another opening
+func shareOpening(id int, write uint64) opening {
+	return opening{key: strconv.Itoa(id) + "/" + strconv.FormatUint(write, 10)}
+}
+
+// decryptionOpening returns the opening of the ciphertext
+func decryptionOpening(ct *paillier.Ciphertext) opening {
+	return opening{decryption: true, key: ctHash(ct)}
+}
+
+// ctHash returns the hash of the ciphertext, in hex
+func ctHash(ct *paillier.Ciphertext) string {
+	h := sha256.Sum256(ct.C.Bytes())
+	return hex.EncodeToString(h[:])
+}
+
+// registry holds the specs a party committed to, by hash
+type registry struct {
+	mu             sync.Mutex
+	specs          map[string]*Spec
+	states         map[string]specState
+	sealed         bool                      // data was uploaded, no new specs are taken
+	datasets       map[string]*Dataset       // uploaded datasets, by name
+	uploaded       map[string]bool           // hashes of the uploaded ciphertexts
+	running        string                    // hash of the running test, empty if none
+	deadline       time.Time                 // end of the window of the running test
+	opened         map[opening]bool          // values the running test opened
+	reveals        int                       // shares the running test revealed
+	decryptions    int                       // ciphertexts the running test decrypted
+	maxReveals     int                       // shares the running test may reveal
+	maxDecryptions int                       // ciphertexts the running test may decrypt
+	statistic      string                    // statistic the running test opened, empty if none yet
+	head           []byte                    // transcript head the statistic was opened with
+	ledgers        map[string][]*LedgerEntry // tests that ran, by dataset
+}
+
+// PreregState is what a party keeps of its preregistrations across a
+// restart
+type PreregState struct {
+	Specs    map[string]*Spec          // recorded specs, by hash
+	Ran      []string                  // hashes of the tests that began
+	Sealed   bool                      // whether the party takes no new specs
+	Datasets map[string]*Dataset       // uploaded datasets, by name
+	Ledgers  map[string][]*LedgerEntry // tests that ran, by dataset
+}
+
+// state returns the registry as the party persists it
+func (reg *registry) state() *PreregState {
+
+	state := &PreregState{
+		Specs:    make(map[string]*Spec, len(reg.specs)),
+		Sealed:   reg.sealed,
+		Datasets: make(map[string]*Dataset, len(reg.datasets)),
+		Ledgers:  make(map[string][]*LedgerEntry, len(reg.ledgers)),
+	}
+	for key, spec := range reg.specs {
+		state.Specs[key] = spec
+		if reg.states[key] != specRegistered {
+			state.Ran = append(state.Ran, key)
+		}
+	}
+	for name, dataset := range reg.datasets {
+		copied := *dataset
+		copied.Ciphertexts = append([]string(nil), dataset.Ciphertexts...)
+		state.Datasets[name] = &copied
+	}
+	for dataset, ledger := range reg.ledgers {
+		state.Ledgers[dataset] = append([]*LedgerEntry(nil), ledger...)
+	}
+
+	return state
+}
+
+// save persists the registry, and undoes the change that led to it if
+// that fails, so the party never acts on a change it would forget
+func (reg *registry) save(party *Party, undo func()) error {
+
+	if party.SavePrereg == nil {
+		return nil
+	}
+	if err := party.SavePrereg(reg.state()); err != nil {
+		undo()
+		return fmt.Errorf("party %d could not save its preregistrations: %w", party.ID, err)
+	}
+	return nil
+}
+
+// interval returns the interval the specs of the dataset declare, nil if
+// there is none. Preregister keeps the specs of a dataset in agreement
+func (reg *registry) interval(dataset string) *Interval {
+
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	for _, spec := range reg.specs {
+		if spec.Dataset == dataset {
+			return spec.Interval
+		}
+	}
+	return nil
+}
+
+// RestorePrereg loads the preregistrations a party saved before a
+// restart. A test that began is closed, whether or not it ended
+func (party *Party) RestorePrereg(state *PreregState) error {
+
+	reg := &party.prereg
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	specs := make(map[string]*Spec, len(state.Specs))
+	states := make(map[string]specState, len(state.Specs))
+	for key, spec := range state.Specs {
+		if spec == nil || hex.EncodeToString(spec.Hash()) != key {
+			return fmt.Errorf("%w: saved spec %s does not match its hash", ErrParameterMismatch, key)
+		}
+		specs[key] = spec
+		states[key] = specRegistered
+	}
+	for _, key := range state.Ran {
+		if _, ok := specs[key]; !ok {
+			return fmt.Errorf("%w: saved test %s has no spec", ErrParameterMismatch, key)
+		}
+		states[key] = specDone
+	}
+
+	uploaded := make(map[string]bool)
+	for _, dataset := range state.Datasets {
+		for _, h := range dataset.Ciphertexts {
+			uploaded[h] = true
+		}
+	}
+
+	reg.specs, reg.states = specs, states
+	reg.sealed = state.Sealed
+	reg.datasets, reg.uploaded = state.Datasets, uploaded
+	reg.ledgers = state.Ledgers
+	reg.running, reg.opened, reg.statistic, reg.head = "", nil, "", nil
+
+	return nil
+}
+
+// Preregister records a test signed by the analyst, as long as the party
+// has not seen any data yet. Recording a spec twice is harmless
+func (party *Party) Preregister(ctx context.Context, signed *SignedSpec) error {
+
+	if party.Analyst == nil {
+		return fmt.Errorf("%w: party %d does not take preregistrations", ErrParameterMismatch, party.ID)
+	}
+	if signed == nil || signed.Spec == nil || !ed25519.Verify(party.Analyst, signed.Spec.Encode(), signed.Signature) {
+		return fmt.Errorf("%w: spec is not signed by the analyst", ErrParameterMismatch)
+	}
+	if err := signed.Spec.check(); err != nil {
+		return err
+	}
+
+	reg := &party.prereg
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	key := hex.EncodeToString(signed.Spec.Hash())
+	if _, ok := reg.states[key]; ok {
+		return nil
+	}
+	if reg.sealed {
+		return fmt.Errorf("%w: party %d has seen data and takes no new specs", ErrPreregistration, party.ID)
+	}
+
+	// the tests on a dataset are corrected together, within one budget
+	spec := signed.Spec
+	family := 1
+	for _, other := range reg.specs {
+		if other.Dataset != spec.Dataset {
+			continue
+		}
+		if !other.sameFamily(spec) {
+			return fmt.Errorf("%w: the tests on %s disagree on alpha, correction or budget", ErrParameterMismatch, spec.Dataset)
+		}
+		if !other.Interval.equal(spec.Interval) {
+			return fmt.Errorf("%w: the tests on %s disagree on the interval of its values", ErrParameterMismatch, spec.Dataset)
+		}
+		family++
+	}
+	if family > spec.Budget {
+		return fmt.Errorf("%w: %s takes at most %d tests", ErrPreregistration, spec.Dataset, spec.Budget)
+	}
+
+	if reg.specs == nil {
+		reg.specs = make(map[string]*Spec)
+		reg.states = make(map[string]specState)
+	}
+	reg.specs[key] = signed.Spec
+	reg.states[key] = specRegistered
+
+	return reg.save(party, func() {
+		delete(reg.specs, key)
+		delete(reg.states, key)
+	})
+}
+
+// BeginTest starts the preregistered test named by hash, which the party
+// then opens values for until EndTest. A test that already ran does not
+// run again
+func (party *Party) BeginTest(ctx context.Context, hash []byte) error {
+
+	if party.Analyst == nil {
+		return fmt.Errorf("%w: party %d does not take preregistrations", ErrParameterMismatch, party.ID)
+	}
+
+	reg := &party.prereg
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	key := hex.EncodeToString(hash)
+
+	if reg.running == key {
+		return nil
+	}
+	if reg.running != "" {
+		return fmt.Errorf("%w: test %s is still running", ErrPreregistration, reg.running)
+	}
+
+	state, ok := reg.states[key]
+	switch {
+	case !ok:
+		return fmt.Errorf("%w: party %d has no spec %x", ErrPreregistration, party.ID, hash)
+	case state == specDone:
+		return fmt.Errorf("%w: test %x already ran", ErrPreregistration, hash)
+	}
+
+	spec := reg.specs[key]
+	if len(reg.ledgers[spec.Dataset]) >= spec.Budget {
+		return fmt.Errorf("%w: the budget of %d tests on %s is spent", ErrPreregistration, spec.Budget, spec.Dataset)
+	}
+	dataset, ok := reg.datasets[spec.Dataset]
+	if !ok {
+		return fmt.Errorf("%w: dataset %s was not uploaded", ErrPreregistration, spec.Dataset)
+	}
+
+	sealed := reg.sealed
+	reg.sealed = true
+	reg.states[key] = specRunning
+	reg.running = key
+
+	// the test counts as run from now on, even if the party restarts
+	err := reg.save(party, func() {
+		reg.sealed = sealed
+		reg.states[key] = specRegistered
+		reg.running = ""
+	})
+	if err != nil {
+		return err
+	}
+
+	reg.deadline = time.Now().Add(spec.Window)
+	reg.opened = make(map[opening]bool)
+	reg.reveals, reg.decryptions = 0, 0
+	reg.maxReveals, reg.maxDecryptions = party.openings(spec.Test, dataset.Rows, dataset.Cols)
+	reg.statistic, reg.head = "", nil
+
+	return nil
+}
+
+// recordStatistic records the statistic the running test opened, with
+// the transcript head it came with. A test opens one statistic only
+func (party *Party) recordStatistic(stat string, head []byte) error {
+
+	reg := &party.prereg
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	switch {
+	case reg.running == "":
+		return fmt.Errorf("%w: party %d records statistics only while a preregistered test runs", ErrPreregistration, party.ID)
+	case reg.statistic == "":
+		reg.statistic, reg.head = stat, head
+	case reg.statistic != stat || !bytes.Equal(reg.head, head):
+		return fmt.Errorf("%w: test %s already opened statistic %s", ErrPreregistration, reg.running, reg.statistic)
+	}
+	return nil
+}
+
+// EndTest closes the running test named by hash for good and records it
+// in the ledger of its dataset, with the statistic the test opened and
+// the shape of the dataset the coordinator reports. It returns the entry,
+// which holds the threshold the test is held to
+func (party *Party) EndTest(ctx context.Context, hash []byte, out *Outcome) (*LedgerEntry, error) {
+
+	if party.Analyst == nil {
+		return nil, fmt.Errorf("%w: party %d does not take preregistrations", ErrParameterMismatch, party.ID)
+	}
+
+	reg := &party.prereg
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	key := hex.EncodeToString(hash)
+	if reg.states[key] == specDone && reg.running != key {
+		if entry, ok := reg.entry(key); ok {
+			return entry, nil
+		}
+	}
+	if reg.running != key {
+		return nil, fmt.Errorf("%w: test %x is not running", ErrPreregistration, hash)
+	}
+
+	if reg.statistic == "" {
+		return nil, fmt.Errorf("%w: test %x opened no statistic", ErrPreregistration, hash)
+	}
+
+	// the p-value is computed on the shape of the dataset as uploaded
+	spec := reg.specs[key]
+	dataset := reg.datasets[spec.Dataset]
+	if out == nil || out.NumRows != dataset.Rows || out.NumCols != dataset.Cols {
+		return nil, fmt.Errorf("%w: %s was uploaded with %d rows of %d values", ErrParameterMismatch, spec.Dataset, dataset.Rows, dataset.Cols)
+	}
+	ledger := reg.ledgers[spec.Dataset]
+	entry, err := newLedgerEntry(spec, ledger, reg.statistic, reg.head, out)
+	if err != nil {
+		return nil, err
+	}
+
+	if reg.ledgers == nil {
+		reg.ledgers = make(map[string][]*LedgerEntry)
+	}
+	reg.ledgers[spec.Dataset] = append(ledger, entry)
+	reg.states[key] = specDone
+	reg.running = ""
+
+	err = reg.save(party, func() {
+		reg.ledgers[spec.Dataset] = ledger
+		reg.states[key] = specRunning
+		reg.running = key
+	})
+	if err != nil {
+		return nil, err
+	}
+	reg.opened = nil
+
+	return entry, nil
+}
+
+// Seal stops the party from taking new specs, as data is about to be
+// uploaded. Sealing twice is harmless
+func (party *Party) Seal(ctx context.Context) error {
+	reg := &party.prereg
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	if reg.sealed {
+		return nil
+	}
+	reg.sealed = true
+
+	return reg.save(party, func() { reg.sealed = false })
+}
+
+// Upload records the rows of a dataset as the coordinator is about to
+// compute on them, and seals the party. The party never decrypts these
+// ciphertexts; values derived from them, even as little as a ciphertext
+// randomized again, it decrypts only within the budget of a test. A
+// dataset uploaded again keeps its shape, and the ciphertexts of every
+// upload are refused
+func (party *Party) Upload(ctx context.Context, name string, rows [][]*paillier.Ciphertext) error {
+
+	if party.Analyst == nil {
+		return fmt.Errorf("%w: party %d does not take preregistrations", ErrParameterMismatch, party.ID)
+	}
+	if len(rows) == 0 || len(rows[0]) == 0 {
+		return fmt.Errorf("%w: empty dataset %s", ErrParameterMismatch, name)
+	}
+	for i, row := range rows {
+		if len(row) != len(rows[0]) {
+			return fmt.Errorf("%w: row %d of %s has %d values, not %d", ErrParameterMismatch, i, name, len(row), len(rows[0]))
+		}
+		for _, ct := range row {
+			if ct == nil || ct.C == nil {
+				return fmt.Errorf("%w: row %d of %s holds a nil ciphertext", ErrParameterMismatch, i, name)
+			}
+		}
+	}
+
+	reg := &party.prereg
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	if reg.running != "" {
+		return fmt.Errorf("%w: test %s is still running", ErrPreregistration, reg.running)
+	}
+	old, ok := reg.datasets[name]
+	if ok && (old.Rows != len(rows) || old.Cols != len(rows[0])) {
+		return fmt.Errorf("%w: dataset %s was uploaded with %d rows of %d values", ErrParameterMismatch, name, old.Rows, old.Cols)
+	}
+
+	if reg.datasets == nil {
+		reg.datasets = make(map[string]*Dataset)
+	}
+	if reg.uploaded == nil {
+		reg.uploaded = make(map[string]bool)
+	}
+
+	dataset := &Dataset{Rows: len(rows), Cols: len(rows[0])}
+	if ok {
+		dataset.Ciphertexts = append(dataset.Ciphertexts, old.Ciphertexts...)
+	}
+	var added []string
+	for _, row := range rows {
+		for _, ct := range row {
+			h := ctHash(ct)
+			if !reg.uploaded[h] {
+				reg.uploaded[h] = true
+				added = append(added, h)
+				dataset.Ciphertexts = append(dataset.Ciphertexts, h)
+			}
+		}
+	}
+
+	sealed := reg.sealed
+	reg.sealed = true
+	reg.datasets[name] = dataset
+
+	return reg.save(party, func() {
+		reg.sealed = sealed
+		if ok {
+			reg.datasets[name] = old
+		} else {
+			delete(reg.datasets, name)
+		}
+		for _, h := range added {
+			delete(reg.uploaded, h)
+		}
+	})
+}
+
+// checkReveal returns an error unless the party may open a value, which
+// under preregistration takes a running test with budget left for it
+func (party *Party) checkReveal(o opening) error {
+
+	if party.Analyst == nil {
+		return nil
+	}
+
+	reg := &party.prereg
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	if reg.running == "" {
+		return fmt.Errorf("%w: party %d opens values only while a preregistered test runs", ErrPreregistration, party.ID)
+	}
+	if time.Now().After(reg.deadline) {
+		return fmt.Errorf("%w: the window of test %s has closed", ErrPreregistration, reg.running)
+	}
+	if reg.opened[o] {
+		return nil
+	}
+
+	if o.decryption {
+		if reg.uploaded[o.key] {
+			return fmt.Errorf("%w: ciphertext %s is part of an uploaded dataset", ErrPreregistration, o.key)
+		}
+		if reg.decryptions >= reg.maxDecryptions {
+			return fmt.Errorf("%w: test %s decrypts at most %d ciphertexts", ErrPreregistration, reg.running, reg.maxDecryptions)
+		}
+		reg.decryptions++
+	} else {
+		if reg.reveals >= reg.maxReveals {
+			return fmt.Errorf("%w: test %s reveals at most %d shares", ErrPreregistration, reg.running, reg.maxReveals)
+		}
+		reg.reveals++
+	}
+	reg.opened[o] = true
+
+	return nil
+}
+
+// ran returns the spec named by hash and its ledger entry if its test ran
+// to the end
+func (party *Party) ran(hash []byte) (*Spec, *LedgerEntry, bool) {
+
+	reg := &party.prereg
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	key := hex.EncodeToString(hash)
+	entry, ok := reg.entry(key)
+	if !ok {
+		return nil, nil, false
+	}
+	return reg.specs[key], entry, true
+}
+
+// entry returns the ledger entry of the spec with the given key
+func (reg *registry) entry(key string) (*LedgerEntry, bool) {
+	spec, ok := reg.specs[key]
+	if !ok {
+		return nil, false
+	}
+	for _, entry := range reg.ledgers[spec.Dataset] {
+		if hex.EncodeToString(entry.Spec) == key {
+			return entry, true
+		}
+	}
+	return nil, false
+}
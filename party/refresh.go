@@ -0,0 +1,279 @@
+package party
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	mathbits "math/bits"
+
+	"github.com/sachaservan/paillier"
+)
+
+// A refresh starts a new epoch in which the parties hold fresh shares of
+// the same secrets. Every party deals a sharing of zero and adds the
+// pieces it receives to its share, so shares taken from a party before
+// the refresh do not combine with shares taken from another party after it
+
+// MaxRefreshes is the number of times a key can be refreshed. Every key
+// refresh lengthens the key shares, and decryption slows down with them,
+// so a party refuses to refresh a key share longer than MaxKeyShareBits
+const MaxRefreshes = 100
+
+// DealZero deals a sharing of zero as this party's piece of share id, and
+// as the MAC of share id if MACs are enabled, the MAC of zero being zero
+func (party *Party) DealZero(ctx context.Context, id int) (*Share, error) {
+
+	ids := []int{id}
+	if _, ok := party.macKeyID(); ok {
+		ids = append(ids, macID(id))
+	}
+
+	for _, i := range ids {
+		err := party.deal(ctx, big.NewInt(0), i)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, i := range ids {
+		err := party.awaitReshares(ctx, i)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &Share{party.ID, id}, nil
+}
+
+// Refresh adds the sharing of zero dealt by DealZero to share in place,
+// and to the MAC of share if it has one. Nothing is stored unless every
+// value it needs is there
+func (party *Party) Refresh(ctx context.Context, share, zero *Share) error {
+
+	pairs := [][2]int{{share.ID, zero.ID}}
+	if _, ok := party.macKeyID(); ok {
+		if _, err := party.getShare(macID(share.ID)); err == nil {
+			pairs = append(pairs, [2]int{macID(share.ID), macID(zero.ID)})
+		}
+	}
+
+	sums := make([]*big.Int, len(pairs))
+	for k, pair := range pairs {
+		v, err := party.getShare(pair[0])
+		if err != nil {
+			return err
+		}
+		z, err := party.getShare(pair[1])
+		if err != nil {
+			return err
+		}
+		sums[k] = v.Add(v, z)
+		sums[k].Mod(sums[k], party.P)
+	}
+
+	for k, pair := range pairs {
+		party.store(pair[0], sums[k])
+	}
+	return nil
+}
+
+// KeyShareBits returns the length of this party's share of the Paillier
+// decryption key, which the coordinator needs to size the refresh
+func (party *Party) KeyShareBits(ctx context.Context) (int, error) {
+	if party.Sk == nil {
+		return 0, fmt.Errorf("%w: no share of the decryption key", ErrShareNotFound)
+	}
+	return party.Sk.Share.BitLen(), nil
+}
+
+// RefreshKeyShare re-randomizes this party's share of the Paillier
+// decryption key, which is an integer. It deals an integer polynomial with
+// constant term 0 and coefficients of RefreshBits bits, which the party
+// works out itself, cut into limbs of KeyLimbBits bits that are dealt as
+// the shares ids, so that the pieces of every limb add up below P. It adds
+// the pieces it receives to its key share and keeps the result aside until
+// SetThresholdKey installs it, and returns the verification key of the
+// new share. It refuses a key share too long to refresh, see MaxRefreshes
+func (party *Party) RefreshKeyShare(ctx context.Context, ids []int) (*big.Int, error) {
+
+	if party.Sk == nil {
+		return nil, fmt.Errorf("%w: no share of the decryption key", ErrShareNotFound)
+	}
+	if party.SecurityBits < 1 {
+		return nil, fmt.Errorf("%w: party %d has no statistical security parameter", ErrParameterMismatch, party.ID)
+	}
+
+	if !Refreshable(party.Sk.N, party.Sk.Share.BitLen(), len(party.Parties), party.Threshold, party.SecurityBits) {
+		return nil, fmt.Errorf("%w: the key was refreshed %d times already", ErrParameterMismatch, MaxRefreshes)
+	}
+
+	bits := RefreshBits(party.Sk.N, party.Sk.Share.BitLen(), len(party.Parties), party.Threshold, party.SecurityBits)
+	width := KeyLimbBits(party.P, len(party.Parties), party.Threshold)
+	if width < 1 || len(ids)*width < bits {
+		return nil, fmt.Errorf("%w: %d limbs cannot hold %d bit coefficients", ErrParameterMismatch, len(ids), bits)
+	}
+
+	coeffs := make([]*big.Int, party.Threshold)
+	coeffs[0] = big.NewInt(0)
+	for k := 1; k < party.Threshold; k++ {
+		coeffs[k] = CryptoRandom(new(big.Int).Lsh(big.NewInt(1), uint(bits)))
+	}
+
+	mask := new(big.Int).Lsh(big.NewInt(1), uint(width))
+	mask.Sub(mask, big.NewInt(1))
+
+	for c, id := range ids {
+		limb := make([]*big.Int, len(coeffs))
+		for k := range coeffs {
+			limb[k] = new(big.Int).Rsh(coeffs[k], uint(c*width))
+			limb[k].And(limb[k], mask)
+		}
+
+		_, values, proofs := party.verifiableShares(limb, id)
+		err := party.DistributeReshares(ctx, id, values, proofs)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	share := new(big.Int).Set(party.Sk.Share)
+	for c, id := range ids {
+		err := party.awaitReshares(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+
+		sum, err := party.getShare(id)
+		if err != nil {
+			return nil, err
+		}
+		party.shares.Delete(id)
+
+		share.Add(share, sum.Lsh(sum, uint(c*width)))
+	}
+
+	party.keygen.mu.Lock()
+	party.keygen.share = share
+	party.keygen.mu.Unlock()
+
+	return verificationKey(party.Sk.V, share, party.Sk.N, len(party.Parties)), nil
+}
+
+// KeyLimbBits returns the width of the limbs in which a key refresh deals
+// its integer polynomials over shares mod p. The pieces of a limb that the
+// given number of parties deal at points up to their number add up below p
+func KeyLimbBits(p *big.Int, parties, threshold int) int {
+	l := mathbits.Len(uint(parties))
+	return p.BitLen() - 2 - (threshold+1)*l
+}
+
+// RefreshBits returns the length of the coefficients a party with a key
+// share of shareBits bits deals in a key refresh. They are s bits longer
+// than the key shares, which differ in length by up to the growth of the
+// polynomial between the points of the parties, so that they hide the
+// polynomial the key was shared with before. Dealt key shares are below
+// N^2, which sets the least length
+func RefreshBits(n *big.Int, shareBits, parties, threshold, s int) int {
+	bits := shareBits + (threshold-1)*mathbits.Len(uint(parties))
+	if min := 2 * n.BitLen(); bits < min {
+		bits = min
+	}
+	return bits + s
+}
+
+// refreshGrowth bounds the number of bits a key refresh adds to a key
+// share longer than 2|N|: s bits of the coefficients, the growth of the
+// polynomials between the points of the parties both before and after,
+// and the sum of the pieces of every party
+func refreshGrowth(parties, threshold, s int) int {
+	return s + 2*threshold*mathbits.Len(uint(parties))
+}
+
+// MaxKeyShareBits returns the length a key share reaches after
+// MaxRefreshes refreshes at the most. Key shares start out shorter than
+// the share modulus of distributed key generation, and dealt ones shorter
+// than N^2
+func MaxKeyShareBits(n *big.Int, parties, threshold, s int) int {
+	l := mathbits.Len(uint(parties))
+	dealt := 3*n.BitLen() + 3*s + (threshold+2)*l + 8
+	return dealt + MaxRefreshes*refreshGrowth(parties, threshold, s)
+}
+
+// Refreshable reports whether a key share of shareBits bits stays within
+// MaxKeyShareBits through one more refresh
+func Refreshable(n *big.Int, shareBits, parties, threshold, s int) bool {
+	return shareBits+refreshGrowth(parties, threshold, s) <= MaxKeyShareBits(n, parties, threshold, s)
+}
+
+// KeyRefreshed reports whether the verification keys of next differ from
+// those of prev by V^(delta*z_i) for shares z_i of zero, which is checked
+// in the exponent. The ratios of the first threshold keys must interpolate
+// to 1 at zero and to the ratio of every other key at its point
+func KeyRefreshed(prev, next *paillier.ThresholdKey, threshold int) bool {
+
+	n := len(prev.Vi)
+	if len(next.Vi) != n {
+		return false
+	}
+	nsq := big.NewInt(0).Mul(prev.N, prev.N)
+	delta := big.NewInt(0).MulRange(1, int64(n))
+
+	ratios := make([]*big.Int, n)
+	for i := range ratios {
+		if next.Vi[i] == nil {
+			return false
+		}
+		inv := big.NewInt(0).ModInverse(prev.Vi[i], nsq)
+		if inv == nil {
+			return false
+		}
+		ratios[i] = inv.Mul(inv, next.Vi[i])
+		ratios[i].Mod(ratios[i], nsq)
+	}
+
+	// z(x)*delta in the exponent from the first threshold points
+	interpolate := func(x int) *big.Int {
+		acc := big.NewInt(1)
+		for j := 0; j < threshold; j++ {
+			e := integerLagrange(j, threshold, x, delta)
+			r := big.NewInt(0).Exp(ratios[j], e, nsq)
+			if r == nil {
+				return nil
+			}
+			acc.Mul(acc, r)
+			acc.Mod(acc, nsq)
+		}
+		return acc
+	}
+
+	if one := interpolate(0); one == nil || one.Cmp(big.NewInt(1)) != 0 {
+		return false
+	}
+
+	for i := threshold; i < n; i++ {
+		want := big.NewInt(0).Exp(ratios[i], delta, nsq)
+		if got := interpolate(i + 1); got == nil || got.Cmp(want) != 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// integerLagrange returns delta times the Lagrange coefficient of party j
+// among the first threshold parties for interpolating at x, which is an
+// integer when delta is the factorial of the number of parties
+func integerLagrange(j, threshold, x int, delta *big.Int) *big.Int {
+
+	num := big.NewInt(0).Set(delta)
+	den := big.NewInt(1)
+	for m := 0; m < threshold; m++ {
+		if m == j {
+			continue
+		}
+		num.Mul(num, big.NewInt(int64(x-(m+1))))
+		den.Mul(den, big.NewInt(int64(j-m)))
+	}
+
+	return num.Quo(num, den)
+}
@@ -0,0 +1,180 @@
+package party
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net"
+	"net/rpc"
+	"sync"
+)
+
+// PartyService exposes a party's Transport over net/rpc to the node
+// authenticated on the connection
+type PartyService struct {
+	party Transport
+	peer  int
+}
+
+// Call answers a single request. net/rpc carries no deadline, so the
+// coordinator enforces its own and abandons requests it no longer needs
+func (s *PartyService) Call(msg *Message, res *Result) error {
+	if err := checkSender(msg, s.peer); err != nil {
+		return err
+	}
+
+	r, err := Dispatch(context.Background(), s.party, msg)
+	if err != nil {
+		return err
+	}
+	*res = *r
+	return nil
+}
+
+// Serve answers requests for the party on every connection accepted by
+// the listener. Connections are mutually authenticated with TLS, and
+// nodes that are not in the roster are turned away during the handshake.
+// It blocks until the listener is closed
+func Serve(party *Party, lis net.Listener, id *Identity, roster Roster) {
+	config := id.ServerConfig(roster)
+	for {
+		conn, err := lis.Accept()
+		if err != nil {
+			return
+		}
+
+		go func() {
+			tlsConn := tls.Server(conn, config)
+			err := tlsConn.Handshake()
+			if err != nil {
+				log.Printf("party %d: rejected connection from %s: %v", party.ID, conn.RemoteAddr(), err)
+				conn.Close()
+				return
+			}
+
+			// the handshake checked that the certificate is in the roster
+			peer, _ := roster.Lookup(tlsConn.ConnectionState().PeerCertificates[0].Raw)
+
+			server := rpc.NewServer()
+			server.RegisterName("Party", &PartyService{party, peer})
+			server.ServeConn(tlsConn)
+		}()
+	}
+}
+
+// checkSender lets only the coordinator drive the protocols. The roster
+// pins its certificate, and any other node, a custodian included, may only
+// deal reshares, on its own behalf, and ask for the commitments a share
+// was dealt with
+func checkSender(msg *Message, peer int) error {
+	switch {
+	case msg.Op == OpReshare:
+		if msg.From != peer {
+			return fmt.Errorf("%w: node %d cannot deal on behalf of party %d", ErrParameterMismatch, peer, msg.From)
+		}
+	case msg.Op == OpPing || msg.Op == OpBatch || msg.Op == OpEcho:
+	case peer != Coordinator:
+		return fmt.Errorf("%w: node %d cannot send request %d, which only the coordinator sends", ErrParameterMismatch, peer, msg.Op)
+	}
+	for _, m := range msg.Batch {
+		if err := checkSender(m, peer); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListenAndServe listens on the TCP address and serves the party
+func ListenAndServe(party *Party, addr string, id *Identity, roster Roster) error {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	Serve(party, lis, id, roster)
+	return nil
+}
+
+// RemoteParty is a Transport to a party running in another process.
+// The connection is established on first use
+type RemoteParty struct {
+	Client
+	ID   int
+	Addr string
+
+	config *tls.Config
+	mu     sync.Mutex
+	client *rpc.Client
+}
+
+// NewRemoteParty returns a Transport to party id at addr. The caller
+// authenticates as self and the party must present its certificate in roster
+func NewRemoteParty(id int, addr string, self *Identity, roster Roster) *RemoteParty {
+	rp := &RemoteParty{ID: id, Addr: addr, config: self.ClientConfig(roster, id)}
+	rp.Send = rp.call
+	return rp
+}
+
+func (rp *RemoteParty) getClient() (*rpc.Client, error) {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+
+	if rp.client != nil {
+		return rp.client, nil
+	}
+
+	conn, err := tls.Dial("tcp", rp.Addr, rp.config)
+	if err != nil {
+		return nil, err
+	}
+
+	client := rpc.NewClient(conn)
+	rp.client = client
+	return client, nil
+}
+
+func (rp *RemoteParty) call(ctx context.Context, msg *Message) (*Result, error) {
+	res := &Result{}
+	client, err := rp.getClient()
+	if err != nil {
+		return res, err
+	}
+
+	call := client.Go("Party.Call", msg, res, make(chan *rpc.Call, 1))
+	select {
+	case <-call.Done:
+		err = call.Error
+	case <-ctx.Done():
+		return &Result{}, ctx.Err()
+	}
+
+	if serr, ok := err.(rpc.ServerError); ok {
+		err = remoteError(string(serr))
+	}
+
+	if err == rpc.ErrShutdown {
+		// drop the connection so the next call redials
+		rp.mu.Lock()
+		if rp.client == client {
+			rp.client = nil
+		}
+		rp.mu.Unlock()
+	}
+
+	return res, err
+}
+
+// Close closes the underlying connection, if any
+func (rp *RemoteParty) Close() error {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+
+	if rp.client == nil {
+		return nil
+	}
+
+	err := rp.client.Close()
+	rp.client = nil
+	return err
+}
@@ -1,31 +1,69 @@
 package party
 
 import (
+	"context"
+	"crypto/ed25519"
 	"crypto/rand"
-	"errors"
+	"fmt"
 	"log"
 	"math/big"
 	"sync"
-	"time"
+	"sync/atomic"
 
 	"github.com/sachaservan/paillier"
 )
 
 var nextShareId = 0
 var shareIdMutex sync.Mutex
-var multMutex sync.Mutex
 
 type Party struct {
-	ID             int
-	Sk             *paillier.ThresholdPrivateKey
-	Pk             *paillier.PublicKey
-	P              *big.Int
-	BetaT          *big.Int // value of this party used for share reconstruction of degree threshold poly
-	BetaN          *big.Int // value of this party used for share reconstruction of degree N poly
-	Threshold      int
-	Parties        []*Party
-	NetworkLatency time.Duration
-	shares         sync.Map
+	ID        int
+	Sk        *paillier.ThresholdPrivateKey
+	Pk        *paillier.PublicKey
+	P         *big.Int
+	BetaT     *big.Int // value of this party used for share reconstruction of degree threshold poly
+	BetaN     *big.Int // value of this party used for share reconstruction of degree N poly
+	Threshold int
+	// statistical security parameter, in bits, of the key refresh
+	SecurityBits int
+	VSS          *VSSParams        // commitment parameters to check dealt shares, nil if VSS is off
+	Identity     *Identity         // authenticates the party to the other nodes
+	Analyst      ed25519.PublicKey // signs the preregistered tests; nil if reveals are not tied to them
+	Roster       Roster            // certificates of all nodes, to check the pieces of an opened statistic
+	Parties      []Transport
+	shares       sync.Map // values of shares, as *stored, by id
+	writes       uint64   // number of share values stored so far
+
+	mu      sync.RWMutex
+	offline []bool // parties the coordinator has taken offline
+	macKey  int    // id of the share of the MAC key, 0 if MACs are off
+
+	inboxMu sync.Mutex
+	inboxes map[int]*inbox // shares being dealt jointly, by id
+
+	keygen keyGen // secrets of distributed key generation
+
+	prereg registry // preregistered tests
+
+	results results // statistics seen opened
+
+	// SaveKey persists a new share of the decryption key before the party
+	// switches to it, after a key refresh. May be nil
+	SaveKey func(sk *paillier.ThresholdPrivateKey) error
+
+	// SavePrereg persists the preregistered tests and their ledgers
+	// whenever they change, before the party acts on the change. May be nil
+	SavePrereg func(state *PreregState) error
+
+	// Openings returns the number of shares a test reveals and of
+	// ciphertexts it decrypts on a dataset with the given shape. Nil
+	// stands for DefaultOpenings
+	Openings func(test string, rows, cols int) (reveals, decryptions int)
+
+	// Intervals returns the interval the values of a dataset lie in, which
+	// VerifyRanges holds the proofs of its data owners to, or nil if there
+	// is none. Under preregistration the specs of the dataset set it instead
+	Intervals func(dataset string) *Interval
 }
 
 type Share struct {
@@ -45,134 +83,211 @@ type PartialDecryptElement struct {
 	Gsk *paillier.Ciphertext
 }
 
-func (party *Party) RevealShare(share *Share) (*big.Int, error) {
-	time.Sleep(party.NetworkLatency)
-	return party.getShare(share.ID)
+// stored is the value of a share along with the write that stored it,
+// which tells apart the values stored under one id over time. A value
+// dealt with VSS also keeps the hash of its commitments, and is not used
+// until the other parties confirmed they saw the same
+type stored struct {
+	value     *big.Int
+	write     uint64
+	dealt     []byte // hash of the commitments of the dealing, nil if not dealt with VSS
+	confirmed uint32 // set once every online party echoed the same hash
 }
 
-// Store stores a share value
-func (party *Party) Store(share *Share, value *big.Int) {
-	party.shares.Store(share.ID, value)
+// store stores value as share id
+func (party *Party) store(id int, value *big.Int) {
+	party.shares.Store(id, &stored{value: value, write: atomic.AddUint64(&party.writes, 1)})
 }
 
-func (party *Party) getShare(shareID int) (*big.Int, error) {
-	// Checks if item exists
-	if v, ok := party.shares.Load(shareID); ok {
-		value := big.NewInt(0)
-		value.Set(v.(*big.Int))
-		return value, nil
-	}
-
-	return nil, errors.New("share not found")
+// storeDealt stores value as share id, dealt with commitments that hash to
+// dealt, until confirm accepts it
+func (party *Party) storeDealt(id int, value *big.Int, dealt []byte) {
+	party.shares.Store(id, &stored{value: value, write: atomic.AddUint64(&party.writes, 1), dealt: dealt})
 }
 
-func (party *Party) DeleteAllShares() {
-	party.shares = sync.Map{}
-	nextShareId = 0
-}
-
-func (party *Party) StoreAddShare(share *Share, value *big.Int) {
-	multMutex.Lock()
-	local, err := party.getShare(share.ID)
-	if err != nil {
-		local = big.NewInt(0)
+// load returns the value of share id and the write that stored it
+func (party *Party) load(id int) (*big.Int, uint64, error) {
+	v, ok := party.shares.Load(id)
+	if !ok {
+		return nil, 0, fmt.Errorf("%w: id %d", ErrShareNotFound, id)
 	}
-
-	local.Add(local, value)
-	party.shares.Store(share.ID, local)
-	multMutex.Unlock()
+	s := v.(*stored)
+	if s.dealt != nil && atomic.LoadUint32(&s.confirmed) == 0 {
+		return nil, 0, fmt.Errorf("%w: the commitments of share %d are not confirmed", ErrInvalidShare, id)
+	}
+	return new(big.Int).Set(s.value), s.write, nil
 }
 
-func (party *Party) Mult(share1, share2 *Share, newId int) (*Share, error) {
-	time.Sleep(party.NetworkLatency)
-
-	v1, err := party.getShare(share1.ID)
+// RevealShare reveals the party's share. Under preregistration the reveal
+// is charged to the running test, once for every value stored under the id
+func (party *Party) RevealShare(ctx context.Context, share *Share) (*big.Int, error) {
+	value, write, err := party.load(share.ID)
 	if err != nil {
 		return nil, err
 	}
-	v2, err := party.getShare(share2.ID)
-	if err != nil {
+	if err := party.checkReveal(shareOpening(share.ID, write)); err != nil {
 		return nil, err
 	}
+	return value, nil
+}
 
-	z := big.NewInt(0).Mul(v1, v2)
-	z.Mul(z, party.BetaN)
+// Store stores a share value, once it matches the commitments of its
+// dealer if VSS is on. The value is then used only after ConfirmShare
+func (party *Party) Store(ctx context.Context, share *Share, value *big.Int, proof *VSSProof) error {
+	if !party.validShare(value, proof) {
+		return fmt.Errorf("%w: party %d rejected its piece of share %d", ErrInvalidShare, party.ID, share.ID)
+	}
+	if party.VSS == nil {
+		party.store(share.ID, value)
+		return nil
+	}
+	party.storeDealt(share.ID, value, proof.hash())
+	return nil
+}
 
-	shares, values, _ := party.CreateShares(z, newId)
+func (party *Party) getShare(shareID int) (*big.Int, error) {
+	value, _, err := party.load(shareID)
+	return value, err
+}
 
-	party.DistributeMultShares(shares, values)
+func (party *Party) DeleteAllShares(ctx context.Context) error {
+	party.failInboxes(func(box *inbox) error { return errInboxDeleted })
 
-	return &Share{party.ID, newId}, nil
+	party.inboxMu.Lock()
+	party.inboxes = nil
+	party.inboxMu.Unlock()
+
+	// clear the map in place, requests may still be using it
+	party.shares.Range(func(id, _ interface{}) bool {
+		party.shares.Delete(id)
+		return true
+	})
+	nextShareId = 0
+
+	party.keygen.mu.Lock()
+	party.keygen.secrets = nil
+	party.keygen.mu.Unlock()
+
+	// the MAC key went with the other shares
+	return party.SetMACKey(ctx, nil)
 }
 
-func (party *Party) Sub(share1, share2 *Share, newId int) (*Share, error) {
-	v1, err := party.getShare(share1.ID)
+// DeleteShare discards a share and its MAC, such as the partial result of
+// a cancelled operation
+func (party *Party) DeleteShare(ctx context.Context, share *Share) error {
+	for _, id := range []int{share.ID, macID(share.ID)} {
+		party.inboxMu.Lock()
+		if box, ok := party.inboxes[id]; ok {
+			box.fail(errInboxDeleted)
+			delete(party.inboxes, id)
+		}
+		party.inboxMu.Unlock()
+
+		party.shares.Delete(id)
+	}
+	party.forgetSecret(share.ID)
+	return nil
+}
+
+func (party *Party) Mult(ctx context.Context, share1, share2 *Share, newId int) (*Share, error) {
+
+	// reshare the product and add up the pieces dealt by the other parties
+	err := party.dealProduct(ctx, share1.ID, share2.ID, newId)
 	if err != nil {
 		return nil, err
 	}
-	v2, err := party.getShare(share2.ID)
+
+	// the MAC of the product is the product of the MAC of share1 with share2
+	_, macs := party.macKeyID()
+	if macs {
+		err = party.dealProduct(ctx, macID(share1.ID), share2.ID, macID(newId))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = party.awaitReshares(ctx, newId)
 	if err != nil {
 		return nil, err
 	}
 
-	val := big.NewInt(0).Sub(v1, v2)
-	val.Mod(val, party.P)
-
-	party.shares.Store(newId, val)
+	if macs {
+		err = party.awaitReshares(ctx, macID(newId))
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	return &Share{party.ID, newId}, nil
 }
 
-func (party *Party) Add(share1, share2 *Share, newId int) (*Share, error) {
-	v1, err := party.getShare(share1.ID)
+func (party *Party) Sub(ctx context.Context, share1, share2 *Share, newId int) (*Share, error) {
+	err := party.linear(newId, func(v ...*big.Int) *big.Int {
+		val := big.NewInt(0).Sub(v[0], v[1])
+		return val.Mod(val, party.P)
+	}, share1.ID, share2.ID)
 	if err != nil {
 		return nil, err
 	}
-	v2, err := party.getShare(share2.ID)
+
+	return &Share{party.ID, newId}, nil
+}
+
+func (party *Party) Add(ctx context.Context, share1, share2 *Share, newId int) (*Share, error) {
+	err := party.linear(newId, func(v ...*big.Int) *big.Int {
+		val := big.NewInt(0).Add(v[0], v[1])
+		return val.Mod(val, party.P)
+	}, share1.ID, share2.ID)
 	if err != nil {
 		return nil, err
 	}
 
-	val := big.NewInt(0).Add(v1, v2)
-	val.Mod(val, party.P)
-
-	party.shares.Store(newId, val)
 	return &Share{party.ID, newId}, nil
 }
 
-func (party *Party) MultC(share *Share, c *big.Int, newId int) (*Share, error) {
-	val, err := party.getShare(share.ID)
+func (party *Party) MultC(ctx context.Context, share *Share, c *big.Int, newId int) (*Share, error) {
+	err := party.linear(newId, func(v ...*big.Int) *big.Int {
+		val := big.NewInt(0).Mul(v[0], c)
+		return val.Mod(val, party.P)
+	}, share.ID)
 	if err != nil {
 		return nil, err
 	}
 
-	val.Mul(val, c)
-	val.Mod(val, party.P)
-
-	party.shares.Store(newId, val)
-
 	return &Share{party.ID, newId}, nil
 }
 
-func (party *Party) CreateRandomShare(bound *big.Int, id int) *Share {
-	time.Sleep(party.NetworkLatency)
+func (party *Party) CreateRandomShare(ctx context.Context, bound *big.Int, id int) (*Share, error) {
 
-	r := Random(bound)
-	shares, values, id := party.CreateShares(r, id)
-	party.DistributeRandShares(shares, values)
+	err := party.deal(ctx, Random(bound), id)
+	if err != nil {
+		return nil, err
+	}
 
-	return shares[party.ID]
-}
+	err = party.awaitReshares(ctx, id)
+	if err != nil {
+		return nil, err
+	}
 
-func (party *Party) CopyShare(share *Share, newId int) *Share {
+	if key, ok := party.macKeyID(); ok {
+		err = party.authenticate(ctx, id, key)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &Share{party.ID, id}, nil
+}
 
-	val, err := party.getShare(share.ID)
+func (party *Party) CopyShare(ctx context.Context, share *Share, newId int) (*Share, error) {
+	err := party.linear(newId, func(v ...*big.Int) *big.Int {
+		return v[0]
+	}, share.ID)
 	if err != nil {
-		return nil
+		return nil, err
 	}
-	party.shares.Store(newId, val)
 
-	return &Share{party.ID, newId}
+	return &Share{party.ID, newId}, nil
 }
 
 func NewShareID() int {
@@ -184,9 +299,13 @@ func NewShareID() int {
 }
 
 func (party *Party) CreateShares(s *big.Int, id int) ([]*Share, []*big.Int, int) {
+	shares, values := party.evaluate(party.polynomial(s), id)
+	return shares, values, id
+}
 
-	shares := make([]*Share, len(party.Parties))
-	values := make([]*big.Int, len(party.Parties))
+// polynomial returns the coefficients of a random polynomial of degree
+// Threshold-1 whose constant term is s
+func (party *Party) polynomial(s *big.Int) []*big.Int {
 
 	coeffs := make([]*big.Int, party.Threshold)
 	coeffs[0] = big.NewInt(0)
@@ -196,6 +315,16 @@ func (party *Party) CreateShares(s *big.Int, id int) ([]*Share, []*big.Int, int)
 		coeffs[i] = CryptoRandom(party.P)
 	}
 
+	return coeffs
+}
+
+// evaluate returns the shares of the polynomial with the given
+// coefficients, party i getting its value at x = i+1
+func (party *Party) evaluate(coeffs []*big.Int, id int) ([]*Share, []*big.Int) {
+
+	shares := make([]*Share, len(party.Parties))
+	values := make([]*big.Int, len(party.Parties))
+
 	var wg sync.WaitGroup
 	for i := 0; i < len(party.Parties); i++ {
 		wg.Add(1)
@@ -205,37 +334,32 @@ func (party *Party) CreateShares(s *big.Int, id int) ([]*Share, []*big.Int, int)
 			// use Horner's method to eval the polynomial
 			x := big.NewInt(int64(i + 1))
 			acc := big.NewInt(0)
-			for k := party.Threshold - 1; k >= 0; k-- {
+			for k := len(coeffs) - 1; k >= 0; k-- {
 				acc.Mul(acc, x)
 				acc.Add(acc, coeffs[k])
 			}
 
 			values[i] = acc.Mod(acc, party.P)
-			shares[i] = &Share{PartyID: party.Parties[i].ID, ID: id}
+			shares[i] = &Share{PartyID: i, ID: id}
 
 		}(i)
 	}
 
 	wg.Wait()
 
-	return shares, values, id
-}
-
-func (party *Party) DistributeShares(shares []*Share, values []*big.Int) {
-	for i := 0; i < len(party.Parties); i++ {
-		party.Parties[i].Store(shares[i], values[i])
-	}
-}
-func (party *Party) DistributeRandShares(shares []*Share, values []*big.Int) {
-	for i := 0; i < len(party.Parties); i++ {
-		party.Parties[shares[i].PartyID].StoreAddShare(shares[i], values[i])
-	}
+	return shares, values
 }
 
-func (party *Party) DistributeMultShares(shares []*Share, values []*big.Int) {
+func (party *Party) DistributeShares(ctx context.Context, shares []*Share, values []*big.Int, proofs []*VSSProof) error {
 	for i := 0; i < len(party.Parties); i++ {
-		party.Parties[i].StoreAddShare(shares[i], values[i])
+		if !party.isOnline(i) {
+			continue
+		}
+		if err := party.Parties[i].Store(ctx, shares[i], values[i], proofs[i]); err != nil {
+			return err
+		}
 	}
+	return nil
 }
 
 // generates a new random number < max
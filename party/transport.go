@@ -0,0 +1,504 @@
+package party
+
+import (
+	"context"
+	"custodes/zkp"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/sachaservan/paillier"
+)
+
+// Transport is the set of requests a party answers, either for
+// the coordinator or for its peers. *Party implements it in-process
+// and *RemoteParty implements it over TCP
+type Transport interface {
+	RevealShare(ctx context.Context, share *Share) (*big.Int, error)
+	Store(ctx context.Context, share *Share, value *big.Int, proof *VSSProof) error
+	Reshare(ctx context.Context, share *Share, from int, value *big.Int, proof *VSSProof) error
+	Echo(ctx context.Context, share *Share) ([]byte, error)
+	ConfirmShare(ctx context.Context, share *Share) error
+	DeleteAllShares(ctx context.Context) error
+	DeleteShare(ctx context.Context, share *Share) error
+	CopyShare(ctx context.Context, share *Share, newId int) (*Share, error)
+	Add(ctx context.Context, share1, share2 *Share, newId int) (*Share, error)
+	Sub(ctx context.Context, share1, share2 *Share, newId int) (*Share, error)
+	MultC(ctx context.Context, share *Share, c *big.Int, newId int) (*Share, error)
+	Mult(ctx context.Context, share1, share2 *Share, newId int) (*Share, error)
+	CreateRandomShare(ctx context.Context, bound *big.Int, id int) (*Share, error)
+	GetRandomMultEnc(ctx context.Context, c *paillier.Ciphertext) (*paillier.Ciphertext, *paillier.Ciphertext, error)
+	GetRandomEncAndShare(ctx context.Context, id int, bound *big.Int) (*paillier.Ciphertext, *Share, error)
+	GetRandomEncBitVector(ctx context.Context, m int, session []byte) ([]*paillier.Ciphertext, []*zkp.BitProof, error)
+	GetRandomEnc(ctx context.Context, bound *big.Int) (*paillier.Ciphertext, error)
+	PartialDecrypt(ctx context.Context, ciphertext *paillier.Ciphertext) (*paillier.PartialDecryption, error)
+	PartialDecryptAndProof(ctx context.Context, ciphertext *paillier.Ciphertext) (*paillier.PartialDecryptionZKP, error)
+	VerifyRanges(ctx context.Context, dataset string, cts []*paillier.Ciphertext, proofs []*zkp.IntervalProof) ([]bool, error)
+	VerifyOneHot(ctx context.Context, dataset string, rows [][]*paillier.Ciphertext, proofs []*zkp.OneHotProof, precBits int) ([]bool, error)
+	SetMACKey(ctx context.Context, key *Share) error
+	Authenticate(ctx context.Context, share *Share) error
+	MACCheck(ctx context.Context, ids []int, values []*big.Int, coeffs []*big.Int, newId int) (*Share, error)
+	DealPrimeShare(ctx context.Context, bits int, id int) (*Share, error)
+	DealKeyMask(ctx context.Context, bits int, id int) (*Share, error)
+	BiprimeTest(ctx context.Context, p, q *Share, n *big.Int, gs []*big.Int) ([]*big.Int, error)
+	DealKeyShare(ctx context.Context, mask *Share, y, n, v *big.Int, bits int, id int) (*big.Int, error)
+	TestKeyShare(ctx context.Context, tk *paillier.ThresholdKey, test *KeyTest) (*paillier.PartialDecryptionZKP, error)
+	SetThresholdKey(ctx context.Context, tk *paillier.ThresholdKey, test *KeyTest) error
+	DealZero(ctx context.Context, id int) (*Share, error)
+	Refresh(ctx context.Context, share, zero *Share) error
+	KeyShareBits(ctx context.Context) (int, error)
+	RefreshKeyShare(ctx context.Context, ids []int) (*big.Int, error)
+	RevealPiece(ctx context.Context, share *Share) (*Piece, error)
+	RecordStatistic(ctx context.Context, opening *Opening) error
+	SignStatement(ctx context.Context, st *Statement) ([]byte, error)
+	Preregister(ctx context.Context, signed *SignedSpec) error
+	BeginTest(ctx context.Context, hash []byte) error
+	EndTest(ctx context.Context, hash []byte, out *Outcome) (*LedgerEntry, error)
+	Seal(ctx context.Context) error
+	Upload(ctx context.Context, dataset string, rows [][]*paillier.Ciphertext) error
+	Ping(ctx context.Context) error
+	SetParties(ctx context.Context, ids []int) error
+}
+
+// Op identifies the request carried by a Message
+type Op int
+
+const (
+	OpRevealShare Op = iota
+	OpStore
+	OpReshare
+	OpDeleteAllShares
+	OpDeleteShare
+	OpCopyShare
+	OpAdd
+	OpSub
+	OpMultC
+	OpMult
+	OpCreateRandomShare
+	OpGetRandomMultEnc
+	OpGetRandomEncAndShare
+	OpGetRandomEncBitVector
+	OpGetRandomEnc
+	OpPartialDecrypt
+	OpPartialDecryptAndProof
+	OpVerifyRanges
+	OpVerifyOneHot
+	OpSetMACKey
+	OpAuthenticate
+	OpMACCheck
+	OpDealPrimeShare
+	OpDealKeyMask
+	OpBiprimeTest
+	OpDealKeyShare
+	OpSetThresholdKey
+	OpDealZero
+	OpRefresh
+	OpKeyShareBits
+	OpRefreshKeyShare
+	OpRevealPiece
+	OpRecordStatistic
+	OpSignStatement
+	OpPreregister
+	OpBeginTest
+	OpEndTest
+	OpTestKeyShare
+	OpSeal
+	OpUpload
+	OpEcho
+	OpConfirmShare
+	OpPing
+	OpSetParties
+	OpBatch
+)
+
+// Message is the wire encoding of a single Transport request
+type Message struct {
+	Op      Op
+	Share1  *Share
+	Share2  *Share
+	NewID   int
+	From    int // party dealing a reshare
+	Value   *big.Int
+	Dealing *VSSProof // commitments to a dealt share
+	Ct      *paillier.Ciphertext
+	M       int
+	IDs     []int
+	Values  []*big.Int
+	Coeffs  []*big.Int
+	Cts     []*paillier.Ciphertext
+	Rows    [][]*paillier.Ciphertext
+	Ranges  []*zkp.IntervalProof
+	OneHots []*zkp.OneHotProof
+	Key     *paillier.ThresholdKey
+	Opening *Opening    // opened statistic of a test
+	Stmt    *Statement  // result to certify
+	Spec    *SignedSpec // test to preregister
+	Hash    []byte      // hash of a preregistered spec
+	Outcome *Outcome    // what a test revealed
+	Dataset string      // name of an uploaded dataset
+	Session []byte      // fresh context of the proofs a request asks for
+	Test    *KeyTest    // test decryption under a new key
+	Batch   []*Message  // requests coalesced by a Batcher
+}
+
+// Result is the wire encoding of the reply to a Message
+type Result struct {
+	Share   *Share
+	Value   *big.Int
+	Ct1     *paillier.Ciphertext
+	Ct2     *paillier.Ciphertext
+	Cts     []*paillier.Ciphertext
+	Bits    []*zkp.BitProof // proofs that Cts encrypt bits
+	Partial *paillier.PartialDecryption
+	Proof   *paillier.PartialDecryptionZKP
+	Values  []*big.Int
+	Valid   []bool       // verdicts on a list of proofs
+	Piece   *Piece       // piece of an opened statistic
+	Sig     []byte       // signature on a statement
+	Hash    []byte       // hash of the commitments a share was dealt with
+	Entry   *LedgerEntry // ledger entry of a test that ended
+	Batch   []*Result    // replies to a batch, in request order
+	Err     string       // error of a request within a batch
+}
+
+// Dispatch answers msg using the given transport
+func Dispatch(ctx context.Context, t Transport, msg *Message) (*Result, error) {
+
+	res := &Result{}
+	if err := ctx.Err(); err != nil {
+		return res, err
+	}
+
+	var err error
+
+	switch msg.Op {
+	case OpRevealShare:
+		res.Value, err = t.RevealShare(ctx, msg.Share1)
+	case OpStore:
+		err = t.Store(ctx, msg.Share1, msg.Value, msg.Dealing)
+	case OpReshare:
+		err = t.Reshare(ctx, msg.Share1, msg.From, msg.Value, msg.Dealing)
+	case OpDeleteAllShares:
+		err = t.DeleteAllShares(ctx)
+	case OpDeleteShare:
+		err = t.DeleteShare(ctx, msg.Share1)
+	case OpCopyShare:
+		res.Share, err = t.CopyShare(ctx, msg.Share1, msg.NewID)
+	case OpAdd:
+		res.Share, err = t.Add(ctx, msg.Share1, msg.Share2, msg.NewID)
+	case OpSub:
+		res.Share, err = t.Sub(ctx, msg.Share1, msg.Share2, msg.NewID)
+	case OpMultC:
+		res.Share, err = t.MultC(ctx, msg.Share1, msg.Value, msg.NewID)
+	case OpMult:
+		res.Share, err = t.Mult(ctx, msg.Share1, msg.Share2, msg.NewID)
+	case OpCreateRandomShare:
+		res.Share, err = t.CreateRandomShare(ctx, msg.Value, msg.NewID)
+	case OpGetRandomMultEnc:
+		res.Ct1, res.Ct2, err = t.GetRandomMultEnc(ctx, msg.Ct)
+	case OpGetRandomEncAndShare:
+		res.Ct1, res.Share, err = t.GetRandomEncAndShare(ctx, msg.NewID, msg.Value)
+	case OpGetRandomEncBitVector:
+		res.Cts, res.Bits, err = t.GetRandomEncBitVector(ctx, msg.M, msg.Session)
+	case OpGetRandomEnc:
+		res.Ct1, err = t.GetRandomEnc(ctx, msg.Value)
+	case OpPartialDecrypt:
+		res.Partial, err = t.PartialDecrypt(ctx, msg.Ct)
+	case OpPartialDecryptAndProof:
+		res.Proof, err = t.PartialDecryptAndProof(ctx, msg.Ct)
+	case OpVerifyRanges:
+		res.Valid, err = t.VerifyRanges(ctx, msg.Dataset, msg.Cts, msg.Ranges)
+	case OpVerifyOneHot:
+		res.Valid, err = t.VerifyOneHot(ctx, msg.Dataset, msg.Rows, msg.OneHots, msg.M)
+	case OpSetMACKey:
+		err = t.SetMACKey(ctx, msg.Share1)
+	case OpAuthenticate:
+		err = t.Authenticate(ctx, msg.Share1)
+	case OpMACCheck:
+		res.Share, err = t.MACCheck(ctx, msg.IDs, msg.Values, msg.Coeffs, msg.NewID)
+	case OpDealPrimeShare:
+		res.Share, err = t.DealPrimeShare(ctx, msg.M, msg.NewID)
+	case OpDealKeyMask:
+		res.Share, err = t.DealKeyMask(ctx, msg.M, msg.NewID)
+	case OpBiprimeTest:
+		res.Values, err = t.BiprimeTest(ctx, msg.Share1, msg.Share2, msg.Value, msg.Values)
+	case OpDealKeyShare:
+		if len(msg.Values) != 3 {
+			return res, fmt.Errorf("%w: expected y, n and v", ErrParameterMismatch)
+		}
+		res.Value, err = t.DealKeyShare(ctx, msg.Share1, msg.Values[0], msg.Values[1], msg.Values[2], msg.M, msg.NewID)
+	case OpSetThresholdKey:
+		err = t.SetThresholdKey(ctx, msg.Key, msg.Test)
+	case OpDealZero:
+		res.Share, err = t.DealZero(ctx, msg.NewID)
+	case OpRefresh:
+		err = t.Refresh(ctx, msg.Share1, msg.Share2)
+	case OpKeyShareBits:
+		var bits int
+		bits, err = t.KeyShareBits(ctx)
+		res.Value = big.NewInt(int64(bits))
+	case OpRefreshKeyShare:
+		res.Value, err = t.RefreshKeyShare(ctx, msg.IDs)
+	case OpRevealPiece:
+		res.Piece, err = t.RevealPiece(ctx, msg.Share1)
+	case OpRecordStatistic:
+		err = t.RecordStatistic(ctx, msg.Opening)
+	case OpSignStatement:
+		res.Sig, err = t.SignStatement(ctx, msg.Stmt)
+	case OpPreregister:
+		err = t.Preregister(ctx, msg.Spec)
+	case OpBeginTest:
+		err = t.BeginTest(ctx, msg.Hash)
+	case OpEndTest:
+		res.Entry, err = t.EndTest(ctx, msg.Hash, msg.Outcome)
+	case OpTestKeyShare:
+		res.Proof, err = t.TestKeyShare(ctx, msg.Key, msg.Test)
+	case OpSeal:
+		err = t.Seal(ctx)
+	case OpUpload:
+		err = t.Upload(ctx, msg.Dataset, msg.Rows)
+	case OpEcho:
+		res.Hash, err = t.Echo(ctx, msg.Share1)
+	case OpConfirmShare:
+		err = t.ConfirmShare(ctx, msg.Share1)
+	case OpPing:
+		err = t.Ping(ctx)
+	case OpSetParties:
+		err = t.SetParties(ctx, msg.IDs)
+	case OpBatch:
+		res.Batch = dispatchBatch(ctx, t, msg.Batch)
+	default:
+		err = errors.New("unknown request")
+	}
+
+	return res, err
+}
+
+// Client implements Transport by encoding every request as a Message
+// and handing it to Send
+type Client struct {
+	Send func(ctx context.Context, msg *Message) (*Result, error)
+}
+
+func (client *Client) RevealShare(ctx context.Context, share *Share) (*big.Int, error) {
+	res, err := client.Send(ctx, &Message{Op: OpRevealShare, Share1: share})
+	return res.Value, err
+}
+
+func (client *Client) Store(ctx context.Context, share *Share, value *big.Int, proof *VSSProof) error {
+	_, err := client.Send(ctx, &Message{Op: OpStore, Share1: share, Value: value, Dealing: proof})
+	return err
+}
+
+func (client *Client) Reshare(ctx context.Context, share *Share, from int, value *big.Int, proof *VSSProof) error {
+	_, err := client.Send(ctx, &Message{Op: OpReshare, Share1: share, From: from, Value: value, Dealing: proof})
+	return err
+}
+
+func (client *Client) DeleteAllShares(ctx context.Context) error {
+	_, err := client.Send(ctx, &Message{Op: OpDeleteAllShares})
+	return err
+}
+
+func (client *Client) DeleteShare(ctx context.Context, share *Share) error {
+	_, err := client.Send(ctx, &Message{Op: OpDeleteShare, Share1: share})
+	return err
+}
+
+func (client *Client) CopyShare(ctx context.Context, share *Share, newId int) (*Share, error) {
+	res, err := client.Send(ctx, &Message{Op: OpCopyShare, Share1: share, NewID: newId})
+	return res.Share, err
+}
+
+func (client *Client) Add(ctx context.Context, share1, share2 *Share, newId int) (*Share, error) {
+	res, err := client.Send(ctx, &Message{Op: OpAdd, Share1: share1, Share2: share2, NewID: newId})
+	return res.Share, err
+}
+
+func (client *Client) Sub(ctx context.Context, share1, share2 *Share, newId int) (*Share, error) {
+	res, err := client.Send(ctx, &Message{Op: OpSub, Share1: share1, Share2: share2, NewID: newId})
+	return res.Share, err
+}
+
+func (client *Client) MultC(ctx context.Context, share *Share, c *big.Int, newId int) (*Share, error) {
+	res, err := client.Send(ctx, &Message{Op: OpMultC, Share1: share, Value: c, NewID: newId})
+	return res.Share, err
+}
+
+func (client *Client) Mult(ctx context.Context, share1, share2 *Share, newId int) (*Share, error) {
+	res, err := client.Send(ctx, &Message{Op: OpMult, Share1: share1, Share2: share2, NewID: newId})
+	return res.Share, err
+}
+
+func (client *Client) CreateRandomShare(ctx context.Context, bound *big.Int, id int) (*Share, error) {
+	res, err := client.Send(ctx, &Message{Op: OpCreateRandomShare, Value: bound, NewID: id})
+	return res.Share, err
+}
+
+func (client *Client) GetRandomMultEnc(ctx context.Context, c *paillier.Ciphertext) (*paillier.Ciphertext, *paillier.Ciphertext, error) {
+	res, err := client.Send(ctx, &Message{Op: OpGetRandomMultEnc, Ct: c})
+	return res.Ct1, res.Ct2, err
+}
+
+func (client *Client) GetRandomEncAndShare(ctx context.Context, id int, bound *big.Int) (*paillier.Ciphertext, *Share, error) {
+	res, err := client.Send(ctx, &Message{Op: OpGetRandomEncAndShare, NewID: id, Value: bound})
+	return res.Ct1, res.Share, err
+}
+
+func (client *Client) GetRandomEncBitVector(ctx context.Context, m int, session []byte) ([]*paillier.Ciphertext, []*zkp.BitProof, error) {
+	res, err := client.Send(ctx, &Message{Op: OpGetRandomEncBitVector, M: m, Session: session})
+	return res.Cts, res.Bits, err
+}
+
+func (client *Client) GetRandomEnc(ctx context.Context, bound *big.Int) (*paillier.Ciphertext, error) {
+	res, err := client.Send(ctx, &Message{Op: OpGetRandomEnc, Value: bound})
+	return res.Ct1, err
+}
+
+func (client *Client) PartialDecrypt(ctx context.Context, ciphertext *paillier.Ciphertext) (*paillier.PartialDecryption, error) {
+	res, err := client.Send(ctx, &Message{Op: OpPartialDecrypt, Ct: ciphertext})
+	return res.Partial, err
+}
+
+func (client *Client) PartialDecryptAndProof(ctx context.Context, ciphertext *paillier.Ciphertext) (*paillier.PartialDecryptionZKP, error) {
+	res, err := client.Send(ctx, &Message{Op: OpPartialDecryptAndProof, Ct: ciphertext})
+	return res.Proof, err
+}
+
+func (client *Client) VerifyRanges(ctx context.Context, dataset string, cts []*paillier.Ciphertext, proofs []*zkp.IntervalProof) ([]bool, error) {
+	res, err := client.Send(ctx, &Message{Op: OpVerifyRanges, Dataset: dataset, Cts: cts, Ranges: proofs})
+	return res.Valid, err
+}
+
+func (client *Client) VerifyOneHot(ctx context.Context, dataset string, rows [][]*paillier.Ciphertext, proofs []*zkp.OneHotProof, precBits int) ([]bool, error) {
+	res, err := client.Send(ctx, &Message{Op: OpVerifyOneHot, Dataset: dataset, Rows: rows, OneHots: proofs, M: precBits})
+	return res.Valid, err
+}
+
+func (client *Client) SetMACKey(ctx context.Context, key *Share) error {
+	_, err := client.Send(ctx, &Message{Op: OpSetMACKey, Share1: key})
+	return err
+}
+
+func (client *Client) Authenticate(ctx context.Context, share *Share) error {
+	_, err := client.Send(ctx, &Message{Op: OpAuthenticate, Share1: share})
+	return err
+}
+
+func (client *Client) MACCheck(ctx context.Context, ids []int, values []*big.Int, coeffs []*big.Int, newId int) (*Share, error) {
+	res, err := client.Send(ctx, &Message{Op: OpMACCheck, IDs: ids, Values: values, Coeffs: coeffs, NewID: newId})
+	return res.Share, err
+}
+
+func (client *Client) DealPrimeShare(ctx context.Context, bits int, id int) (*Share, error) {
+	res, err := client.Send(ctx, &Message{Op: OpDealPrimeShare, M: bits, NewID: id})
+	return res.Share, err
+}
+
+func (client *Client) DealKeyMask(ctx context.Context, bits int, id int) (*Share, error) {
+	res, err := client.Send(ctx, &Message{Op: OpDealKeyMask, M: bits, NewID: id})
+	return res.Share, err
+}
+
+func (client *Client) BiprimeTest(ctx context.Context, p, q *Share, n *big.Int, gs []*big.Int) ([]*big.Int, error) {
+	res, err := client.Send(ctx, &Message{Op: OpBiprimeTest, Share1: p, Share2: q, Value: n, Values: gs})
+	return res.Values, err
+}
+
+func (client *Client) DealKeyShare(ctx context.Context, mask *Share, y, n, v *big.Int, bits int, id int) (*big.Int, error) {
+	res, err := client.Send(ctx, &Message{Op: OpDealKeyShare, Share1: mask, Values: []*big.Int{y, n, v}, M: bits, NewID: id})
+	return res.Value, err
+}
+
+func (client *Client) SetThresholdKey(ctx context.Context, tk *paillier.ThresholdKey, test *KeyTest) error {
+	_, err := client.Send(ctx, &Message{Op: OpSetThresholdKey, Key: tk, Test: test})
+	return err
+}
+
+func (client *Client) DealZero(ctx context.Context, id int) (*Share, error) {
+	res, err := client.Send(ctx, &Message{Op: OpDealZero, NewID: id})
+	return res.Share, err
+}
+
+func (client *Client) Refresh(ctx context.Context, share, zero *Share) error {
+	_, err := client.Send(ctx, &Message{Op: OpRefresh, Share1: share, Share2: zero})
+	return err
+}
+
+func (client *Client) KeyShareBits(ctx context.Context) (int, error) {
+	res, err := client.Send(ctx, &Message{Op: OpKeyShareBits})
+	if err != nil {
+		return 0, err
+	}
+	return int(res.Value.Int64()), nil
+}
+
+func (client *Client) RefreshKeyShare(ctx context.Context, ids []int) (*big.Int, error) {
+	res, err := client.Send(ctx, &Message{Op: OpRefreshKeyShare, IDs: ids})
+	return res.Value, err
+}
+
+func (client *Client) RevealPiece(ctx context.Context, share *Share) (*Piece, error) {
+	res, err := client.Send(ctx, &Message{Op: OpRevealPiece, Share1: share})
+	return res.Piece, err
+}
+
+func (client *Client) RecordStatistic(ctx context.Context, opening *Opening) error {
+	_, err := client.Send(ctx, &Message{Op: OpRecordStatistic, Opening: opening})
+	return err
+}
+
+func (client *Client) SignStatement(ctx context.Context, st *Statement) ([]byte, error) {
+	res, err := client.Send(ctx, &Message{Op: OpSignStatement, Stmt: st})
+	return res.Sig, err
+}
+
+func (client *Client) Preregister(ctx context.Context, signed *SignedSpec) error {
+	_, err := client.Send(ctx, &Message{Op: OpPreregister, Spec: signed})
+	return err
+}
+
+func (client *Client) BeginTest(ctx context.Context, hash []byte) error {
+	_, err := client.Send(ctx, &Message{Op: OpBeginTest, Hash: hash})
+	return err
+}
+
+func (client *Client) EndTest(ctx context.Context, hash []byte, out *Outcome) (*LedgerEntry, error) {
+	res, err := client.Send(ctx, &Message{Op: OpEndTest, Hash: hash, Outcome: out})
+	return res.Entry, err
+}
+
+func (client *Client) TestKeyShare(ctx context.Context, tk *paillier.ThresholdKey, test *KeyTest) (*paillier.PartialDecryptionZKP, error) {
+	res, err := client.Send(ctx, &Message{Op: OpTestKeyShare, Key: tk, Test: test})
+	return res.Proof, err
+}
+
+func (client *Client) Seal(ctx context.Context) error {
+	_, err := client.Send(ctx, &Message{Op: OpSeal})
+	return err
+}
+
+func (client *Client) Upload(ctx context.Context, dataset string, rows [][]*paillier.Ciphertext) error {
+	_, err := client.Send(ctx, &Message{Op: OpUpload, Dataset: dataset, Rows: rows})
+	return err
+}
+
+func (client *Client) Echo(ctx context.Context, share *Share) ([]byte, error) {
+	res, err := client.Send(ctx, &Message{Op: OpEcho, Share1: share})
+	return res.Hash, err
+}
+
+func (client *Client) ConfirmShare(ctx context.Context, share *Share) error {
+	_, err := client.Send(ctx, &Message{Op: OpConfirmShare, Share1: share})
+	return err
+}
+
+func (client *Client) Ping(ctx context.Context) error {
+	_, err := client.Send(ctx, &Message{Op: OpPing})
+	return err
+}
+
+func (client *Client) SetParties(ctx context.Context, ids []int) error {
+	_, err := client.Send(ctx, &Message{Op: OpSetParties, IDs: ids})
+	return err
+}
@@ -14,3 +14,27 @@ func CryptoRandom(max *big.Int) *big.Int {
 
 	return rand
 }
+
+// LagrangeCoefficient returns the coefficient of party id used to
+// reconstruct f(0) from the evaluations of f held by the parties in ids.
+// Party i holds the evaluation at x = i+1
+func LagrangeCoefficient(id int, ids []int, modulus *big.Int) *big.Int {
+
+	xi := big.NewInt(int64(id + 1))
+	num := big.NewInt(1)
+	denom := big.NewInt(1)
+
+	for _, j := range ids {
+		if j == id {
+			continue
+		}
+
+		xj := big.NewInt(int64(j + 1))
+		num.Mul(num, xj)
+		denom.Mul(denom, big.NewInt(0).Sub(xj, xi))
+	}
+
+	denom.ModInverse(denom.Mod(denom, modulus), modulus)
+	num.Mul(num, denom)
+	return num.Mod(num, modulus)
+}
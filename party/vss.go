@@ -0,0 +1,293 @@
+package party
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync/atomic"
+)
+
+// ErrInvalidShare is returned when a dealt share does not lie on the
+// polynomial its dealer committed to
+var ErrInvalidShare = errors.New("share does not match its commitments")
+
+// vssGroupBits is the minimum size of the prime Q, so that discrete logs
+// mod Q are as hard as breaking the Paillier key
+const vssGroupBits = 2048
+
+// VSSParams are the public parameters of the Pedersen commitments that
+// make sharings verifiable: a prime Q = kP+1 and two generators G and H
+// of the subgroup of order P whose discrete log relation nobody knows
+type VSSParams struct {
+	Q *big.Int
+	G *big.Int
+	H *big.Int
+}
+
+// VSSProof lets the recipient of a share check that it lies on the
+// polynomial the dealer committed to. The dealer shares a random blinding
+// polynomial along with the secret one, which keeps the commitments hiding
+type VSSProof struct {
+	Blind       *big.Int   // recipient's share of the blinding polynomial
+	Commitments []*big.Int // G^a_k * H^b_k for the coefficients a_k and b_k of both polynomials
+}
+
+// NewVSSParams returns commitment parameters for shares mod the prime p
+func NewVSSParams(p *big.Int) (*VSSParams, error) {
+
+	bits := vssGroupBits
+	if bits < p.BitLen()+64 {
+		bits = p.BitLen() + 64
+	}
+	kBits := bits - p.BitLen()
+
+	q := new(big.Int)
+	for {
+		k, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), uint(kBits)))
+		if err != nil {
+			return nil, err
+		}
+		// k is even so that Q is odd, and has its top bit set so that Q has the full size
+		k.SetBit(k, 0, 0)
+		k.SetBit(k, kBits-1, 1)
+
+		q.Mul(k, p)
+		q.Add(q, big.NewInt(1))
+		if q.ProbablyPrime(20) {
+			break
+		}
+	}
+
+	return &VSSParams{
+		Q: q,
+		G: generator(q, p, "G"),
+		H: generator(q, p, "H"),
+	}, nil
+}
+
+// generator hashes tag into the subgroup of order p of Z_q^*. Every
+// generator comes out of a hash, so nobody knows the discrete log of one
+// to the base of another
+func generator(q, p *big.Int, tag string) *big.Int {
+
+	cofactor := new(big.Int).Sub(q, big.NewInt(1))
+	cofactor.Div(cofactor, p)
+
+	for counter := uint64(0); ; counter++ {
+
+		// hash well past the size of q so that x is close to uniform mod q
+		var buf []byte
+		for block := uint64(0); len(buf)*8 < q.BitLen()+128; block++ {
+			h := sha256.New()
+			h.Write([]byte("custodes vss " + tag))
+			binary.Write(h, binary.BigEndian, counter)
+			binary.Write(h, binary.BigEndian, block)
+			h.Write(q.Bytes())
+			buf = h.Sum(buf)
+		}
+
+		x := new(big.Int).SetBytes(buf)
+		x.Mod(x, q)
+		x.Exp(x, cofactor, q)
+		if x.Cmp(big.NewInt(1)) > 0 {
+			return x
+		}
+	}
+}
+
+// commit returns the commitments G^a_k * H^b_k mod Q to the coefficients
+// of the polynomials a and b
+func (params *VSSParams) commit(a, b []*big.Int, p *big.Int) []*big.Int {
+
+	commitments := make([]*big.Int, len(a))
+	for k := range a {
+		c := new(big.Int).Exp(params.G, new(big.Int).Mod(a[k], p), params.Q)
+		c.Mul(c, new(big.Int).Exp(params.H, new(big.Int).Mod(b[k], p), params.Q))
+		commitments[k] = c.Mod(c, params.Q)
+	}
+	return commitments
+}
+
+// verify reports whether value and proof.Blind are the evaluations at x of
+// the polynomials committed to in proof, which must have degree threshold-1
+func (params *VSSParams) verify(x int, value *big.Int, proof *VSSProof, threshold int, p *big.Int) bool {
+
+	if value == nil || value.Sign() < 0 || value.Cmp(p) >= 0 {
+		return false
+	}
+	if proof == nil || proof.Blind == nil || proof.Blind.Sign() < 0 || proof.Blind.Cmp(p) >= 0 {
+		return false
+	}
+	if len(proof.Commitments) != threshold {
+		return false
+	}
+
+	lhs := new(big.Int).Exp(params.G, value, params.Q)
+	lhs.Mul(lhs, new(big.Int).Exp(params.H, proof.Blind, params.Q))
+	lhs.Mod(lhs, params.Q)
+
+	// prod C_k^(x^k), evaluated with Horner's method in the exponent
+	xBig := big.NewInt(int64(x))
+	rhs := big.NewInt(1)
+	for k := threshold - 1; k >= 0; k-- {
+		c := proof.Commitments[k]
+		if c == nil || c.Sign() <= 0 || c.Cmp(params.Q) >= 0 {
+			return false
+		}
+		rhs.Exp(rhs, xBig, params.Q)
+		rhs.Mul(rhs, c)
+		rhs.Mod(rhs, params.Q)
+	}
+
+	return lhs.Cmp(rhs) == 0
+}
+
+// validShare reports whether value, dealt to the party with proof, lies on
+// the polynomial the dealer committed to. Any value is valid if VSS is off
+func (party *Party) validShare(value *big.Int, proof *VSSProof) bool {
+	if party.VSS == nil {
+		return true
+	}
+	return party.VSS.verify(party.ID+1, value, proof, party.Threshold, party.P)
+}
+
+// CreateVerifiableShares is CreateShares along with a Pedersen commitment
+// to the polynomial, which lets every party check its share on receipt.
+// The proofs are nil if the party has no VSS parameters
+func (party *Party) CreateVerifiableShares(s *big.Int, id int) ([]*Share, []*big.Int, []*VSSProof, int) {
+	shares, values, proofs := party.verifiableShares(party.polynomial(s), id)
+	return shares, values, proofs, id
+}
+
+// verifiableShares returns the shares of the polynomial with the given
+// coefficients and, if VSS is on, the proofs that go with them
+func (party *Party) verifiableShares(coeffs []*big.Int, id int) ([]*Share, []*big.Int, []*VSSProof) {
+
+	shares, values := party.evaluate(coeffs, id)
+
+	proofs := make([]*VSSProof, len(party.Parties))
+	if party.VSS == nil {
+		return shares, values, proofs
+	}
+
+	blinding := party.polynomial(CryptoRandom(party.P))
+	_, blinds := party.evaluate(blinding, id)
+
+	commitments := party.VSS.commit(coeffs, blinding, party.P)
+	for i := range proofs {
+		proofs[i] = &VSSProof{Blind: blinds[i], Commitments: commitments}
+	}
+
+	return shares, values, proofs
+}
+
+// hash returns the hash of the commitments of the proof, which is what
+// the parties echo to each other. The blind is the recipient's own
+func (proof *VSSProof) hash() []byte {
+	h := sha256.New()
+	h.Write([]byte("custodes vss commitments"))
+	binary.Write(h, binary.BigEndian, uint64(len(proof.Commitments)))
+	for _, c := range proof.Commitments {
+		b := c.Bytes()
+		binary.Write(h, binary.BigEndian, uint64(len(b)))
+		h.Write(b)
+	}
+	return h.Sum(nil)
+}
+
+// dealingsHash returns the hash of the commitments of the pieces of a
+// share, given by dealer
+func dealingsHash(dealt map[int][]byte) []byte {
+
+	dealers := make([]int, 0, len(dealt))
+	for from := range dealt {
+		dealers = append(dealers, from)
+	}
+	sort.Ints(dealers)
+
+	h := sha256.New()
+	h.Write([]byte("custodes vss dealings"))
+	for _, from := range dealers {
+		binary.Write(h, binary.BigEndian, uint64(from))
+		h.Write(dealt[from])
+	}
+	return h.Sum(nil)
+}
+
+// Echo returns the hash of the commitments the party's share was dealt
+// with, once all of its pieces have arrived
+func (party *Party) Echo(ctx context.Context, share *Share) ([]byte, error) {
+
+	// the pieces of a reshare may still be on their way
+	party.inboxMu.Lock()
+	box, ok := party.inboxes[share.ID]
+	if _, done := party.shares.Load(share.ID); !ok && !done {
+		box, ok = party.inbox(share.ID), true
+	}
+	party.inboxMu.Unlock()
+
+	if ok {
+		select {
+		case <-box.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if box.err != nil {
+			return nil, box.err
+		}
+	}
+
+	v, ok := party.shares.Load(share.ID)
+	if !ok {
+		return nil, fmt.Errorf("%w: id %d", ErrShareNotFound, share.ID)
+	}
+	dealt := v.(*stored).dealt
+	if dealt == nil {
+		return nil, fmt.Errorf("%w: share %d was not dealt with commitments", ErrParameterMismatch, share.ID)
+	}
+	return dealt, nil
+}
+
+// ConfirmShare accepts a share the coordinator dealt with Store once every
+// online party echoed the same commitments
+func (party *Party) ConfirmShare(ctx context.Context, share *Share) error {
+	return party.confirm(ctx, share.ID)
+}
+
+// confirm accepts the share id once every other online party echoed the
+// commitments this party saw. A share checks out against commitments that
+// the dealer sent it alone, so a dealer who commits to other polynomials
+// towards other parties is only caught here
+func (party *Party) confirm(ctx context.Context, id int) error {
+
+	v, ok := party.shares.Load(id)
+	if !ok {
+		return fmt.Errorf("%w: id %d", ErrShareNotFound, id)
+	}
+	s := v.(*stored)
+	if s.dealt == nil {
+		return fmt.Errorf("%w: share %d was not dealt with commitments", ErrParameterMismatch, id)
+	}
+
+	for i := 0; i < len(party.Parties); i++ {
+		if i == party.ID || !party.isOnline(i) {
+			continue
+		}
+		dealt, err := party.Parties[i].Echo(ctx, &Share{PartyID: i, ID: id})
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(dealt, s.dealt) {
+			return fmt.Errorf("%w: party %d saw other commitments to share %d than party %d", ErrInvalidShare, i, id, party.ID)
+		}
+	}
+
+	atomic.StoreUint32(&s.confirmed, 1)
+	return nil
+}
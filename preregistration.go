@@ -0,0 +1,104 @@
+package custodes
+
+import (
+	"custodes/party"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/sachaservan/paillier"
+)
+
+// preregister has every online party record the spec the analyst signed.
+// A party that is offline now will refuse to run the test later
+func (mpc *MPC) preregister(signed *party.SignedSpec) {
+
+	require(signed != nil && signed.Spec != nil, "nil spec")
+	mpc = mpc.scope("Preregister")
+
+	mpc.broadcast(func(i int, t party.Transport) error {
+		return t.Preregister(mpc.ctx, signed)
+	})
+}
+
+// seal has every online party stop taking specs
+func (mpc *MPC) seal() {
+	mpc = mpc.scope("Seal")
+	mpc.broadcast(func(i int, t party.Transport) error {
+		return t.Seal(mpc.ctx)
+	})
+}
+
+// upload has every online party record the rows of a dataset
+func (mpc *MPC) upload(dataset string, rows [][]*paillier.Ciphertext) {
+
+	require(len(rows) > 0, "empty dataset")
+	mpc = mpc.scope("Upload")
+
+	mpc.broadcast(func(i int, t party.Transport) error {
+		return t.Upload(mpc.ctx, dataset, rows)
+	})
+}
+
+// beginTest starts the preregistered test at every online party
+func (mpc *MPC) beginTest(spec *party.Spec) {
+
+	require(spec != nil, "nil spec")
+	mpc = mpc.scope("BeginTest")
+
+	hash := spec.Hash()
+	mpc.broadcast(func(i int, t party.Transport) error {
+		return t.BeginTest(mpc.ctx, hash)
+	})
+}
+
+// endTest closes the running test at every online party and returns the
+// entry the parties recorded in the ledger of its dataset
+func (mpc *MPC) endTest(spec *party.Spec, out *party.Outcome) *party.LedgerEntry {
+
+	require(spec != nil, "nil spec")
+	require(out != nil, "nil outcome")
+	mpc = mpc.scope("EndTest")
+
+	var mu sync.Mutex
+	entries := make(map[int]*party.LedgerEntry)
+	hash := spec.Hash()
+	mpc.broadcast(func(i int, t party.Transport) error {
+		entry, err := t.EndTest(mpc.ctx, hash, out)
+		if err == nil {
+			mu.Lock()
+			entries[i] = entry
+			mu.Unlock()
+		}
+		return err
+	})
+
+	// the ledgers of parties that missed a test have drifted apart
+	var res *party.LedgerEntry
+	for _, i := range mpc.Online() {
+		entry := entries[i]
+		require(entry != nil, "party %d recorded no ledger entry", i)
+		if res == nil {
+			res = entry
+			continue
+		}
+		if !reflect.DeepEqual(entry, res) {
+			fail(&PartyError{Party: i, Err: fmt.Errorf("%w: ledger of %s does not match", ErrParameterMismatch, spec.Dataset)})
+		}
+	}
+
+	return res
+}
+
+// broadcast sends the same request to every online party in one round.
+// The requests are idempotent, so a failure is simply retried
+func (mpc *MPC) broadcast(f func(i int, t party.Transport) error) {
+
+	err := mpc.retry(func() error {
+		return mpc.each(false, f)
+	})
+	if err != nil {
+		fail(err)
+	}
+	mpc.recordRounds(1)
+}
@@ -0,0 +1,280 @@
+package custodes
+
+import (
+	"context"
+	"crypto/ed25519"
+	"custodes/party"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/sachaservan/paillier"
+)
+
+// newPreregMPC returns an MPC instance whose parties open values for
+// preregistered tests only, as many as given whatever the test, and the
+// analyst who signs the tests
+func newPreregMPC(t *testing.T, reveals, decryptions int) (*MPC, *party.Identity) {
+	t.Helper()
+
+	analyst, err := party.NewIdentity(party.AnalystID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mpc, err := NewMPCKeyGen(&MPCKeyGenParams{
+		NumParties:      3,
+		Threshold:       2,
+		KeyBits:         512,
+		MessageBits:     100,
+		SecurityBits:    40,
+		FPPrecisionBits: 30,
+		Preregistration: true,
+		Analyst:         analyst.Key.Public().(ed25519.PublicKey)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tr := range mpc.Parties {
+		tr.(*party.Party).Openings = func(string, int, int) (int, int) {
+			return reveals, decryptions
+		}
+	}
+
+	return mpc, analyst
+}
+
+// uploadExample uploads a dataset of ten rows of two values under name
+func uploadExample(t *testing.T, mpc *MPC, name string) [][]*paillier.Ciphertext {
+	t.Helper()
+
+	rows := make([][]*paillier.Ciphertext, 10)
+	for i := range rows {
+		rows[i] = []*paillier.Ciphertext{mpc.Pk.Encrypt(big.NewInt(int64(i))), mpc.Pk.Encrypt(big.NewInt(int64(2 * i)))}
+	}
+	if err := mpc.Upload(name, rows); err != nil {
+		t.Fatal(err)
+	}
+	return rows
+}
+
+func TestPreregistrationBudget(t *testing.T) {
+
+	mpc, analyst := newPreregMPC(t, 2, 1)
+
+	spec := &party.Spec{
+		Test:       "T-Test",
+		Dataset:    "budget",
+		Alpha:      0.05,
+		Sides:      2,
+		Correction: party.Bonferroni,
+		Budget:     2,
+		Window:     time.Hour,
+	}
+	expired := *spec
+	expired.Test = "Pearson"
+	expired.Window = time.Nanosecond
+
+	// the coordinator cannot sign specs in place of the analyst
+	if err := mpc.Preregister(party.SignSpec(spec, mpc.Identity)); !errors.Is(err, ErrParameterMismatch) {
+		t.Fatalf("spec signed by the coordinator: got %v, want ErrParameterMismatch", err)
+	}
+	for _, s := range []*party.Spec{spec, &expired} {
+		if err := mpc.Preregister(party.SignSpec(s, analyst)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// once sealed, the parties take no new specs
+	if err := mpc.Seal(); err != nil {
+		t.Fatal(err)
+	}
+	late := *spec
+	late.Columns = []int{1}
+	if err := mpc.Preregister(party.SignSpec(&late, analyst)); !errors.Is(err, ErrPreregistration) {
+		t.Fatalf("spec after sealing: got %v, want ErrPreregistration", err)
+	}
+
+	a := mpc.MustCreateShares(big.NewInt(1))
+	b := mpc.MustCreateShares(big.NewInt(2))
+	c := mpc.MustCreateShares(big.NewInt(3))
+	x := mpc.Pk.Encrypt(big.NewInt(4))
+	y := mpc.Pk.Encrypt(big.NewInt(5))
+
+	// a test runs only on an uploaded dataset, whose rows are never
+	// decrypted
+	if err := mpc.BeginTest(spec); !errors.Is(err, ErrPreregistration) {
+		t.Fatalf("test before the upload: got %v, want ErrPreregistration", err)
+	}
+	rows := uploadExample(t, mpc, spec.Dataset)
+	if err := mpc.BeginTest(spec); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mpc.RevealInt(rows[3][1]); !errors.Is(err, ErrPreregistration) {
+		t.Fatalf("decryption of an uploaded ciphertext: got %v, want ErrPreregistration", err)
+	}
+
+	// opening a value again is not charged to the budget
+	for _, share := range []*party.Share{a, a, b} {
+		if _, err := mpc.RevealShare(share); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, ct := range []*paillier.Ciphertext{x, x} {
+		if _, err := mpc.RevealInt(ct); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := mpc.RevealShare(c); !errors.Is(err, ErrPreregistration) {
+		t.Fatalf("reveal past the budget: got %v, want ErrPreregistration", err)
+	}
+
+	// a value stored under an id that was opened is another value to open
+	for i, tr := range mpc.Parties {
+		err := tr.Store(context.Background(), &party.Share{PartyID: i, ID: a.ID}, big.NewInt(int64(i+7)), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := mpc.RevealShare(a); !errors.Is(err, ErrPreregistration) {
+		t.Fatalf("reveal of a value stored over an opened one: got %v, want ErrPreregistration", err)
+	}
+	if _, err := mpc.RevealInt(y); !errors.Is(err, ErrPreregistration) {
+		t.Fatalf("decryption past the budget: got %v, want ErrPreregistration", err)
+	}
+
+	// the ledger holds the statistic the parties saw opened
+	if _, err := mpc.EndTest(spec, &party.Outcome{NumRows: 10, NumCols: 2}); !errors.Is(err, ErrPreregistration) {
+		t.Fatalf("test that opened no statistic: got %v, want ErrPreregistration", err)
+	}
+	if _, err := mpc.RevealStatistic(b, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mpc.EndTest(spec, &party.Outcome{NumRows: 9, NumCols: 2}); !errors.Is(err, ErrParameterMismatch) {
+		t.Fatalf("outcome of another shape than the upload: got %v, want ErrParameterMismatch", err)
+	}
+	entry, err := mpc.EndTest(spec, &party.Outcome{NumRows: 10, NumCols: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.Statistic != "2" {
+		t.Fatalf("ledger holds statistic %s, want 2", entry.Statistic)
+	}
+
+	// nothing is opened once the window of a test has closed
+	if err := mpc.BeginTest(&expired); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond)
+	if _, err := mpc.RevealShare(c); !errors.Is(err, ErrPreregistration) {
+		t.Fatalf("reveal past the window: got %v, want ErrPreregistration", err)
+	}
+}
+
+func TestPreregistrationRestart(t *testing.T) {
+
+	mpc, analyst := newPreregMPC(t, 1, 0)
+
+	// party 0 keeps its preregistrations as a daemon does
+	p := mpc.Parties[0].(*party.Party)
+	var saved []byte
+	p.SavePrereg = func(state *party.PreregState) error {
+		data, err := json.Marshal(state)
+		saved = data
+		return err
+	}
+
+	spec := &party.Spec{
+		Test:       "T-Test",
+		Dataset:    "restart",
+		Alpha:      0.05,
+		Sides:      2,
+		Correction: party.Bonferroni,
+		Budget:     1,
+		Window:     time.Hour,
+	}
+	if err := mpc.Preregister(party.SignSpec(spec, analyst)); err != nil {
+		t.Fatal(err)
+	}
+	uploadExample(t, mpc, spec.Dataset)
+	if err := mpc.BeginTest(spec); err != nil {
+		t.Fatal(err)
+	}
+
+	// after a restart, the test that began is closed and the party
+	// still takes no new specs
+	restarted := &party.Party{ID: p.ID, Analyst: p.Analyst}
+	state := &party.PreregState{}
+	if err := json.Unmarshal(saved, state); err != nil {
+		t.Fatal(err)
+	}
+	if err := restarted.RestorePrereg(state); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if err := restarted.BeginTest(ctx, spec.Hash()); !errors.Is(err, ErrPreregistration) {
+		t.Fatalf("test that began before the restart: got %v, want ErrPreregistration", err)
+	}
+	late := *spec
+	late.Columns = []int{1}
+	if err := restarted.Preregister(ctx, party.SignSpec(&late, analyst)); !errors.Is(err, ErrPreregistration) {
+		t.Fatalf("spec after the restart: got %v, want ErrPreregistration", err)
+	}
+}
+
+func TestPreregistrationMACCheck(t *testing.T) {
+
+	mpc, analyst := newPreregMPC(t, 2, 0)
+	if err := mpc.EnableMACs(); err != nil {
+		t.Fatal(err)
+	}
+
+	spec := &party.Spec{
+		Test:       "T-Test",
+		Dataset:    "macs",
+		Alpha:      0.05,
+		Sides:      2,
+		Correction: party.Bonferroni,
+		Budget:     1,
+		Window:     time.Hour,
+	}
+	if err := mpc.Preregister(party.SignSpec(spec, analyst)); err != nil {
+		t.Fatal(err)
+	}
+
+	x := mpc.MustCreateShares(big.NewInt(42))
+	a := mpc.MustCreateShares(big.NewInt(1))
+	uploadExample(t, mpc, spec.Dataset)
+	if err := mpc.BeginTest(spec); err != nil {
+		t.Fatal(err)
+	}
+
+	// an honest reveal pays for the value and for the MAC check
+	if _, err := mpc.RevealShare(a); err != nil {
+		t.Fatal(err)
+	}
+
+	// a MAC check with a forged value of 0 opens alpha times x, which
+	// the parties charge like any reveal
+	ctx := context.Background()
+	id := party.NewShareID()
+	errs := make(chan error, len(mpc.Parties))
+	for _, tr := range mpc.Parties {
+		go func(tr party.Transport) {
+			_, err := tr.MACCheck(ctx, []int{x.ID}, []*big.Int{big.NewInt(0)}, []*big.Int{big.NewInt(1)}, id)
+			errs <- err
+		}(tr)
+	}
+	for range mpc.Parties {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, tr := range mpc.Parties {
+		_, err := tr.RevealShare(ctx, &party.Share{PartyID: i, ID: id})
+		if !errors.Is(err, ErrPreregistration) {
+			t.Fatalf("party %d opened a forged MAC check: got %v, want ErrPreregistration", i, err)
+		}
+	}
+}
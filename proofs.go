@@ -0,0 +1,142 @@
+package custodes
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	mathbits "math/bits"
+	"sync"
+
+	"custodes/party"
+	"custodes/zkp"
+
+	"github.com/sachaservan/paillier"
+)
+
+// verifyRanges has every online party check the interval proofs attached
+// to uploaded ciphertexts, against the interval the party knows for the
+// dataset, and returns which ciphertexts all of them accept
+func (mpc *MPC) verifyRanges(dataset string, cts []*paillier.Ciphertext, proofs []*zkp.IntervalProof) []bool {
+
+	require(len(cts) == len(proofs), "%d ciphertexts but %d range proofs", len(cts), len(proofs))
+	mpc = mpc.scope("VerifyRanges")
+
+	return mpc.verifyAll(len(cts), func(t party.Transport) ([]bool, error) {
+		return t.VerifyRanges(mpc.ctx, dataset, cts, proofs)
+	})
+}
+
+// CheckInterval returns an error unless rows values in the fixed point
+// interval [lo, hi] leave the statistics room in the message space. The
+// sum of the values must fit in K bits, and the sum of the squares of the
+// differences of two values, which EMult produces and ETruncPR takes at
+// 2K bits, must fit in 2K bits
+func (mpc *MPC) CheckInterval(lo, hi *big.Int, rows int) error {
+
+	if lo == nil || hi == nil || lo.Cmp(hi) > 0 || rows < 1 {
+		return fmt.Errorf("%w: %d rows in [%v, %v]", ErrParameterMismatch, rows, lo, hi)
+	}
+
+	bits := new(big.Int).Abs(lo).BitLen()
+	if b := new(big.Int).Abs(hi).BitLen(); b > bits {
+		bits = b
+	}
+	sum := mathbits.Len(uint(rows))
+
+	if bits+sum > mpc.K-1 {
+		return fmt.Errorf("%w: the sum of %d values in [%v, %v] does not fit in %d bits", ErrParameterMismatch, rows, lo, hi, mpc.K)
+	}
+	if 2*(bits+1)+sum > 2*mpc.K-1 {
+		return fmt.Errorf("%w: the sum of squares of %d values in [%v, %v] does not fit in %d bits", ErrParameterMismatch, rows, lo, hi, 2*mpc.K)
+	}
+	return nil
+}
+
+// verifyOneHot has every online party check the one-hot proofs attached to
+// uploaded categorical rows and returns which rows all of them accept
+func (mpc *MPC) verifyOneHot(dataset string, rows [][]*paillier.Ciphertext, proofs []*zkp.OneHotProof) []bool {
+
+	require(len(rows) == len(proofs), "%d rows but %d one-hot proofs", len(rows), len(proofs))
+	mpc = mpc.scope("VerifyOneHot")
+
+	return mpc.verifyAll(len(rows), func(t party.Transport) ([]bool, error) {
+		return t.VerifyOneHot(mpc.ctx, dataset, rows, proofs, mpc.FPPrecBits)
+	})
+}
+
+// verifyBitVectors reports, for every party that answered, whether it sent
+// m ciphertexts with valid proofs that they encrypt bits, made by the party
+// itself in the given session
+func (mpc *MPC) verifyBitVectors(vectors [][]*paillier.Ciphertext, proofs [][]*zkp.BitProof, m int, session []byte) map[int]bool {
+
+	var mu sync.Mutex
+	valid := make(map[int]bool)
+
+	var wg sync.WaitGroup
+	for i, vec := range vectors {
+		if vec == nil {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, vec []*paillier.Ciphertext, vecProofs []*zkp.BitProof) {
+			defer wg.Done()
+
+			zc := zkp.Context{Prover: i, Session: session}
+			ok := len(vec) == m && len(vecProofs) == m
+			for k := 0; ok && k < m; k++ {
+				ok = vecProofs[k].Verify(mpc.Pk, zc, vec[k])
+			}
+
+			mu.Lock()
+			valid[i] = ok
+			mu.Unlock()
+		}(i, vec, proofs[i])
+	}
+	wg.Wait()
+
+	return valid
+}
+
+// newSession returns a fresh session for the proofs of one run of a protocol
+func newSession() []byte {
+	session := make([]byte, 16)
+	if _, err := rand.Read(session); err != nil {
+		fail(err)
+	}
+	return session
+}
+
+// verifyAll asks every online party for its verdicts on n proofs and
+// returns which proofs all of them accept
+func (mpc *MPC) verifyAll(n int, verdicts func(t party.Transport) ([]bool, error)) []bool {
+
+	mpc.recordRounds(1)
+
+	valid := make([]bool, n)
+	for k := range valid {
+		valid[k] = true
+	}
+
+	// a proof is rejected as soon as one party rejects it
+	var mu sync.Mutex
+	err := mpc.retry(func() error {
+		return mpc.each(false, func(i int, t party.Transport) error {
+			v, err := verdicts(t)
+			if err != nil {
+				return err
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			for k := range valid {
+				valid[k] = valid[k] && k < len(v) && v[k]
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		fail(err)
+	}
+
+	return valid
+}
@@ -0,0 +1,118 @@
+package custodes
+
+import (
+	"custodes/party"
+	"custodes/zkp"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/sachaservan/paillier"
+)
+
+// proveUploads returns an encryption of 50 proven in [0, 100] and one of
+// 500 proven in [-1000, 1000], as data owners of the dataset would upload
+func proveUploads(t *testing.T, mpc *MPC, dataset string) ([]*paillier.Ciphertext, []*zkp.IntervalProof) {
+	t.Helper()
+
+	zc := party.UploadContext(dataset)
+	inside, insideProof, err := zkp.EncryptInInterval(mpc.Pk, zc, big.NewInt(50), big.NewInt(0), big.NewInt(100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wide, wideProof, err := zkp.EncryptInInterval(mpc.Pk, zc, big.NewInt(500), big.NewInt(-1000), big.NewInt(1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return []*paillier.Ciphertext{inside, wide}, []*zkp.IntervalProof{insideProof, wideProof}
+}
+
+// checkVerdicts fails the test unless the parties accept the value proven
+// in [0, 100] only
+func checkVerdicts(t *testing.T, valid []bool) {
+	t.Helper()
+
+	if len(valid) != 2 || !valid[0] {
+		t.Fatalf("verdicts %v: a value proven in the interval of the dataset was rejected", valid)
+	}
+	if valid[1] {
+		t.Fatal("a value proven in a wider interval than the one of the dataset was accepted")
+	}
+}
+
+func TestVerifyRangesConfiguredInterval(t *testing.T) {
+
+	params := &MPCKeyGenParams{
+		NumParties:      3,
+		Threshold:       2,
+		KeyBits:         512,
+		MessageBits:     100,
+		SecurityBits:    40,
+		FPPrecisionBits: 30,
+		Interval:        &party.Interval{Lo: big.NewInt(0), Hi: big.NewInt(100)}}
+	mpc, err := NewMPCKeyGen(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cts, proofs := proveUploads(t, mpc, "configured")
+	valid, err := mpc.VerifyRanges("configured", cts, proofs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkVerdicts(t, valid)
+
+	// parties that were not told the interval of the dataset check nothing
+	params.Interval = nil
+	mpc, err = NewMPCKeyGen(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cts, proofs = proveUploads(t, mpc, "configured")
+	if _, err := mpc.VerifyRanges("configured", cts, proofs); !errors.Is(err, ErrParameterMismatch) {
+		t.Fatalf("dataset without an interval: got %v, want ErrParameterMismatch", err)
+	}
+}
+
+func TestVerifyRangesPreregisteredInterval(t *testing.T) {
+
+	mpc, analyst := newPreregMPC(t, 0, 0)
+
+	spec := &party.Spec{
+		Test:       "T-Test",
+		Dataset:    "preregistered",
+		Columns:    []int{0, 1},
+		Alpha:      0.05,
+		Sides:      2,
+		Correction: party.Bonferroni,
+		Budget:     2,
+		Window:     time.Hour,
+		Interval:   &party.Interval{Lo: big.NewInt(0), Hi: big.NewInt(100)},
+	}
+	if err := mpc.Preregister(party.SignSpec(spec, analyst)); err != nil {
+		t.Fatal(err)
+	}
+
+	// the tests on a dataset agree on its interval
+	wider := *spec
+	wider.Test = "Pearson"
+	wider.Interval = &party.Interval{Lo: big.NewInt(-1000), Hi: big.NewInt(1000)}
+	if err := mpc.Preregister(party.SignSpec(&wider, analyst)); !errors.Is(err, ErrParameterMismatch) {
+		t.Fatalf("spec with another interval for the dataset: got %v, want ErrParameterMismatch", err)
+	}
+
+	cts, proofs := proveUploads(t, mpc, spec.Dataset)
+	valid, err := mpc.VerifyRanges(spec.Dataset, cts, proofs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkVerdicts(t, valid)
+
+	// no spec declares the interval of another dataset
+	cts, proofs = proveUploads(t, mpc, "unregistered")
+	if _, err := mpc.VerifyRanges("unregistered", cts, proofs); !errors.Is(err, ErrPreregistration) {
+		t.Fatalf("dataset without a preregistered interval: got %v, want ErrPreregistration", err)
+	}
+}
@@ -2,12 +2,14 @@ package custodes
 
 // Constants
 import (
+	"context"
+	"crypto/ed25519"
 	"crypto/rand"
 	"custodes/party"
-	"errors"
+	"fmt"
 	"math"
 	"math/big"
-
+	"sync"
 	"time"
 
 	"github.com/sachaservan/paillier"
@@ -20,14 +22,27 @@ var big1 *big.Int
 var big0 *big.Int
 
 type MPC struct {
-	Party      *party.Party   // party initiating the requests
-	Parties    []*party.Party // all other parties in the system
+	Party      *party.Party      // party initiating the requests
+	Parties    []party.Transport // all other parties in the system
 	Threshold  int
 	Pk         *paillier.PublicKey
-	K          int      // message space 2^K < N
-	S          int      // security parameter for statistically secure protocols
-	P          *big.Int // secret share prime modulus
-	FPPrecBits int      // fixed point precision bits
+	Tk         *paillier.ThresholdKey // public threshold key used to combine decryptions
+	K          int                    // message space 2^K < N
+	S          int                    // security parameter for statistically secure protocols
+	P          *big.Int               // secret share prime modulus
+	FPPrecBits int                    // fixed point precision bits
+	Identity   *party.Identity        // authenticates the coordinator to the parties
+	Roster     party.Roster           // certificates of the coordinator and all parties
+	Timeout    time.Duration          // how long to wait for a party before probing it, 0 to wait forever
+	Verify     bool                   // check the proofs of every partial decryption
+	Transcript *MPCTranscript         // records the Paillier protocols and decryptions if set
+
+	ctx           context.Context // protocols are abandoned once it is done
+	stats         *CommStats      // communication charged to the protocol being run
+	created       *shareLog       // shares created by the protocol being run
+	liveness      *liveness
+	macs          *macState
+	lagrangeCache *sync.Map // reconstruction coefficients by set of parties
 }
 
 type MPCKeyGenParams struct {
@@ -37,87 +52,203 @@ type MPCKeyGenParams struct {
 	SecurityBits    int // at least 40 bits
 	MessageBits     int // message space bits
 	FPPrecisionBits int
-	NetworkLatency  time.Duration // for network latency testing
+	NetworkLatency  time.Duration   // for network latency testing
+	Topology        *party.Topology // emulated network, overrides NetworkLatency
+	Batching        bool            // coalesce concurrent requests to each party into one message
+	Timeout         time.Duration   // how long to wait for a party before probing it, 0 to wait forever
+	Verify          bool            // check the proofs of every partial decryption
+	VSS             bool            // commit to every dealt polynomial so that the parties check their shares
+	// generate the Paillier key among the parties instead of dealing it from
+	// one process. Slow: see DistributedKeyGen
+	DistributedKeyGen bool
+	// have the parties open values only for tests preregistered by the
+	// analyst, see Preregister
+	Preregistration bool
+	Analyst         ed25519.PublicKey // signs the specs of preregistered tests, required with Preregistration
+	// fixed point interval the parties hold the uploaded values of every
+	// dataset to in VerifyRanges, nil for none. Preregistered specs name
+	// the interval of their dataset instead
+	Interval *party.Interval
 }
 
 func NewMPCKeyGen(params *MPCKeyGenParams) (*MPC, error) {
 
+	if params.Preregistration && len(params.Analyst) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("%w: preregistration takes the public key of the analyst", ErrParameterMismatch)
+	}
+
+	// degree reduction in Mult needs 2*Threshold-1 parties
+	if params.Threshold < 1 || params.NumParties < 2*params.Threshold-1 {
+		return nil, fmt.Errorf("%w: threshold %d is too high for %d parties", ErrParameterMismatch, params.Threshold, params.NumParties)
+	}
+
 	nu := int(math.Log2(float64(params.NumParties)))
 	if int64(params.MessageBits+params.SecurityBits+params.FPPrecisionBits+nu+1) >= int64(params.KeyBits) {
-		return nil, errors.New("modulus not big enough for given parameters")
+		return nil, fmt.Errorf("%w: modulus not big enough for given parameters", ErrParameterMismatch)
 	}
 
 	//shareModulusBits := 4*params.MessageBits + params.FPPrecisionBits + params.SecurityBits + nu + 1
 	secretSharePrime, err := rand.Prime(rand.Reader, params.KeyBits)
 
-	tkh, err := paillier.GetThresholdKeyGenerator(params.KeyBits, params.NumParties, params.Threshold, rand.Reader)
-	if err != nil {
-		return nil, err
+	var vss *party.VSSParams
+	if params.VSS {
+		vss, err = party.NewVSSParams(secretSharePrime)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	tpks, err := tkh.Generate()
-	pk := &tpks[0].PublicKey
-
-	if err != nil {
-		return nil, err
+	// identities used to authenticate the channels between nodes
+	identities := make([]*party.Identity, params.NumParties+1)
+	for i := range identities {
+		identities[i], err = party.NewIdentity(i - 1)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	// generate shamir polynomial
 	parties := make([]*party.Party, params.NumParties)
 	for i := 0; i < params.NumParties; i++ {
+		parties[i] = &party.Party{
+			ID:           i,
+			P:            secretSharePrime,
+			BetaT:        party.LagrangeCoefficient(i, firstIDs(params.Threshold), secretSharePrime),
+			BetaN:        party.LagrangeCoefficient(i, firstIDs(params.NumParties), secretSharePrime),
+			Threshold:    params.Threshold,
+			SecurityBits: params.SecurityBits,
+			VSS:          vss,
+			Identity:     identities[i+1]}
+	}
 
-		// generate the Beta value used for
-		// share reconstruction
-		si := big.NewInt(int64(i + 1))
-		betaThreshold := big.NewInt(1)
-		betaFull := big.NewInt(1)
-
-		denomThreshold := big.NewInt(1)
-		denomFull := big.NewInt(1)
+	topo := params.Topology
+	if topo == nil && params.NetworkLatency > 0 {
+		topo = party.UniformTopology(params.NetworkLatency)
+	}
 
-		for j := 1; j <= params.NumParties; j++ {
+	for i := 0; i < params.NumParties; i++ {
+		parties[i].Parties = connectParties(parties, topo, params.Batching, i)
+	}
 
-			if i+1 != j {
-				sj := big.NewInt(int64(j))
+	transports := connectParties(parties, topo, params.Batching, party.Coordinator)
 
-				if j <= params.Threshold {
-					betaThreshold.Mul(betaThreshold, sj)
-					denomThreshold.Mul(denomThreshold, big.NewInt(0).Sub(sj, si))
-				}
+	var tk *paillier.ThresholdKey
+	if params.DistributedKeyGen {
+		tk, err = generateKey(parties, transports, params)
+	} else {
+		tk, err = dealKey(parties, params)
+	}
+	if err != nil {
+		return nil, err
+	}
 
-				betaFull.Mul(betaFull, sj)
-				denomFull.Mul(denomFull, big.NewInt(0).Sub(sj, si))
-			}
+	// the key is in place, so from now on values are opened for preregistered tests only
+	if params.Preregistration {
+		for i := range parties {
+			parties[i].Analyst = params.Analyst
+		}
+	}
+	if params.Interval != nil {
+		for i := range parties {
+			parties[i].Intervals = func(string) *party.Interval { return params.Interval }
 		}
+	}
+
+	mpc := NewMPC(parties[0], transports, tk, secretSharePrime, params)
+	mpc.Identity = identities[0]
+	mpc.Roster = party.NewRoster(identities...)
+	for i := range parties {
+		parties[i].Roster = mpc.Roster
+	}
 
-		denomThreshold.ModInverse(denomThreshold, secretSharePrime)
-		denomFull.ModInverse(denomFull, secretSharePrime)
+	return mpc, nil
+}
 
-		betaThreshold.Mul(betaThreshold, denomThreshold)
-		betaThreshold.Mod(betaThreshold, secretSharePrime)
-		betaFull.Mul(betaFull, denomFull)
-		betaFull.Mod(betaFull, secretSharePrime)
+// dealKey generates the Paillier key in this process and hands every
+// party its key share
+func dealKey(parties []*party.Party, params *MPCKeyGenParams) (*paillier.ThresholdKey, error) {
 
-		parties[i] = &party.Party{
-			ID:             i,
-			Sk:             tpks[i],
-			Pk:             pk,
-			P:              secretSharePrime,
-			BetaT:          betaThreshold,
-			BetaN:          betaFull,
-			Threshold:      params.Threshold,
-			Parties:        parties,
-			NetworkLatency: params.NetworkLatency}
+	tkh, err := paillier.GetThresholdKeyGenerator(params.KeyBits, params.NumParties, params.Threshold, rand.Reader)
+	if err != nil {
+		return nil, err
 	}
 
-	mpc := &MPC{parties[0], parties, params.Threshold, pk, params.MessageBits, params.SecurityBits, secretSharePrime, params.FPPrecisionBits}
+	tpks, err := tkh.Generate()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range parties {
+		parties[i].Sk = tpks[i]
+		parties[i].Pk = &tpks[0].PublicKey
+	}
+
+	return &tpks[0].ThresholdKey, nil
+}
+
+// connectParties returns the transports through which node from reaches
+// every party, over the emulated network if a topology is given
+func connectParties(parties []*party.Party, topo *party.Topology, batching bool, from int) []party.Transport {
+
+	transports := make([]party.Transport, len(parties))
+	for i := 0; i < len(parties); i++ {
+		if i == from {
+			transports[i] = parties[i]
+			continue
+		}
+
+		var t party.Transport = parties[i]
+		if topo != nil {
+			t = party.NewEmulatedTransport(t, topo, from, i)
+		}
+		if batching {
+			t = party.NewBatcher(t, party.DefaultBatchWindow)
+		}
+		transports[i] = t
+	}
+
+	return transports
+}
+
+// NewMPC returns an MPC instance that coordinates the given parties.
+// The dealer is used to create shares of public values and need not hold a key share
+func NewMPC(dealer *party.Party, parties []party.Transport, tk *paillier.ThresholdKey, secretSharePrime *big.Int, params *MPCKeyGenParams) *MPC {
+
+	mpc := &MPC{
+		Party:      dealer,
+		Parties:    parties,
+		Threshold:  params.Threshold,
+		Pk:         &tk.PublicKey,
+		Tk:         tk,
+		K:          params.MessageBits,
+		S:          params.SecurityBits,
+		P:          secretSharePrime,
+		FPPrecBits: params.FPPrecisionBits,
+		Timeout:    params.Timeout,
+		Verify:     params.Verify,
+
+		ctx:           context.Background(),
+		stats:         newCommStats(),
+		liveness:      &liveness{},
+		macs:          &macState{},
+		lagrangeCache: &sync.Map{},
+	}
 
 	// init constants
 	big0 = big.NewInt(0)
 	big1 = big.NewInt(1)
 	big2 = big.NewInt(2)
-	big2InvN = big.NewInt(0).ModInverse(big2, pk.N)
+	big2InvN = big.NewInt(0).ModInverse(big2, tk.N)
 	big2InvP = big.NewInt(0).ModInverse(big2, secretSharePrime)
 
-	return mpc, nil
+	return mpc
+}
+
+// firstIDs returns the ids of the first n parties
+func firstIDs(n int) []int {
+	ids := make([]int, n)
+	for i := 0; i < n; i++ {
+		ids[i] = i
+	}
+	return ids
 }
@@ -0,0 +1,23 @@
+package custodes
+
+import "testing"
+
+// newTestMPC returns a system of three in-process parties with the
+// parameters of the command line tools
+func newTestMPC(t *testing.T) *MPC {
+	t.Helper()
+
+	mpc, err := NewMPCKeyGen(&MPCKeyGenParams{
+		NumParties:      3,
+		Threshold:       2,
+		KeyBits:         512,
+		MessageBits:     100,
+		SecurityBits:    40,
+		FPPrecisionBits: 30,
+		Verify:          true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return mpc
+}
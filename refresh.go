@@ -0,0 +1,144 @@
+package custodes
+
+import (
+	"fmt"
+	"math/big"
+
+	"custodes/party"
+
+	"github.com/sachaservan/paillier"
+)
+
+// refresh starts a new epoch: it re-randomizes the shares of the
+// decryption key and the given shares, and returns the threshold key with
+// the new verification keys
+func (mpc *MPC) refresh(shares []*party.Share) *paillier.ThresholdKey {
+
+	mpc = mpc.scope("Refresh")
+
+	// a party left out would keep shares that no longer fit the others
+	require(len(mpc.Online()) == len(mpc.Parties), "every party must take part in a refresh")
+
+	mpc.refreshShares(shares)
+	return mpc.refreshKey()
+}
+
+// refreshShares adds a fresh sharing of zero to every share, and to the
+// MAC key if MACs are on. Their MACs get a sharing of zero too
+func (mpc *MPC) refreshShares(shares []*party.Share) {
+
+	mpc.macs.mu.Lock()
+	if mpc.macs.key != nil {
+		shares = append(shares[:len(shares):len(shares)], mpc.macs.key)
+	}
+	mpc.macs.mu.Unlock()
+
+	if len(shares) == 0 {
+		return
+	}
+
+	zeros := make([]*party.Share, len(shares))
+	mpc.parallel(len(shares), func(mpc *MPC, k int) {
+		zeros[k] = mpc.newShare(true, func(t party.Transport, id int) error {
+			_, err := t.DealZero(mpc.ctx, id)
+			return err
+		})
+	})
+	mpc.recordRounds(1)
+	mpc.recordShareMessages(len(shares) * mpc.peerMessages())
+
+	ids := make([]int, len(zeros))
+	for k, zero := range zeros {
+		ids[k] = zero.ID
+	}
+	defer mpc.discard(ids...)
+
+	// every zero was dealt before any share changes, so a party that drops
+	// out now is the only one left with its old shares
+	err := mpc.each(false, func(i int, t party.Transport) error {
+		for k := range shares {
+			err := t.Refresh(mpc.ctx, shares[k], zeros[k])
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		fail(err)
+	}
+	mpc.recordRounds(1)
+}
+
+// refreshKey adds an integer sharing of zero to the shares of the
+// decryption key. Every party works out the length of the coefficients it
+// deals from N, its key share and its own security parameter, see
+// party.RefreshBits, and the coordinator only sizes the limbs they are
+// dealt in from the longest key share. Every party checks the new key
+// with a test decryption before it swaps in its new share
+func (mpc *MPC) refreshKey() *paillier.ThresholdKey {
+
+	n := len(mpc.Parties)
+	require(mpc.Tk != nil && len(mpc.Tk.Vi) == n, "no verification keys to refresh")
+
+	sizes := make([]int, n)
+	err := mpc.each(false, func(i int, t party.Transport) error {
+		bits, err := t.KeyShareBits(mpc.ctx)
+		sizes[i] = bits
+		return err
+	})
+	if err != nil {
+		fail(err)
+	}
+	mpc.recordRounds(1)
+
+	// a party would refuse to deal, and leave the others waiting for it
+	for i, size := range sizes {
+		if !party.Refreshable(mpc.Tk.N, size, n, mpc.Threshold, mpc.S) {
+			fail(fmt.Errorf("%w: the key share of party %d was refreshed %d times already", ErrParameterMismatch, i, party.MaxRefreshes))
+		}
+	}
+
+	bits := 0
+	for _, size := range sizes {
+		if b := party.RefreshBits(mpc.Tk.N, size, n, mpc.Threshold, mpc.S); b > bits {
+			bits = b
+		}
+	}
+
+	width := party.KeyLimbBits(mpc.P, n, mpc.Threshold)
+	require(width > 0, "the share modulus is too small to refresh the key")
+
+	ids := make([]int, (bits+width-1)/width)
+	for c := range ids {
+		ids[c] = party.NewShareID()
+		mpc.created.add(ids[c])
+	}
+	defer mpc.discard(ids...)
+
+	vis := make([]*big.Int, n)
+	err = mpc.each(true, func(i int, t party.Transport) error {
+		vi, err := t.RefreshKeyShare(mpc.ctx, ids)
+		vis[i] = vi
+		return err
+	})
+	if err != nil {
+		fail(err)
+	}
+	mpc.recordRounds(1)
+	mpc.recordShareMessages(len(ids) * mpc.peerMessages())
+
+	for i, vi := range vis {
+		require(vi != nil, "party %d returned no verification key", i)
+	}
+
+	tk := *mpc.Tk
+	tk.Vi = vis
+	if !party.KeyRefreshed(mpc.Tk, &tk, mpc.Threshold) {
+		fail(fmt.Errorf("%w: the refreshed key shares do not fit the key", ErrInvalidShare))
+	}
+
+	mpc.installKey(&tk)
+
+	return &tk
+}
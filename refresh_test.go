@@ -0,0 +1,92 @@
+package custodes
+
+import (
+	"custodes/party"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/sachaservan/paillier"
+)
+
+// keyShares returns the key shares the parties hold now
+func keyShares(mpc *MPC) []*paillier.ThresholdPrivateKey {
+	sks := make([]*paillier.ThresholdPrivateKey, len(mpc.Parties))
+	for i, t := range mpc.Parties {
+		sks[i] = t.(*party.Party).Sk
+	}
+	return sks
+}
+
+// combine decrypts ct with the partial decryptions of the given key shares
+func combine(t *testing.T, tk *paillier.ThresholdKey, ct *paillier.Ciphertext, sks ...*paillier.ThresholdPrivateKey) *big.Int {
+	t.Helper()
+
+	pds := make([]*paillier.PartialDecryption, len(sks))
+	for i, sk := range sks {
+		pds[i] = sk.Decrypt(ct.C)
+	}
+	m, err := tk.CombinePartialDecryptions(pds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestRefresh(t *testing.T) {
+
+	mpc := newTestMPC(t)
+
+	a := mpc.MustCreateShares(big.NewInt(5))
+	ct := mpc.Pk.Encrypt(big.NewInt(42))
+	before := keyShares(mpc)
+
+	mpc.MustRefresh(a)
+	after := keyShares(mpc)
+
+	if got := mpc.MustRevealInt(ct); got.Cmp(big.NewInt(42)) != 0 {
+		t.Fatalf("decryption after a refresh gave %v, want 42", got)
+	}
+	if got := mpc.MustRevealShare(a); got.Cmp(big.NewInt(5)) != 0 {
+		t.Fatalf("share revealed after a refresh as %v, want 5", got)
+	}
+
+	for i := range before {
+		if before[i].Share.Cmp(after[i].Share) == 0 {
+			t.Fatalf("party %d kept its key share through a refresh", i)
+		}
+	}
+
+	// key shares of one epoch combine, but not with those of another
+	if got := combine(t, mpc.Tk, ct, before[0], before[1]); got.Cmp(big.NewInt(42)) != 0 {
+		t.Fatalf("key shares from before the refresh decrypt to %v, want 42", got)
+	}
+	if got := combine(t, mpc.Tk, ct, after[0], after[1]); got.Cmp(big.NewInt(42)) != 0 {
+		t.Fatalf("key shares from after the refresh decrypt to %v, want 42", got)
+	}
+	if got := combine(t, mpc.Tk, ct, before[0], after[1]); got.Cmp(big.NewInt(42)) == 0 {
+		t.Fatal("key shares from before and after the refresh combined")
+	}
+	if got := combine(t, mpc.Tk, ct, after[0], before[1]); got.Cmp(big.NewInt(42)) == 0 {
+		t.Fatal("key shares from after and before the refresh combined")
+	}
+}
+
+func TestRefreshLimit(t *testing.T) {
+
+	mpc := newTestMPC(t)
+	p := mpc.Parties[1].(*party.Party)
+
+	// a key share as long as MaxRefreshes refreshes make it
+	sk := *p.Sk
+	max := party.MaxKeyShareBits(sk.N, len(mpc.Parties), mpc.Threshold, p.SecurityBits)
+	sk.Share = new(big.Int).Lsh(big.NewInt(1), uint(max-1))
+	p.Sk = &sk
+
+	if err := mpc.Refresh(); !errors.Is(err, ErrParameterMismatch) {
+		t.Fatalf("refresh of a key share of %d bits: got %v, want ErrParameterMismatch", max, err)
+	}
+	if _, err := p.RefreshKeyShare(mpc.ctx, []int{party.NewShareID()}); !errors.Is(err, ErrParameterMismatch) {
+		t.Fatalf("party refreshed a key share of %d bits: got %v, want ErrParameterMismatch", max, err)
+	}
+}
@@ -0,0 +1,126 @@
+package custodes
+
+import (
+	"context"
+	"custodes/party"
+	"math/big"
+	"net"
+	"testing"
+)
+
+// serveLocal serves every party of mpc on a localhost TCP port and
+// returns an MPC instance that drives them over RemoteParty, as the
+// coordinator of a deployment would
+func serveLocal(t *testing.T, mpc *MPC) *MPC {
+	t.Helper()
+
+	remote := make([]party.Transport, len(mpc.Parties))
+	for i, tr := range mpc.Parties {
+		p := tr.(*party.Party)
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { lis.Close() })
+		go party.Serve(p, lis, p.Identity, mpc.Roster)
+
+		rp := party.NewRemoteParty(i, lis.Addr().String(), mpc.Identity, mpc.Roster)
+		t.Cleanup(func() { rp.Close() })
+		remote[i] = rp
+	}
+
+	// the coordinator deals shares of public values only
+	dealer := &party.Party{
+		ID:        0,
+		Pk:        mpc.Pk,
+		P:         mpc.P,
+		Threshold: mpc.Threshold,
+		Parties:   remote,
+	}
+
+	params := &MPCKeyGenParams{
+		NumParties:      len(remote),
+		Threshold:       mpc.Threshold,
+		MessageBits:     mpc.K,
+		SecurityBits:    mpc.S,
+		FPPrecisionBits: mpc.FPPrecBits,
+		Verify:          mpc.Verify,
+	}
+	res := NewMPC(dealer, remote, mpc.Tk, mpc.P, params)
+	res.Identity = mpc.Identity
+	res.Roster = mpc.Roster
+
+	return res
+}
+
+func TestRemotePartyMatchesInProcess(t *testing.T) {
+
+	local := newTestMPC(t)
+	remote := serveLocal(t, local)
+
+	// both instances reach the same parties, so a share created through
+	// one can be opened through the other
+	for _, mpc := range []*MPC{local, remote} {
+		a, err := mpc.CreateShares(big.NewInt(7))
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := mpc.CreateShares(big.NewInt(6))
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, err := mpc.Mult(a, b)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, opener := range []*MPC{local, remote} {
+			for share, want := range map[*party.Share]int64{a: 7, b: 6, c: 42} {
+				got, err := opener.RevealShare(share)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got.Cmp(big.NewInt(want)) != 0 {
+					t.Fatalf("revealed %v, want %d", got, want)
+				}
+			}
+		}
+	}
+
+	// partial decryptions are deterministic, so both transports must
+	// return the same ones, with proofs that verify
+	ct := local.Pk.Encrypt(big.NewInt(1234))
+	ctx := context.Background()
+	for i := range local.Parties {
+		want, err := local.Parties[i].PartialDecrypt(ctx, ct)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := remote.Parties[i].PartialDecrypt(ctx, ct)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Id != want.Id || got.Decryption.Cmp(want.Decryption) != 0 {
+			t.Fatalf("party %d: remote partial decryption differs from the in-process one", i)
+		}
+
+		proof, err := remote.Parties[i].PartialDecryptAndProof(ctx, ct)
+		if err != nil {
+			t.Fatal(err)
+		}
+		proof.Key = local.Tk
+		if proof.Decryption.Cmp(want.Decryption) != 0 || !proof.Verify() {
+			t.Fatalf("party %d: remote decryption proof is invalid", i)
+		}
+	}
+
+	for _, mpc := range []*MPC{local, remote} {
+		val, err := mpc.RevealInt(ct)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if val.Cmp(big.NewInt(1234)) != 0 {
+			t.Fatalf("decrypted %v, want 1234", val)
+		}
+	}
+}
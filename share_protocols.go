@@ -3,61 +3,80 @@ package custodes
 import (
 	"math"
 	"math/big"
-	"sync"
 
 	"custodes/party"
 )
 
-func (mpc *MPC) RevealShareFP(share *party.Share, scale int) *big.Float {
+func (mpc *MPC) revealShareFP(share *party.Share, scale int) *big.Float {
 
-	val := mpc.RevealShare(share)
+	val := mpc.revealShare(share)
 	scaleFactor := big.NewInt(0).Exp(big2, big.NewInt(int64(scale)), nil)
 	fp := big.NewFloat(0.0).SetInt(val)
 	fp.Quo(fp, big.NewFloat(0.0).SetInt(scaleFactor))
 	return fp
 }
 
-func (mpc *MPC) RevealShare(share *party.Share) *big.Int {
-
-	var wg sync.WaitGroup
-	values := make([]*big.Int, len(mpc.Parties))
-	for i := 0; i < len(mpc.Parties); i++ {
-		wg.Add(1)
-		go func(i int) {
-			defer wg.Done()
-			val, err := mpc.Parties[i].RevealShare(share)
-			if err != nil {
-				panic(err)
-			}
-			val.Mul(val, mpc.Parties[i].BetaT)
-			values[i] = val
-		}(i)
+func (mpc *MPC) revealShare(share *party.Share) *big.Int {
+
+	requireShares(share)
+	mpc.recordRounds(1)
+
+	// reconstruct from whichever Threshold parties answer first
+	var values []*big.Int
+	var ids []int
+	err := mpc.retry(func() error {
+		// late answers of an earlier attempt must not race with this one
+		vals := make([]*big.Int, len(mpc.Parties))
+		var err error
+		ids, err = mpc.quorum(mpc.Threshold, func(i int, t party.Transport) error {
+			val, err := t.RevealShare(mpc.ctx, share)
+			vals[i] = val
+			return err
+		})
+		values = vals
+		return err
+	})
+	if err != nil {
+		fail(err)
 	}
 
-	wg.Wait()
+	coeffs := mpc.lagrange(ids)
+	terms := make([]*big.Int, len(ids))
+	for k, i := range ids {
+		terms[k] = big.NewInt(0).Mul(values[i], coeffs[k])
+	}
 
-	return mpc.ReconstructShare(values[0:mpc.Threshold])
+	value := mpc.ReconstructShare(terms)
+	mpc.macs.record(share.ID, value)
+	return value
 }
 
-func (mpc *MPC) DeleteAllShares() int {
+func (mpc *MPC) deleteAllShares() int {
 
 	numShares := party.NewShareID()
 
-	for i := 0; i < len(mpc.Parties); i++ {
-		mpc.Parties[i].DeleteAllShares()
+	// housekeeping is not charged to the protocol that just ran
+	for _, i := range mpc.Online() {
+		err := mpc.Parties[i].DeleteAllShares(mpc.ctx)
+		if err != nil {
+			fail(err)
+		}
+	}
+
+	// the parties dropped the MAC key along with the shares
+	if mpc.macs.enabled() {
+		mpc.enableMACs()
 	}
 
 	return numShares
 }
 
-func (mpc *MPC) CopyShare(share *party.Share) *party.Share {
-
-	id := party.NewShareID()
-	for i := 0; i < len(mpc.Parties); i++ {
-		mpc.Parties[i].CopyShare(share, id)
-	}
-
-	return &party.Share{mpc.Party.ID, id}
+func (mpc *MPC) copyShare(share *party.Share) *party.Share {
+	requireShares(share)
+	return mpc.newShare(false, func(t party.Transport, id int) error {
+		_, err := t.CopyShare(mpc.ctx, share, id)
+		return err
+	})
 }
 
 func (mpc *MPC) ReconstructShare(values []*big.Int) *big.Int {
@@ -72,12 +91,44 @@ func (mpc *MPC) ReconstructShare(values []*big.Int) *big.Int {
 
 	return s
 }
-func (mpc *MPC) CreateShares(value *big.Int) *party.Share {
+func (mpc *MPC) createShares(value *big.Int) *party.Share {
+
+	// authenticating the share multiplies it with the MAC key
+	if mpc.macs.enabled() && len(mpc.Online()) < 2*mpc.Threshold-1 {
+		fail(errTooFewParties)
+	}
 
-	id := party.NewShareID()
-	shares, values, _ := mpc.Party.CreateShares(value, id)
-	mpc.Party.DistributeShares(shares, values)
-	return shares[mpc.Party.ID]
+	var id int
+	err := mpc.retry(func() error {
+		var shares []*party.Share
+		var values []*big.Int
+		var proofs []*party.VSSProof
+		shares, values, proofs, id = mpc.Party.CreateVerifiableShares(value, party.NewShareID())
+		mpc.created.add(id)
+		err := mpc.each(true, func(i int, t party.Transport) error {
+			return t.Store(mpc.ctx, shares[i], values[i], proofs[i])
+		})
+		// with VSS, the parties check that they were all dealt the same
+		// commitments before they use the share
+		if err == nil && mpc.Party.VSS != nil {
+			err = mpc.each(true, func(i int, t party.Transport) error {
+				return t.ConfirmShare(mpc.ctx, shares[i])
+			})
+		}
+		if err == nil && mpc.macs.enabled() {
+			err = mpc.authenticate(&party.Share{PartyID: mpc.Party.ID, ID: id})
+			if err != nil {
+				// also wakes the parties waiting for the pieces of the MAC
+				mpc.discard(id)
+			}
+		}
+		return err
+	})
+	if err != nil {
+		fail(err)
+	}
+
+	return &party.Share{PartyID: mpc.Party.ID, ID: id}
 }
 
 func (mpc *MPC) EncodeFixedPoint(a *big.Float, prec int) *big.Int {
@@ -90,141 +141,110 @@ func (mpc *MPC) EncodeFixedPoint(a *big.Float, prec int) *big.Int {
 	return floor
 }
 
-func (mpc *MPC) Add(share1, share2 *party.Share) *party.Share {
-
-	id := party.NewShareID()
-
-	var res *party.Share
-	var err error
-	for i := 0; i < len(mpc.Parties); i++ {
-		res, err = mpc.Parties[i].Add(share1, share2, id)
-		if err != nil {
-			panic(err)
-		}
-	}
-
-	return res
+func (mpc *MPC) add(share1, share2 *party.Share) *party.Share {
+	requireShares(share1, share2)
+	return mpc.newShare(false, func(t party.Transport, id int) error {
+		_, err := t.Add(mpc.ctx, share1, share2, id)
+		return err
+	})
 }
-func (mpc *MPC) Sub(share1, share2 *party.Share) *party.Share {
-
-	id := party.NewShareID()
-
-	var res *party.Share
-	var err error
-	for i := 0; i < len(mpc.Parties); i++ {
-		res, err = mpc.Parties[i].Sub(share1, share2, id)
-		if err != nil {
-			panic(err)
-		}
-	}
-
-	return res
+func (mpc *MPC) sub(share1, share2 *party.Share) *party.Share {
+	requireShares(share1, share2)
+	return mpc.newShare(false, func(t party.Transport, id int) error {
+		_, err := t.Sub(mpc.ctx, share1, share2, id)
+		return err
+	})
 }
 
-func (mpc *MPC) MultC(share *party.Share, c *big.Int) *party.Share {
-
-	id := party.NewShareID()
-
-	var res *party.Share
-	var err error
-	var wg sync.WaitGroup
-	for i := 0; i < len(mpc.Parties); i++ {
-		wg.Add(1)
-		go func(i int) {
-			defer wg.Done()
-			res, err = mpc.Parties[i].MultC(share, c, id)
-			if err != nil {
-				panic(err)
-			}
-		}(i)
-	}
-
-	wg.Wait()
-
-	return res
+func (mpc *MPC) multC(share *party.Share, c *big.Int) *party.Share {
+	requireShares(share)
+	return mpc.newShare(false, func(t party.Transport, id int) error {
+		_, err := t.MultC(mpc.ctx, share, c, id)
+		return err
+	})
 }
 
-func (mpc *MPC) Mult(share1, share2 *party.Share) *party.Share {
-
-	id := party.NewShareID()
+func (mpc *MPC) mult(share1, share2 *party.Share) *party.Share {
 
-	var res *party.Share
-	var err error
-	var wg sync.WaitGroup
+	requireShares(share1, share2)
 
-	for i := 0; i < len(mpc.Parties); i++ {
-		wg.Add(1)
-		go func(i int) {
-			defer wg.Done()
-			res, err = mpc.Parties[i].Mult(share1, share2, id)
-			if err != nil {
-				panic(err)
-			}
-		}(i)
+	// degree reduction needs the product polynomial of degree 2(Threshold-1)
+	if len(mpc.Online()) < 2*mpc.Threshold-1 {
+		fail(errTooFewParties)
 	}
 
-	wg.Wait()
+	res := mpc.newShare(true, func(t party.Transport, id int) error {
+		_, err := t.Mult(mpc.ctx, share1, share2, id)
+		return err
+	})
+
+	// request round followed by the degree reduction among peers, which
+	// reduces the MAC along with the product
+	mpc.recordRounds(2)
+	mpc.recordShareMessages(mpc.peerMessages())
+	if mpc.macs.enabled() {
+		mpc.recordShareMessages(mpc.peerMessages())
+	}
 
 	return res
 }
 
-// EFPNormalize returns a tuple (b, v) such that a/2^v is between 0.5 and 1
-func (mpc *MPC) FPNormalize(b *party.Share) (*party.Share, *party.Share) {
+func (mpc *MPC) fpNormalize(b *party.Share) (*party.Share, *party.Share) {
 
-	bitsa := mpc.ReverseBits(mpc.BitsDec(b, mpc.K))
-	ybits := mpc.ReverseBits(mpc.BitsPrefixOR(bitsa))
+	bitsa := mpc.ReverseBits(mpc.bitsDec(b, mpc.K))
+	ybits := mpc.ReverseBits(mpc.bitsPrefixOR(bitsa))
 
 	for i := 0; i < mpc.K-1; i++ {
-		ybits[i] = mpc.Sub(ybits[i], ybits[i+1])
+		ybits[i] = mpc.sub(ybits[i], ybits[i+1])
 	}
 
-	v := mpc.CreateShares(big.NewInt(0))
+	v := mpc.createShares(big.NewInt(0))
 
 	pow := big.NewInt(0).Exp(big.NewInt(2), big.NewInt(int64(mpc.K-1)), nil)
 
 	for i := 0; i < mpc.K; i++ {
-		t := mpc.MultC(ybits[i], pow)
-		v = mpc.Add(v, t)
+		t := mpc.multC(ybits[i], pow)
+		v = mpc.add(v, t)
 		pow.Div(pow, big.NewInt(2))
 	}
 
-	u := mpc.Mult(b, v)
+	u := mpc.mult(b, v)
 
 	return u, v
 }
 
-//EFPReciprocal return an approximation of [1/b]
-func (mpc *MPC) FPReciprocal(b *party.Share) *party.Share {
+func (mpc *MPC) fpReciprocal(b *party.Share) *party.Share {
 
-	a := mpc.CreateShares((mpc.EncodeFixedPoint(big.NewFloat(1.0), mpc.FPPrecBits)))
-	return mpc.FPDivision(a, b)
+	a := mpc.createShares((mpc.EncodeFixedPoint(big.NewFloat(1.0), mpc.FPPrecBits)))
+	return mpc.fpDivision(a, b)
 }
 
-// FPDivision return the approximate result of [a/b]
-func (mpc *MPC) FPDivision(a, b *party.Share) *party.Share {
+func (mpc *MPC) fpDivision(a, b *party.Share) *party.Share {
+
+	mpc = mpc.scope("FPDivision")
 
 	// init goldschmidt constants
 	theta := int(math.Ceil(math.Log2(float64(mpc.K) / 3.75)))
-	alphaEnc := mpc.CreateShares(mpc.EncodeFixedPoint(big.NewFloat(1.0), mpc.K))
+	alphaEnc := mpc.createShares(mpc.EncodeFixedPoint(big.NewFloat(1.0), mpc.K))
 
 	w := mpc.initReciprocal(b)
 
 	// x = theta - bw
-	x := mpc.Sub(alphaEnc, mpc.Mult(b, w))
+	x := mpc.sub(alphaEnc, mpc.mult(b, w))
 
 	// y = a*w
-	y := mpc.Mult(a, w)
-	y = mpc.TruncPR(y, 2*mpc.K, mpc.K/2)
+	y := mpc.mult(a, w)
+	y = mpc.truncPR(y, 2*mpc.K, mpc.K/2)
 
 	for i := 0; i < theta; i++ {
 
 		// y = y * (alpha + x)
-		y = mpc.Mult(y, mpc.Add(alphaEnc, x))
-		y = mpc.TruncPR(y, 2*mpc.K, mpc.K)
+		y = mpc.mult(y, mpc.add(alphaEnc, x))
+		y = mpc.truncPR(y, 2*mpc.K, mpc.K)
 
 		if i+1 < theta {
-			x = mpc.Mult(x, x)
-			x = mpc.TruncPR(x, 2*mpc.K, mpc.K)
+			x = mpc.mult(x, x)
+			x = mpc.truncPR(x, 2*mpc.K, mpc.K)
 		}
 	}
 
@@ -234,55 +254,56 @@ func (mpc *MPC) FPDivision(a, b *party.Share) *party.Share {
 func (mpc *MPC) initReciprocal(b *party.Share) *party.Share {
 
 	// init goldschmidt constant
-	alpha := mpc.CreateShares(mpc.EncodeFixedPoint(big.NewFloat(2.9142), mpc.K))
+	alpha := mpc.createShares(mpc.EncodeFixedPoint(big.NewFloat(2.9142), mpc.K))
 
 	// normalize the denominator
-	u, v := mpc.FPNormalize(b)
+	u, v := mpc.fpNormalize(b)
 
 	// d = alpha - 2u
-	d := mpc.Sub(alpha, mpc.MultC(u, big.NewInt(2)))
+	d := mpc.sub(alpha, mpc.multC(u, big.NewInt(2)))
 
 	// w = d*v
-	w := mpc.Mult(d, v)
+	w := mpc.mult(d, v)
 
 	// return the normalize initial approximation
-	t := mpc.TruncPR(w, 2*mpc.K, mpc.K)
+	t := mpc.truncPR(w, 2*mpc.K, mpc.K)
 
 	return t
 }
 
-// FPDivision return the approximate result of [a/b]
-func (mpc *MPC) FPSqrtReciprocal(a *party.Share) *party.Share {
+func (mpc *MPC) fpSqrtReciprocal(a *party.Share) *party.Share {
+
+	mpc = mpc.scope("FPSqrtReciprocal")
 
 	// init goldschmidt constants
 	theta := int(math.Ceil(math.Log2(float64(mpc.K) / 3.75)))
 
 	// get initial reciprocal  approximation
-	b := mpc.CopyShare(a)
+	b := mpc.copyShare(a)
 	precPow := big.NewInt(0).Exp(big.NewInt(2), big.NewInt(int64(mpc.K/2-mpc.FPPrecBits)), nil)
 
-	b = mpc.MultC(b, precPow)
+	b = mpc.multC(b, precPow)
 	y := mpc.initSquareRoot(a)
-	z := mpc.CopyShare(y)
+	z := mpc.copyShare(y)
 
 	for i := 0; i < theta; i++ {
 
 		// compute y^2
-		y2 := mpc.Mult(y, y)
-		y2 = mpc.TruncPR(y2, 2*mpc.K, mpc.K/2)
+		y2 := mpc.mult(y, y)
+		y2 = mpc.truncPR(y2, 2*mpc.K, mpc.K/2)
 
-		b = mpc.Mult(b, y2)
-		b = mpc.TruncPR(b, 2*mpc.K, mpc.K/2)
+		b = mpc.mult(b, y2)
+		b = mpc.truncPR(b, 2*mpc.K, mpc.K/2)
 
-		three := mpc.CreateShares(mpc.EncodeFixedPoint(big.NewFloat(3.0), mpc.K/2))
+		three := mpc.createShares(mpc.EncodeFixedPoint(big.NewFloat(3.0), mpc.K/2))
 		half := mpc.EncodeFixedPoint(big.NewFloat(0.5), mpc.K/2)
 
-		y = mpc.Sub(three, b)
-		y = mpc.MultC(y, half)
-		y = mpc.TruncPR(y, 2*mpc.K, mpc.K/2)
+		y = mpc.sub(three, b)
+		y = mpc.multC(y, half)
+		y = mpc.truncPR(y, 2*mpc.K, mpc.K/2)
 
-		z = mpc.Mult(z, y)
-		z = mpc.TruncPR(z, 2*mpc.K, mpc.K/2)
+		z = mpc.mult(z, y)
+		z = mpc.truncPR(z, 2*mpc.K, mpc.K/2)
 
 	}
 
@@ -291,72 +312,77 @@ func (mpc *MPC) FPSqrtReciprocal(a *party.Share) *party.Share {
 
 func (mpc *MPC) initSquareRoot(a *party.Share) *party.Share {
 
-	bitsa := mpc.ReverseBits(mpc.BitsDec(a, mpc.K))
-	ybits := mpc.ReverseBits(mpc.BitsPrefixOR(bitsa))
+	bitsa := mpc.ReverseBits(mpc.bitsDec(a, mpc.K))
+	ybits := mpc.ReverseBits(mpc.bitsPrefixOR(bitsa))
 
 	for i := 0; i < mpc.K-1; i++ {
-		ybits[i] = mpc.Sub(ybits[i], ybits[i+1])
+		ybits[i] = mpc.sub(ybits[i], ybits[i+1])
 	}
 
-	v := mpc.CreateShares(big.NewInt(0))
+	v := mpc.createShares(big.NewInt(0))
 
 	aprx := mpc.EncodeFixedPoint(big.NewFloat(1.0), mpc.K/2)
 
 	for i := 0; i < mpc.K; i++ {
-		t := mpc.MultC(ybits[i], aprx)
-		v = mpc.Add(v, t)
+		t := mpc.multC(ybits[i], aprx)
+		v = mpc.add(v, t)
 		aprx = mpc.EncodeFixedPoint(big.NewFloat(1.0/math.Sqrt(math.Pow(2, float64(i-mpc.FPPrecBits+1)))), mpc.K/2)
 	}
 
 	return v
 }
 
-func (mpc *MPC) TruncPR(a *party.Share, k, m int) *party.Share {
+func (mpc *MPC) truncPR(a *party.Share, k, m int) *party.Share {
+
+	require(0 < m && m <= k, "cannot truncate %d bits of a %d bit value", m, k)
+	mpc = mpc.scope("TruncPR")
 
 	// get 2^k-1 + a
-	b := mpc.CreateShares(big.NewInt(0).Exp(big2, big.NewInt(int64(k-1)), nil))
-	z := mpc.Add(b, a)
+	b := mpc.createShares(big.NewInt(0).Exp(big2, big.NewInt(int64(k-1)), nil))
+	z := mpc.add(b, a)
 
 	// 2^m
 	big2m := big.NewInt(0).Exp(big2, big.NewInt(int64(m)), nil)
 	big2mInv := big.NewInt(0).ModInverse(big2m, mpc.P)
 
 	// get solved bits
-	_, r, _ := mpc.SolvedBits(m)
+	_, r, _ := mpc.solvedBits(m)
 
 	exp := big.NewInt(0).Exp(big2, big.NewInt(int64(mpc.S+k-m)), nil)
-	rnd := mpc.RandomShare(exp)
+	rnd := mpc.randomShare(exp)
 
 	// 2^m*rnd + r
-	q := mpc.MultC(rnd, big2m)
-	mask := mpc.Add(q, r)
+	q := mpc.multC(rnd, big2m)
+	mask := mpc.add(q, r)
 
-	e := mpc.Add(z, mask)
-	c := mpc.RevealShare(e)
+	e := mpc.add(z, mask)
+	c := mpc.revealShare(e)
 	c = c.Mod(c, big2m)
 
-	res := mpc.CreateShares(c)
-	res = mpc.Sub(res, r)
-	res = mpc.Sub(a, res)
-	res = mpc.MultC(res, big2mInv)
+	res := mpc.createShares(c)
+	res = mpc.sub(res, r)
+	res = mpc.sub(a, res)
+	res = mpc.multC(res, big2mInv)
 
 	return res
 }
 
-func (mpc *MPC) SignBit(a *party.Share) *party.Share {
+func (mpc *MPC) signBit(a *party.Share) *party.Share {
+
+	mpc = mpc.scope("SignBit")
 	big2K := big.NewInt(0).Exp(big.NewInt(2), big.NewInt(int64(mpc.K-1)), nil)
 
-	shiftShare := mpc.CreateShares(big2K)
-	pos := mpc.Add(a, shiftShare)
-	aBits := mpc.BitsDec(pos, mpc.K)
-	thresholdBits := mpc.BitsBigEndian(big2K, mpc.K)
-	signbit := mpc.BitsLT(aBits, thresholdBits)
+	shiftShare := mpc.createShares(big2K)
+	pos := mpc.add(a, shiftShare)
+	aBits := mpc.bitsDec(pos, mpc.K)
+	thresholdBits := mpc.bitsBigEndian(big2K, mpc.K)
+	signbit := mpc.bitsLT(aBits, thresholdBits)
 	return signbit
 
-	// 	fmt.Println("[DEBUG] SHIFTED: " + mpc.RevealShare(pos).String())
+	// 	fmt.Println("[DEBUG] SHIFTED: " + mpc.revealShare(pos).String())
 	// 	fmt.Println("[DEBUG] BITS: ")
 	// 	for i := len(aBits) - 1; i >= 0; i-- {
-	// 		fmt.Print(mpc.RevealShare(aBits[i]))
+	// 		fmt.Print(mpc.revealShare(aBits[i]))
 	// 	}
 	// 	fmt.Println()
 }
@@ -0,0 +1,105 @@
+package custodes
+
+import (
+	"context"
+	"custodes/party"
+	"sync"
+	"sync/atomic"
+)
+
+// CommStats counts the communication cost of a protocol, including every
+// sub-protocol it invokes. Rounds counts interactive steps; instructions
+// the coordinator sends for local operations (Add, Sub, MultC...) add
+// messages and bytes but no rounds. Rounds of concurrent invocations are summed
+type CommStats struct {
+	Calls    int64
+	Rounds   int64
+	Messages int64
+	Bytes    int64
+	Children map[string]*CommStats `json:",omitempty"`
+
+	parent *CommStats
+	mu     sync.Mutex
+}
+
+func newCommStats() *CommStats {
+	return &CommStats{}
+}
+
+// child returns the counter of the named sub-protocol and counts one call to it
+func (cs *CommStats) child(name string) *CommStats {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	if cs.Children == nil {
+		cs.Children = make(map[string]*CommStats)
+	}
+
+	c, ok := cs.Children[name]
+	if !ok {
+		c = &CommStats{parent: cs}
+		cs.Children[name] = c
+	}
+
+	atomic.AddInt64(&c.Calls, 1)
+	return c
+}
+
+// add charges the cost to this protocol and all the protocols that invoked it
+func (cs *CommStats) add(rounds, messages, bytes int64) {
+	for node := cs; node != nil; node = node.parent {
+		atomic.AddInt64(&node.Rounds, rounds)
+		atomic.AddInt64(&node.Messages, messages)
+		atomic.AddInt64(&node.Bytes, bytes)
+	}
+}
+
+// CommStats returns the communication counted since the last reset
+func (mpc *MPC) CommStats() *CommStats {
+	return mpc.stats
+}
+
+// ResetCommStats clears the communication counters
+func (mpc *MPC) ResetCommStats() {
+	mpc.stats = newCommStats()
+}
+
+// scope returns a copy of mpc that charges communication to the named sub-protocol
+func (mpc *MPC) scope(name string) *MPC {
+	cpy := *mpc
+	cpy.stats = mpc.stats.child(name)
+	return &cpy
+}
+
+// to returns the transport to party i, charging every request and reply
+// to the current protocol and giving up on the party after mpc.Timeout
+func (mpc *MPC) to(i int) party.Transport {
+	t := mpc.Parties[i]
+	stats := mpc.stats
+	timeout := mpc.Timeout
+	return &party.Client{Send: func(ctx context.Context, msg *party.Message) (*party.Result, error) {
+		res, err := dispatchWithin(ctx, t, msg, timeout)
+		stats.add(0, 2, int64(msg.Size()+res.Size()))
+		return res, err
+	}}
+}
+
+// recordRounds charges interactive rounds to the current protocol
+func (mpc *MPC) recordRounds(rounds int) {
+	mpc.stats.add(int64(rounds), 0, 0)
+}
+
+// recordShareMessages charges count share values sent outside of the
+// coordinator's transports, such as peers resharing to each other
+func (mpc *MPC) recordShareMessages(count int) {
+	msg := &party.Message{Share1: &party.Share{}, Value: mpc.P}
+	res := &party.Result{}
+	mpc.stats.add(0, 2*int64(count), int64(count*(msg.Size()+res.Size())))
+}
+
+// peerMessages returns the number of messages sent when every party
+// deals a sharing to every other party
+func (mpc *MPC) peerMessages() int {
+	n := len(mpc.Parties)
+	return n * (n - 1)
+}
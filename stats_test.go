@@ -0,0 +1,110 @@
+package custodes
+
+import (
+	"math/big"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newStatsMPC returns a system of three in-process parties, over links of
+// the given latency
+func newStatsMPC(t *testing.T, latency time.Duration) *MPC {
+	t.Helper()
+
+	mpc, err := NewMPCKeyGen(&MPCKeyGenParams{
+		NumParties:      3,
+		Threshold:       2,
+		KeyBits:         512,
+		MessageBits:     100,
+		SecurityBits:    40,
+		FPPrecisionBits: 30,
+		NetworkLatency:  latency})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return mpc
+}
+
+// rounds returns the rounds charged to the protocol so far. The parties a
+// decryption did not wait for may still be charged as it is read
+func rounds(stats *CommStats) int64 {
+	return atomic.LoadInt64(&stats.Rounds)
+}
+
+// checkCost fails the test unless the protocol was called once and took
+// want rounds, and between min and max messages. A decryption needs
+// the answers of Threshold parties only, and the others may or may not
+// have answered yet, so their messages are not counted for sure
+func checkCost(t *testing.T, name string, stats *CommStats, want, min, max int64) {
+	t.Helper()
+
+	if stats == nil {
+		t.Fatalf("no communication charged to %s", name)
+	}
+	calls := atomic.LoadInt64(&stats.Calls)
+	messages := atomic.LoadInt64(&stats.Messages)
+	bytes := atomic.LoadInt64(&stats.Bytes)
+	if got := rounds(stats); calls != 1 || got != want {
+		t.Fatalf("%s: %d calls and %d rounds, want 1 call and %d rounds", name, calls, got, want)
+	}
+	if messages < min || messages > max || bytes <= 0 {
+		t.Fatalf("%s: %d messages and %d bytes, want %d to %d messages and some bytes", name, messages, bytes, min, max)
+	}
+}
+
+func TestCommStatsRounds(t *testing.T) {
+
+	mpc := newStatsMPC(t, 0)
+	n, threshold := int64(len(mpc.Parties)), int64(mpc.Threshold)
+	a := mpc.Pk.Encrypt(big.NewInt(6))
+	b := mpc.Pk.Encrypt(big.NewInt(7))
+
+	// a product takes one round for the mask, a request to and a reply
+	// from every party, and one for its decryption
+	mpc.ResetCommStats()
+	mpc.MustEMult(a, b)
+	emult := mpc.CommStats().Children["EMult"]
+	checkCost(t, "EMult", emult, 2, 2*n+2*threshold, 4*n)
+	checkCost(t, "RevealInt in EMult", emult.Children["RevealInt"], 1, 2*threshold, 2*n)
+	if total := rounds(mpc.CommStats()); total != rounds(emult) {
+		t.Fatalf("EMult alone took %d rounds in total but %d itself", total, rounds(emult))
+	}
+
+	// a truncation takes two rounds for its random masks and one for the
+	// decryption of the masked value
+	mpc.ResetCommStats()
+	mpc.MustETruncPR(mpc.Pk.Encrypt(big.NewInt(1<<20)), mpc.K, mpc.FPPrecBits)
+	truncpr := mpc.CommStats().Children["ETruncPR"]
+	checkCost(t, "ETruncPR", truncpr, 3, 4*n+2*threshold, 6*n)
+	checkCost(t, "RevealInt in ETruncPR", truncpr.Children["RevealInt"], 1, 2*threshold, 2*n)
+
+	// a fixed point product is both, one after the other
+	mpc.ResetCommStats()
+	mpc.MustEFPMult(a, b)
+	total := mpc.CommStats()
+	if rounds(total) != 5 || rounds(total.Children["EMult"]) != 2 || rounds(total.Children["ETruncPR"]) != 3 {
+		t.Fatalf("EFPMult took %d rounds, want 2 for EMult and 3 for ETruncPR", rounds(total))
+	}
+}
+
+func TestCommStatsLatency(t *testing.T) {
+
+	latency := 20 * time.Millisecond
+	mpc := newStatsMPC(t, latency)
+	a := mpc.Pk.Encrypt(big.NewInt(6))
+	b := mpc.Pk.Encrypt(big.NewInt(7))
+
+	// every round waits for a request to reach the parties and their
+	// replies to come back
+	mpc.ResetCommStats()
+	start := time.Now()
+	mpc.MustEMult(a, b)
+	elapsed := time.Since(start)
+
+	trips := rounds(mpc.CommStats())
+	if min := time.Duration(trips) * 2 * latency; elapsed < min {
+		t.Fatalf("EMult took %v over links of %v, less than %d round trips", elapsed, latency, trips)
+	}
+}
@@ -0,0 +1,266 @@
+package custodes
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"sync"
+
+	"custodes/party"
+
+	"github.com/sachaservan/paillier"
+)
+
+// ProtocolType identifies the protocol a transcript entry records
+type ProtocolType int
+
+const (
+	ETruncPR ProtocolType = iota
+	EMult
+	Decrypt
+	PaillierToShare
+	Reveal // final opening of the statistic of a test
+)
+
+func (p ProtocolType) String() string {
+	switch p {
+	case ETruncPR:
+		return "ETruncPR"
+	case EMult:
+		return "EMult"
+	case Decrypt:
+		return "Decrypt"
+	case PaillierToShare:
+		return "PaillierToShare"
+	case Reveal:
+		return "Reveal"
+	}
+	return fmt.Sprintf("ProtocolType(%d)", int(p))
+}
+
+// MPCTranscriptEntry records one invocation of a protocol. Hash covers
+// the entry and the hash of the entry before it, so changing or dropping
+// an entry breaks every hash that follows. CtAux and Params hold what an
+// auditor needs to recompute CtOut from CtIn and the decryptions that
+// precede the entry; see Auditor. A Reveal entry holds the signed pieces
+// of the share of a statistic, its value in PtOut and its number of
+// fractional bits in Params
+type MPCTranscriptEntry struct {
+	Protocol ProtocolType                     // type of protocol
+	Params   []int                            // public parameters, such as k and m of ETruncPR
+	CtIn     []*paillier.Ciphertext           // inputs to the protocol
+	CtAux    []*paillier.Ciphertext           // joint random masks the protocol used
+	CtOut    []*paillier.Ciphertext           // ciphertext output
+	PtOut    *big.Int                         // plaintext output, nil if nothing was revealed
+	Proofs   []*paillier.PartialDecryptionZKP `json:",omitempty"` // proofs of the partial decryptions combined into PtOut
+	Pieces   []*party.Piece                   `json:",omitempty"` // signed pieces of the share a Reveal opened
+	Hash     []byte
+}
+
+// MPCTranscript is an append-only, hash-chained log of the Paillier
+// protocols an MPC instance ran, of the plaintexts they revealed and of
+// the statistics opened from shares.
+// Set mpc.Transcript to a new transcript to start recording
+type MPCTranscript struct {
+	Entries []*MPCTranscriptEntry
+
+	mu sync.Mutex
+}
+
+// NewMPCTranscript returns an empty transcript
+func NewMPCTranscript() *MPCTranscript {
+	return &MPCTranscript{}
+}
+
+// Head returns the hash of the last entry, which commits to the whole
+// transcript, or the hash of the empty chain
+func (trans *MPCTranscript) Head() []byte {
+	trans.mu.Lock()
+	defer trans.mu.Unlock()
+
+	if len(trans.Entries) == 0 {
+		return chainStart()
+	}
+	return append([]byte(nil), trans.Entries[len(trans.Entries)-1].Hash...)
+}
+
+// Check recomputes the hash chain and returns ErrTranscript, naming the
+// first entry that does not match, if the transcript was tampered with
+func (trans *MPCTranscript) Check() error {
+	trans.mu.Lock()
+	defer trans.mu.Unlock()
+
+	prev := chainStart()
+	for i, entry := range trans.Entries {
+		if entry == nil || !bytes.Equal(entry.digest(prev), entry.Hash) {
+			return fmt.Errorf("%w at entry %d", ErrTranscript, i)
+		}
+		prev = entry.Hash
+	}
+	return nil
+}
+
+// WriteFile writes the transcript to filename as JSON
+func (trans *MPCTranscript) WriteFile(filename string) error {
+	trans.mu.Lock()
+	data, err := json.MarshalIndent(trans, "", "\t")
+	trans.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filename, data, 0644)
+}
+
+// ReadTranscript reads a transcript written by WriteFile and checks its
+// hash chain
+func ReadTranscript(filename string) (*MPCTranscript, error) {
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	trans := NewMPCTranscript()
+	err = json.Unmarshal(data, trans)
+	if err != nil {
+		return nil, err
+	}
+
+	return trans, trans.Check()
+}
+
+// ResetTranscript starts a new transcript if mpc records one
+func (mpc *MPC) ResetTranscript() {
+	if mpc.Transcript != nil {
+		mpc.Transcript = NewMPCTranscript()
+	}
+}
+
+// Append chains entry to the transcript and sets its Hash
+func (trans *MPCTranscript) Append(entry *MPCTranscriptEntry) {
+	trans.mu.Lock()
+	defer trans.mu.Unlock()
+
+	prev := chainStart()
+	if len(trans.Entries) > 0 {
+		prev = trans.Entries[len(trans.Entries)-1].Hash
+	}
+	entry.Hash = entry.digest(prev)
+	trans.Entries = append(trans.Entries, entry)
+}
+
+// chainStart is the hash the first entry chains to
+func chainStart() []byte {
+	h := sha256.Sum256([]byte("custodes transcript"))
+	return h[:]
+}
+
+// digest hashes prev and an unambiguous encoding of the entry: every
+// variable length field is prefixed with its length
+func (entry *MPCTranscriptEntry) digest(prev []byte) []byte {
+
+	h := sha256.New()
+	h.Write(prev)
+	binary.Write(h, binary.BigEndian, int64(entry.Protocol))
+
+	binary.Write(h, binary.BigEndian, int64(len(entry.Params)))
+	for _, p := range entry.Params {
+		binary.Write(h, binary.BigEndian, int64(p))
+	}
+
+	writeInt := func(x *big.Int) {
+		if x == nil {
+			binary.Write(h, binary.BigEndian, int64(-1))
+			return
+		}
+		binary.Write(h, binary.BigEndian, int64(x.Sign()))
+		binary.Write(h, binary.BigEndian, int64(len(x.Bytes())))
+		h.Write(x.Bytes())
+	}
+
+	for _, cts := range [][]*paillier.Ciphertext{entry.CtIn, entry.CtAux, entry.CtOut} {
+		binary.Write(h, binary.BigEndian, int64(len(cts)))
+		for _, ct := range cts {
+			if ct == nil {
+				writeInt(nil)
+			} else {
+				writeInt(ct.C)
+			}
+		}
+	}
+	writeInt(entry.PtOut)
+
+	binary.Write(h, binary.BigEndian, int64(len(entry.Proofs)))
+	for _, proof := range entry.Proofs {
+		if proof == nil {
+			writeInt(nil)
+			continue
+		}
+		binary.Write(h, binary.BigEndian, int64(proof.Id))
+		for _, x := range []*big.Int{proof.Decryption, proof.C, proof.E, proof.Z} {
+			writeInt(x)
+		}
+	}
+
+	binary.Write(h, binary.BigEndian, int64(len(entry.Pieces)))
+	for _, piece := range entry.Pieces {
+		if piece == nil {
+			writeInt(nil)
+			continue
+		}
+		binary.Write(h, binary.BigEndian, int64(piece.Party))
+		binary.Write(h, binary.BigEndian, int64(piece.Share))
+		writeInt(piece.Value)
+		binary.Write(h, binary.BigEndian, int64(len(piece.Signature)))
+		h.Write(piece.Signature)
+	}
+
+	return h.Sum(nil)
+}
+
+// record appends a copy of entry to the transcript of mpc, if it keeps
+// one, since callers may modify the values it refers to. The proofs are
+// stored without the threshold key, which the auditor brings along
+func (mpc *MPC) record(entry *MPCTranscriptEntry) {
+
+	if mpc.Transcript == nil {
+		return
+	}
+
+	cp := func(cts []*paillier.Ciphertext) []*paillier.Ciphertext {
+		if cts == nil {
+			return nil
+		}
+		res := make([]*paillier.Ciphertext, len(cts))
+		for i, ct := range cts {
+			res[i] = &paillier.Ciphertext{C: big.NewInt(0).Set(ct.C)}
+		}
+		return res
+	}
+
+	cpy := &MPCTranscriptEntry{
+		Protocol: entry.Protocol,
+		Params:   append([]int(nil), entry.Params...),
+		CtIn:     cp(entry.CtIn),
+		CtAux:    cp(entry.CtAux),
+		CtOut:    cp(entry.CtOut),
+	}
+	if entry.PtOut != nil {
+		cpy.PtOut = big.NewInt(0).Set(entry.PtOut)
+	}
+	for _, proof := range entry.Proofs {
+		p := *proof
+		p.Key = nil
+		cpy.Proofs = append(cpy.Proofs, &p)
+	}
+	for _, piece := range entry.Pieces {
+		p := *piece
+		cpy.Pieces = append(cpy.Pieces, &p)
+	}
+	mpc.Transcript.Append(cpy)
+}
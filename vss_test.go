@@ -0,0 +1,104 @@
+package custodes
+
+import (
+	"context"
+	"custodes/party"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+// newVSSMPC returns a system of three in-process parties that deal every
+// share verifiably
+func newVSSMPC(t *testing.T) *MPC {
+	t.Helper()
+
+	mpc, err := NewMPCKeyGen(&MPCKeyGenParams{
+		NumParties:      3,
+		Threshold:       2,
+		KeyBits:         512,
+		MessageBits:     100,
+		SecurityBits:    40,
+		FPPrecisionBits: 30,
+		Verify:          true,
+		VSS:             true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return mpc
+}
+
+// confirmAll has every party confirm share id at once, as each waits for
+// the echoes of the others, and returns their errors
+func confirmAll(mpc *MPC, id int) []error {
+	errs := make([]error, len(mpc.Parties))
+	done := make(chan struct{})
+	for i, tr := range mpc.Parties {
+		go func(i int, tr party.Transport) {
+			errs[i] = tr.ConfirmShare(context.Background(), &party.Share{PartyID: i, ID: id})
+			done <- struct{}{}
+		}(i, tr)
+	}
+	for range mpc.Parties {
+		<-done
+	}
+	return errs
+}
+
+func TestVSSHonestDealers(t *testing.T) {
+
+	mpc := newVSSMPC(t)
+
+	a := mpc.MustCreateShares(big.NewInt(6))
+	b := mpc.MustCreateShares(big.NewInt(7))
+	if got := mpc.MustRevealShare(mpc.MustMult(a, b)); got.Cmp(big.NewInt(42)) != 0 {
+		t.Fatalf("product of verifiable shares is %v, want 42", got)
+	}
+}
+
+func TestVSSInconsistentDealer(t *testing.T) {
+
+	mpc := newVSSMPC(t)
+	ctx := context.Background()
+	dealer := mpc.Party
+
+	// the coordinator deals party 2 a share of another polynomial, with
+	// commitments that check out against that share alone
+	id := party.NewShareID()
+	shares, values, proofs, _ := dealer.CreateVerifiableShares(big.NewInt(1), id)
+	_, other, otherProofs, _ := dealer.CreateVerifiableShares(big.NewInt(2), id)
+	values[2], proofs[2] = other[2], otherProofs[2]
+	for i, tr := range mpc.Parties {
+		if err := tr.Store(ctx, shares[i], values[i], proofs[i]); err != nil {
+			t.Fatalf("party %d rejected a share on its own commitments: %v", i, err)
+		}
+	}
+	for i, err := range confirmAll(mpc, id) {
+		if !errors.Is(err, ErrInvalidShare) {
+			t.Fatalf("party %d confirmed an inconsistent dealing: got %v, want ErrInvalidShare", i, err)
+		}
+	}
+	if _, err := mpc.Parties[0].RevealShare(ctx, shares[0]); !errors.Is(err, ErrInvalidShare) {
+		t.Fatalf("reveal of an unconfirmed share: got %v, want ErrInvalidShare", err)
+	}
+
+	// party 2 deals its piece of a reshare the same way
+	id = party.NewShareID()
+	for from, tr := range mpc.Parties {
+		p := tr.(*party.Party)
+		_, values, proofs, _ := p.CreateVerifiableShares(big.NewInt(int64(from)), id)
+		if from == 2 {
+			_, other, otherProofs, _ := p.CreateVerifiableShares(big.NewInt(5), id)
+			values[0], proofs[0] = other[0], otherProofs[0]
+		}
+		if err := p.DistributeReshares(ctx, id, values, proofs); err != nil {
+			t.Fatalf("a piece dealt by party %d was rejected on its own commitments: %v", from, err)
+		}
+	}
+	for i, err := range confirmAll(mpc, id) {
+		if !errors.Is(err, ErrInvalidShare) {
+			t.Fatalf("party %d confirmed an inconsistent reshare: got %v, want ErrInvalidShare", i, err)
+		}
+	}
+}
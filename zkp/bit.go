@@ -0,0 +1,105 @@
+package zkp
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/sachaservan/paillier"
+)
+
+// BitProof shows that a ciphertext encrypts 0 or 1 without revealing
+// which. It is the disjunction of the proofs that c or c/g encrypts zero,
+// where the prover simulates the branch that does not hold
+type BitProof struct {
+	E0, Z0 *big.Int // branch c encrypts 0
+	E1, Z1 *big.Int // branch c encrypts 1
+}
+
+// EncryptBit encrypts bit, which must be 0 or 1, and proves it is a bit
+func EncryptBit(pk *paillier.PublicKey, zc Context, bit *big.Int) (*paillier.Ciphertext, *BitProof, error) {
+
+	ct, r, err := encryptWithNoise(pk, bit)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	proof, err := ProveBit(pk, zc, ct, bit, r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return ct, proof, nil
+}
+
+// ProveBit proves that ct, encrypted with randomness r, encrypts bit
+// and that bit is 0 or 1
+func ProveBit(pk *paillier.PublicKey, zc Context, ct *paillier.Ciphertext, bit, r *big.Int) (*BitProof, error) {
+
+	if bit.Sign() != 0 && bit.Cmp(one) != 0 {
+		return nil, errors.New("not a bit")
+	}
+	b := int(bit.Int64())
+
+	nsq := nsquare(pk)
+	u := bitStatements(pk, ct.C)
+
+	e := make([]*big.Int, 2)
+	z := make([]*big.Int, 2)
+	a := make([]*big.Int, 2)
+
+	// simulate the false branch
+	var err error
+	if e[1-b], err = randomChallenge(); err != nil {
+		return nil, err
+	}
+	if z[1-b], err = randomUnit(pk); err != nil {
+		return nil, err
+	}
+	a[1-b] = commitment(pk, u[1-b], e[1-b], z[1-b])
+
+	// and commit to the true one
+	s, err := randomUnit(pk)
+	if err != nil {
+		return nil, err
+	}
+	a[b] = new(big.Int).Exp(s, pk.N, nsq)
+
+	c := challenge(pk, zc, "bit", ct.C, a[0], a[1])
+	mod := new(big.Int).Lsh(one, challengeBits)
+	e[b] = new(big.Int).Sub(c, e[1-b])
+	e[b].Mod(e[b], mod)
+
+	z[b] = new(big.Int).Exp(r, e[b], pk.N)
+	z[b].Mul(z[b], s)
+	z[b].Mod(z[b], pk.N)
+
+	return &BitProof{E0: e[0], Z0: z[0], E1: e[1], Z1: z[1]}, nil
+}
+
+// Verify checks that ct encrypts 0 or 1
+func (proof *BitProof) Verify(pk *paillier.PublicKey, zc Context, ct *paillier.Ciphertext) bool {
+
+	if proof == nil || ct == nil || !isUnit(ct.C, nsquare(pk)) {
+		return false
+	}
+	if !isChallenge(proof.E0) || !isChallenge(proof.E1) || !isUnit(proof.Z0, pk.N) || !isUnit(proof.Z1, pk.N) {
+		return false
+	}
+
+	u := bitStatements(pk, ct.C)
+	a0 := commitment(pk, u[0], proof.E0, proof.Z0)
+	a1 := commitment(pk, u[1], proof.E1, proof.Z1)
+
+	sum := new(big.Int).Add(proof.E0, proof.E1)
+	sum.Mod(sum, new(big.Int).Lsh(one, challengeBits))
+	return challenge(pk, zc, "bit", ct.C, a0, a1).Cmp(sum) == 0
+}
+
+// bitStatements returns c and c/g, one of which is an N-th power if c
+// encrypts a bit
+func bitStatements(pk *paillier.PublicKey, c *big.Int) []*big.Int {
+	nsq := nsquare(pk)
+	// g^-1 = 1 - N mod N^2
+	u1 := new(big.Int).Mul(c, gPow(pk, big.NewInt(-1)))
+	return []*big.Int{c, u1.Mod(u1, nsq)}
+}
@@ -0,0 +1,131 @@
+package zkp
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/sachaservan/paillier"
+)
+
+// IntervalProof shows that a ciphertext encrypts a value v in [lo, hi],
+// reading plaintexts above N/2 as negative. It holds two range proofs of
+// IntervalBits(lo, hi) bits, on encryptions of v-lo and hi-v that anyone
+// derives from the ciphertext, showing that both lie in [0, 2^bits). Their
+// sum is hi-lo mod N, and since it is also below 2^(bits+1) it is hi-lo,
+// so neither can wrap around
+type IntervalProof struct {
+	Above *RangeProof // v-lo is in [0, 2^bits)
+	Below *RangeProof // hi-v is in [0, 2^bits)
+}
+
+// IntervalBits returns the number of bits of the range proofs of an
+// interval proof for [lo, hi]
+func IntervalBits(lo, hi *big.Int) int {
+	bits := new(big.Int).Sub(hi, lo).BitLen()
+	if bits < 1 {
+		return 1
+	}
+	return bits
+}
+
+// EncryptInInterval encrypts m, which must lie in [lo, hi] once read as a
+// signed value mod N, and proves that it does
+func EncryptInInterval(pk *paillier.PublicKey, zc Context, m, lo, hi *big.Int) (*paillier.Ciphertext, *IntervalProof, error) {
+
+	ct, r, err := encryptWithNoise(pk, m)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	proof, err := ProveInterval(pk, zc, ct, m, r, lo, hi)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return ct, proof, nil
+}
+
+// ProveInterval proves that ct, encrypted with randomness r, encrypts m
+// and that m lies in [lo, hi]
+func ProveInterval(pk *paillier.PublicKey, zc Context, ct *paillier.Ciphertext, m, r, lo, hi *big.Int) (*IntervalProof, error) {
+
+	if !intervalFits(pk, lo, hi) {
+		return nil, errors.New("interval does not fit the plaintext space")
+	}
+
+	// read m as a signed value
+	v := new(big.Int).Mod(m, pk.N)
+	if v.Cmp(new(big.Int).Rsh(pk.N, 1)) > 0 {
+		v.Sub(v, pk.N)
+	}
+	if v.Cmp(lo) < 0 || v.Cmp(hi) > 0 {
+		return nil, errors.New("value out of range")
+	}
+
+	bits := IntervalBits(lo, hi)
+	off := offset(bits)
+
+	above, err := ProveRange(pk, zc, shiftAbove(pk, ct, lo, bits), new(big.Int).Sub(new(big.Int).Sub(v, lo), off), r, bits)
+	if err != nil {
+		return nil, err
+	}
+
+	below, ok := shiftBelow(pk, ct, hi, bits)
+	if !ok {
+		return nil, errors.New("ciphertext is not invertible")
+	}
+	rInv := new(big.Int).ModInverse(r, pk.N)
+	if rInv == nil {
+		return nil, errors.New("randomness is not invertible")
+	}
+	belowProof, err := ProveRange(pk, zc, below, new(big.Int).Sub(new(big.Int).Sub(hi, v), off), rInv, bits)
+	if err != nil {
+		return nil, err
+	}
+
+	return &IntervalProof{Above: above, Below: belowProof}, nil
+}
+
+// Verify checks that ct encrypts a value in [lo, hi]
+func (proof *IntervalProof) Verify(pk *paillier.PublicKey, zc Context, ct *paillier.Ciphertext, lo, hi *big.Int) bool {
+
+	if proof == nil || ct == nil || !intervalFits(pk, lo, hi) {
+		return false
+	}
+
+	bits := IntervalBits(lo, hi)
+	below, ok := shiftBelow(pk, ct, hi, bits)
+	if !ok {
+		return false
+	}
+
+	return proof.Above.Verify(pk, zc, shiftAbove(pk, ct, lo, bits), bits) && proof.Below.Verify(pk, zc, below, bits)
+}
+
+// intervalFits reports whether lo <= hi and the range proofs of the
+// interval fit the plaintext space with room for their sum
+func intervalFits(pk *paillier.PublicKey, lo, hi *big.Int) bool {
+	return lo != nil && hi != nil && lo.Cmp(hi) <= 0 && IntervalBits(lo, hi)+1 < pk.N.BitLen()-1
+}
+
+// shiftAbove returns ct * g^-(lo+2^(bits-1)), which encrypts v-lo shifted
+// into the range of a range proof, with the randomness of ct
+func shiftAbove(pk *paillier.PublicKey, ct *paillier.Ciphertext, lo *big.Int, bits int) *paillier.Ciphertext {
+	nsq := nsquare(pk)
+	shift := new(big.Int).Add(lo, offset(bits))
+	u := new(big.Int).Mul(ct.C, gPow(pk, shift.Neg(shift)))
+	return &paillier.Ciphertext{C: u.Mod(u, nsq)}
+}
+
+// shiftBelow returns g^(hi-2^(bits-1)) / ct, which encrypts hi-v shifted
+// into the range of a range proof, with the inverse of the randomness of
+// ct. It fails if ct is not invertible mod N^2
+func shiftBelow(pk *paillier.PublicKey, ct *paillier.Ciphertext, hi *big.Int, bits int) (*paillier.Ciphertext, bool) {
+	nsq := nsquare(pk)
+	inv := new(big.Int).ModInverse(ct.C, nsq)
+	if inv == nil {
+		return nil, false
+	}
+	u := inv.Mul(inv, gPow(pk, new(big.Int).Sub(hi, offset(bits))))
+	return &paillier.Ciphertext{C: u.Mod(u, nsq)}, true
+}
@@ -0,0 +1,115 @@
+package zkp
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/sachaservan/paillier"
+)
+
+// OneHotProof shows that a row of ciphertexts encrypts a one-hot vector
+// in fixed point, that is, every entry encrypts 0 or 2^precBits and
+// exactly one of them is not 0. Scaling each ciphertext by 2^-precBits
+// mod N leaves an encryption of a bit, and the scaled entries must add up to 1
+type OneHotProof struct {
+	Bits []*BitProof // the scaled entries are bits
+	Sum  *ZeroProof  // the scaled entries add up to 1
+}
+
+// EncryptOneHot encrypts every entry of row, which must be a one-hot
+// vector of 0s and 1s, in fixed point with precBits of precision, and
+// proves that the row is one-hot
+func EncryptOneHot(pk *paillier.PublicKey, zc Context, row []int64, precBits int) ([]*paillier.Ciphertext, *OneHotProof, error) {
+
+	hot := 0
+	for _, v := range row {
+		if v != 0 && v != 1 {
+			return nil, nil, errors.New("not a one-hot vector")
+		}
+		hot += int(v)
+	}
+	if hot != 1 {
+		return nil, nil, errors.New("not a one-hot vector")
+	}
+
+	inv := unscale(pk, precBits)
+	scale := new(big.Int).Lsh(one, uint(precBits))
+
+	cts := make([]*paillier.Ciphertext, len(row))
+	proof := &OneHotProof{Bits: make([]*BitProof, len(row))}
+	root := big.NewInt(1)
+
+	for j, v := range row {
+		bit := big.NewInt(v)
+
+		ct, r, err := encryptWithNoise(pk, new(big.Int).Mul(bit, scale))
+		if err != nil {
+			return nil, nil, err
+		}
+		cts[j] = ct
+
+		// ct^inv encrypts the bit with randomness r^inv
+		rs := new(big.Int).Exp(r, inv, pk.N)
+		if proof.Bits[j], err = ProveBit(pk, zc, scaleDown(pk, ct, inv), bit, rs); err != nil {
+			return nil, nil, err
+		}
+
+		root.Mul(root, rs)
+		root.Mod(root, pk.N)
+	}
+
+	var err error
+	proof.Sum, err = proveZero(pk, zc, proof.difference(pk, cts, inv), root)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return cts, proof, nil
+}
+
+// Verify checks that cts encrypts a one-hot vector in fixed point with
+// precBits of precision
+func (proof *OneHotProof) Verify(pk *paillier.PublicKey, zc Context, cts []*paillier.Ciphertext, precBits int) bool {
+
+	if proof == nil || len(cts) == 0 || len(proof.Bits) != len(cts) || precBits < 0 || precBits >= pk.N.BitLen()-1 {
+		return false
+	}
+
+	inv := unscale(pk, precBits)
+	nsq := nsquare(pk)
+	for j, ct := range cts {
+		if ct == nil || !isUnit(ct.C, nsq) {
+			return false
+		}
+		if !proof.Bits[j].Verify(pk, zc, scaleDown(pk, ct, inv)) {
+			return false
+		}
+	}
+
+	return proof.Sum.verify(pk, zc, proof.difference(pk, cts, inv))
+}
+
+// difference returns prod cts[j]^inv / g, which encrypts zero if the
+// scaled entries add up to 1
+func (proof *OneHotProof) difference(pk *paillier.PublicKey, cts []*paillier.Ciphertext, inv *big.Int) *big.Int {
+
+	nsq := nsquare(pk)
+
+	u := gPow(pk, big.NewInt(-1))
+	for _, ct := range cts {
+		u.Mul(u, scaleDown(pk, ct, inv).C)
+		u.Mod(u, nsq)
+	}
+	return u
+}
+
+// unscale returns 2^-precBits mod N
+func unscale(pk *paillier.PublicKey, precBits int) *big.Int {
+	scale := new(big.Int).Lsh(one, uint(precBits))
+	return scale.ModInverse(scale, pk.N)
+}
+
+// scaleDown returns ct^inv, which encrypts the plaintext of ct times inv
+func scaleDown(pk *paillier.PublicKey, ct *paillier.Ciphertext, inv *big.Int) *paillier.Ciphertext {
+	return &paillier.Ciphertext{C: new(big.Int).Exp(ct.C, inv, nsquare(pk))}
+}
@@ -0,0 +1,133 @@
+package zkp
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/sachaservan/paillier"
+)
+
+// RangeProof shows that a ciphertext encrypts a value in
+// [-2^(Bits-1), 2^(Bits-1)), reading plaintexts above N/2 as negative.
+// The prover encrypts the bits of the value shifted by 2^(Bits-1), proves
+// each of them is a bit, and proves that they add up to the shifted value
+type RangeProof struct {
+	Bits      int
+	BitCts    []*paillier.Ciphertext // encryptions of the bits, least significant first
+	BitProofs []*BitProof
+	Sum       *ZeroProof // the bits recombine to the shifted value
+}
+
+// EncryptInRange encrypts m, which must lie in [-2^(bits-1), 2^(bits-1))
+// once read as a signed value mod N, and proves that it does
+func EncryptInRange(pk *paillier.PublicKey, zc Context, m *big.Int, bits int) (*paillier.Ciphertext, *RangeProof, error) {
+
+	ct, r, err := encryptWithNoise(pk, m)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	proof, err := ProveRange(pk, zc, ct, m, r, bits)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return ct, proof, nil
+}
+
+// ProveRange proves that ct, encrypted with randomness r, encrypts m and
+// that m lies in [-2^(bits-1), 2^(bits-1))
+func ProveRange(pk *paillier.PublicKey, zc Context, ct *paillier.Ciphertext, m, r *big.Int, bits int) (*RangeProof, error) {
+
+	if bits < 1 || bits >= pk.N.BitLen()-1 {
+		return nil, errors.New("range does not fit the plaintext space")
+	}
+
+	// read m as a signed value and shift it into [0, 2^bits)
+	v := new(big.Int).Mod(m, pk.N)
+	if v.Cmp(new(big.Int).Rsh(pk.N, 1)) > 0 {
+		v.Sub(v, pk.N)
+	}
+	v.Add(v, offset(bits))
+	if v.Sign() < 0 || v.BitLen() > bits {
+		return nil, errors.New("value out of range")
+	}
+
+	proof := &RangeProof{
+		Bits:      bits,
+		BitCts:    make([]*paillier.Ciphertext, bits),
+		BitProofs: make([]*BitProof, bits),
+	}
+
+	// the sum proof needs the root r / prod r_j^(2^j) mod N
+	root := new(big.Int).Set(r)
+	for j := 0; j < bits; j++ {
+		bit := big.NewInt(int64(v.Bit(j)))
+
+		ct, rj, err := encryptWithNoise(pk, bit)
+		if err != nil {
+			return nil, err
+		}
+		proof.BitCts[j] = ct
+		if proof.BitProofs[j], err = ProveBit(pk, zc, ct, bit, rj); err != nil {
+			return nil, err
+		}
+
+		rj.Exp(rj, new(big.Int).Lsh(one, uint(j)), pk.N)
+		root.Mul(root, rj.ModInverse(rj, pk.N))
+		root.Mod(root, pk.N)
+	}
+
+	var err error
+	proof.Sum, err = proveZero(pk, zc, proof.difference(pk, ct), root)
+	if err != nil {
+		return nil, err
+	}
+
+	return proof, nil
+}
+
+// Verify checks that ct encrypts a value in [-2^(bits-1), 2^(bits-1))
+func (proof *RangeProof) Verify(pk *paillier.PublicKey, zc Context, ct *paillier.Ciphertext, bits int) bool {
+
+	if proof == nil || ct == nil || proof.Bits != bits || bits < 1 || bits >= pk.N.BitLen()-1 {
+		return false
+	}
+	if len(proof.BitCts) != bits || len(proof.BitProofs) != bits {
+		return false
+	}
+	if !isUnit(ct.C, nsquare(pk)) {
+		return false
+	}
+
+	for j := 0; j < bits; j++ {
+		if !proof.BitProofs[j].Verify(pk, zc, proof.BitCts[j]) {
+			return false
+		}
+	}
+
+	return proof.Sum.verify(pk, zc, proof.difference(pk, ct))
+}
+
+// difference returns ct * g^(2^(Bits-1)) / prod BitCts[j]^(2^j), which
+// encrypts zero if the bits add up to the shifted value of ct
+func (proof *RangeProof) difference(pk *paillier.PublicKey, ct *paillier.Ciphertext) *big.Int {
+
+	nsq := nsquare(pk)
+
+	recombined := big.NewInt(1)
+	for j := len(proof.BitCts) - 1; j >= 0; j-- {
+		recombined.Mul(recombined, recombined)
+		recombined.Mul(recombined, proof.BitCts[j].C)
+		recombined.Mod(recombined, nsq)
+	}
+
+	u := new(big.Int).Mul(ct.C, gPow(pk, offset(proof.Bits)))
+	u.Mul(u, recombined.ModInverse(recombined, nsq))
+	return u.Mod(u, nsq)
+}
+
+// offset returns 2^(bits-1), the shift that maps the range onto [0, 2^bits)
+func offset(bits int) *big.Int {
+	return new(big.Int).Lsh(one, uint(bits-1))
+}
@@ -0,0 +1,163 @@
+// Package zkp implements non-interactive zero-knowledge proofs about
+// Paillier ciphertexts, made non-interactive with the Fiat-Shamir heuristic.
+// They let data owners and parties show that what they encrypted is well
+// formed without revealing it. All proofs assume the generator g = N+1.
+// A proof is made in a Context, which names the prover and the run of the
+// protocol it belongs to, and checks out only in the same context
+package zkp
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"math/big"
+
+	"github.com/sachaservan/paillier"
+)
+
+// challengeBits is the length of the Fiat-Shamir challenges. It must stay
+// well below the bit length of the prime factors of N
+const challengeBits = 128
+
+var one = big.NewInt(1)
+
+// Context binds a proof to its prover and to one run of a protocol, so
+// that nobody can pass off the proof of another prover as their own or
+// replay a proof from an earlier run
+type Context struct {
+	Prover  int    // id of the prover
+	Session []byte // fresh for every run of the protocol
+}
+
+// ZeroProof shows that a ciphertext encrypts zero, that is, it is an N-th
+// power mod N^2 and the prover knows its root
+type ZeroProof struct {
+	E *big.Int // challenge
+	Z *big.Int // response
+}
+
+// challenge hashes the public key, the context, a tag naming the proof and
+// the given values into a challengeBits long challenge
+func challenge(pk *paillier.PublicKey, zc Context, tag string, values ...*big.Int) *big.Int {
+
+	h := sha256.New()
+	write := func(b []byte) {
+		var n [8]byte
+		binary.BigEndian.PutUint64(n[:], uint64(len(b)))
+		h.Write(n[:])
+		h.Write(b)
+	}
+
+	write([]byte(tag))
+	write(pk.N.Bytes())
+	var prover [8]byte
+	binary.BigEndian.PutUint64(prover[:], uint64(int64(zc.Prover)))
+	write(prover[:])
+	write(zc.Session)
+	for _, v := range values {
+		write(v.Bytes())
+	}
+
+	return new(big.Int).SetBytes(h.Sum(nil)[:challengeBits/8])
+}
+
+// nsquare returns N^2
+func nsquare(pk *paillier.PublicKey) *big.Int {
+	return new(big.Int).Mul(pk.N, pk.N)
+}
+
+// randomUnit returns a uniformly random element of Z_N^*
+func randomUnit(pk *paillier.PublicKey) (*big.Int, error) {
+	for {
+		r, err := rand.Int(rand.Reader, pk.N)
+		if err != nil {
+			return nil, err
+		}
+		if r.Sign() > 0 && new(big.Int).GCD(nil, nil, r, pk.N).Cmp(one) == 0 {
+			return r, nil
+		}
+	}
+}
+
+// randomChallenge returns a uniformly random challenge
+func randomChallenge() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(one, challengeBits))
+}
+
+// isUnit reports whether 0 < x < m and x is invertible mod m
+func isUnit(x, m *big.Int) bool {
+	return x != nil && x.Sign() > 0 && x.Cmp(m) < 0 && new(big.Int).GCD(nil, nil, x, m).Cmp(one) == 0
+}
+
+// isChallenge reports whether e is a valid challenge
+func isChallenge(e *big.Int) bool {
+	return e != nil && e.Sign() >= 0 && e.BitLen() <= challengeBits
+}
+
+// encryptWithNoise encrypts m and returns the ciphertext along with the
+// randomness used, which the proofs need
+func encryptWithNoise(pk *paillier.PublicKey, m *big.Int) (*paillier.Ciphertext, *big.Int, error) {
+	r, err := randomUnit(pk)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pk.EncryptWithR(m, r), r, nil
+}
+
+// gPow returns g^m mod N^2 for g = N+1, which is 1 + mN
+func gPow(pk *paillier.PublicKey, m *big.Int) *big.Int {
+	nsq := nsquare(pk)
+	gm := new(big.Int).Mod(m, pk.N)
+	gm.Mul(gm, pk.N)
+	gm.Add(gm, one)
+	return gm.Mod(gm, nsq)
+}
+
+// commitment recomputes the prover's first message z^N * u^-e mod N^2
+// from the challenge e and response z of a proof that u is an N-th power
+func commitment(pk *paillier.PublicKey, u, e, z *big.Int) *big.Int {
+	nsq := nsquare(pk)
+	a := new(big.Int).Exp(z, pk.N, nsq)
+	ue := new(big.Int).Exp(u, e, nsq)
+	a.Mul(a, ue.ModInverse(ue, nsq))
+	return a.Mod(a, nsq)
+}
+
+// proveZero proves that u = root^N mod N^2
+func proveZero(pk *paillier.PublicKey, zc Context, u, root *big.Int) (*ZeroProof, error) {
+
+	s, err := randomUnit(pk)
+	if err != nil {
+		return nil, err
+	}
+
+	a := new(big.Int).Exp(s, pk.N, nsquare(pk))
+	e := challenge(pk, zc, "zero", u, a)
+
+	z := new(big.Int).Exp(root, e, pk.N)
+	z.Mul(z, s)
+	z.Mod(z, pk.N)
+
+	return &ZeroProof{E: e, Z: z}, nil
+}
+
+// verify checks the proof that u is an N-th power mod N^2
+func (proof *ZeroProof) verify(pk *paillier.PublicKey, zc Context, u *big.Int) bool {
+
+	if proof == nil || !isChallenge(proof.E) || !isUnit(proof.Z, pk.N) || !isUnit(u, nsquare(pk)) {
+		return false
+	}
+
+	a := commitment(pk, u, proof.E, proof.Z)
+	return challenge(pk, zc, "zero", u, a).Cmp(proof.E) == 0
+}
+
+// ProveZero proves that ct, encrypted with randomness r, encrypts zero
+func ProveZero(pk *paillier.PublicKey, zc Context, ct *paillier.Ciphertext, r *big.Int) (*ZeroProof, error) {
+	return proveZero(pk, zc, ct.C, r)
+}
+
+// Verify checks that ct encrypts zero
+func (proof *ZeroProof) Verify(pk *paillier.PublicKey, zc Context, ct *paillier.Ciphertext) bool {
+	return ct != nil && proof.verify(pk, zc, ct.C)
+}
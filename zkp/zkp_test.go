@@ -0,0 +1,250 @@
+package zkp
+
+import (
+	"crypto/rand"
+	"math/big"
+	"testing"
+
+	"github.com/sachaservan/paillier"
+)
+
+// newTestKey returns a small Paillier key, which is all the proofs need
+func newTestKey(t *testing.T) *paillier.PublicKey {
+	t.Helper()
+
+	tkh, err := paillier.GetThresholdKeyGenerator(512, 1, 1, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tpks, err := tkh.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &tpks[0].PublicKey
+}
+
+var testContext = Context{Prover: 1, Session: []byte("session")}
+
+func TestZeroProof(t *testing.T) {
+
+	pk := newTestKey(t)
+
+	ct, r, err := encryptWithNoise(pk, big.NewInt(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof, err := ProveZero(pk, testContext, ct, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !proof.Verify(pk, testContext, ct) {
+		t.Fatal("honest proof of zero rejected")
+	}
+
+	tampered := &ZeroProof{E: new(big.Int).Add(proof.E, one), Z: proof.Z}
+	if tampered.Verify(pk, testContext, ct) {
+		t.Fatal("proof of zero with a tampered challenge accepted")
+	}
+	tampered = &ZeroProof{E: proof.E, Z: new(big.Int).Mod(new(big.Int).Lsh(proof.Z, 1), pk.N)}
+	if tampered.Verify(pk, testContext, ct) {
+		t.Fatal("proof of zero with a tampered response accepted")
+	}
+
+	// the proof does not carry over to another encryption of zero
+	other := pk.Encrypt(big.NewInt(0))
+	if proof.Verify(pk, testContext, other) {
+		t.Fatal("proof of zero replayed under another ciphertext accepted")
+	}
+
+	nonzero, r, err := encryptWithNoise(pk, big.NewInt(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof, err = ProveZero(pk, testContext, nonzero, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proof.Verify(pk, testContext, nonzero) {
+		t.Fatal("proof that an encryption of 1 encrypts zero accepted")
+	}
+}
+
+func TestBitProof(t *testing.T) {
+
+	pk := newTestKey(t)
+
+	for _, b := range []int64{0, 1} {
+		ct, proof, err := EncryptBit(pk, testContext, big.NewInt(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !proof.Verify(pk, testContext, ct) {
+			t.Fatalf("honest proof of bit %d rejected", b)
+		}
+
+		tampered := *proof
+		tampered.E0 = new(big.Int).Add(proof.E0, one)
+		if tampered.Verify(pk, testContext, ct) {
+			t.Fatalf("proof of bit %d with a tampered challenge accepted", b)
+		}
+		tampered = *proof
+		tampered.Z1 = new(big.Int).Mod(new(big.Int).Lsh(proof.Z1, 1), pk.N)
+		if tampered.Verify(pk, testContext, ct) {
+			t.Fatalf("proof of bit %d with a tampered response accepted", b)
+		}
+
+		if proof.Verify(pk, testContext, pk.Encrypt(big.NewInt(b))) {
+			t.Fatalf("proof of bit %d replayed under another ciphertext accepted", b)
+		}
+	}
+
+	if _, _, err := EncryptBit(pk, testContext, big.NewInt(2)); err == nil {
+		t.Fatal("proved that 2 is a bit")
+	}
+}
+
+func TestProofContext(t *testing.T) {
+
+	pk := newTestKey(t)
+
+	ct, proof, err := EncryptBit(pk, testContext, big.NewInt(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a proof checks out for its own prover and session only
+	for _, zc := range []Context{
+		{Prover: 2, Session: testContext.Session},
+		{Prover: testContext.Prover, Session: []byte("another session")},
+		{Prover: testContext.Prover},
+	} {
+		if proof.Verify(pk, zc, ct) {
+			t.Fatalf("proof made in %+v accepted in %+v", testContext, zc)
+		}
+	}
+}
+
+func TestRangeProof(t *testing.T) {
+
+	pk := newTestKey(t)
+
+	for _, m := range []int64{-8, -1, 0, 7} {
+		ct, proof, err := EncryptInRange(pk, testContext, big.NewInt(m), 4)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !proof.Verify(pk, testContext, ct, 4) {
+			t.Fatalf("honest range proof of %d rejected", m)
+		}
+		if proof.Verify(pk, testContext, ct, 5) {
+			t.Fatalf("range proof of %d accepted for another number of bits", m)
+		}
+		if proof.Verify(pk, testContext, pk.Encrypt(big.NewInt(m)), 4) {
+			t.Fatalf("range proof of %d replayed under another ciphertext accepted", m)
+		}
+	}
+
+	for _, m := range []int64{-9, 8} {
+		if _, _, err := EncryptInRange(pk, testContext, big.NewInt(m), 4); err == nil {
+			t.Fatalf("proved that %d lies in [-8, 8)", m)
+		}
+	}
+}
+
+func TestIntervalProof(t *testing.T) {
+
+	pk := newTestKey(t)
+	lo, hi := big.NewInt(-100), big.NewInt(100)
+
+	for _, m := range []int64{-100, -3, 0, 42, 100} {
+		ct, proof, err := EncryptInInterval(pk, testContext, big.NewInt(m), lo, hi)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !proof.Verify(pk, testContext, ct, lo, hi) {
+			t.Fatalf("honest interval proof of %d rejected", m)
+		}
+		if proof.Verify(pk, testContext, pk.Encrypt(big.NewInt(m)), lo, hi) {
+			t.Fatalf("interval proof of %d replayed under another ciphertext accepted", m)
+		}
+	}
+
+	for _, m := range []int64{-101, 101} {
+		if _, _, err := EncryptInInterval(pk, testContext, big.NewInt(m), lo, hi); err == nil {
+			t.Fatalf("proved that %d lies in [%v, %v]", m, lo, hi)
+		}
+	}
+
+	// a proof that 10 lies in [0, 100] does not show that it lies in
+	// [20, 100], although both range proofs have as many bits
+	ct, proof, err := EncryptInInterval(pk, testContext, big.NewInt(10), big.NewInt(0), big.NewInt(100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proof.Verify(pk, testContext, ct, big.NewInt(20), big.NewInt(100)) {
+		t.Fatal("interval proof accepted for a value outside the interval")
+	}
+
+	tampered := *proof.Above
+	tampered.Sum = &ZeroProof{E: new(big.Int).Add(proof.Above.Sum.E, one), Z: proof.Above.Sum.Z}
+	if (&IntervalProof{Above: &tampered, Below: proof.Below}).Verify(pk, testContext, ct, big.NewInt(0), big.NewInt(100)) {
+		t.Fatal("interval proof with a tampered challenge accepted")
+	}
+}
+
+func TestOneHotProof(t *testing.T) {
+
+	pk := newTestKey(t)
+	const precBits = 30
+
+	cts, proof, err := EncryptOneHot(pk, testContext, []int64{0, 1, 0}, precBits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !proof.Verify(pk, testContext, cts, precBits) {
+		t.Fatal("honest one-hot proof rejected")
+	}
+
+	// the proof of one row does not carry over to another encryption of it
+	others, _, err := EncryptOneHot(pk, testContext, []int64{0, 1, 0}, precBits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proof.Verify(pk, testContext, others, precBits) {
+		t.Fatal("one-hot proof replayed under other ciphertexts accepted")
+	}
+
+	for _, row := range [][]int64{{1, 1, 0}, {0, 0, 0}, {0, 2, 0}} {
+		if _, _, err := EncryptOneHot(pk, testContext, row, precBits); err == nil {
+			t.Fatalf("proved that %v is one-hot", row)
+		}
+	}
+
+	// a row of bits that add up to 2 fails the sum, even with valid
+	// proofs for its bits
+	inv := unscale(pk, precBits)
+	scale := new(big.Int).Lsh(one, precBits)
+	forged := &OneHotProof{Bits: make([]*BitProof, 2)}
+	forgedCts := make([]*paillier.Ciphertext, 2)
+	root := big.NewInt(1)
+	for j := range forgedCts {
+		ct, r, err := encryptWithNoise(pk, scale)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rs := new(big.Int).Exp(r, inv, pk.N)
+		if forged.Bits[j], err = ProveBit(pk, testContext, scaleDown(pk, ct, inv), one, rs); err != nil {
+			t.Fatal(err)
+		}
+		forgedCts[j] = ct
+		root.Mul(root, rs)
+		root.Mod(root, pk.N)
+	}
+	if forged.Sum, err = proveZero(pk, testContext, forged.difference(pk, forgedCts, inv), root); err != nil {
+		t.Fatal(err)
+	}
+	if forged.Verify(pk, testContext, forgedCts, precBits) {
+		t.Fatal("one-hot proof accepted for a row of two ones")
+	}
+}